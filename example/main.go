@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"embed"
 	"errors"
@@ -10,6 +11,9 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/dv1x3r/w2go/w2"
 	"github.com/dv1x3r/w2go/w2db"
@@ -115,8 +119,94 @@ func main() {
 	v1.HandleFunc("GET /status/grid/records", getStatusGridRecords)
 	v1.HandleFunc("POST /status/grid/reorder", postStatusGridReorder)
 
-	v1.HandleFunc("GET /sql", w2explorer.SQLiteSchemaHTTPHandler(db))
-	v1.HandleFunc("POST /sql", w2explorer.SQLExecHTTPHandler(db))
+	queries := w2explorer.NewQueryStore(db, w2explorer.QueryStoreOptions{MaxHistory: 1000})
+	if err := queries.Init(context.Background()); err != nil {
+		log.Fatalln(err)
+	}
+
+	// one monitor limits and lists the queries of every data source
+	monitor := w2explorer.NewQueryMonitor(w2explorer.QueryMonitorOptions{MaxConcurrent: 8, MaxConcurrentPerUser: 2})
+
+	// every execution is logged and stored in the app database with secrets redacted
+	audit := w2explorer.NewAuditLog(w2explorer.AuditLogOptions{DB: db})
+	if err := audit.Init(context.Background()); err != nil {
+		log.Fatalln(err)
+	}
+
+	explorerOpts := w2explorer.SQLExecOptions{
+		ReadOnly:           *readonly,
+		ConfirmDestructive: true,
+		MaxRows:            10000,
+		Editable:           true,
+		History:            queries,
+		Timeout:            30 * time.Second,
+		Monitor:            monitor,
+		Audit:              audit,
+		Authorize: func(ctx context.Context, req w2explorer.SQLExecRequest) error {
+			query := strings.ToLower(req.Query)
+			if strings.Contains(query, "w2explorer_audit") {
+				return errors.New("the audit table is not available in the explorer")
+			}
+			// a backup of the app database would contain the audit table and the query history
+			if strings.HasPrefix(query, "vacuum") && w2explorer.SourceFromContext(ctx) == "app" {
+				return errors.New("the app database is not backed up from the explorer")
+			}
+			return nil
+		},
+	}
+
+	// the explorer serves the app database and two scratch files, and the
+	// archive can be attached to the scratch database for cross-database queries
+	sources := w2explorer.NewSourceRegistry()
+	defer sources.Close()
+
+	if _, err := sources.Register("app", db, w2explorer.DataSourceOptions{Exec: explorerOpts}); err != nil {
+		log.Fatalln(err)
+	}
+
+	scratchPath := filepath.Join(os.TempDir(), "w2go-scratch.db")
+	scratchOpts := w2explorer.DataSourceOptions{
+		Exec:        explorerOpts,
+		AllowAttach: !*readonly,
+		Backup:      w2explorer.BackupOptions{AllowRestore: true},
+	}
+	if _, err := sources.Open("scratch", "sqlite", scratchPath, scratchOpts); err != nil {
+		log.Fatalln(err)
+	}
+
+	archiveOpts := explorerOpts
+	archiveOpts.ReadOnly = true
+	archivePath := filepath.Join(os.TempDir(), "w2go-archive.db")
+	if _, err := sources.Open("archive", "sqlite", archivePath, w2explorer.DataSourceOptions{Exec: archiveOpts}); err != nil {
+		log.Fatalln(err)
+	}
+
+	v1.HandleFunc("GET /sql", sources.SchemaHTTPHandler())
+	v1.HandleFunc("GET /sql/erd", sources.ERDiagramHTTPHandler())
+	v1.HandleFunc("POST /sql", sources.SQLExecHTTPHandler())
+	v1.HandleFunc("POST /sql/blob", sources.SQLBlobHTTPHandler())
+	v1.HandleFunc("POST /sql/export", sources.SQLExportHTTPHandler())
+	v1.HandleFunc("POST /sql/explain", sources.SQLExplainHTTPHandler())
+	v1.HandleFunc("POST /sql/params", w2explorer.SQLParamsHTTPHandler())
+	v1.HandleFunc("POST /sql/format", w2explorer.SQLFormatHTTPHandler())
+	v1.HandleFunc("POST /sql/lint", sources.SQLLintHTTPHandler())
+	v1.HandleFunc("POST /sql/save", sources.SQLSaveHTTPHandler())
+	v1.HandleFunc("GET /sql/table", sources.TableHTTPHandler())
+	v1.HandleFunc("GET /sql/table/records", sources.RecordsHTTPHandler())
+	v1.HandleFunc("POST /sql/table/insert", sources.InsertHTTPHandler())
+	v1.HandleFunc("POST /sql/table/remove", sources.RemoveHTTPHandler())
+	v1.HandleFunc("GET /sql/backup", sources.BackupHTTPHandler())
+	v1.HandleFunc("POST /sql/restore", sources.RestoreHTTPHandler())
+	v1.HandleFunc("GET /sql/sources", sources.SourcesHTTPHandler())
+	v1.HandleFunc("POST /sql/sources/attach", sources.AttachHTTPHandler())
+	v1.HandleFunc("POST /sql/sources/detach", sources.DetachHTTPHandler())
+	v1.HandleFunc("GET /sql/running", monitor.RunningHTTPHandler())
+	v1.HandleFunc("POST /sql/running/kill", monitor.KillHTTPHandler())
+	v1.HandleFunc("GET /sql/history", queries.HistoryHTTPHandler())
+	v1.HandleFunc("GET /sql/saved", queries.SavedQueriesHTTPHandler())
+	v1.HandleFunc("GET /sql/saved/record", queries.SavedQueryHTTPHandler())
+	v1.HandleFunc("POST /sql/saved", queries.SaveQueryHTTPHandler())
+	v1.HandleFunc("POST /sql/saved/remove", queries.RemoveSavedQueriesHTTPHandler())
 
 	router.Handle("/api/v1/", protect(cors(http.StripPrefix("/api/v1", v1))))
 
@@ -292,41 +382,31 @@ func postTodoForm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.RecID == 0 {
-		recID, err := w2db.Insert(db, w2db.InsertOptions{
-			Into: "todo",
-			Values: map[string]any{
-				"name":        req.Record.Name,
-				"description": req.Record.Description.NotNull(),
-				"quantity":    req.Record.Quantity,
-				"status_id":   req.Record.Status.ID,
-			},
-		})
-		if err != nil {
-			res := w2.NewErrorResponse(err.Error())
-			res.Write(w, http.StatusInternalServerError)
-			return
-		}
-		req.RecID = recID
-	} else {
-		_, err := w2db.Update(db, w2db.UpdateOptions{
-			Update: "todo",
-			Values: map[string]any{
-				"name":        req.Record.Name,
-				"description": req.Record.Description.NotNull(),
-				"quantity":    req.Record.Quantity,
-				"status_id":   req.Record.Status.ID,
-			},
-			Where: map[string]any{"id": req.RecID},
-		})
-		if err != nil {
-			res := w2.NewErrorResponse(err.Error())
-			res.Write(w, http.StatusInternalServerError)
-			return
-		}
+	recID, err := w2db.SaveForm(db, req, w2db.SaveFormOptions[Todo]{
+		Table:   "todo",
+		IDField: "id",
+		Values: func(record Todo) map[string]any {
+			return map[string]any{
+				"name":        record.Name,
+				"description": record.Description.NotNull(),
+				"quantity":    record.Quantity,
+				"status_id":   record.Status.ID,
+			}
+		},
+		CheckExists: true,
+	})
+
+	if errors.Is(err, sql.ErrNoRows) {
+		res := w2.NewErrorResponse(http.StatusText(http.StatusNotFound))
+		res.Write(w, http.StatusNotFound)
+		return
+	} else if err != nil {
+		res := w2.NewErrorResponse(err.Error())
+		res.Write(w, http.StatusInternalServerError)
+		return
 	}
 
-	res := w2.NewSaveFormResponse(req.RecID)
+	res := w2.NewSaveFormResponse(recID)
 	res.Write(w)
 }
 
@@ -408,7 +488,8 @@ func postStatusGridReorder(w http.ResponseWriter, r *http.Request) {
 
 func protect(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if *readonly && r.Method != "GET" {
+		// the SQL explorer enforces read-only mode itself
+		if *readonly && r.Method != "GET" && !strings.HasPrefix(r.URL.Path, "/api/v1/sql") {
 			res := w2.NewErrorResponse("running in readonly mode")
 			res.Write(w, http.StatusForbidden)
 			return
@@ -2,6 +2,7 @@ package w2
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 )
 
@@ -12,6 +13,23 @@ type Dropdown struct {
 
 	// Text is the option label shown to the user.
 	Text Field[string] `json:"text"`
+
+	// Icon is an optional icon CSS class shown next to the label.
+	Icon string `json:"icon,omitempty"`
+
+	// Description is optional help text. w2ui menus show it as the item tooltip.
+	Description string `json:"tooltip,omitempty"`
+}
+
+// NewDropdownHeader returns a w2ui menu divider labeled text.
+//
+// w2ui renders items whose text starts with "--" as dividers, which makes them
+// group headers in grouped option lists. Headers have a null ID and cannot be selected.
+func NewDropdownHeader(text string) Dropdown {
+	return Dropdown{
+		ID:   Field[int]{Provided: true},
+		Text: NewField("--" + text),
+	}
 }
 
 // UnmarshalJSON accepts the common w2ui dropdown encodings.
@@ -44,13 +62,114 @@ func (d *Dropdown) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
-	if err := json.Unmarshal(raw["text"], &d.Text); err != nil {
+	// w2ui enum items may omit the label
+	if text, ok := raw["text"]; ok {
+		if err := json.Unmarshal(text, &d.Text); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DropdownList is a multi-select dropdown value, such as a w2ui enum field.
+//
+// Like Field, it tracks whether the client sent the value, so an omitted
+// inline-edit column can be told apart from a cleared selection.
+type DropdownList struct {
+	// Items are the selected options.
+	Items []Dropdown
+
+	// Provided is true when the value was present in JSON or read from SQL.
+	Provided bool
+}
+
+// NewDropdownList returns a provided DropdownList containing items.
+func NewDropdownList(items ...Dropdown) DropdownList {
+	return DropdownList{Items: items, Provided: true}
+}
+
+// IDs returns the IDs of all valid selected items.
+func (l DropdownList) IDs() []int {
+	ids := make([]int, 0, len(l.Items))
+	for _, item := range l.Items {
+		if item.ID.Valid {
+			ids = append(ids, item.ID.V)
+		}
+	}
+	return ids
+}
+
+// IsProvided implements the w2db.Providable interface.
+func (l DropdownList) IsProvided() bool {
+	return l.Provided
+}
+
+// IsZero reports whether the list was not provided.
+//
+// It lets encoding/json omit non-provided lists when the struct tag uses ",omitzero".
+func (l DropdownList) IsZero() bool {
+	return !l.Provided
+}
+
+// UnmarshalJSON accepts the common w2ui enum encodings.
+//
+// w2ui may submit the selection as an array of integer IDs, as an array of
+// objects containing id and text, as null, or as an empty string. null and an
+// empty string produce a provided but empty list.
+func (l *DropdownList) UnmarshalJSON(data []byte) error {
+	l.Provided = true
+	l.Items = nil
+
+	if string(data) == "null" || string(data) == `""` {
+		return nil
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
 		return err
 	}
 
+	items := make([]Dropdown, 0, len(raw))
+	for _, value := range raw {
+		var item Dropdown
+		if err := json.Unmarshal(value, &item); err != nil {
+			return err
+		}
+		if item.ID.Valid {
+			items = append(items, item)
+		}
+	}
+
+	l.Items = items
 	return nil
 }
 
+// MarshalJSON encodes the list as an array of id/text objects.
+func (l DropdownList) MarshalJSON() ([]byte, error) {
+	if l.Items == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(l.Items)
+}
+
+// Scan implements sql.Scanner for JSON arrays, such as the output of SQLite
+// json_group_array, and marks the list as provided. SQL NULL scans to an empty list.
+func (l *DropdownList) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		l.Provided = true
+		l.Items = nil
+		return nil
+	case string:
+		return l.UnmarshalJSON([]byte(v))
+	case []byte:
+		return l.UnmarshalJSON(v)
+	default:
+		return fmt.Errorf("w2.DropdownList: cannot scan %T", value)
+	}
+}
+
 // GetDropdownRequest is the request payload w2ui sends when loading dropdown
 // options.
 type GetDropdownRequest struct {
@@ -59,6 +178,25 @@ type GetDropdownRequest struct {
 
 	// Search is the user's search text.
 	Search string `json:"search"`
+
+	// Offset is the zero-based option offset used to page long lists. w2ui
+	// does not send it, so it is set through postData or by custom clients.
+	Offset int `json:"offset"`
+
+	// Extra contains request keys outside the dropdown protocol, such as custom postData.
+	Extra Extra `json:"-"`
+}
+
+// UnmarshalJSON decodes a dropdown request and keeps unknown keys in Extra.
+func (req *GetDropdownRequest) UnmarshalJSON(data []byte) error {
+	type alias GetDropdownRequest
+	if err := json.Unmarshal(data, (*alias)(req)); err != nil {
+		return err
+	}
+
+	extra, err := splitExtra(data, "max", "search", "offset")
+	req.Extra = extra
+	return err
 }
 
 // ParseGetDropdownRequest decodes the JSON value from a dropdown "request"
@@ -100,3 +238,18 @@ func (res GetDropdownResponse[T]) Write(w http.ResponseWriter) error {
 	_, err = w.Write(data)
 	return err
 }
+
+// Facet is a distinct column value with its row count.
+//
+// It uses the dropdown id/text shape, so facets can be returned in a
+// GetDropdownResponse and selected by w2grid enum filters.
+type Facet struct {
+	// ID is the distinct value as stored in the database.
+	ID any `json:"id"`
+
+	// Text is the value formatted for display.
+	Text string `json:"text"`
+
+	// Count is the number of rows with this value under the current search.
+	Count int `json:"count"`
+}
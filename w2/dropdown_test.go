@@ -0,0 +1,105 @@
+package w2_test
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/dv1x3r/w2go/w2"
+)
+
+func TestDropdownList(t *testing.T) {
+	t.Run("JSONRoundTrip", func(t *testing.T) {
+		tests := []struct {
+			InputJSON    string
+			ExpectedIDs  []int
+			ExpectedJSON string
+		}{
+			{
+				InputJSON:    `[1, 2, 3]`,
+				ExpectedIDs:  []int{1, 2, 3},
+				ExpectedJSON: `[{"id":1,"text":null},{"id":2,"text":null},{"id":3,"text":null}]`,
+			},
+			{
+				InputJSON:    `[{"id": 1, "text": "red"}, {"id": 2, "text": "green"}, {"id": 3}]`,
+				ExpectedIDs:  []int{1, 2, 3},
+				ExpectedJSON: `[{"id":1,"text":"red"},{"id":2,"text":"green"},{"id":3,"text":null}]`,
+			},
+			{
+				InputJSON:    `null`,
+				ExpectedIDs:  []int{},
+				ExpectedJSON: `[]`,
+			},
+			{
+				InputJSON:    `""`,
+				ExpectedIDs:  []int{},
+				ExpectedJSON: `[]`,
+			},
+			{
+				InputJSON:    `[]`,
+				ExpectedIDs:  []int{},
+				ExpectedJSON: `[]`,
+			},
+		}
+
+		for _, test := range tests {
+			var value w2.DropdownList
+			err := json.Unmarshal([]byte(test.InputJSON), &value)
+			if err != nil {
+				t.Errorf("❌ Unmarshal error for input %s: %v", test.InputJSON, err)
+				continue
+			}
+
+			if !value.Provided {
+				t.Errorf("❌ Expected provided list for input %s", test.InputJSON)
+				continue
+			}
+
+			if ids := value.IDs(); !slices.Equal(ids, test.ExpectedIDs) {
+				t.Errorf("❌ Unexpected IDs for input %s:\n  got:  %v\n  want: %v", test.InputJSON, ids, test.ExpectedIDs)
+				continue
+			}
+
+			output, err := json.Marshal(value)
+			if err != nil {
+				t.Errorf("❌ Marshal error for input %s: %v", test.InputJSON, err)
+				continue
+			}
+
+			if string(output) != test.ExpectedJSON {
+				t.Errorf("❌ Unexpected output JSON:\n  got:  %s\n  want: %s", output, test.ExpectedJSON)
+			}
+		}
+	})
+
+	t.Run("Omitted", func(t *testing.T) {
+		var value struct {
+			Tags w2.DropdownList `json:"tags,omitzero"`
+		}
+
+		if err := json.Unmarshal([]byte(`{}`), &value); err != nil {
+			t.Fatalf("❌ Unmarshal error: %v", err)
+		}
+
+		if value.Tags.Provided {
+			t.Errorf("❌ Expected omitted list to be not provided")
+		}
+	})
+}
+
+func TestDropdownHeader(t *testing.T) {
+	records := []w2.Dropdown{
+		w2.NewDropdownHeader("Fruit"),
+		{ID: w2.NewField(1), Text: w2.NewField("Apple"), Icon: "fa fa-apple", Description: "Red"},
+	}
+
+	output, err := json.Marshal(records)
+	if err != nil {
+		t.Fatalf("❌ Marshal error: %v", err)
+	}
+
+	expected := `[{"id":null,"text":"--Fruit"},{"id":1,"text":"Apple","icon":"fa fa-apple","tooltip":"Red"}]`
+	if string(output) != expected {
+		t.Errorf("❌ Unexpected output JSON:\n  got:  %s\n  want: %s", output, expected)
+	}
+}
@@ -0,0 +1,148 @@
+package w2
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+)
+
+// Extra holds request keys that are not part of the w2ui protocol.
+//
+// w2ui merges a widget's postData into the request it sends, so custom values
+// such as a parent record ID arrive next to the standard keys. Values are
+// decoded from JSON, so numbers are float64 and arrays are []any. Use the typed
+// accessors instead of asserting types directly.
+type Extra map[string]any
+
+// Has reports whether key was sent.
+func (e Extra) Has(key string) bool {
+	_, ok := e[key]
+	return ok
+}
+
+// String returns the value of key as a string.
+//
+// Strings are returned as-is and numbers and booleans are formatted. The
+// second result is false when key is missing or has another type.
+func (e Extra) String(key string) (string, bool) {
+	switch v := e[key].(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	default:
+		return "", false
+	}
+}
+
+// Int returns the value of key as an int.
+//
+// Whole JSON numbers and numeric strings are accepted. The second result is
+// false when key is missing or cannot be converted.
+func (e Extra) Int(key string) (int, bool) {
+	switch v := e[key].(type) {
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, false
+		}
+		return int(v), true
+	case string:
+		n, err := strconv.Atoi(v)
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// Float returns the value of key as a float64.
+//
+// JSON numbers and numeric strings are accepted. The second result is false
+// when key is missing or cannot be converted.
+func (e Extra) Float(key string) (float64, bool) {
+	switch v := e[key].(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// Bool returns the value of key as a bool.
+//
+// JSON booleans, the numbers 0 and 1, and strings accepted by
+// strconv.ParseBool are converted. The second result is false when key is
+// missing or cannot be converted.
+func (e Extra) Bool(key string) (bool, bool) {
+	switch v := e[key].(type) {
+	case bool:
+		return v, true
+	case float64:
+		if v == 0 || v == 1 {
+			return v == 1, true
+		}
+		return false, false
+	case string:
+		b, err := strconv.ParseBool(v)
+		return b, err == nil
+	default:
+		return false, false
+	}
+}
+
+// Ints returns the value of key as a slice of ints.
+//
+// A JSON array of whole numbers or dropdown objects with an integer id is
+// converted, as is a single number. The second result is false when key is
+// missing or any element cannot be converted.
+func (e Extra) Ints(key string) ([]int, bool) {
+	value, ok := e[key]
+	if !ok {
+		return nil, false
+	}
+
+	values, ok := value.([]any)
+	if !ok {
+		n, ok := Extra{key: value}.Int(key)
+		if !ok {
+			return nil, false
+		}
+		return []int{n}, true
+	}
+
+	ints := make([]int, 0, len(values))
+	for _, v := range values {
+		if item, ok := v.(map[string]any); ok {
+			v = item["id"]
+		}
+		n, ok := Extra{key: v}.Int(key)
+		if !ok {
+			return nil, false
+		}
+		ints = append(ints, n)
+	}
+
+	return ints, true
+}
+
+// splitExtra decodes data into a map and removes the keys listed in known.
+func splitExtra(data []byte, known ...string) (Extra, error) {
+	var extra Extra
+	if err := json.Unmarshal(data, &extra); err != nil {
+		return nil, err
+	}
+
+	for _, key := range known {
+		delete(extra, key)
+	}
+
+	if len(extra) == 0 {
+		return nil, nil
+	}
+
+	return extra, nil
+}
@@ -0,0 +1,80 @@
+package w2_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/dv1x3r/w2go/w2"
+)
+
+func TestExtra(t *testing.T) {
+	t.Run("ParseGetGridRequest", func(t *testing.T) {
+		req, err := w2.ParseGetGridRequest(`{"limit": 100, "offset": 0, "projectId": 7, "archived": "false", "tags": [1, {"id": 2, "text": "b"}]}`)
+		if err != nil {
+			t.Fatalf("❌ Parse error: %v", err)
+		}
+
+		if req.Limit != 100 {
+			t.Errorf("❌ Unexpected limit: got %d, want 100", req.Limit)
+		}
+
+		if req.Extra.Has("limit") {
+			t.Errorf("❌ Protocol key limit should not be kept in Extra")
+		}
+
+		if id, ok := req.Extra.Int("projectId"); !ok || id != 7 {
+			t.Errorf("❌ Unexpected projectId: got %d, %v", id, ok)
+		}
+
+		if archived, ok := req.Extra.Bool("archived"); !ok || archived {
+			t.Errorf("❌ Unexpected archived: got %v, %v", archived, ok)
+		}
+
+		if tags, ok := req.Extra.Ints("tags"); !ok || !slices.Equal(tags, []int{1, 2}) {
+			t.Errorf("❌ Unexpected tags: got %v, %v", tags, ok)
+		}
+	})
+
+	t.Run("ParseGetDropdownRequest", func(t *testing.T) {
+		req, err := w2.ParseGetDropdownRequest(`{"max": 50, "search": "ri", "country": "12"}`)
+		if err != nil {
+			t.Fatalf("❌ Parse error: %v", err)
+		}
+
+		if req.Max != 50 || req.Search != "ri" {
+			t.Errorf("❌ Unexpected request: %+v", req)
+		}
+
+		if country, ok := req.Extra.Int("country"); !ok || country != 12 {
+			t.Errorf("❌ Unexpected country: got %d, %v", country, ok)
+		}
+
+		if country, ok := req.Extra.String("country"); !ok || country != "12" {
+			t.Errorf("❌ Unexpected country string: got %q, %v", country, ok)
+		}
+
+		if _, ok := req.Extra.Int("missing"); ok {
+			t.Errorf("❌ Missing key should not convert")
+		}
+	})
+
+	t.Run("Conversions", func(t *testing.T) {
+		extra := w2.Extra{"ratio": 1.5, "flag": float64(1), "name": "x"}
+
+		if _, ok := extra.Int("ratio"); ok {
+			t.Errorf("❌ Fractional number should not convert to int")
+		}
+
+		if f, ok := extra.Float("ratio"); !ok || f != 1.5 {
+			t.Errorf("❌ Unexpected ratio: got %v, %v", f, ok)
+		}
+
+		if b, ok := extra.Bool("flag"); !ok || !b {
+			t.Errorf("❌ Unexpected flag: got %v, %v", b, ok)
+		}
+
+		if _, ok := extra.Ints("name"); ok {
+			t.Errorf("❌ Non-numeric string should not convert to ints")
+		}
+	})
+}
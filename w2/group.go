@@ -0,0 +1,71 @@
+package w2
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// GroupCountField is the record field that holds GridGroup.Count. The w2
+// prefix keeps it from colliding with a grouped or subtotal field named "count".
+const GroupCountField = "w2count"
+
+// GridGroup is a w2grid group header row with subtotal values and nested detail rows.
+//
+// The row is encoded as a flat record so the group key and subtotals render in
+// the grid's own columns. Detail rows are written to w2ui.children, which
+// w2grid displays as expandable tree rows.
+type GridGroup[T any] struct {
+	// RecIDField is the w2grid recid field name. It defaults to "recid".
+	RecIDField string
+
+	// Field is the client-side field name that holds the group key.
+	Field string
+
+	// Key is the group value shared by all detail rows.
+	Key any
+
+	// Count is the number of detail rows in the group. It is encoded as
+	// GroupCountField.
+	Count int
+
+	// Subtotals contains aggregate values keyed by client-side field names.
+	Subtotals map[string]any
+
+	// Children contains the detail rows. A nil slice is encoded as an empty
+	// children list, so w2grid still shows the expand control for lazy loading.
+	Children []T
+}
+
+// RecID returns the synthetic record ID used for the group header row.
+//
+// The "group:" prefix keeps group rows from colliding with detail row IDs.
+func (g GridGroup[T]) RecID() string {
+	return fmt.Sprintf("group:%v", g.Key)
+}
+
+// MarshalJSON encodes the group as a w2grid record with w2ui.children.
+func (g GridGroup[T]) MarshalJSON() ([]byte, error) {
+	recIDField := g.RecIDField
+	if recIDField == "" {
+		recIDField = "recid"
+	}
+
+	children := g.Children
+	if children == nil {
+		children = []T{}
+	}
+
+	record := make(map[string]any, len(g.Subtotals)+4)
+	for field, value := range g.Subtotals {
+		record[field] = value
+	}
+
+	record[recIDField] = g.RecID()
+	record[GroupCountField] = g.Count
+	if g.Field != "" {
+		record[g.Field] = g.Key
+	}
+
+	record["w2ui"] = map[string]any{"children": children}
+	return json.Marshal(record)
+}
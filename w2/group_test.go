@@ -0,0 +1,68 @@
+package w2_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dv1x3r/w2go/w2"
+)
+
+func TestGridGroup(t *testing.T) {
+	t.Run("MarshalJSON", func(t *testing.T) {
+		tests := []struct {
+			Input        w2.GridGroup[Todo]
+			ExpectedJSON string
+		}{
+			{
+				Input: w2.GridGroup[Todo]{
+					RecIDField: "id",
+					Field:      "status",
+					Key:        1,
+					Count:      1,
+					Subtotals:  map[string]any{"quantity": 2},
+					Children: []Todo{
+						{ID: 1, Name: w2.NewField("Buy milk"), Quantity: w2.NewField(2)},
+					},
+				},
+				ExpectedJSON: `{"id":"group:1","quantity":2,"status":1,"w2count":1,"w2ui":{"children":[{"id":1,"name":"Buy milk","quantity":2}]}}`,
+			},
+			{
+				Input: w2.GridGroup[Todo]{
+					Field: "status",
+					Key:   "pending",
+					Count: 3,
+				},
+				ExpectedJSON: `{"recid":"group:pending","status":"pending","w2count":3,"w2ui":{"children":[]}}`,
+			},
+			{
+				Input: w2.GridGroup[Todo]{
+					Field:     "count",
+					Key:       5,
+					Count:     2,
+					Subtotals: map[string]any{"quantity": 7},
+				},
+				ExpectedJSON: `{"count":5,"quantity":7,"recid":"group:5","w2count":2,"w2ui":{"children":[]}}`,
+			},
+			{
+				Input: w2.GridGroup[Todo]{
+					Field: "status",
+					Key:   nil,
+					Count: 4,
+				},
+				ExpectedJSON: `{"recid":"group:\u003cnil\u003e","status":null,"w2count":4,"w2ui":{"children":[]}}`,
+			},
+		}
+
+		for _, test := range tests {
+			output, err := json.Marshal(test.Input)
+			if err != nil {
+				t.Errorf("❌ Marshal error for struct %+v: %v", test.Input, err)
+				continue
+			}
+
+			if string(output) != test.ExpectedJSON {
+				t.Errorf("❌ Unexpected output JSON:\n  got:  %s\n  want: %s", output, test.ExpectedJSON)
+			}
+		}
+	})
+}
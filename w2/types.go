@@ -2,8 +2,10 @@ package w2
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 )
 
 // Status is the w2ui response status string.
@@ -65,6 +67,21 @@ type GetGridRequest struct {
 
 	// Sort contains the requested sort columns.
 	Sort []GridSort `json:"sort"`
+
+	// Extra contains request keys outside the w2grid protocol, such as custom postData.
+	Extra Extra `json:"-"`
+}
+
+// UnmarshalJSON decodes a w2grid request and keeps unknown keys in Extra.
+func (req *GetGridRequest) UnmarshalJSON(data []byte) error {
+	type alias GetGridRequest
+	if err := json.Unmarshal(data, (*alias)(req)); err != nil {
+		return err
+	}
+
+	extra, err := splitExtra(data, "limit", "offset", "searchLogic", "search", "sort")
+	req.Extra = extra
+	return err
 }
 
 // GridSearch describes one w2grid search/filter rule.
@@ -235,6 +252,59 @@ func ParseSaveFormRequest[T any](body io.Reader) (SaveFormRequest[T], error) {
 	return req, json.NewDecoder(body).Decode(&req)
 }
 
+// UnmarshalJSON decodes a w2form save request.
+//
+// New records may be sent with recid set to null, an empty string, or a
+// numeric string, so all of these are accepted. null and "" decode to zero.
+func (req *SaveFormRequest[T]) UnmarshalJSON(data []byte) error {
+	type alias SaveFormRequest[T]
+	v := struct {
+		*alias
+		RecID json.RawMessage `json:"recid"`
+	}{alias: (*alias)(req)}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	req.RecID = 0
+	if len(v.RecID) == 0 || string(v.RecID) == "null" || string(v.RecID) == `""` {
+		return nil
+	}
+
+	if err := json.Unmarshal(v.RecID, &req.RecID); err == nil {
+		return nil
+	}
+
+	var value string
+	if err := json.Unmarshal(v.RecID, &value); err != nil {
+		return err
+	}
+
+	recID, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("w2.SaveFormRequest: cannot parse recid %q: %w", value, err)
+	}
+
+	req.RecID = recID
+	return nil
+}
+
+// ChildChanges is the set of edits made to a grid embedded in a form.
+//
+// It is typically a field of the form record, for example the order lines of
+// an order form. Added rows carry client-side temporary IDs.
+type ChildChanges[T any] struct {
+	// Added contains rows created on the client.
+	Added []T `json:"added"`
+
+	// Changed contains existing rows edited on the client.
+	Changed []T `json:"changed"`
+
+	// Removed contains the IDs of existing rows deleted on the client.
+	Removed []int `json:"removed"`
+}
+
 // SaveFormResponse is the JSON response expected by w2form after saving.
 type SaveFormResponse struct {
 	// Status is set to StatusSuccess by NewSaveFormResponse.
@@ -242,6 +312,9 @@ type SaveFormResponse struct {
 
 	// RecID is the saved record ID. For inserts, set it to the new ID.
 	RecID int `json:"recid,omitempty"`
+
+	// ChildIDs maps client-side temporary IDs of inserted child rows to their new IDs.
+	ChildIDs map[string]int `json:"childIDs,omitempty"`
 }
 
 // NewSaveFormResponse returns a successful form-save response.
@@ -252,6 +325,16 @@ func NewSaveFormResponse(recID int) SaveFormResponse {
 	}
 }
 
+// NewSaveFormResponseWithChildIDs returns a successful form-save response with
+// the IDs assigned to inserted child rows.
+func NewSaveFormResponseWithChildIDs(recID int, childIDs map[string]int) SaveFormResponse {
+	return SaveFormResponse{
+		Status:   StatusSuccess,
+		RecID:    recID,
+		ChildIDs: childIDs,
+	}
+}
+
 // Write sends the form-save response as application/json.
 func (res SaveFormResponse) Write(w http.ResponseWriter) error {
 	data, err := json.Marshal(res)
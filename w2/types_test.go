@@ -0,0 +1,63 @@
+package w2_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dv1x3r/w2go/w2"
+)
+
+func TestSaveFormRequest(t *testing.T) {
+	t.Run("UnmarshalRecID", func(t *testing.T) {
+		tests := []struct {
+			InputJSON string
+			Expected  w2.SaveFormRequest[Todo]
+		}{
+			{
+				InputJSON: `{"action": "save", "name": "todoForm", "recid": 7, "record": {"id": 7, "name": "Buy milk"}}`,
+				Expected: w2.SaveFormRequest[Todo]{
+					Action: "save",
+					Name:   "todoForm",
+					RecID:  7,
+					Record: Todo{ID: 7, Name: w2.NewField("Buy milk")},
+				},
+			},
+			{
+				InputJSON: `{"recid": "12", "record": {"id": 12}}`,
+				Expected:  w2.SaveFormRequest[Todo]{RecID: 12, Record: Todo{ID: 12}},
+			},
+			{
+				InputJSON: `{"recid": "", "record": {"name": "New"}}`,
+				Expected:  w2.SaveFormRequest[Todo]{Record: Todo{Name: w2.NewField("New")}},
+			},
+			{
+				InputJSON: `{"recid": null, "record": {}}`,
+				Expected:  w2.SaveFormRequest[Todo]{},
+			},
+			{
+				InputJSON: `{"record": {}}`,
+				Expected:  w2.SaveFormRequest[Todo]{},
+			},
+		}
+
+		for _, test := range tests {
+			var req w2.SaveFormRequest[Todo]
+			err := json.Unmarshal([]byte(test.InputJSON), &req)
+			if err != nil {
+				t.Errorf("❌ Unmarshal error for input %s: %v", test.InputJSON, err)
+				continue
+			}
+
+			if req != test.Expected {
+				t.Errorf("❌ Unexpected struct for input %s:\n  got:  %+v\n  want: %+v", test.InputJSON, req, test.Expected)
+			}
+		}
+	})
+
+	t.Run("UnmarshalInvalidRecID", func(t *testing.T) {
+		var req w2.SaveFormRequest[Todo]
+		if err := json.Unmarshal([]byte(`{"recid": "abc"}`), &req); err == nil {
+			t.Errorf("❌ Expected error for non-numeric recid, got %+v", req)
+		}
+	})
+}
@@ -2,12 +2,14 @@ package w2db
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"log/slog"
 	"time"
 
 	"github.com/dv1x3r/w2go/w2"
+	"github.com/dv1x3r/w2go/w2sql"
 	"github.com/huandu/go-sqlbuilder"
 )
 
@@ -25,6 +27,41 @@ type GetDropdownOptions struct {
 	// OrderByField is the trusted SQL expression used to order options.
 	OrderByField string
 
+	// SearchFields lists additional trusted SQL expressions matched against
+	// req.Search. An option matches when TextField or any of them contains the text.
+	SearchFields []string
+
+	// Unaccent is an optional trusted SQL function name wrapped around the
+	// searched expressions and the search text for accent-insensitive matching,
+	// such as "unaccent" on PostgreSQL. SQLite has no built-in equivalent, so
+	// the application must register the function with its driver.
+	Unaccent string
+
+	// RankPrefix lists options whose TextField starts with req.Search before
+	// options that only contain it.
+	RankPrefix bool
+
+	// IconField is an optional trusted SQL expression returned as each option icon.
+	IconField string
+
+	// DescriptionField is an optional trusted SQL expression returned as each
+	// option description.
+	DescriptionField string
+
+	// GroupField is an optional trusted SQL expression used to group options.
+	// Options are ordered by it first, and a header item from
+	// w2.NewDropdownHeader is inserted whenever the group changes.
+	GroupField string
+
+	// Selected lists option IDs that are always returned on the first page,
+	// even when they do not match req.Search, so the current value of a field
+	// can be shown. Build and Params still apply to them.
+	Selected []int
+
+	// Params maps w2.Extra request keys to trusted SQL expressions or
+	// predicates. See w2sql.Params.
+	Params map[string]string
+
 	// Build customizes the SELECT query, for example by adding joins or fixed filters.
 	Build func(sb *sqlbuilder.SelectBuilder)
 
@@ -43,7 +80,13 @@ func GetDropdown(db QueryExecer, req w2.GetDropdownRequest, opts GetDropdownOpti
 // GetDropdownContext loads dropdown options and filters them by req.Search.
 //
 // The response records use w2.Dropdown, with ID and Text scanned as w2.Field
-// values so nullable database values round-trip correctly.
+// values so nullable database values round-trip correctly. Options in
+// opts.Selected are listed first, then options are ordered by opts.GroupField,
+// by prefix rank when opts.RankPrefix is set, and by opts.OrderByField.
+// req.Max and req.Offset page through the ordered options, and req.Offset is
+// ignored when req.Max is negative. Group headers are
+// not counted by req.Max, and a header is repeated when a group continues on
+// the next page or also contains a selected option.
 func GetDropdownContext(ctx context.Context, db QueryExecer, req w2.GetDropdownRequest, opts GetDropdownOptions) (w2.GetDropdownResponse[w2.Dropdown], error) {
 	if opts.From == "" {
 		return w2.GetDropdownResponse[w2.Dropdown]{}, errors.New("opts.From is required")
@@ -71,22 +114,51 @@ func GetDropdownContext(ctx context.Context, db QueryExecer, req w2.GetDropdownR
 		logger = defaultLogger
 	}
 
-	builder := sqlbuilder.Select(opts.IDField, opts.TextField).From(opts.From)
+	columns := []string{opts.IDField, opts.TextField}
+	for _, field := range []string{opts.IconField, opts.DescriptionField, opts.GroupField} {
+		if field != "" {
+			columns = append(columns, field)
+		}
+	}
+
+	builder := sqlbuilder.Select(columns...).From(opts.From)
 	if opts.Build != nil {
 		opts.Build(builder)
 	}
 
+	w2sql.Params(builder, req.Extra, opts.Params)
+
 	if req.Search != "" {
-		if flavor == sqlbuilder.SQLite {
-			expr := sqlbuilder.Buildf("INSTR(LOWER(%v), LOWER(%v)) > 0", sqlbuilder.Raw(opts.TextField), req.Search)
-			builder.Where(builder.Var(expr))
-		} else {
-			builder.Where(builder.Like(opts.TextField, "%"+req.Search+"%"))
+		fields := append([]string{opts.TextField}, opts.SearchFields...)
+		conds := make([]string, 0, len(fields)+1)
+		for _, field := range fields {
+			conds = append(conds, opts.contains(builder, flavor, field, req.Search))
+		}
+		if len(opts.Selected) > 0 {
+			conds = append(conds, builder.In(opts.IDField, sqlbuilder.List(opts.Selected)))
 		}
+		builder.Where(builder.Or(conds...))
+	}
+
+	if len(opts.Selected) > 0 {
+		selected := builder.In(opts.IDField, sqlbuilder.List(opts.Selected))
+		builder.OrderBy("CASE WHEN " + selected + " THEN 0 ELSE 1 END")
+	}
+
+	if opts.GroupField != "" {
+		builder.OrderBy(opts.GroupField)
+	}
+
+	if opts.RankPrefix && req.Search != "" {
+		prefix := opts.hasPrefix(builder, flavor, opts.TextField, req.Search)
+		builder.OrderBy("CASE WHEN " + prefix + " THEN 0 ELSE 1 END")
 	}
 
 	builder.OrderBy(opts.OrderByField)
 	builder.Limit(req.Max)
+	if req.Offset > 0 {
+		builder.Offset(req.Offset)
+	}
 	query, args := builder.BuildWithFlavor(flavor)
 
 	begin := time.Now()
@@ -98,13 +170,35 @@ func GetDropdownContext(ctx context.Context, db QueryExecer, req w2.GetDropdownR
 	defer rows.Close()
 
 	var records []w2.Dropdown
+	var lastGroup sql.NullString
 
 	for rows.Next() {
 		var record w2.Dropdown
-		if err := rows.Scan(&record.ID, &record.Text); err != nil {
+		var icon, description, group sql.NullString
+
+		dest := []any{&record.ID, &record.Text}
+		if opts.IconField != "" {
+			dest = append(dest, &icon)
+		}
+		if opts.DescriptionField != "" {
+			dest = append(dest, &description)
+		}
+		if opts.GroupField != "" {
+			dest = append(dest, &group)
+		}
+
+		if err := rows.Scan(dest...); err != nil {
 			traceSQL(ctx, logger, begin, query, args, err)
 			return w2.GetDropdownResponse[w2.Dropdown]{}, fmt.Errorf("scan: %w", err)
 		}
+
+		if opts.GroupField != "" && (len(records) == 0 || group != lastGroup) {
+			records = append(records, w2.NewDropdownHeader(group.String))
+			lastGroup = group
+		}
+
+		record.Icon = icon.String
+		record.Description = description.String
 		records = append(records, record)
 	}
 
@@ -117,3 +211,46 @@ func GetDropdownContext(ctx context.Context, db QueryExecer, req w2.GetDropdownR
 	res := w2.NewGetDropdownResponse(records)
 	return res, nil
 }
+
+// unaccent wraps expr with the configured Unaccent function.
+func (opts GetDropdownOptions) unaccent(expr string) string {
+	if opts.Unaccent == "" {
+		return expr
+	}
+	return opts.Unaccent + "(" + expr + ")"
+}
+
+// contains returns a case-insensitive condition matching expr values that contain search.
+func (opts GetDropdownOptions) contains(builder *sqlbuilder.SelectBuilder, flavor sqlbuilder.Flavor, expr, search string) string {
+	if flavor == sqlbuilder.SQLite {
+		cond := sqlbuilder.Buildf("INSTR(LOWER(%v), LOWER(%v)) > 0", sqlbuilder.Raw(opts.unaccent(expr)), opts.searchArg(search))
+		return builder.Var(cond)
+	}
+
+	if opts.Unaccent == "" {
+		return builder.Like(expr, "%"+search+"%")
+	}
+
+	cond := sqlbuilder.Buildf("%v LIKE %v", sqlbuilder.Raw(opts.unaccent(expr)), opts.searchArg("%"+search+"%"))
+	return builder.Var(cond)
+}
+
+// hasPrefix returns a case-insensitive condition matching expr values that start with search.
+func (opts GetDropdownOptions) hasPrefix(builder *sqlbuilder.SelectBuilder, flavor sqlbuilder.Flavor, expr, search string) string {
+	if flavor == sqlbuilder.SQLite {
+		cond := sqlbuilder.Buildf("INSTR(LOWER(%v), LOWER(%v)) = 1", sqlbuilder.Raw(opts.unaccent(expr)), opts.searchArg(search))
+		return builder.Var(cond)
+	}
+
+	cond := sqlbuilder.Buildf("LOWER(%v) LIKE LOWER(%v)", sqlbuilder.Raw(opts.unaccent(expr)), opts.searchArg(search+"%"))
+	return builder.Var(cond)
+}
+
+// searchArg returns the search text as a query argument wrapped with the
+// configured Unaccent function.
+func (opts GetDropdownOptions) searchArg(search string) any {
+	if opts.Unaccent == "" {
+		return search
+	}
+	return sqlbuilder.Buildf(opts.Unaccent+"(%v)", search)
+}
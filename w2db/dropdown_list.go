@@ -0,0 +1,339 @@
+package w2db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/dv1x3r/w2go/w2"
+	"github.com/huandu/go-sqlbuilder"
+)
+
+// DropdownListOptions configures the many-to-many helpers for w2.DropdownList
+// values stored in a junction table.
+type DropdownListOptions struct {
+	// Junction is the trusted junction table name.
+	Junction string
+
+	// ParentField is the trusted junction column that references the parent record.
+	ParentField string
+
+	// ChildField is the trusted junction column that references the option ID.
+	ChildField string
+
+	// From is the trusted option table used to load labels.
+	From string
+
+	// IDField is the trusted option ID column in From.
+	IDField string
+
+	// TextField is the trusted SQL expression returned as each option label.
+	// A bare column name is qualified with the option table, so it may share
+	// its name with a junction column.
+	TextField string
+
+	// OrderByField is the trusted SQL expression used to order loaded options.
+	// It defaults to TextField and is qualified the same way.
+	OrderByField string
+
+	// Flavor overrides the package default SQL dialect when non-zero.
+	Flavor sqlbuilder.Flavor
+
+	// Logger overrides the package default SQL logger when non-nil.
+	Logger *slog.Logger
+}
+
+// SaveDropdownList syncs a junction table using context.Background.
+func SaveDropdownList(db QueryExecer, parentID int, list w2.DropdownList, opts DropdownListOptions) (int, error) {
+	return SaveDropdownListContext(context.Background(), db, parentID, list, opts)
+}
+
+// SaveDropdownListContext makes the junction rows of parentID match list and
+// returns the number of inserted and deleted rows.
+//
+// Only the difference is written: options missing from list are deleted and
+// new options are inserted. A list that was not provided is skipped. When db
+// is a *sql.DB, SaveDropdownListContext opens a transaction around the
+// statements. When db is already a *sql.Tx, it uses that transaction directly.
+func SaveDropdownListContext(ctx context.Context, db QueryExecer, parentID int, list w2.DropdownList, opts DropdownListOptions) (int, error) {
+	if !list.Provided {
+		return 0, nil
+	}
+
+	// sync requires a transaction for the delete and insert statements,
+	// but SQLite does not support nested transactions,
+	// so begin one if db is not already a *sql.Tx transaction
+	if sqlDB, ok := db.(*sql.DB); ok {
+		tx, err := sqlDB.BeginTx(ctx, nil)
+		if err != nil {
+			return 0, err
+		}
+		defer tx.Rollback()
+
+		affected, err := saveDropdownListContext(ctx, tx, parentID, list, opts)
+		if err != nil {
+			return 0, err
+		}
+
+		return affected, tx.Commit()
+	} else {
+		// db is already a *sql.Tx transaction
+		return saveDropdownListContext(ctx, db, parentID, list, opts)
+	}
+}
+
+func saveDropdownListContext(ctx context.Context, db QueryExecer, parentID int, list w2.DropdownList, opts DropdownListOptions) (int, error) {
+	if opts.Junction == "" {
+		return 0, errors.New("opts.Junction is required")
+	}
+
+	if opts.ParentField == "" {
+		return 0, errors.New("opts.ParentField is required")
+	}
+
+	if opts.ChildField == "" {
+		return 0, errors.New("opts.ChildField is required")
+	}
+
+	flavor := opts.Flavor
+	if flavor == 0 {
+		flavor = defaultFlavor
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = defaultLogger
+	}
+
+	selectBuilder := sqlbuilder.Select(opts.ChildField).From(opts.Junction)
+	selectBuilder.Where(selectBuilder.EQ(opts.ParentField, parentID))
+	query, args := selectBuilder.BuildWithFlavor(flavor)
+
+	begin := time.Now()
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		traceSQL(ctx, logger, begin, query, args, err)
+		return 0, err
+	}
+	defer rows.Close()
+
+	var current []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			traceSQL(ctx, logger, begin, query, args, err)
+			return 0, fmt.Errorf("scan: %w", err)
+		}
+		current = append(current, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		traceSQL(ctx, logger, begin, query, args, err)
+		return 0, err
+	}
+	traceSQL(ctx, logger, begin, query, args, nil)
+	rows.Close()
+
+	wanted := list.IDs()
+
+	var removed, added []int
+	for _, id := range current {
+		if !slices.Contains(wanted, id) {
+			removed = append(removed, id)
+		}
+	}
+	for _, id := range wanted {
+		if !slices.Contains(current, id) && !slices.Contains(added, id) {
+			added = append(added, id)
+		}
+	}
+
+	var affected int
+
+	if len(removed) > 0 {
+		deleteBuilder := sqlbuilder.DeleteFrom(opts.Junction)
+		deleteBuilder.Where(
+			deleteBuilder.EQ(opts.ParentField, parentID),
+			deleteBuilder.In(opts.ChildField, sqlbuilder.List(removed)),
+		)
+		query, args = deleteBuilder.BuildWithFlavor(flavor)
+
+		begin = time.Now()
+		result, err := db.ExecContext(ctx, query, args...)
+		traceSQL(ctx, logger, begin, query, args, err)
+		if err != nil {
+			return 0, fmt.Errorf("delete: %w", err)
+		}
+
+		n, _ := result.RowsAffected()
+		affected += int(n)
+	}
+
+	if len(added) > 0 {
+		insertBuilder := sqlbuilder.InsertInto(opts.Junction)
+		insertBuilder.Cols(opts.ParentField, opts.ChildField)
+		for _, id := range added {
+			insertBuilder.Values(parentID, id)
+		}
+		query, args = insertBuilder.BuildWithFlavor(flavor)
+
+		begin = time.Now()
+		result, err := db.ExecContext(ctx, query, args...)
+		traceSQL(ctx, logger, begin, query, args, err)
+		if err != nil {
+			return 0, fmt.Errorf("insert: %w", err)
+		}
+
+		n, _ := result.RowsAffected()
+		affected += int(n)
+	}
+
+	return affected, nil
+}
+
+// LoadDropdownLists loads junction options for many parents using context.Background.
+func LoadDropdownLists(db QueryExecer, parentIDs []int, opts DropdownListOptions) (map[int]w2.DropdownList, error) {
+	return LoadDropdownListsContext(context.Background(), db, parentIDs, opts)
+}
+
+// LoadDropdownListsContext loads the selected options of every parent in
+// parentIDs with one batched query.
+//
+// Use it after GetGrid or GetForm to fill w2.DropdownList fields on the loaded
+// records. Every parent ID is present in the returned map, with an empty
+// provided list when it has no options.
+func LoadDropdownListsContext(ctx context.Context, db QueryExecer, parentIDs []int, opts DropdownListOptions) (map[int]w2.DropdownList, error) {
+	if err := opts.validateLoad(); err != nil {
+		return nil, err
+	}
+
+	lists := make(map[int]w2.DropdownList, len(parentIDs))
+	for _, id := range parentIDs {
+		lists[id] = w2.NewDropdownList()
+	}
+
+	if len(parentIDs) == 0 {
+		return lists, nil
+	}
+
+	flavor := opts.Flavor
+	if flavor == 0 {
+		flavor = defaultFlavor
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = defaultLogger
+	}
+
+	orderByField := opts.OrderByField
+	if orderByField == "" {
+		orderByField = opts.TextField
+	}
+
+	builder := sqlbuilder.Select("j."+opts.ParentField, "o."+opts.IDField, optionColumn(opts.TextField))
+	builder.From(builder.As(opts.Junction, "j"))
+	builder.Join(builder.As(opts.From, "o"), "o."+opts.IDField+" = j."+opts.ChildField)
+	builder.Where(builder.In("j."+opts.ParentField, sqlbuilder.List(parentIDs)))
+	builder.OrderBy(optionColumn(orderByField))
+	query, args := builder.BuildWithFlavor(flavor)
+
+	begin := time.Now()
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		traceSQL(ctx, logger, begin, query, args, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var parentID int
+		var item w2.Dropdown
+		if err := rows.Scan(&parentID, &item.ID, &item.Text); err != nil {
+			traceSQL(ctx, logger, begin, query, args, err)
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		list := lists[parentID]
+		list.Items = append(list.Items, item)
+		lists[parentID] = list
+	}
+
+	if err := rows.Err(); err != nil {
+		traceSQL(ctx, logger, begin, query, args, err)
+		return nil, err
+	}
+
+	traceSQL(ctx, logger, begin, query, args, nil)
+	return lists, nil
+}
+
+// SelectJSON returns a correlated SQLite subquery that aggregates the options
+// of parentExpr into a JSON array with json_group_array.
+//
+// Add it to GetGridOptions.Select or GetFormOptions.Select and scan the column
+// into a w2.DropdownList, which loads the list without a second query. The
+// option table is aliased as "o" and the junction table as "j". Bare column
+// names in TextField and OrderByField are qualified with "o", and other
+// expressions should use those aliases when they reference columns.
+func (opts DropdownListOptions) SelectJSON(parentExpr string) string {
+	orderByField := opts.OrderByField
+	if orderByField == "" {
+		orderByField = opts.TextField
+	}
+
+	return fmt.Sprintf(
+		"(SELECT json_group_array(json_object('id', o.%s, 'text', %s) ORDER BY %s) FROM %s AS j JOIN %s AS o ON o.%s = j.%s WHERE j.%s = %s)",
+		opts.IDField, optionColumn(opts.TextField), optionColumn(orderByField),
+		opts.Junction, opts.From, opts.IDField, opts.ChildField,
+		opts.ParentField, parentExpr,
+	)
+}
+
+// optionColumn qualifies a bare column name with the option table alias "o",
+// so it is not ambiguous with a junction column of the same name. Qualified
+// names and other expressions are returned unchanged.
+func optionColumn(expr string) string {
+	if expr == "" || expr[0] >= '0' && expr[0] <= '9' {
+		return expr
+	}
+
+	for _, c := range expr {
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return expr
+		}
+	}
+
+	return "o." + expr
+}
+
+func (opts DropdownListOptions) validateLoad() error {
+	if opts.Junction == "" {
+		return errors.New("opts.Junction is required")
+	}
+
+	if opts.ParentField == "" {
+		return errors.New("opts.ParentField is required")
+	}
+
+	if opts.ChildField == "" {
+		return errors.New("opts.ChildField is required")
+	}
+
+	if opts.From == "" {
+		return errors.New("opts.From is required")
+	}
+
+	if opts.IDField == "" {
+		return errors.New("opts.IDField is required")
+	}
+
+	if opts.TextField == "" {
+		return errors.New("opts.TextField is required")
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package w2db_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/dv1x3r/w2go/w2"
+	"github.com/dv1x3r/w2go/w2db"
+	"github.com/huandu/go-sqlbuilder"
+)
+
+// tagOptions shares the name column between the option and junction tables,
+// so unqualified columns would be ambiguous.
+var tagOptions = w2db.DropdownListOptions{
+	Junction:    "todo_tag",
+	ParentField: "todo_id",
+	ChildField:  "tag_id",
+	From:        "tag",
+	IDField:     "id",
+	TextField:   "name",
+	Flavor:      sqlbuilder.SQLite,
+}
+
+func openTagDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", "file:/"+url.PathEscape(t.Name())+"?vfs=memdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec(`
+CREATE TABLE tag (id INTEGER PRIMARY KEY, name TEXT);
+CREATE TABLE todo_tag (todo_id INTEGER, tag_id INTEGER, name TEXT, PRIMARY KEY (todo_id, tag_id));
+INSERT INTO tag (name) VALUES ('red'), ('green'), ('blue');
+INSERT INTO todo_tag (todo_id, tag_id) VALUES (1, 1), (1, 2), (2, 3);
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func tagIDs(t *testing.T, db *sql.DB, todoID int) []int {
+	t.Helper()
+
+	rows, err := db.Query("SELECT tag_id FROM todo_tag WHERE todo_id = ? ORDER BY tag_id", todoID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+func TestSaveDropdownList(t *testing.T) {
+	tests := []struct {
+		Name     string
+		JSON     string
+		Expected []int
+		Affected int
+	}{
+		{Name: "Diff", JSON: `[2, 3, 3]`, Expected: []int{2, 3}, Affected: 2},
+		{Name: "Objects", JSON: `[{"id": 1, "text": "red"}, {"id": 2}]`, Expected: []int{1, 2}, Affected: 0},
+		{Name: "Clear", JSON: `""`, Expected: []int{}, Affected: 2},
+		{Name: "NotProvided", Expected: []int{1, 2}, Affected: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			db := openTagDB(t)
+
+			var list w2.DropdownList
+			if test.JSON != "" {
+				if err := json.Unmarshal([]byte(test.JSON), &list); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			affected, err := w2db.SaveDropdownList(db, 1, list, tagOptions)
+			if err != nil || affected != test.Affected {
+				t.Errorf("❌ Expected %d rows affected, got: %d %v", test.Affected, affected, err)
+			}
+
+			if ids := tagIDs(t, db, 1); !slices.Equal(ids, test.Expected) {
+				t.Errorf("❌ Expected tags %v, got: %v", test.Expected, ids)
+			}
+
+			if ids := tagIDs(t, db, 2); !slices.Equal(ids, []int{3}) {
+				t.Errorf("❌ Expected the other todo to keep its tags, got: %v", ids)
+			}
+		})
+	}
+}
+
+func TestLoadDropdownLists(t *testing.T) {
+	db := openTagDB(t)
+
+	lists, err := w2db.LoadDropdownLists(db, []int{1, 2, 9}, tagOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := json.Marshal(lists)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"1":[{"id":2,"text":"green"},{"id":1,"text":"red"}],"2":[{"id":3,"text":"blue"}],"9":[]}`
+	if string(output) != expected {
+		t.Errorf("❌ Unexpected output JSON:\n  got:  %s\n  want: %s", output, expected)
+	}
+
+	// SelectJSON loads the same lists in a correlated subquery
+	var list w2.DropdownList
+	if err := db.QueryRow("SELECT " + tagOptions.SelectJSON("1")).Scan(&list); err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := list.IDs(); !slices.Equal(ids, []int{2, 1}) {
+		t.Errorf("❌ Expected tags [2 1], got: %v", ids)
+	}
+}
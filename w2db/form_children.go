@@ -0,0 +1,292 @@
+package w2db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"time"
+
+	"github.com/dv1x3r/w2go/w2"
+	"github.com/huandu/go-sqlbuilder"
+)
+
+// SaveFormWithChildrenOptions configures SaveFormWithChildren and SaveFormWithChildrenContext.
+type SaveFormWithChildrenOptions[T, C any] struct {
+	// Table is the trusted header table name.
+	Table string
+
+	// IDField is the trusted header ID column.
+	IDField string
+
+	// Values converts the header record into values keyed by trusted column names.
+	Values func(record T) map[string]any
+
+	// ChildTable is the trusted child table name.
+	ChildTable string
+
+	// ChildIDField is the trusted child ID column.
+	ChildIDField string
+
+	// ChildParentField is the trusted child column that references the header ID.
+	ChildParentField string
+
+	// ChildID returns the client-side ID of a child row. For added rows this
+	// is the temporary ID assigned by the client.
+	ChildID func(child C) any
+
+	// ChildValues converts one child row into values keyed by trusted column
+	// names. The parent column is set automatically.
+	ChildValues func(child C) map[string]any
+
+	// Flavor overrides the package default SQL dialect when non-zero.
+	Flavor sqlbuilder.Flavor
+
+	// Logger overrides the package default SQL logger when non-nil.
+	Logger *slog.Logger
+}
+
+// SaveFormWithChildren saves a form header and its child rows using context.Background.
+func SaveFormWithChildren[T, C any](db QueryExecer, req w2.SaveFormRequest[T], children w2.ChildChanges[C], opts SaveFormWithChildrenOptions[T, C]) (int, map[string]int, error) {
+	return SaveFormWithChildrenContext(context.Background(), db, req, children, opts)
+}
+
+// SaveFormWithChildrenContext inserts or updates a form header and then writes
+// its removed, changed, and added child rows.
+//
+// A zero req.RecID inserts a new header. The function returns the header ID
+// and a map from the string form of each added child's temporary ID to its new
+// ID. Changed and removed rows are scoped to the header, so a client cannot
+// modify another header's children. An update of a missing header, or of a
+// changed row that does not exist under it, returns an error wrapping
+// sql.ErrNoRows and writes nothing. When db is a *sql.DB, all statements run
+// in one transaction. When db is already a *sql.Tx, it uses that transaction directly.
+func SaveFormWithChildrenContext[T, C any](ctx context.Context, db QueryExecer, req w2.SaveFormRequest[T], children w2.ChildChanges[C], opts SaveFormWithChildrenOptions[T, C]) (int, map[string]int, error) {
+	// save requires a transaction for header and child writes,
+	// but SQLite does not support nested transactions,
+	// so begin one if db is not already a *sql.Tx transaction
+	if sqlDB, ok := db.(*sql.DB); ok {
+		tx, err := sqlDB.BeginTx(ctx, nil)
+		if err != nil {
+			return 0, nil, err
+		}
+		defer tx.Rollback()
+
+		recID, childIDs, err := saveFormWithChildrenContext(ctx, tx, req, children, opts)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		return recID, childIDs, tx.Commit()
+	} else {
+		// db is already a *sql.Tx transaction
+		return saveFormWithChildrenContext(ctx, db, req, children, opts)
+	}
+}
+
+func saveFormWithChildrenContext[T, C any](ctx context.Context, db QueryExecer, req w2.SaveFormRequest[T], children w2.ChildChanges[C], opts SaveFormWithChildrenOptions[T, C]) (int, map[string]int, error) {
+	if opts.Table == "" {
+		return 0, nil, errors.New("opts.Table is required")
+	}
+
+	if opts.IDField == "" {
+		return 0, nil, errors.New("opts.IDField is required")
+	}
+
+	if opts.Values == nil {
+		return 0, nil, errors.New("opts.Values is required")
+	}
+
+	if opts.ChildTable == "" {
+		return 0, nil, errors.New("opts.ChildTable is required")
+	}
+
+	if opts.ChildIDField == "" {
+		return 0, nil, errors.New("opts.ChildIDField is required")
+	}
+
+	if opts.ChildParentField == "" {
+		return 0, nil, errors.New("opts.ChildParentField is required")
+	}
+
+	if opts.ChildID == nil {
+		return 0, nil, errors.New("opts.ChildID is required")
+	}
+
+	if opts.ChildValues == nil {
+		return 0, nil, errors.New("opts.ChildValues is required")
+	}
+
+	flavor := opts.Flavor
+	if flavor == 0 {
+		flavor = defaultFlavor
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = defaultLogger
+	}
+
+	// children must not be written under a header that does not exist
+	recID, err := SaveFormContext(ctx, db, req, SaveFormOptions[T]{
+		Table:       opts.Table,
+		IDField:     opts.IDField,
+		Values:      opts.Values,
+		CheckExists: true,
+		Flavor:      opts.Flavor,
+		Logger:      opts.Logger,
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if len(children.Removed) > 0 {
+		builder := sqlbuilder.DeleteFrom(opts.ChildTable)
+		builder.Where(
+			builder.EQ(opts.ChildParentField, recID),
+			builder.In(opts.ChildIDField, sqlbuilder.List(children.Removed)),
+		)
+		query, args := builder.BuildWithFlavor(flavor)
+
+		begin := time.Now()
+		_, err = db.ExecContext(ctx, query, args...)
+		traceSQL(ctx, logger, begin, query, args, err)
+		if err != nil {
+			return 0, nil, fmt.Errorf("delete children: %w", err)
+		}
+	}
+
+	for i, child := range children.Changed {
+		values := opts.ChildValues(child)
+
+		affected, err := UpdateContext(ctx, db, UpdateOptions{
+			Update: opts.ChildTable,
+			Values: values,
+			Where: map[string]any{
+				opts.ChildIDField:     opts.ChildID(child),
+				opts.ChildParentField: recID,
+			},
+			Flavor: opts.Flavor,
+			Logger: opts.Logger,
+		})
+		if err != nil {
+			return 0, nil, fmt.Errorf("update child [%d]: %w", i, err)
+		}
+
+		// a missing row or a row of another header rolls the save back
+		if affected == 0 && hasProvided(values) {
+			return 0, nil, fmt.Errorf("update child [%d]: %w", i, sql.ErrNoRows)
+		}
+	}
+
+	childIDs := make(map[string]int, len(children.Added))
+
+	for i, child := range children.Added {
+		values := maps.Clone(opts.ChildValues(child))
+		if values == nil {
+			values = make(map[string]any, 1)
+		}
+		values[opts.ChildParentField] = recID
+
+		id, err := InsertContext(ctx, db, InsertOptions{
+			Into:   opts.ChildTable,
+			Values: values,
+			Flavor: opts.Flavor,
+			Logger: opts.Logger,
+		})
+		if err != nil {
+			return 0, nil, fmt.Errorf("insert child [%d]: %w", i, err)
+		}
+		childIDs[fmt.Sprint(opts.ChildID(child))] = id
+	}
+
+	return recID, childIDs, nil
+}
+
+// GetFormWithChildrenOptions configures GetFormWithChildren and GetFormWithChildrenContext.
+type GetFormWithChildrenOptions[T, C any] struct {
+	// Form configures the header query.
+	Form GetFormOptions[T]
+
+	// ChildFrom is the child table, view, or join expression used in the FROM clause.
+	ChildFrom string
+
+	// ChildParentField is the trusted SQL expression compared to req.RecID.
+	ChildParentField string
+
+	// ChildSelect lists the SQL expressions returned for each child row.
+	ChildSelect []string
+
+	// ChildOrderBy lists trusted SQL expressions used to order child rows.
+	ChildOrderBy []string
+
+	// ChildBuild customizes the child SELECT query, for example by adding joins.
+	ChildBuild func(sb *sqlbuilder.SelectBuilder)
+
+	// ChildScan copies the current child row into child.
+	ChildScan func(rows *sql.Rows, child *C) error
+
+	// Attach stores the loaded child rows on the header record.
+	Attach func(record *T, children []C)
+}
+
+// GetFormWithChildren loads a form header and its child rows using context.Background.
+func GetFormWithChildren[T, C any](db QueryExecer, req w2.GetFormRequest, opts GetFormWithChildrenOptions[T, C]) (w2.GetFormResponse[T], error) {
+	return GetFormWithChildrenContext(context.Background(), db, req, opts)
+}
+
+// GetFormWithChildrenContext loads one form record by req.RecID together with
+// the child rows that reference it.
+//
+// The header is loaded with GetFormContext, so a missing header returns an
+// error wrapping sql.ErrNoRows. Child rows are passed to opts.Attach.
+func GetFormWithChildrenContext[T, C any](ctx context.Context, db QueryExecer, req w2.GetFormRequest, opts GetFormWithChildrenOptions[T, C]) (w2.GetFormResponse[T], error) {
+	if opts.ChildFrom == "" {
+		return w2.GetFormResponse[T]{}, errors.New("opts.ChildFrom is required")
+	}
+
+	if opts.ChildParentField == "" {
+		return w2.GetFormResponse[T]{}, errors.New("opts.ChildParentField is required")
+	}
+
+	if len(opts.ChildSelect) == 0 {
+		return w2.GetFormResponse[T]{}, errors.New("opts.ChildSelect is required")
+	}
+
+	if opts.ChildScan == nil {
+		return w2.GetFormResponse[T]{}, errors.New("opts.ChildScan is required")
+	}
+
+	if opts.Attach == nil {
+		return w2.GetFormResponse[T]{}, errors.New("opts.Attach is required")
+	}
+
+	res, err := GetFormContext(ctx, db, req, opts.Form)
+	if err != nil {
+		return w2.GetFormResponse[T]{}, err
+	}
+
+	children, err := SelectContext(ctx, db, SelectOptions[C]{
+		Build: func(sb *sqlbuilder.SelectBuilder) {
+			sb.Select(opts.ChildSelect...).From(opts.ChildFrom)
+			if opts.ChildBuild != nil {
+				opts.ChildBuild(sb)
+			}
+			sb.Where(sb.EQ(opts.ChildParentField, req.RecID))
+			if len(opts.ChildOrderBy) > 0 {
+				sb.OrderBy(opts.ChildOrderBy...)
+			}
+		},
+		Scan:   opts.ChildScan,
+		Flavor: opts.Form.Flavor,
+		Logger: opts.Form.Logger,
+	})
+	if err != nil {
+		return w2.GetFormResponse[T]{}, fmt.Errorf("children: %w", err)
+	}
+
+	opts.Attach(res.Record, children)
+	return res, nil
+}
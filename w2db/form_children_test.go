@@ -0,0 +1,137 @@
+package w2db_test
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/dv1x3r/w2go/w2"
+	"github.com/dv1x3r/w2go/w2db"
+	"github.com/huandu/go-sqlbuilder"
+)
+
+type orderForm struct {
+	Customer string
+	Lines    w2.ChildChanges[orderLine]
+}
+
+type orderLine struct {
+	ID  any
+	Qty int
+}
+
+func openOrderDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", "file:/"+url.PathEscape(t.Name())+"?vfs=memdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec(`
+CREATE TABLE orders (id INTEGER PRIMARY KEY, customer TEXT);
+CREATE TABLE order_lines (id INTEGER PRIMARY KEY, order_id INTEGER, qty INTEGER);
+INSERT INTO orders (customer) VALUES ('a'), ('b');
+INSERT INTO order_lines (order_id, qty) VALUES (1, 1), (1, 2), (2, 3);
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func countRows(t *testing.T, db *sql.DB, query string) int {
+	t.Helper()
+
+	var count int
+	if err := db.QueryRow(query).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+
+	return count
+}
+
+func TestSaveFormWithChildren(t *testing.T) {
+	opts := w2db.SaveFormWithChildrenOptions[orderForm, orderLine]{
+		Table:            "orders",
+		IDField:          "id",
+		Values:           func(o orderForm) map[string]any { return map[string]any{"customer": o.Customer} },
+		ChildTable:       "order_lines",
+		ChildIDField:     "id",
+		ChildParentField: "order_id",
+		ChildID:          func(l orderLine) any { return l.ID },
+		ChildValues:      func(l orderLine) map[string]any { return map[string]any{"qty": l.Qty} },
+		Flavor:           sqlbuilder.SQLite,
+	}
+
+	t.Run("Insert", func(t *testing.T) {
+		db := openOrderDB(t)
+		record := orderForm{Customer: "c", Lines: w2.ChildChanges[orderLine]{Added: []orderLine{{ID: "new-1", Qty: 4}}}}
+
+		recID, childIDs, err := w2db.SaveFormWithChildren(db, w2.SaveFormRequest[orderForm]{Record: record}, record.Lines, opts)
+		if err != nil || recID != 3 || childIDs["new-1"] != 4 {
+			t.Fatalf("❌ Expected order 3 with line 4, got: %d %v %v", recID, childIDs, err)
+		}
+
+		if count := countRows(t, db, "SELECT count(*) FROM order_lines WHERE order_id = 3 AND qty = 4"); count != 1 {
+			t.Errorf("❌ Expected the line under order 3, got: %d", count)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		db := openOrderDB(t)
+		record := orderForm{Customer: "x", Lines: w2.ChildChanges[orderLine]{
+			Added:   []orderLine{{ID: "new-1", Qty: 5}},
+			Changed: []orderLine{{ID: 1, Qty: 9}},
+			Removed: []int{2},
+		}}
+
+		recID, _, err := w2db.SaveFormWithChildren(db, w2.SaveFormRequest[orderForm]{RecID: 1, Record: record}, record.Lines, opts)
+		if err != nil || recID != 1 {
+			t.Fatalf("❌ Expected order 1, got: %d %v", recID, err)
+		}
+
+		if count := countRows(t, db, "SELECT count(*) FROM order_lines WHERE order_id = 1 AND qty IN (9, 5)"); count != 2 {
+			t.Errorf("❌ Expected the changed and added lines, got: %d", count)
+		}
+	})
+
+	t.Run("MissingHeader", func(t *testing.T) {
+		db := openOrderDB(t)
+		record := orderForm{Customer: "x", Lines: w2.ChildChanges[orderLine]{Added: []orderLine{{ID: "new-1", Qty: 5}}}}
+
+		_, _, err := w2db.SaveFormWithChildren(db, w2.SaveFormRequest[orderForm]{RecID: 9, Record: record}, record.Lines, opts)
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("❌ Expected sql.ErrNoRows, got: %v", err)
+		}
+
+		if count := countRows(t, db, "SELECT count(*) FROM order_lines"); count != 3 {
+			t.Errorf("❌ Expected no orphan lines, got %d lines", count)
+		}
+	})
+
+	t.Run("ForeignChild", func(t *testing.T) {
+		db := openOrderDB(t)
+		record := orderForm{Customer: "x", Lines: w2.ChildChanges[orderLine]{
+			Added:   []orderLine{{ID: "new-1", Qty: 5}},
+			Changed: []orderLine{{ID: 3, Qty: 9}},
+		}}
+
+		_, _, err := w2db.SaveFormWithChildren(db, w2.SaveFormRequest[orderForm]{RecID: 1, Record: record}, record.Lines, opts)
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("❌ Expected sql.ErrNoRows, got: %v", err)
+		}
+
+		// the header update and every child write are rolled back
+		if count := countRows(t, db, "SELECT count(*) FROM orders WHERE customer = 'x'"); count != 0 {
+			t.Errorf("❌ Expected the header update to be rolled back, got: %d", count)
+		}
+
+		if count := countRows(t, db, "SELECT count(*) FROM order_lines WHERE qty IN (5, 9)"); count != 0 {
+			t.Errorf("❌ Expected no line writes, got: %d", count)
+		}
+	})
+}
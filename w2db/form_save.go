@@ -0,0 +1,135 @@
+package w2db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/dv1x3r/w2go/w2"
+	"github.com/huandu/go-sqlbuilder"
+)
+
+// SaveFormOptions configures SaveForm and SaveFormContext.
+type SaveFormOptions[T any] struct {
+	// Table is the trusted table name used for both inserts and updates.
+	Table string
+
+	// IDField is the trusted ID column compared to req.RecID on update.
+	IDField string
+
+	// Values converts the form record into values keyed by trusted column names.
+	//
+	// Values that implement Providable are skipped on update when IsProvided returns false.
+	Values func(record T) map[string]any
+
+	// Immutable lists trusted column names that are written on insert and
+	// may not be changed on update, for example owner or creation columns.
+	// An update that provides a value for one of them fails with ErrImmutableColumn.
+	Immutable []string
+
+	// CheckExists verifies that the record exists before an update. A missing
+	// record returns an error wrapping sql.ErrNoRows, which handlers can map to 404.
+	CheckExists bool
+
+	// Flavor overrides the package default SQL dialect when non-zero.
+	Flavor sqlbuilder.Flavor
+
+	// Logger overrides the package default SQL logger when non-nil.
+	Logger *slog.Logger
+}
+
+// ErrImmutableColumn is returned when a form update provides a value for a
+// column listed in SaveFormOptions.Immutable.
+var ErrImmutableColumn = errors.New("column is immutable")
+
+// SaveForm inserts or updates a form record using context.Background.
+func SaveForm[T any](db QueryExecer, req w2.SaveFormRequest[T], opts SaveFormOptions[T]) (int, error) {
+	return SaveFormContext(context.Background(), db, req, opts)
+}
+
+// SaveFormContext inserts a new record when req.RecID is zero and updates the
+// existing record otherwise. It returns the record ID for w2.NewSaveFormResponse.
+//
+// The same opts.Values builder is used for both cases. On update, a column in
+// opts.Immutable must be absent from the values or hold a Providable value that
+// was not provided, otherwise nothing is written and an error wrapping
+// ErrImmutableColumn is returned. With opts.CheckExists a missing record,
+// checked before the update or matched by no row of it, returns an error
+// wrapping sql.ErrNoRows. Without it an update of a missing record succeeds
+// with zero rows affected.
+func SaveFormContext[T any](ctx context.Context, db QueryExecer, req w2.SaveFormRequest[T], opts SaveFormOptions[T]) (int, error) {
+	if opts.Table == "" {
+		return 0, errors.New("opts.Table is required")
+	}
+
+	if opts.IDField == "" {
+		return 0, errors.New("opts.IDField is required")
+	}
+
+	if opts.Values == nil {
+		return 0, errors.New("opts.Values is required")
+	}
+
+	values := opts.Values(req.Record)
+
+	if req.RecID == 0 {
+		return InsertContext(ctx, db, InsertOptions{
+			Into:   opts.Table,
+			Values: values,
+			Flavor: opts.Flavor,
+			Logger: opts.Logger,
+		})
+	}
+
+	for _, col := range opts.Immutable {
+		if value, ok := values[col]; ok && isProvided(value) {
+			return 0, fmt.Errorf("%w: %s", ErrImmutableColumn, col)
+		}
+	}
+
+	flavor := opts.Flavor
+	if flavor == 0 {
+		flavor = defaultFlavor
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = defaultLogger
+	}
+
+	if opts.CheckExists {
+		builder := sqlbuilder.Select("1").From(opts.Table)
+		builder.Where(builder.EQ(opts.IDField, req.RecID))
+		query, args := builder.BuildWithFlavor(flavor)
+
+		var exists int
+
+		begin := time.Now()
+		err := db.QueryRowContext(ctx, query, args...).Scan(&exists)
+		traceSQL(ctx, logger, begin, query, args, err)
+		if err != nil {
+			return 0, fmt.Errorf("check exists: %w", err)
+		}
+	}
+
+	affected, err := UpdateContext(ctx, db, UpdateOptions{
+		Update: opts.Table,
+		Values: values,
+		Where:  map[string]any{opts.IDField: req.RecID},
+		Flavor: opts.Flavor,
+		Logger: opts.Logger,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// the record may be deleted between the check and the update
+	if opts.CheckExists && affected == 0 && hasProvided(values) {
+		return 0, fmt.Errorf("update: %w", sql.ErrNoRows)
+	}
+
+	return req.RecID, nil
+}
@@ -0,0 +1,66 @@
+package w2db_test
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/dv1x3r/w2go/w2"
+	"github.com/dv1x3r/w2go/w2db"
+	"github.com/huandu/go-sqlbuilder"
+)
+
+type todoForm struct {
+	Name   string
+	Status w2.Field[int]
+}
+
+func TestSaveForm(t *testing.T) {
+	opts := w2db.SaveFormOptions[todoForm]{
+		Table:   "todo",
+		IDField: "id",
+		Values: func(record todoForm) map[string]any {
+			return map[string]any{"name": record.Name, "status_id": record.Status}
+		},
+		Immutable:   []string{"status_id"},
+		CheckExists: true,
+		Flavor:      sqlbuilder.SQLite,
+	}
+
+	tests := []struct {
+		Name     string
+		RecID    int
+		Record   todoForm
+		Expected int
+		Error    error
+		Where    string
+	}{
+		{Name: "Insert", Record: todoForm{Name: "d", Status: w2.NewField(3)}, Expected: 4, Where: "id = 4 AND name = 'd' AND status_id = 3"},
+		{Name: "Update", RecID: 1, Record: todoForm{Name: "x"}, Expected: 1, Where: "id = 1 AND name = 'x' AND status_id = 1"},
+		{Name: "UpdateImmutable", RecID: 1, Record: todoForm{Name: "x", Status: w2.NewField(2)}, Error: w2db.ErrImmutableColumn, Where: "id = 1 AND name = 'a' AND status_id = 1"},
+		{Name: "UpdateMissing", RecID: 9, Record: todoForm{Name: "x"}, Error: sql.ErrNoRows, Where: "name = 'x'"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			db := openTodoDB(t)
+			req := w2.SaveFormRequest[todoForm]{RecID: test.RecID, Record: test.Record}
+
+			recID, err := w2db.SaveForm(db, req, opts)
+			if test.Error != nil && !errors.Is(err, test.Error) {
+				t.Errorf("❌ Expected %v, got: %d %v", test.Error, recID, err)
+			} else if test.Error == nil && (err != nil || recID != test.Expected) {
+				t.Errorf("❌ Expected record %d, got: %d %v", test.Expected, recID, err)
+			}
+
+			expected := 1
+			if test.Error == sql.ErrNoRows {
+				expected = 0
+			}
+
+			if count := countTodo(t, db, test.Where); count != expected {
+				t.Errorf("❌ Expected %d rows where %s, got: %d", expected, test.Where, count)
+			}
+		})
+	}
+}
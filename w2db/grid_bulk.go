@@ -0,0 +1,296 @@
+package w2db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/dv1x3r/w2go/w2"
+	"github.com/dv1x3r/w2go/w2sql"
+	"github.com/huandu/go-sqlbuilder"
+)
+
+// UpdateWhereOptions configures UpdateWhere and UpdateWhereContext.
+type UpdateWhereOptions struct {
+	// Update is the trusted table name or update target.
+	Update string
+
+	// IDField is the trusted ID column of the update target.
+	IDField string
+
+	// Values lists values keyed by trusted column names.
+	//
+	// Values that implement Providable are skipped when IsProvided returns false.
+	Values map[string]any
+
+	// Source is the table, view, or join expression searched for matching
+	// rows. It defaults to Update.
+	Source string
+
+	// SourceIDField is the trusted SQL expression in Source that selects
+	// target IDs. It defaults to IDField.
+	SourceIDField string
+
+	// Where maps w2grid search field names to trusted SQL expressions.
+	Where map[string]string
+
+	// Params maps w2.Extra request keys to trusted SQL expressions or
+	// predicates. See w2sql.Params.
+	Params map[string]string
+
+	// Build customizes the search query, for example by adding joins or fixed filters.
+	Build func(sb *sqlbuilder.SelectBuilder)
+
+	// DryRun counts the matching rows without updating them.
+	DryRun bool
+
+	// AllowEmptySearch allows updating every row when the request has no
+	// search rule that adds a condition.
+	AllowEmptySearch bool
+
+	// Flavor overrides the package default SQL dialect when non-zero.
+	Flavor sqlbuilder.Flavor
+
+	// Logger overrides the package default SQL logger when non-nil.
+	Logger *slog.Logger
+}
+
+// UpdateWhere updates all rows matching a grid search using context.Background.
+func UpdateWhere(db QueryExecer, req w2.GetGridRequest, opts UpdateWhereOptions) (int, error) {
+	return UpdateWhereContext(context.Background(), db, req, opts)
+}
+
+// UpdateWhereContext updates all rows matching the grid search in req with a
+// single statement and returns RowsAffected.
+//
+// Paging and sorting in req are ignored, so the update covers every page the
+// user filtered. With opts.DryRun, the matching rows are counted instead. If
+// every value is a Providable value that was not provided, no SQL statement is
+// executed and the function returns zero rows affected.
+func UpdateWhereContext(ctx context.Context, db QueryExecer, req w2.GetGridRequest, opts UpdateWhereOptions) (int, error) {
+	if opts.Update == "" {
+		return 0, errors.New("opts.Update is required")
+	}
+
+	if opts.IDField == "" {
+		return 0, errors.New("opts.IDField is required")
+	}
+
+	if len(opts.Values) == 0 {
+		return 0, errors.New("opts.Values is required")
+	}
+
+	flavor := opts.Flavor
+	if flavor == 0 {
+		flavor = defaultFlavor
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = defaultLogger
+	}
+
+	selection, searched := selectWhere(req, bulkSource{
+		Target:        opts.Update,
+		IDField:       opts.IDField,
+		Source:        opts.Source,
+		SourceIDField: opts.SourceIDField,
+		Where:         opts.Where,
+		Params:        opts.Params,
+		Build:         opts.Build,
+	}, flavor)
+
+	if !searched && !opts.AllowEmptySearch {
+		return 0, errors.New("req.Search must contain a valid search rule")
+	}
+
+	builder := sqlbuilder.Update(opts.Update)
+	assigned := 0
+
+	for col, value := range opts.Values {
+		if p, ok := value.(Providable); ok && !p.IsProvided() {
+			continue
+		}
+		builder.SetMore(builder.Assign(col, value))
+		assigned++
+	}
+
+	if assigned == 0 {
+		return 0, nil
+	}
+
+	if opts.DryRun {
+		return countWhere(ctx, db, opts.Update, opts.IDField, selection, flavor, logger)
+	}
+
+	builder.Where(builder.In(opts.IDField, selection))
+	query, args := builder.BuildWithFlavor(flavor)
+
+	begin := time.Now()
+	result, err := db.ExecContext(ctx, query, args...)
+	traceSQL(ctx, logger, begin, query, args, err)
+	if err != nil {
+		return 0, fmt.Errorf("update: %w", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	return int(affected), nil
+}
+
+// RemoveWhereOptions configures RemoveWhere and RemoveWhereContext.
+type RemoveWhereOptions struct {
+	// From is the trusted table name or delete target.
+	From string
+
+	// IDField is the trusted ID column of the delete target.
+	IDField string
+
+	// Source is the table, view, or join expression searched for matching
+	// rows. It defaults to From.
+	Source string
+
+	// SourceIDField is the trusted SQL expression in Source that selects
+	// target IDs. It defaults to IDField.
+	SourceIDField string
+
+	// Where maps w2grid search field names to trusted SQL expressions.
+	Where map[string]string
+
+	// Params maps w2.Extra request keys to trusted SQL expressions or
+	// predicates. See w2sql.Params.
+	Params map[string]string
+
+	// Build customizes the search query, for example by adding joins or fixed filters.
+	Build func(sb *sqlbuilder.SelectBuilder)
+
+	// DryRun counts the matching rows without deleting them.
+	DryRun bool
+
+	// AllowEmptySearch allows deleting every row when the request has no
+	// search rule that adds a condition.
+	AllowEmptySearch bool
+
+	// Flavor overrides the package default SQL dialect when non-zero.
+	Flavor sqlbuilder.Flavor
+
+	// Logger overrides the package default SQL logger when non-nil.
+	Logger *slog.Logger
+}
+
+// RemoveWhere deletes all rows matching a grid search using context.Background.
+func RemoveWhere(db QueryExecer, req w2.GetGridRequest, opts RemoveWhereOptions) (int, error) {
+	return RemoveWhereContext(context.Background(), db, req, opts)
+}
+
+// RemoveWhereContext deletes all rows matching the grid search in req with a
+// single statement and returns RowsAffected.
+//
+// Paging and sorting in req are ignored. With opts.DryRun, the matching rows
+// are counted instead.
+func RemoveWhereContext(ctx context.Context, db QueryExecer, req w2.GetGridRequest, opts RemoveWhereOptions) (int, error) {
+	if opts.From == "" {
+		return 0, errors.New("opts.From is required")
+	}
+
+	if opts.IDField == "" {
+		return 0, errors.New("opts.IDField is required")
+	}
+
+	flavor := opts.Flavor
+	if flavor == 0 {
+		flavor = defaultFlavor
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = defaultLogger
+	}
+
+	selection, searched := selectWhere(req, bulkSource{
+		Target:        opts.From,
+		IDField:       opts.IDField,
+		Source:        opts.Source,
+		SourceIDField: opts.SourceIDField,
+		Where:         opts.Where,
+		Params:        opts.Params,
+		Build:         opts.Build,
+	}, flavor)
+
+	if !searched && !opts.AllowEmptySearch {
+		return 0, errors.New("req.Search must contain a valid search rule")
+	}
+
+	if opts.DryRun {
+		return countWhere(ctx, db, opts.From, opts.IDField, selection, flavor, logger)
+	}
+
+	builder := sqlbuilder.DeleteFrom(opts.From)
+	builder.Where(builder.In(opts.IDField, selection))
+	query, args := builder.BuildWithFlavor(flavor)
+
+	begin := time.Now()
+	result, err := db.ExecContext(ctx, query, args...)
+	traceSQL(ctx, logger, begin, query, args, err)
+	if err != nil {
+		return 0, fmt.Errorf("delete: %w", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	return int(affected), nil
+}
+
+type bulkSource struct {
+	Target        string
+	IDField       string
+	Source        string
+	SourceIDField string
+	Where         map[string]string
+	Params        map[string]string
+	Build         func(sb *sqlbuilder.SelectBuilder)
+}
+
+// selectWhere builds the subquery that selects the IDs matched by the grid
+// search. It also reports whether the search added a condition, because rules
+// with unknown fields, unknown operators, or empty values are skipped.
+func selectWhere(req w2.GetGridRequest, src bulkSource, flavor sqlbuilder.Flavor) (*sqlbuilder.SelectBuilder, bool) {
+	source := src.Source
+	if source == "" {
+		source = src.Target
+	}
+
+	sourceIDField := src.SourceIDField
+	if sourceIDField == "" {
+		sourceIDField = src.IDField
+	}
+
+	builder := sqlbuilder.Select(sourceIDField).From(source)
+	builder.SetFlavor(flavor)
+	if src.Build != nil {
+		src.Build(builder)
+	}
+
+	w2sql.Params(builder, req.Extra, src.Params)
+
+	before := builder.String()
+	w2sql.Where(builder, req, src.Where)
+	return builder, builder.String() != before
+}
+
+func countWhere(ctx context.Context, db QueryExecer, target, idField string, selection *sqlbuilder.SelectBuilder, flavor sqlbuilder.Flavor, logger *slog.Logger) (int, error) {
+	builder := sqlbuilder.Select("count(*)").From(target)
+	builder.Where(builder.In(idField, selection))
+	query, args := builder.BuildWithFlavor(flavor)
+
+	var count int
+
+	begin := time.Now()
+	err := db.QueryRowContext(ctx, query, args...).Scan(&count)
+	traceSQL(ctx, logger, begin, query, args, err)
+	if err != nil {
+		return 0, fmt.Errorf("count: %w", err)
+	}
+
+	return count, nil
+}
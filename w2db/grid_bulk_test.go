@@ -0,0 +1,124 @@
+package w2db_test
+
+import (
+	"database/sql"
+	"net/url"
+	"testing"
+
+	"github.com/dv1x3r/w2go/w2"
+	"github.com/dv1x3r/w2go/w2db"
+	"github.com/huandu/go-sqlbuilder"
+	_ "modernc.org/sqlite"
+)
+
+func openTodoDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", "file:/"+url.PathEscape(t.Name())+"?vfs=memdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec(`
+CREATE TABLE todo (id INTEGER PRIMARY KEY, name TEXT, status_id INTEGER);
+INSERT INTO todo (name, status_id) VALUES ('a', 1), ('b', 2), ('c', 2);
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func countTodo(t *testing.T, db *sql.DB, where string) int {
+	t.Helper()
+
+	var count int
+	if err := db.QueryRow("SELECT count(*) FROM todo WHERE " + where).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+
+	return count
+}
+
+func TestBulkWhere(t *testing.T) {
+	where := map[string]string{"name": "name", "status": "status_id"}
+
+	tests := []struct {
+		Name     string
+		Search   []w2.GridSearch
+		Expected int
+		Error    bool
+	}{
+		{Name: "Match", Search: []w2.GridSearch{{Field: "status", Operator: "is", Value: 2}}, Expected: 2},
+		{Name: "NoSearch", Error: true},
+		{Name: "UnknownField", Search: []w2.GridSearch{{Field: "id", Operator: "is", Value: 1}}, Error: true},
+		{Name: "BogusOperator", Search: []w2.GridSearch{{Field: "status", Operator: "bogus", Value: 2}}, Error: true},
+		{Name: "EmptyContains", Search: []w2.GridSearch{{Field: "name", Operator: "contains", Value: ""}}, Error: true},
+		{Name: "MalformedBetween", Search: []w2.GridSearch{{Field: "status", Operator: "between", Value: 1}}, Error: true},
+	}
+
+	for _, test := range tests {
+		t.Run("Update"+test.Name, func(t *testing.T) {
+			db := openTodoDB(t)
+			req := w2.GetGridRequest{Search: test.Search, SearchLogic: "AND"}
+
+			affected, err := w2db.UpdateWhere(db, req, w2db.UpdateWhereOptions{
+				Update:  "todo",
+				IDField: "id",
+				Where:   where,
+				Values:  map[string]any{"name": "x"},
+				Flavor:  sqlbuilder.SQLite,
+			})
+
+			if test.Error && err == nil {
+				t.Errorf("❌ Expected an error, got %d rows affected", affected)
+			} else if !test.Error && (err != nil || affected != test.Expected) {
+				t.Errorf("❌ Expected %d rows affected, got: %d %v", test.Expected, affected, err)
+			}
+
+			if updated := countTodo(t, db, "name = 'x'"); updated != affected {
+				t.Errorf("❌ Expected %d updated rows, got: %d", affected, updated)
+			}
+		})
+
+		t.Run("Remove"+test.Name, func(t *testing.T) {
+			db := openTodoDB(t)
+			req := w2.GetGridRequest{Search: test.Search, SearchLogic: "AND"}
+
+			affected, err := w2db.RemoveWhere(db, req, w2db.RemoveWhereOptions{
+				From:    "todo",
+				IDField: "id",
+				Where:   where,
+				Flavor:  sqlbuilder.SQLite,
+			})
+
+			if test.Error && err == nil {
+				t.Errorf("❌ Expected an error, got %d rows affected", affected)
+			} else if !test.Error && (err != nil || affected != test.Expected) {
+				t.Errorf("❌ Expected %d rows affected, got: %d %v", test.Expected, affected, err)
+			}
+
+			if left := countTodo(t, db, "1 = 1"); left != 3-affected {
+				t.Errorf("❌ Expected %d rows left, got: %d", 3-affected, left)
+			}
+		})
+	}
+
+	t.Run("AllowEmptySearch", func(t *testing.T) {
+		db := openTodoDB(t)
+		req := w2.GetGridRequest{Search: []w2.GridSearch{{Field: "status", Operator: "bogus", Value: 2}}}
+
+		affected, err := w2db.RemoveWhere(db, req, w2db.RemoveWhereOptions{
+			From:             "todo",
+			IDField:          "id",
+			Where:            where,
+			AllowEmptySearch: true,
+			Flavor:           sqlbuilder.SQLite,
+		})
+		if err != nil || affected != 3 {
+			t.Errorf("❌ Expected 3 rows affected, got: %d %v", affected, err)
+		}
+	})
+}
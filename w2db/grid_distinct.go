@@ -0,0 +1,108 @@
+package w2db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dv1x3r/w2go/w2"
+	"github.com/dv1x3r/w2go/w2sql"
+	"github.com/huandu/go-sqlbuilder"
+)
+
+// GetDistinct loads distinct values of a grid field using context.Background.
+func GetDistinct[T any](db QueryExecer, grid w2.GetGridRequest, req w2.GetDropdownRequest, field string, opts GetGridOptions[T]) (w2.GetDropdownResponse[w2.Facet], error) {
+	return GetDistinctContext(context.Background(), db, grid, req, field, opts)
+}
+
+// GetDistinctContext loads the distinct values of field with row counts under
+// the current grid search.
+//
+// field is a client-side field name and must exist in opts.Where. Search rules
+// on field itself are ignored, so the list shows every value the user can
+// still pick for that column. The options are the same as for GetGrid, which
+// lets a handler share one GetGridOptions value, including Params; Select,
+// OrderBy, and Scan are not used. NULL values are skipped, and values are
+// filtered by req.Search and limited to req.Max.
+func GetDistinctContext[T any](ctx context.Context, db QueryExecer, grid w2.GetGridRequest, req w2.GetDropdownRequest, field string, opts GetGridOptions[T]) (w2.GetDropdownResponse[w2.Facet], error) {
+	if opts.From == "" {
+		return w2.GetDropdownResponse[w2.Facet]{}, errors.New("opts.From is required")
+	}
+
+	expr, ok := opts.Where[field]
+	if !ok {
+		return w2.GetDropdownResponse[w2.Facet]{}, fmt.Errorf("field %q is not allowed", field)
+	}
+
+	flavor := opts.Flavor
+	if flavor == 0 {
+		flavor = defaultFlavor
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = defaultLogger
+	}
+
+	search := make([]w2.GridSearch, 0, len(grid.Search))
+	for _, s := range grid.Search {
+		if s.Field != field {
+			search = append(search, s)
+		}
+	}
+	grid.Search = search
+
+	builder := sqlbuilder.Select(expr, "count(*)").From(opts.From)
+	builder.SetFlavor(flavor)
+	if opts.Build != nil {
+		opts.Build(builder)
+	}
+
+	w2sql.Params(builder, grid.Extra, opts.Params)
+	w2sql.Where(builder, grid, opts.Where)
+	builder.Where(builder.IsNotNull(expr))
+
+	if req.Search != "" {
+		if flavor == sqlbuilder.SQLite {
+			expr := sqlbuilder.Buildf("INSTR(LOWER(%v), LOWER(%v)) > 0", sqlbuilder.Raw(expr), req.Search)
+			builder.Where(builder.Var(expr))
+		} else {
+			builder.Where(builder.Like(expr, "%"+req.Search+"%"))
+		}
+	}
+
+	builder.GroupBy(expr)
+	builder.OrderBy(expr)
+	builder.Limit(req.Max)
+	query, args := builder.Build()
+
+	begin := time.Now()
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		traceSQL(ctx, logger, begin, query, args, err)
+		return w2.GetDropdownResponse[w2.Facet]{}, err
+	}
+	defer rows.Close()
+
+	records := []w2.Facet{}
+
+	for rows.Next() {
+		var record w2.Facet
+		if err := rows.Scan(&record.ID, &record.Count); err != nil {
+			traceSQL(ctx, logger, begin, query, args, err)
+			return w2.GetDropdownResponse[w2.Facet]{}, fmt.Errorf("scan: %w", err)
+		}
+		record.ID = scanValue(record.ID)
+		record.Text = fmt.Sprint(record.ID)
+		records = append(records, record)
+	}
+
+	if err := rows.Err(); err != nil {
+		traceSQL(ctx, logger, begin, query, args, err)
+		return w2.GetDropdownResponse[w2.Facet]{}, err
+	}
+
+	traceSQL(ctx, logger, begin, query, args, nil)
+	return w2.NewGetDropdownResponse(records), nil
+}
@@ -30,6 +30,10 @@ type GetGridOptions[T any] struct {
 	// OrderBy maps w2grid sort field names to trusted SQL expressions.
 	OrderBy map[string]string
 
+	// Params maps w2.Extra request keys to trusted SQL expressions or
+	// predicates. See w2sql.Params.
+	Params map[string]string
+
 	// Build customizes the SELECT query, for example by adding joins or fixed filters.
 	Build func(sb *sqlbuilder.SelectBuilder)
 
@@ -90,6 +94,7 @@ func GetGridContext[T any](ctx context.Context, db QueryExecer, req w2.GetGridRe
 		opts.Build(countBuilder)
 	}
 
+	w2sql.Params(countBuilder, req.Extra, opts.Params)
 	w2sql.Where(countBuilder, req, opts.Where)
 	query, args := countBuilder.Build()
 
@@ -103,46 +108,59 @@ func GetGridContext[T any](ctx context.Context, db QueryExecer, req w2.GetGridRe
 		return w2.GetGridResponse[T]{}, err
 	}
 
+	capacity := total
+	if req.Limit > 0 {
+		capacity = min(total, req.Limit)
+	}
+
+	records, err = selectGridRecords(ctx, db, req, opts, flavor, logger, capacity)
+	if err != nil {
+		return w2.GetGridResponse[T]{}, err
+	}
+
+	return w2.NewGetGridResponse(records, total), nil
+}
+
+// selectGridRecords runs the data query of GetGridContext without the count
+// query and returns the scanned records.
+func selectGridRecords[T any](ctx context.Context, db QueryExecer, req w2.GetGridRequest, opts GetGridOptions[T], flavor sqlbuilder.Flavor, logger *slog.Logger, capacity int) ([]T, error) {
 	dataBuilder := sqlbuilder.Select(opts.Select...).From(opts.From)
 	dataBuilder.SetFlavor(flavor)
 	if opts.Build != nil {
 		opts.Build(dataBuilder)
 	}
 
+	w2sql.Params(dataBuilder, req.Extra, opts.Params)
 	w2sql.Where(dataBuilder, req, opts.Where)
 	w2sql.OrderBy(dataBuilder, req, opts.OrderBy)
 	w2sql.Limit(dataBuilder, req)
 	w2sql.Offset(dataBuilder, req)
-	query, args = dataBuilder.Build()
+	query, args := dataBuilder.Build()
 
-	begin = time.Now()
+	begin := time.Now()
 	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		traceSQL(ctx, logger, begin, query, args, err)
-		return w2.GetGridResponse[T]{}, err
+		return nil, err
 	}
 	defer rows.Close()
 
-	capacity := total
-	if req.Limit > 0 {
-		capacity = min(total, req.Limit)
-	}
-	records = make([]T, 0, capacity)
+	records := make([]T, 0, capacity)
 
 	for rows.Next() {
 		var record T
 		if err := opts.Scan(rows, &record); err != nil {
 			traceSQL(ctx, logger, begin, query, args, err)
-			return w2.GetGridResponse[T]{}, fmt.Errorf("scan: %w", err)
+			return nil, fmt.Errorf("scan: %w", err)
 		}
 		records = append(records, record)
 	}
 
 	if err := rows.Err(); err != nil {
 		traceSQL(ctx, logger, begin, query, args, err)
-		return w2.GetGridResponse[T]{}, err
+		return nil, err
 	}
 
 	traceSQL(ctx, logger, begin, query, args, nil)
-	return w2.NewGetGridResponse(records, total), nil
+	return records, nil
 }
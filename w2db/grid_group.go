@@ -0,0 +1,302 @@
+package w2db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/dv1x3r/w2go/w2"
+	"github.com/dv1x3r/w2go/w2sql"
+	"github.com/huandu/go-sqlbuilder"
+)
+
+// GetGroupedGridOptions configures GetGroupedGrid, GetGroupedGridContext,
+// GetGroupChildren, and GetGroupChildrenContext.
+type GetGroupedGridOptions[T any] struct {
+	// From is the table, view, or join expression used in the FROM clause.
+	From string
+
+	// Select lists the SQL expressions returned for each detail row.
+	Select []string
+
+	// GroupBy maps w2grid group field names to trusted SQL expressions.
+	GroupBy map[string]string
+
+	// Group is the client-side field name to group by. It must exist in GroupBy.
+	Group string
+
+	// Subtotals maps w2grid field names to trusted aggregate expressions, for
+	// example "quantity": "sum(t.quantity)". The values are written to the
+	// group header rows.
+	Subtotals map[string]string
+
+	// RecIDField is the w2grid recid field name used for group header rows.
+	// It defaults to "recid".
+	RecIDField string
+
+	// Lazy skips loading detail rows. Use GetGroupChildren to load the rows
+	// of one group when the client expands it.
+	Lazy bool
+
+	// Where maps w2grid search field names to trusted SQL expressions.
+	Where map[string]string
+
+	// OrderBy maps w2grid sort field names to trusted SQL expressions for detail rows.
+	OrderBy map[string]string
+
+	// Params maps w2.Extra request keys to trusted SQL expressions or
+	// predicates. See w2sql.Params.
+	Params map[string]string
+
+	// Build customizes the SELECT queries, for example by adding joins or fixed filters.
+	Build func(sb *sqlbuilder.SelectBuilder)
+
+	// Scan copies the current detail row into record.
+	Scan func(rows *sql.Rows, record *T) error
+
+	// Flavor overrides the package default SQL dialect when non-zero.
+	Flavor sqlbuilder.Flavor
+
+	// Logger overrides the package default SQL logger when non-nil.
+	Logger *slog.Logger
+}
+
+// GetGroupedGrid loads grouped records for a w2grid request using context.Background.
+func GetGroupedGrid[T any](db QueryExecer, req w2.GetGridRequest, opts GetGroupedGridOptions[T]) (w2.GetGridResponse[w2.GridGroup[T]], error) {
+	return GetGroupedGridContext(context.Background(), db, req, opts)
+}
+
+// GetGroupedGridContext loads a filtered, sorted, and paginated page of group
+// header rows with counts and subtotals.
+//
+// Pagination and the response total count groups rather than detail rows.
+// Group rows can be sorted by the group field, by any Subtotals field, or by
+// w2.GroupCountField. Unless opts.Lazy is set, the detail rows of each group
+// on the page are loaded with GetGroupChildren semantics and nested as w2ui
+// children. That is one query per group, without a count query, because the
+// group row already holds the count.
+func GetGroupedGridContext[T any](ctx context.Context, db QueryExecer, req w2.GetGridRequest, opts GetGroupedGridOptions[T]) (w2.GetGridResponse[w2.GridGroup[T]], error) {
+	if opts.From == "" {
+		return w2.GetGridResponse[w2.GridGroup[T]]{}, errors.New("opts.From is required")
+	}
+
+	if !opts.Lazy && len(opts.Select) == 0 {
+		return w2.GetGridResponse[w2.GridGroup[T]]{}, errors.New("opts.Select is required")
+	}
+
+	if !opts.Lazy && opts.Scan == nil {
+		return w2.GetGridResponse[w2.GridGroup[T]]{}, errors.New("opts.Scan is required")
+	}
+
+	groupExpr, err := opts.groupExpr()
+	if err != nil {
+		return w2.GetGridResponse[w2.GridGroup[T]]{}, err
+	}
+
+	flavor := opts.Flavor
+	if flavor == 0 {
+		flavor = defaultFlavor
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = defaultLogger
+	}
+
+	var total int
+	var records []w2.GridGroup[T]
+
+	innerBuilder := sqlbuilder.Select(groupExpr).From(opts.From)
+	innerBuilder.SetFlavor(flavor)
+	if opts.Build != nil {
+		opts.Build(innerBuilder)
+	}
+
+	w2sql.Params(innerBuilder, req.Extra, opts.Params)
+	w2sql.Where(innerBuilder, req, opts.Where)
+	innerBuilder.GroupBy(groupExpr)
+
+	countBuilder := sqlbuilder.Select("count(*)")
+	countBuilder.From(countBuilder.BuilderAs(innerBuilder, "g"))
+	query, args := countBuilder.BuildWithFlavor(flavor)
+
+	begin := time.Now()
+	row := db.QueryRowContext(ctx, query, args...)
+	err = row.Scan(&total)
+	traceSQL(ctx, logger, begin, query, args, err)
+	if errors.Is(err, sql.ErrNoRows) {
+		return w2.NewGetGridResponse(records, 0), nil
+	} else if err != nil {
+		return w2.GetGridResponse[w2.GridGroup[T]]{}, err
+	}
+
+	subtotalFields := slices.Sorted(maps.Keys(opts.Subtotals))
+
+	columns := make([]string, 0, len(subtotalFields)+2)
+	columns = append(columns, groupExpr, "count(*)")
+	for _, field := range subtotalFields {
+		columns = append(columns, opts.Subtotals[field])
+	}
+
+	orderBy := map[string]string{opts.Group: groupExpr, w2.GroupCountField: "count(*)"}
+	for _, field := range subtotalFields {
+		orderBy[field] = opts.Subtotals[field]
+	}
+
+	groupBuilder := sqlbuilder.Select(columns...).From(opts.From)
+	groupBuilder.SetFlavor(flavor)
+	if opts.Build != nil {
+		opts.Build(groupBuilder)
+	}
+
+	w2sql.Params(groupBuilder, req.Extra, opts.Params)
+	w2sql.Where(groupBuilder, req, opts.Where)
+	groupBuilder.GroupBy(groupExpr)
+	w2sql.OrderBy(groupBuilder, req, orderBy)
+	groupBuilder.OrderByAsc(groupExpr)
+	w2sql.Limit(groupBuilder, req)
+	w2sql.Offset(groupBuilder, req)
+	query, args = groupBuilder.Build()
+
+	begin = time.Now()
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		traceSQL(ctx, logger, begin, query, args, err)
+		return w2.GetGridResponse[w2.GridGroup[T]]{}, err
+	}
+	defer rows.Close()
+
+	capacity := total
+	if req.Limit > 0 {
+		capacity = min(total, req.Limit)
+	}
+	records = make([]w2.GridGroup[T], 0, capacity)
+
+	for rows.Next() {
+		values := make([]any, len(subtotalFields))
+		dest := make([]any, 0, len(columns))
+
+		group := w2.GridGroup[T]{
+			RecIDField: opts.RecIDField,
+			Field:      opts.Group,
+			Subtotals:  make(map[string]any, len(subtotalFields)),
+		}
+
+		dest = append(dest, &group.Key, &group.Count)
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+
+		if err := rows.Scan(dest...); err != nil {
+			traceSQL(ctx, logger, begin, query, args, err)
+			return w2.GetGridResponse[w2.GridGroup[T]]{}, fmt.Errorf("scan: %w", err)
+		}
+
+		group.Key = scanValue(group.Key)
+		for i, field := range subtotalFields {
+			group.Subtotals[field] = scanValue(values[i])
+		}
+
+		records = append(records, group)
+	}
+
+	if err := rows.Err(); err != nil {
+		traceSQL(ctx, logger, begin, query, args, err)
+		return w2.GetGridResponse[w2.GridGroup[T]]{}, err
+	}
+
+	traceSQL(ctx, logger, begin, query, args, nil)
+
+	if opts.Lazy {
+		return w2.NewGetGridResponse(records, total), nil
+	}
+
+	// rows must be released before running the detail queries,
+	// because a single-connection pool would otherwise deadlock
+	rows.Close()
+
+	childReq := req
+	childReq.Limit = 0
+	childReq.Offset = 0
+
+	// group.Count already holds the number of detail rows, so each group only
+	// runs its data query and not the count query of GetGridContext
+	for i := range records {
+		children, err := selectGridRecords(ctx, db, childReq, opts.childOptions(groupExpr, records[i].Key), flavor, logger, records[i].Count)
+		if err != nil {
+			return w2.GetGridResponse[w2.GridGroup[T]]{}, fmt.Errorf("group [%v]: %w", records[i].Key, err)
+		}
+		records[i].Children = children
+	}
+
+	return w2.NewGetGridResponse(records, total), nil
+}
+
+// GetGroupChildren loads the detail rows of one group using context.Background.
+func GetGroupChildren[T any](db QueryExecer, req w2.GetGridRequest, key any, opts GetGroupedGridOptions[T]) (w2.GetGridResponse[T], error) {
+	return GetGroupChildrenContext(context.Background(), db, req, key, opts)
+}
+
+// GetGroupChildrenContext loads the filtered, sorted, and paginated detail
+// rows whose group value equals key.
+//
+// It is intended for lazy grouped grids, where the client requests the rows
+// of a group when it is expanded. A nil key matches rows with a NULL group value.
+func GetGroupChildrenContext[T any](ctx context.Context, db QueryExecer, req w2.GetGridRequest, key any, opts GetGroupedGridOptions[T]) (w2.GetGridResponse[T], error) {
+	groupExpr, err := opts.groupExpr()
+	if err != nil {
+		return w2.GetGridResponse[T]{}, err
+	}
+
+	return GetGridContext(ctx, db, req, opts.childOptions(groupExpr, key))
+}
+
+func (opts GetGroupedGridOptions[T]) groupExpr() (string, error) {
+	if opts.Group == "" {
+		return "", errors.New("opts.Group is required")
+	}
+
+	expr, ok := opts.GroupBy[opts.Group]
+	if !ok {
+		return "", fmt.Errorf("group field %q is not allowed", opts.Group)
+	}
+
+	return expr, nil
+}
+
+func (opts GetGroupedGridOptions[T]) childOptions(groupExpr string, key any) GetGridOptions[T] {
+	return GetGridOptions[T]{
+		From:    opts.From,
+		Select:  opts.Select,
+		Where:   opts.Where,
+		OrderBy: opts.OrderBy,
+		Params:  opts.Params,
+		Build: func(sb *sqlbuilder.SelectBuilder) {
+			if opts.Build != nil {
+				opts.Build(sb)
+			}
+			if key == nil {
+				sb.Where(sb.IsNull(groupExpr))
+			} else {
+				sb.Where(sb.EQ(groupExpr, key))
+			}
+		},
+		Scan:   opts.Scan,
+		Flavor: opts.Flavor,
+		Logger: opts.Logger,
+	}
+}
+
+// scanValue converts driver byte slices to strings so scanned values
+// encode as JSON text and compare correctly as query arguments.
+func scanValue(value any) any {
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+	return value
+}
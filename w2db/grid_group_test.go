@@ -0,0 +1,111 @@
+package w2db_test
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/dv1x3r/w2go/w2"
+	"github.com/dv1x3r/w2go/w2db"
+	"github.com/huandu/go-sqlbuilder"
+)
+
+type todoRow struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestGetGroupedGrid(t *testing.T) {
+	var trace bytes.Buffer
+
+	opts := w2db.GetGroupedGridOptions[todoRow]{
+		From:       "todo",
+		Select:     []string{"id", "name"},
+		GroupBy:    map[string]string{"status": "status_id"},
+		Group:      "status",
+		Subtotals:  map[string]string{"ids": "sum(id)"},
+		RecIDField: "id",
+		Where:      map[string]string{"name": "name"},
+		OrderBy:    map[string]string{"name": "name"},
+		Scan: func(rows *sql.Rows, record *todoRow) error {
+			return rows.Scan(&record.ID, &record.Name)
+		},
+		Flavor: sqlbuilder.SQLite,
+		Logger: slog.New(slog.NewTextHandler(&trace, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	}
+
+	tests := []struct {
+		Name         string
+		Request      w2.GetGridRequest
+		Lazy         bool
+		ExpectedJSON string
+		Queries      int
+	}{
+		{
+			Name:         "Page",
+			Request:      w2.GetGridRequest{Limit: 1, Offset: 1},
+			ExpectedJSON: `{"status":"success","records":[{"id":"group:2","ids":5,"status":2,"w2count":2,"w2ui":{"children":[{"id":2,"name":"b"},{"id":3,"name":"c"}]}}],"total":2}`,
+			Queries:      3,
+		},
+		{
+			Name:         "SortByCount",
+			Request:      w2.GetGridRequest{Sort: []w2.GridSort{{Field: w2.GroupCountField, Direction: "asc"}, {Field: "name", Direction: "desc"}}},
+			ExpectedJSON: `{"status":"success","records":[{"id":"group:1","ids":1,"status":1,"w2count":1,"w2ui":{"children":[{"id":1,"name":"a"}]}},{"id":"group:2","ids":5,"status":2,"w2count":2,"w2ui":{"children":[{"id":3,"name":"c"},{"id":2,"name":"b"}]}}],"total":2}`,
+			Queries:      4,
+		},
+		{
+			Name:         "Search",
+			Request:      w2.GetGridRequest{Search: []w2.GridSearch{{Field: "name", Operator: "is", Value: "c"}}, SearchLogic: "AND"},
+			ExpectedJSON: `{"status":"success","records":[{"id":"group:2","ids":3,"status":2,"w2count":1,"w2ui":{"children":[{"id":3,"name":"c"}]}}],"total":1}`,
+			Queries:      3,
+		},
+		{
+			Name:         "Lazy",
+			Request:      w2.GetGridRequest{Limit: 1},
+			Lazy:         true,
+			ExpectedJSON: `{"status":"success","records":[{"id":"group:1","ids":1,"status":1,"w2count":1,"w2ui":{"children":[]}}],"total":2}`,
+			Queries:      2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			db := openTodoDB(t)
+			trace.Reset()
+
+			opts := opts
+			opts.Lazy = test.Lazy
+
+			res, err := w2db.GetGroupedGrid(db, test.Request, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			output, err := json.Marshal(res)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(output) != test.ExpectedJSON {
+				t.Errorf("❌ Unexpected output JSON:\n  got:  %s\n  want: %s", output, test.ExpectedJSON)
+			}
+
+			if queries := strings.Count(trace.String(), "msg=w2db"); queries != test.Queries {
+				t.Errorf("❌ Expected %d queries, got: %d\n%s", test.Queries, queries, trace.String())
+			}
+		})
+	}
+
+	t.Run("Children", func(t *testing.T) {
+		db := openTodoDB(t)
+		req := w2.GetGridRequest{Limit: 1, Offset: 1, Sort: []w2.GridSort{{Field: "name", Direction: "asc"}}}
+
+		res, err := w2db.GetGroupChildren(db, req, 2, opts)
+		if err != nil || res.Total != 2 || len(res.Records) != 1 || res.Records[0].Name != "c" {
+			t.Errorf("❌ Expected row c of 2, got: %+v %v", res, err)
+		}
+	})
+}
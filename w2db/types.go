@@ -20,3 +20,20 @@ type QueryExecer interface {
 type Providable interface {
 	IsProvided() bool
 }
+
+// isProvided reports whether Update writes value, which skips Providable
+// values that were not provided.
+func isProvided(value any) bool {
+	p, ok := value.(Providable)
+	return !ok || p.IsProvided()
+}
+
+// hasProvided reports whether Update writes any of values.
+func hasProvided(values map[string]any) bool {
+	for _, value := range values {
+		if isProvided(value) {
+			return true
+		}
+	}
+	return false
+}
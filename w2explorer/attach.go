@@ -0,0 +1,189 @@
+package w2explorer
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"maps"
+	"sync"
+)
+
+// attachConnector wraps a driver.Connector so every pooled connection has
+// the same databases attached.
+//
+// SQLite attaches databases per connection. Connections opened after an
+// Attach or Detach are set up in Connect, and pooled connections catch up
+// in ResetSession before they are reused.
+type attachConnector struct {
+	base driver.Connector
+
+	mu         sync.Mutex
+	attached   map[string]string
+	generation int
+}
+
+// dsnConnector is the driver.Connector of a driver without DriverContext.
+type dsnConnector struct {
+	driver driver.Driver
+	dsn    string
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) { return c.driver.Open(c.dsn) }
+func (c dsnConnector) Driver() driver.Driver                        { return c.driver }
+
+func newAttachConnector(d driver.Driver, dsn string) (*attachConnector, error) {
+	var base driver.Connector = dsnConnector{driver: d, dsn: dsn}
+	if dc, ok := d.(driver.DriverContext); ok {
+		var err error
+		if base, err = dc.OpenConnector(dsn); err != nil {
+			return nil, err
+		}
+	}
+
+	return &attachConnector{base: base, attached: map[string]string{}}, nil
+}
+
+// set changes the attached databases and returns the previous ones.
+func (c *attachConnector) set(attached map[string]string) map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	previous := c.attached
+	c.attached = attached
+	c.generation++
+	return previous
+}
+
+// snapshot returns a copy of the attached databases and their generation.
+func (c *attachConnector) snapshot() (map[string]string, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return maps.Clone(c.attached), c.generation
+}
+
+func (c *attachConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.base.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ac := &attachConn{Conn: conn, connector: c, generation: -1, attached: map[string]string{}}
+	if err := ac.sync(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return ac, nil
+}
+
+func (c *attachConnector) Driver() driver.Driver { return c.base.Driver() }
+
+// attachConn is a driver.Conn that attaches the databases of its connector.
+// It forwards the optional driver interfaces of the wrapped connection.
+type attachConn struct {
+	driver.Conn
+	connector  *attachConnector
+	generation int
+	attached   map[string]string
+}
+
+// sync attaches and detaches databases until the connection matches its connector.
+func (c *attachConn) sync(ctx context.Context) error {
+	want, generation := c.connector.snapshot()
+	if generation == c.generation {
+		return nil
+	}
+
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return errors.New("w2explorer: driver does not support ExecerContext")
+	}
+
+	for alias, file := range c.attached {
+		if want[alias] != file {
+			if _, err := execer.ExecContext(ctx, "DETACH DATABASE "+quoteIdent(alias), nil); err != nil {
+				return err
+			}
+			delete(c.attached, alias)
+		}
+	}
+
+	for alias, file := range want {
+		if _, ok := c.attached[alias]; !ok {
+			args := []driver.NamedValue{{Ordinal: 1, Value: file}}
+			if _, err := execer.ExecContext(ctx, "ATTACH DATABASE ? AS "+quoteIdent(alias), args); err != nil {
+				return err
+			}
+			c.attached[alias] = file
+		}
+	}
+
+	c.generation = generation
+	return nil
+}
+
+// ResetSession runs before a pooled connection is reused. A connection that
+// cannot attach its databases is discarded, and the error surfaces from the
+// new connection that replaces it.
+func (c *attachConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		if err := resetter.ResetSession(ctx); err != nil {
+			return err
+		}
+	}
+
+	if err := c.sync(ctx); err != nil {
+		return driver.ErrBadConn
+	}
+
+	return nil
+}
+
+func (c *attachConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *attachConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *attachConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *attachConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *attachConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if execer, ok := c.Conn.(driver.ExecerContext); ok {
+		return execer.ExecContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *attachConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if queryer, ok := c.Conn.(driver.QueryerContext); ok {
+		return queryer.QueryContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *attachConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
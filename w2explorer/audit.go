@@ -0,0 +1,433 @@
+package w2explorer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/huandu/go-sqlbuilder"
+)
+
+// ErrAccessDenied is returned when SQLExecOptions.Authorize rejects a query.
+var ErrAccessDenied = errors.New("access denied")
+
+// authorize runs opts.Authorize for req and wraps its error with ErrAccessDenied.
+func (opts SQLExecOptions) authorize(ctx context.Context, req SQLExecRequest) error {
+	if opts.Authorize == nil {
+		return nil
+	}
+
+	if err := opts.Authorize(ctx, req); err != nil {
+		if errors.Is(err, ErrAccessDenied) {
+			return err
+		}
+		return fmt.Errorf("%w: %w", ErrAccessDenied, err)
+	}
+
+	return nil
+}
+
+// Audit actions.
+const (
+	AuditExec    = "exec"
+	AuditBlob    = "blob"
+	AuditSave    = "save"
+	AuditExport  = "export"
+	AuditExplain = "explain"
+
+	// AuditBrowse records the reads of a TableBrowser, and AuditBrowseWrite
+	// its inserts and deletes.
+	AuditBrowse      = "browse"
+	AuditBrowseWrite = "browse-write"
+
+	// AuditBackup and AuditRestore record SQLiteBackup and SQLiteRestore.
+	AuditBackup  = "backup"
+	AuditRestore = "restore"
+)
+
+// AuditRecord is one audited SQL explorer execution.
+type AuditRecord struct {
+	// User is the user set by WithUser.
+	User string `json:"user"`
+
+	// Source is the data source set by WithSource.
+	Source string `json:"source"`
+
+	// Action is AuditExec for scripts, AuditBlob for cell downloads,
+	// AuditSave for saved result edits, AuditExport for exports,
+	// AuditExplain for query plans, AuditBrowse or AuditBrowseWrite for the table browser, and
+	// AuditBackup or AuditRestore for backups and restores.
+	Action string `json:"action"`
+
+	// Query is the executed SQL text. Saved edits record their UPDATE statements.
+	Query string `json:"query"`
+
+	// Params holds the parameter values of the query.
+	Params []SQLParam `json:"params"`
+
+	// Elapsed is the execution time in seconds.
+	Elapsed float64 `json:"elapsed"`
+
+	// Rows is the number of rows returned plus the number of rows affected.
+	Rows int64 `json:"rows"`
+
+	// Error is the error message of a failed or rejected execution.
+	Error string `json:"error,omitempty"`
+}
+
+// RedactRules decide which literals of an audited query and which parameter
+// values are replaced by "***".
+type RedactRules struct {
+	// Literals match string literals and parameter values that look like
+	// secrets, such as tokens and private keys.
+	Literals []*regexp.Regexp
+
+	// Names match column and parameter names that hold secrets. A string
+	// literal compared with, assigned to, or inserted into such a column is
+	// redacted, as in "password = 'hunter2'".
+	Names *regexp.Regexp
+}
+
+// DefaultRedactRules redact common token and key formats, credentials in
+// URLs, and the values of password, secret, token, and key columns.
+var DefaultRedactRules = RedactRules{
+	Literals: []*regexp.Regexp{
+		regexp.MustCompile(`(?i)^bearer\s+\S+`),
+		regexp.MustCompile(`^eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]*$`),
+		regexp.MustCompile(`^(AKIA|ASIA)[0-9A-Z]{16}$`),
+		regexp.MustCompile(`^gh[pousr]_[A-Za-z0-9]{30,}$`),
+		regexp.MustCompile(`^(sk|pk|rk)_(live|test)_[A-Za-z0-9]{10,}$`),
+		regexp.MustCompile(`^xox[abposr]-[A-Za-z0-9-]{10,}$`),
+		regexp.MustCompile(`^\$2[aby]\$\d\d\$[./A-Za-z0-9]{53}$`),
+		regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`),
+		regexp.MustCompile(`[A-Za-z][A-Za-z0-9+.-]*://[^/\s:@]+:[^/\s@]+@`),
+	},
+	Names: regexp.MustCompile(`(?i)passw(or)?d|pwd|secret|token|api_?key|access_?key|private_?key|credential`),
+}
+
+const redacted = "***"
+
+func (rules RedactRules) isSecret(value string) bool {
+	for _, pattern := range rules.Literals {
+		if pattern.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rules RedactRules) isSecretName(name string) bool {
+	return rules.Names != nil && rules.Names.MatchString(name)
+}
+
+// Query returns query with the string literals that match the rules
+// replaced by '***'. Double-quoted strings, which SQLite reads as literals
+// when they name no column, are replaced by "***" the same way.
+func (rules RedactRules) Query(query string) string {
+	tokens := tokenize(query)
+	inserted := rules.secretInsertValues(tokens)
+
+	var sb strings.Builder
+	for i, t := range tokens {
+		if quote := t.Text[0]; t.Kind == tokenString && quote == '\'' || t.Kind == tokenQuoted && quote == '"' {
+			value := strings.ReplaceAll(strings.TrimSuffix(t.Text[1:], string(quote)), string(quote)+string(quote), string(quote))
+			if rules.isSecret(value) || inserted[i] || rules.isSecretColumn(tokens, i) {
+				sb.WriteString(string(quote) + redacted + string(quote))
+				continue
+			}
+		}
+		sb.WriteString(t.Text)
+	}
+
+	return sb.String()
+}
+
+// Params returns a copy of the params of query with the values replaced by
+// "***" when they match Literals, when their names match Names, or when
+// query compares them with, assigns them to, or inserts them into a secret
+// column.
+func (rules RedactRules) Params(query string, params []SQLParam) []SQLParam {
+	tokens := tokenize(query)
+	inserted := rules.secretInsertValues(tokens)
+	secret := map[string]bool{}
+	for i, t := range tokens {
+		if t.Kind == tokenParam && t.Text != "?" && (inserted[i] || rules.isSecretColumn(tokens, i)) {
+			secret[t.Text] = true
+		}
+	}
+
+	out := make([]SQLParam, len(params))
+	for i, param := range params {
+		out[i] = param
+		text, _ := param.Value.(string)
+		if param.Value != nil && (secret[param.Name] || rules.isSecretName(strings.TrimLeft(param.Name, ":@$?")) || rules.isSecret(text)) {
+			out[i].Value = redacted
+		}
+	}
+	return out
+}
+
+// isSecretColumn reports whether the token at i is compared with, matched
+// against, or assigned to a column whose name matches Names, on either side,
+// or is an item of an IN list of such a column.
+func (rules RedactRules) isSecretColumn(tokens []token, i int) bool {
+	// significant returns the index of the nearest token from i in the
+	// direction of step that is not a space or comment, or -1
+	significant := func(i, step int) int {
+		for i += step; i >= 0 && i < len(tokens); i += step {
+			if tokens[i].Kind != tokenSpace && tokens[i].Kind != tokenComment {
+				return i
+			}
+		}
+		return -1
+	}
+
+	isSecretName := func(name int) bool {
+		return name >= 0 && (tokens[name].Kind == tokenWord || tokens[name].Kind == tokenQuoted) && rules.isSecretName(unquoteIdent(tokens[name].Text))
+	}
+
+	// comparisons such as "password = 'x'", "password NOT LIKE 'x'", or "password IS NOT 'x'"
+	isOperator := func(t token) bool {
+		return slices.Contains([]string{"=", "==", "!=", "<>"}, t.Text) || t.is("LIKE") || t.is("GLOB") || t.is("IS") || t.is("NOT")
+	}
+
+	for _, step := range []int{-1, 1} {
+		name, ops := significant(i, step), 0
+		for ; name >= 0 && ops < 2 && isOperator(tokens[name]); ops++ {
+			name = significant(name, step)
+		}
+		if ops > 0 && isSecretName(name) {
+			return true
+		}
+	}
+
+	// IN lists such as "password IN ('x', 'y')" or "password NOT IN ('x')"
+	item := i
+	for {
+		prev := significant(item, -1)
+		if prev < 0 {
+			return false
+		}
+
+		if kind := tokens[prev].Kind; kind == tokenString || kind == tokenQuoted || kind == tokenNumber || kind == tokenParam || tokens[prev].Text == "," {
+			item = prev
+			continue
+		}
+
+		if tokens[prev].Text != "(" {
+			return false
+		}
+
+		in := significant(prev, -1)
+		if in < 0 || !tokens[in].is("IN") {
+			return false
+		}
+
+		name := significant(in, -1)
+		if name >= 0 && tokens[name].is("NOT") {
+			name = significant(name, -1)
+		}
+		return isSecretName(name)
+	}
+}
+
+// secretInsertValues returns the indexes of the tokens that the VALUES of an
+// INSERT statement with a column list write to columns matching Names.
+// Values nested in expressions are not tracked.
+func (rules RedactRules) secretInsertValues(tokens []token) map[int]bool {
+	const (
+		none = iota
+		insert
+		columnList
+		afterColumns
+		values
+	)
+
+	secret := map[int]bool{}
+	var columns []string
+	state, depth, column := none, 0, 0
+
+	for i, t := range tokens {
+		if t.Kind == tokenSpace || t.Kind == tokenComment {
+			continue
+		}
+
+		switch {
+		case t.Text == ";":
+			state = none
+		case state == none && (t.is("INSERT") || t.is("REPLACE")):
+			state, columns = insert, nil
+		case state == insert && t.Text == "(":
+			state = columnList
+		case state == insert && (t.is("VALUES") || t.is("SELECT") || t.is("DEFAULT")):
+			state = none
+		case state == columnList && t.Text == ")":
+			state = afterColumns
+		case state == columnList && (t.Kind == tokenWord || t.Kind == tokenQuoted):
+			columns = append(columns, unquoteIdent(t.Text))
+		case state == afterColumns && t.is("VALUES"):
+			state, depth = values, 0
+		case state == afterColumns:
+			state = none
+		case state == values && t.Text == "(":
+			if depth++; depth == 1 {
+				column = 0
+			}
+		case state == values && t.Text == ")":
+			depth--
+		case state == values && depth == 1 && t.Text == ",":
+			column++
+		case state == values && depth == 0 && t.Text != ",":
+			// ON CONFLICT or RETURNING
+			state = none
+		case state == values && depth == 1 && column < len(columns) && rules.isSecretName(columns[column]):
+			secret[i] = true
+		}
+	}
+
+	return secret
+}
+
+// unquoteIdent returns the name of a quoted or bare SQLite identifier.
+func unquoteIdent(ident string) string {
+	if len(ident) >= 2 {
+		switch ident[0] {
+		case '"', '`':
+			return strings.ReplaceAll(ident[1:len(ident)-1], ident[:1]+ident[:1], ident[:1])
+		case '[':
+			return ident[1 : len(ident)-1]
+		}
+	}
+	return ident
+}
+
+// AuditLogOptions configures NewAuditLog.
+type AuditLogOptions struct {
+	// Logger receives every record at info level. It defaults to slog.Default().
+	Logger *slog.Logger
+
+	// DB also stores the records in Table when non-nil.
+	DB *sql.DB
+
+	// Table is the trusted name of the audit table. It defaults to "w2explorer_audit".
+	Table string
+
+	// Redact overrides DefaultRedactRules. Use an empty RedactRules to keep
+	// queries and parameters as they are.
+	Redact *RedactRules
+}
+
+// AuditLog records who ran which SQL explorer query. Set it as
+// SQLExecOptions.Audit to record every execution, including rejected ones.
+//
+// Secrets are redacted before a record is logged or stored.
+type AuditLog struct {
+	opts AuditLogOptions
+}
+
+// NewAuditLog returns an audit log. Call Init to create its table when
+// opts.DB is set.
+func NewAuditLog(opts AuditLogOptions) *AuditLog {
+	if opts.Table == "" {
+		opts.Table = "w2explorer_audit"
+	}
+
+	if opts.Redact == nil {
+		opts.Redact = &DefaultRedactRules
+	}
+
+	return &AuditLog{opts: opts}
+}
+
+// Init creates the audit table when it does not exist. It does nothing
+// without a database.
+func (a *AuditLog) Init(ctx context.Context) error {
+	if a.opts.DB == nil {
+		return nil
+	}
+
+	query := fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %[1]s (
+  id INTEGER PRIMARY KEY,
+  user TEXT NOT NULL DEFAULT '',
+  source TEXT NOT NULL DEFAULT '',
+  action TEXT NOT NULL,
+  query TEXT NOT NULL,
+  params TEXT NOT NULL DEFAULT '[]',
+  elapsed REAL NOT NULL DEFAULT 0,
+  rows INTEGER NOT NULL DEFAULT 0,
+  error TEXT NOT NULL DEFAULT '',
+  created_at TEXT NOT NULL DEFAULT (%[2]s)
+);
+CREATE INDEX IF NOT EXISTS %[1]s_user_idx ON %[1]s (user, id);
+`, a.opts.Table, storeTimestamp)
+
+	_, err := a.opts.DB.ExecContext(ctx, query)
+	return err
+}
+
+// Record redacts record, logs it, and stores it in the audit table.
+func (a *AuditLog) Record(ctx context.Context, record AuditRecord) error {
+	record.Params = a.opts.Redact.Params(record.Query, record.Params)
+	record.Query = a.opts.Redact.Query(record.Query)
+
+	logger := a.opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	logger.InfoContext(ctx, "w2explorer: audit",
+		"user", record.User,
+		"source", record.Source,
+		"action", record.Action,
+		"query", record.Query,
+		"params", record.Params,
+		"elapsed", record.Elapsed,
+		"rows", record.Rows,
+		"error", record.Error,
+	)
+
+	if a.opts.DB == nil {
+		return nil
+	}
+
+	params, err := json.Marshal(record.Params)
+	if err != nil {
+		return err
+	}
+
+	builder := sqlbuilder.InsertInto(a.opts.Table)
+	builder.Cols("user", "source", "action", "query", "params", "elapsed", "rows", "error")
+	builder.Values(record.User, record.Source, record.Action, record.Query, string(params), record.Elapsed, record.Rows, record.Error)
+	query, args := builder.BuildWithFlavor(sqlbuilder.SQLite)
+
+	_, err = a.opts.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
+// audit records one execution in opts.Audit with the user and source of ctx.
+// A failure to record it is logged and does not fail the request.
+func (opts SQLExecOptions) audit(ctx context.Context, record AuditRecord, err error) {
+	if opts.Audit == nil {
+		return
+	}
+
+	record.User = UserFromContext(ctx)
+	record.Source = SourceFromContext(ctx)
+	if record.Params == nil {
+		record.Params = []SQLParam{}
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	if err := opts.Audit.Record(context.WithoutCancel(ctx), record); err != nil {
+		slog.ErrorContext(ctx, "w2explorer: record audit", "error", err)
+	}
+}
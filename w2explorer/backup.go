@@ -0,0 +1,578 @@
+package w2explorer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dv1x3r/w2go/w2"
+	"github.com/dv1x3r/w2go/w2db"
+	"github.com/dv1x3r/w2go/w2file"
+)
+
+// BackupOptions configures SQLite backups and restores.
+type BackupOptions struct {
+	// TempDir is the directory where backups and uploaded files are staged.
+	// It defaults to os.TempDir().
+	TempDir string
+
+	// AllowRestore enables SQLiteRestore and the restore handlers, which
+	// replace every table, view, index, and trigger of the main database.
+	AllowRestore bool
+
+	// MaxRestoreSize is the maximum size of an uploaded database file.
+	// It defaults to the w2file limit of 32 MiB.
+	MaxRestoreSize int64
+
+	// MaxRestoreFileSize is the maximum size of the database file to restore
+	// after a gzip upload is decompressed. It defaults to 1 GiB.
+	MaxRestoreFileSize int64
+
+	// Exec holds the Authorize hook and the Audit log of backups and
+	// restores. Authorize sees a backup as the statement
+	// `VACUUM "main" INTO ?` of its database and a restore as the comment
+	// "-- restore main". Exec.ReadOnly disables restoring.
+	Exec SQLExecOptions
+}
+
+// defaultMaxRestoreFileSize is the default BackupOptions.MaxRestoreFileSize.
+const defaultMaxRestoreFileSize = 1 << 30
+
+// restoreQuery is the query that audit records and Authorize see for a restore.
+const restoreQuery = "-- restore main"
+
+// ErrRestoreNotAllowed is returned when BackupOptions.AllowRestore is not set
+// or BackupOptions.Exec is read-only.
+var ErrRestoreNotAllowed = errors.New("restore is not allowed")
+
+// ErrRestoreTooLarge is returned when the decompressed database file to
+// restore exceeds BackupOptions.MaxRestoreFileSize.
+var ErrRestoreTooLarge = errors.New("database file to restore is too large")
+
+// ErrIntegrityCheck is returned when a database file to restore fails
+// PRAGMA integrity_check.
+var ErrIntegrityCheck = errors.New("integrity check failed")
+
+// restoreSchema is the schema name the uploaded database is attached as.
+const restoreSchema = "w2explorer_restore"
+
+// SQLiteBackup writes a consistent copy of the database to w, compressed
+// with gzip when compress is set. Database is the schema name, such as
+// "main" or an attached database, and defaults to "main".
+//
+// The copy is made with VACUUM INTO a staged file, which reads one
+// snapshot of the database while other connections keep reading and
+// writing. The staged file is removed afterwards. opts.Exec.Authorize is
+// called before the copy is made, and opts.Exec.Audit records the backup.
+func SQLiteBackup(ctx context.Context, db *sql.DB, database string, w io.Writer, compress bool, opts BackupOptions) error {
+	if database == "" {
+		database = "main"
+	}
+
+	query := "VACUUM " + quoteIdent(database) + " INTO ?"
+
+	begin := time.Now()
+	err := sqliteBackup(ctx, db, query, w, compress, opts)
+	opts.Exec.audit(ctx, AuditRecord{Action: AuditBackup, Query: query, Elapsed: time.Since(begin).Seconds()}, err)
+
+	return err
+}
+
+func sqliteBackup(ctx context.Context, db *sql.DB, query string, w io.Writer, compress bool, opts BackupOptions) error {
+	if err := opts.Exec.authorize(ctx, SQLExecRequest{Query: query}); err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp(opts.TempDir, "w2explorer-backup-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "backup.db")
+	if _, err := db.ExecContext(ctx, query, file); err != nil {
+		return err
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if !compress {
+		_, err := io.Copy(w, f)
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	if _, err := io.Copy(gz, f); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+// SQLiteRestore replaces the main database with the SQLite database read
+// from r, which may be gzip-compressed.
+//
+// The upload is staged in a file, attached, and checked with
+// PRAGMA integrity_check. Then one transaction drops every table, view,
+// index, and trigger of the main database, recreates them from the upload,
+// copies the rows, and sets the user_version. Foreign keys are not enforced
+// while the rows are copied. Connections of other users see the old or the
+// new database, never a mix.
+//
+// opts.Exec.Authorize is called before the upload is read, and
+// opts.Exec.Audit records the restore.
+func SQLiteRestore(ctx context.Context, db *sql.DB, r io.Reader, opts BackupOptions) error {
+	begin := time.Now()
+	err := sqliteRestore(ctx, db, r, opts)
+	opts.Exec.audit(ctx, AuditRecord{Action: AuditRestore, Query: restoreQuery, Elapsed: time.Since(begin).Seconds()}, err)
+
+	return err
+}
+
+func sqliteRestore(ctx context.Context, db *sql.DB, r io.Reader, opts BackupOptions) error {
+	if !opts.AllowRestore || opts.Exec.ReadOnly {
+		return ErrRestoreNotAllowed
+	}
+
+	if err := opts.Exec.authorize(ctx, SQLExecRequest{Query: restoreQuery}); err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp(opts.TempDir, "w2explorer-restore-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "restore.db")
+	maxSize := opts.MaxRestoreFileSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxRestoreFileSize
+	}
+
+	if err := stageRestoreFile(file, r, maxSize); err != nil {
+		return err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// the staged file exists, so SQLite rejects it because it is not a database
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS "+restoreSchema, file); err != nil {
+		return fmt.Errorf("%w: %w", ErrIntegrityCheck, err)
+	}
+
+	foreignKeys := false
+	defer func() {
+		// use a fresh context so a cancelled request still resets the pooled connection,
+		// and discard the connection if the reset fails
+		_, err := conn.ExecContext(context.Background(), "DETACH DATABASE "+restoreSchema)
+		if err == nil && foreignKeys {
+			_, err = conn.ExecContext(context.Background(), "PRAGMA foreign_keys = ON")
+		}
+		if err != nil {
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+	}()
+
+	if err := integrityCheck(ctx, conn, restoreSchema); err != nil {
+		return err
+	}
+
+	if err := conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+		return err
+	}
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := restoreObjects(ctx, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// stageRestoreFile writes r to file and decompresses it when it starts with
+// the gzip magic number. It returns ErrRestoreTooLarge when the file would
+// exceed maxSize bytes.
+func stageRestoreFile(file string, r io.Reader, maxSize int64) error {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+
+	n, err := io.Copy(f, io.LimitReader(r, maxSize+1))
+	if err == nil && n > maxSize {
+		err = fmt.Errorf("%w: more than %d bytes", ErrRestoreTooLarge, maxSize)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// integrityCheck runs PRAGMA integrity_check on the database and returns
+// ErrIntegrityCheck with the first reported problems unless it is "ok".
+func integrityCheck(ctx context.Context, db w2db.QueryExecer, database string) error {
+	problems, err := queryStrings(ctx, db, "PRAGMA "+quoteIdent(database)+".integrity_check(10)")
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrIntegrityCheck, err)
+	}
+
+	if len(problems) != 1 || problems[0] != "ok" {
+		return fmt.Errorf("%w: %s", ErrIntegrityCheck, strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// restoreObjects replaces the objects of the main database with the objects
+// of the attached restore database.
+func restoreObjects(ctx context.Context, tx *sql.Tx) error {
+	// triggers and views go first so dropping tables does not break them
+	drops, err := queryStrings(ctx, tx, `
+SELECT 'DROP ' || upper(s.type) || ' IF EXISTS main."' || replace(s.name, '"', '""') || '"'
+FROM main.sqlite_schema s
+LEFT JOIN pragma_table_list t ON t.schema = 'main' AND t.name = s.name
+WHERE s.type IN ('trigger', 'view', 'table') AND s.name NOT LIKE 'sqlite_%' AND coalesce(t.type, '') <> 'shadow'
+ORDER BY CASE s.type WHEN 'trigger' THEN 0 WHEN 'view' THEN 1 ELSE 2 END`)
+	if err != nil {
+		return err
+	}
+
+	// shadow tables are created by their virtual table, and indexes, views,
+	// and triggers after the rows are copied
+	creates, err := queryStrings(ctx, tx, `
+SELECT s.sql
+FROM `+restoreSchema+`.sqlite_schema s
+LEFT JOIN pragma_table_list t ON t.schema = ?1 AND t.name = s.name
+WHERE s.sql IS NOT NULL AND s.name NOT LIKE 'sqlite_%' AND coalesce(t.type, '') <> 'shadow'
+ORDER BY CASE s.type WHEN 'table' THEN 0 WHEN 'index' THEN 2 WHEN 'view' THEN 3 ELSE 4 END, s.rowid`, restoreSchema)
+	if err != nil {
+		return err
+	}
+
+	copies, err := restoreCopies(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	var tables, rest []string
+	for _, create := range creates {
+		if isCreateTable(create) {
+			tables = append(tables, create)
+		} else {
+			rest = append(rest, create)
+		}
+	}
+
+	var userVersion int
+	if err := tx.QueryRowContext(ctx, "PRAGMA "+restoreSchema+".user_version").Scan(&userVersion); err != nil {
+		return err
+	}
+
+	statements := append(append(drops, tables...), copies...)
+	for _, query := range statements {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("%w: %s", err, query)
+		}
+	}
+
+	if err := restoreSequence(ctx, tx); err != nil {
+		return err
+	}
+
+	for _, query := range rest {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("%w: %s", err, query)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "PRAGMA main.user_version = "+strconv.Itoa(userVersion))
+	return err
+}
+
+// restoreCopies returns one INSERT ... SELECT per table of the restore
+// database. Rowid tables keep their rowids, and generated and hidden
+// columns are left out.
+func restoreCopies(ctx context.Context, tx *sql.Tx) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `
+SELECT name, type = 'table' AND NOT wr FROM pragma_table_list
+WHERE schema = ? AND type IN ('table', 'virtual') AND name NOT LIKE 'sqlite_%'`, restoreSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	type table struct {
+		name  string
+		rowid bool
+	}
+
+	var tables []table
+	for rows.Next() {
+		var t table
+		if err := rows.Scan(&t.name, &t.rowid); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	copies := make([]string, 0, len(tables))
+	for _, t := range tables {
+		columns, err := queryStrings(ctx, tx, "SELECT name FROM pragma_table_xinfo(?, ?) WHERE hidden = 0 ORDER BY cid", t.name, restoreSchema)
+		if err != nil {
+			return nil, err
+		}
+
+		quoted := make([]string, 0, len(columns)+1)
+		if t.rowid {
+			quoted = append(quoted, "_rowid_")
+		}
+		for _, column := range columns {
+			quoted = append(quoted, quoteIdent(column))
+		}
+
+		list := strings.Join(quoted, ", ")
+		copies = append(copies, fmt.Sprintf("INSERT INTO main.%s (%s) SELECT %s FROM %s.%s", quoteIdent(t.name), list, list, restoreSchema, quoteIdent(t.name)))
+	}
+
+	return copies, nil
+}
+
+// isCreateTable reports whether the DDL creates a table or virtual table.
+func isCreateTable(ddl string) bool {
+	fields := strings.Fields(strings.ToUpper(ddl))
+	return len(fields) > 2 && fields[0] == "CREATE" && (fields[1] == "TABLE" || fields[1] == "VIRTUAL")
+}
+
+// restoreSequence copies the AUTOINCREMENT counters when both databases
+// have a sqlite_sequence table.
+func restoreSequence(ctx context.Context, tx *sql.Tx) error {
+	var count int
+	err := tx.QueryRowContext(ctx, `
+SELECT count(*) FROM pragma_table_list
+WHERE name = 'sqlite_sequence' AND schema IN ('main', ?)`, restoreSchema).Scan(&count)
+	if err != nil || count < 2 {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM main.sqlite_sequence"); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO main.sqlite_sequence SELECT * FROM "+restoreSchema+".sqlite_sequence")
+	return err
+}
+
+// parseBackupQuery reads the "database" and "gzip" query parameters.
+func parseBackupQuery(r *http.Request) (string, bool, error) {
+	database := r.URL.Query().Get("database")
+	if database == "" {
+		database = "main"
+	}
+
+	compress := false
+	if value := r.URL.Query().Get("gzip"); value != "" {
+		var err error
+		if compress, err = strconv.ParseBool(value); err != nil {
+			return "", false, fmt.Errorf("invalid gzip parameter: %w", err)
+		}
+	}
+
+	return database, compress, nil
+}
+
+// writeBackupHeaders sets the attachment headers of a backup download.
+func writeBackupHeaders(w http.ResponseWriter, database string, compress bool) {
+	name := fmt.Sprintf("%s-%s.db", database, time.Now().UTC().Format("20060102-150405"))
+	if compress {
+		name += ".gz"
+		w.Header().Set("Content-Type", "application/gzip")
+	} else {
+		w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+}
+
+// SQLiteBackupHandler returns a backup handler that reports errors to the
+// caller instead of writing error responses itself.
+func SQLiteBackupHandler(db *sql.DB, opts BackupOptions) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		database, compress, err := parseBackupQuery(r)
+		if err != nil {
+			return err
+		}
+
+		writeBackupHeaders(w, database, compress)
+		return SQLiteBackup(r.Context(), db, database, w, compress, opts)
+	}
+}
+
+// SQLiteBackupHTTPHandler returns an http.HandlerFunc that downloads a
+// backup of the database named by the "database" query parameter ("main"
+// by default), gzip-compressed when the "gzip" parameter is true.
+//
+// The backup is staged before anything is written, so a failed VACUUM INTO
+// still gets a JSON error response. Backups rejected by opts.Exec.Authorize
+// return 403 Forbidden.
+func SQLiteBackupHTTPHandler(db *sql.DB, opts BackupOptions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		database, compress, err := parseBackupQuery(r)
+		if err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusBadRequest)
+			return
+		}
+
+		bw := &backupWriter{w: w, database: database, compress: compress}
+		err = SQLiteBackup(r.Context(), db, database, bw, compress, opts)
+		if err != nil && !bw.started && errors.Is(err, ErrAccessDenied) {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusForbidden)
+		} else if err != nil && !bw.started {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusInternalServerError)
+		}
+	}
+}
+
+// backupWriter sets the download headers on the first write, so errors
+// before the first byte can still be sent as JSON.
+type backupWriter struct {
+	w        http.ResponseWriter
+	database string
+	compress bool
+	started  bool
+}
+
+func (bw *backupWriter) Write(p []byte) (int, error) {
+	if !bw.started {
+		bw.started = true
+		writeBackupHeaders(bw.w, bw.database, bw.compress)
+	}
+	return bw.w.Write(p)
+}
+
+// SQLiteRestoreHandler returns a restore handler that reports errors to the
+// caller instead of writing error responses itself.
+func SQLiteRestoreHandler(db *sql.DB, opts BackupOptions) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if err := restoreUpload(r, db, opts); err != nil {
+			return err
+		}
+
+		res := w2.NewSuccessResponse()
+		return res.Write(w, http.StatusOK)
+	}
+}
+
+// SQLiteRestoreHTTPHandler returns an http.HandlerFunc that restores the
+// main database from the single file uploaded in the "files[]" multipart
+// field, as sent by the w2upload helper.
+//
+// It returns 403 Forbidden unless opts.AllowRestore is set or when
+// opts.Exec.Authorize rejects the restore, 413 Request Entity Too Large when
+// the decompressed file exceeds opts.MaxRestoreFileSize, and 422
+// Unprocessable Entity when the file fails the integrity check.
+func SQLiteRestoreHTTPHandler(db *sql.DB, opts BackupOptions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := restoreUpload(r, db, opts)
+		if errors.Is(err, ErrRestoreNotAllowed) || errors.Is(err, ErrAccessDenied) {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusForbidden)
+			return
+		} else if errors.Is(err, ErrRestoreTooLarge) {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusRequestEntityTooLarge)
+			return
+		} else if errors.Is(err, ErrIntegrityCheck) {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusUnprocessableEntity)
+			return
+		} else if errors.Is(err, errRestoreUpload) {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusBadRequest)
+			return
+		} else if err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusInternalServerError)
+			return
+		}
+
+		res := w2.NewSuccessResponse()
+		res.Write(w, http.StatusOK)
+	}
+}
+
+// errRestoreUpload marks malformed restore uploads.
+var errRestoreUpload = errors.New("invalid restore upload")
+
+// restoreUpload restores the database from the uploaded file of r.
+func restoreUpload(r *http.Request, db *sql.DB, opts BackupOptions) error {
+	if !opts.AllowRestore || opts.Exec.ReadOnly {
+		return ErrRestoreNotAllowed
+	}
+
+	headers, err := w2file.ParseMultipartFilesWithOptions(r, w2file.ParseMultipartFilesOptions{MaxUploadSize: opts.MaxRestoreSize})
+	if err != nil {
+		return fmt.Errorf("%w: %w", errRestoreUpload, err)
+	}
+
+	if len(headers) != 1 {
+		return fmt.Errorf("%w: expected one file, got %d", errRestoreUpload, len(headers))
+	}
+
+	f, err := headers[0].Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return SQLiteRestore(r.Context(), db, f, opts)
+}
@@ -0,0 +1,222 @@
+package w2explorer_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dv1x3r/w2go/w2explorer"
+)
+
+func TestSQLiteBackupRestore(t *testing.T) {
+	ctx := context.Background()
+
+	backup := func(t *testing.T, compress bool) []byte {
+		t.Helper()
+
+		db := openTestFile(t,
+			`CREATE TABLE todo (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT)`,
+			`INSERT INTO todo (name) VALUES ('a'), ('b')`,
+			`PRAGMA user_version = 7`,
+		)
+
+		var buf bytes.Buffer
+		if err := w2explorer.SQLiteBackup(ctx, db, "", &buf, compress, w2explorer.BackupOptions{TempDir: t.TempDir()}); err != nil {
+			t.Fatal(err)
+		}
+
+		return buf.Bytes()
+	}
+
+	for _, compress := range []bool{false, true} {
+		name := "Plain"
+		if compress {
+			name = "Gzip"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			file := backup(t, compress)
+			db := openTestFile(t, `CREATE TABLE old (id INTEGER)`)
+
+			opts := w2explorer.BackupOptions{TempDir: t.TempDir(), AllowRestore: true}
+			if err := w2explorer.SQLiteRestore(ctx, db, bytes.NewReader(file), opts); err != nil {
+				t.Fatal(err)
+			}
+
+			var names string
+			var version int
+			if err := db.QueryRow("SELECT group_concat(name) FROM todo").Scan(&names); err != nil {
+				t.Fatal(err)
+			}
+			if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+				t.Fatal(err)
+			}
+
+			if names != "a,b" || version != 7 {
+				t.Errorf("❌ Expected rows a,b and user_version 7, got: %q %d", names, version)
+			}
+
+			var old int
+			if err := db.QueryRow("SELECT count(*) FROM sqlite_schema WHERE name = 'old'").Scan(&old); err != nil || old != 0 {
+				t.Errorf("❌ Expected the old table to be dropped, got: %d %v", old, err)
+			}
+		})
+	}
+
+	gzipped := func(data []byte) []byte {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write(data)
+		gz.Close()
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		Name     string
+		Upload   []byte
+		Opts     w2explorer.BackupOptions
+		Expected error
+	}{
+		{
+			Name:     "NotAllowed",
+			Upload:   backup(t, false),
+			Opts:     w2explorer.BackupOptions{},
+			Expected: w2explorer.ErrRestoreNotAllowed,
+		},
+		{
+			Name:     "ReadOnly",
+			Upload:   backup(t, false),
+			Opts:     w2explorer.BackupOptions{AllowRestore: true, Exec: w2explorer.SQLExecOptions{ReadOnly: true}},
+			Expected: w2explorer.ErrRestoreNotAllowed,
+		},
+		{
+			Name:     "Corrupt",
+			Upload:   []byte(strings.Repeat("not a database ", 100)),
+			Opts:     w2explorer.BackupOptions{AllowRestore: true},
+			Expected: w2explorer.ErrIntegrityCheck,
+		},
+		{
+			Name:     "CorruptGzip",
+			Upload:   gzipped([]byte(strings.Repeat("not a database ", 100))),
+			Opts:     w2explorer.BackupOptions{AllowRestore: true},
+			Expected: w2explorer.ErrIntegrityCheck,
+		},
+		{
+			Name:     "Oversized",
+			Upload:   backup(t, false),
+			Opts:     w2explorer.BackupOptions{AllowRestore: true, MaxRestoreFileSize: 1024},
+			Expected: w2explorer.ErrRestoreTooLarge,
+		},
+		{
+			Name:     "OversizedGzip",
+			Upload:   gzipped(make([]byte, 1<<20)),
+			Opts:     w2explorer.BackupOptions{AllowRestore: true, MaxRestoreFileSize: 1 << 16},
+			Expected: w2explorer.ErrRestoreTooLarge,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			db := openTestFile(t, `CREATE TABLE keep (id INTEGER)`)
+
+			test.Opts.TempDir = t.TempDir()
+			err := w2explorer.SQLiteRestore(ctx, db, bytes.NewReader(test.Upload), test.Opts)
+			if !errors.Is(err, test.Expected) {
+				t.Errorf("❌ Expected %v, got: %v", test.Expected, err)
+			}
+
+			var keep int
+			if err := db.QueryRow("SELECT count(*) FROM sqlite_schema WHERE name = 'keep'").Scan(&keep); err != nil || keep != 1 {
+				t.Errorf("❌ Expected the database to be unchanged, got: %d %v", keep, err)
+			}
+		})
+	}
+
+	t.Run("TruncatedGzip", func(t *testing.T) {
+		db := openTestFile(t)
+
+		upload := gzipped(backup(t, false))
+		opts := w2explorer.BackupOptions{TempDir: t.TempDir(), AllowRestore: true}
+		if err := w2explorer.SQLiteRestore(ctx, db, bytes.NewReader(upload[:len(upload)/2]), opts); err == nil {
+			t.Error("❌ Expected an error for a truncated upload")
+		}
+	})
+
+	t.Run("AuthorizeAudit", func(t *testing.T) {
+		db := openTestFile(t, `CREATE TABLE todo (id INTEGER PRIMARY KEY)`)
+		opts := w2explorer.BackupOptions{
+			TempDir:      t.TempDir(),
+			AllowRestore: true,
+			Exec: w2explorer.SQLExecOptions{
+				Authorize: func(ctx context.Context, req w2explorer.SQLExecRequest) error {
+					return errors.New("no backups")
+				},
+				Audit: auditTrail(t, db),
+			},
+		}
+
+		var buf bytes.Buffer
+		if err := w2explorer.SQLiteBackup(ctx, db, "main", &buf, false, opts); !errors.Is(err, w2explorer.ErrAccessDenied) || buf.Len() > 0 {
+			t.Errorf("❌ Expected ErrAccessDenied without output, got: %v (%d bytes)", err, buf.Len())
+		}
+
+		if err := w2explorer.SQLiteRestore(ctx, db, strings.NewReader(""), opts); !errors.Is(err, w2explorer.ErrAccessDenied) {
+			t.Errorf("❌ Expected ErrAccessDenied, got: %v", err)
+		}
+
+		expected := []string{"backup (error)", "restore (error)"}
+		if actual := auditActions(t, db); strings.Join(actual, ",") != strings.Join(expected, ",") {
+			t.Errorf("❌ Expected audit records %v, got: %v", expected, actual)
+		}
+	})
+}
+
+func TestSQLiteRestoreHTTPHandler(t *testing.T) {
+	upload := func(t *testing.T, data []byte) *http.Request {
+		t.Helper()
+
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		fw, err := mw.CreateFormFile("files[]", "restore.db")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(data)
+		mw.Close()
+
+		r := httptest.NewRequest(http.MethodPost, "/sql/restore", &body)
+		r.Header.Set("Content-Type", mw.FormDataContentType())
+		return r
+	}
+
+	tests := []struct {
+		Name     string
+		Upload   []byte
+		Opts     w2explorer.BackupOptions
+		Expected int
+	}{
+		{Name: "NotAllowed", Upload: []byte("x"), Opts: w2explorer.BackupOptions{}, Expected: http.StatusForbidden},
+		{Name: "Corrupt", Upload: []byte(strings.Repeat("x", 4096)), Opts: w2explorer.BackupOptions{AllowRestore: true}, Expected: http.StatusUnprocessableEntity},
+		{Name: "Oversized", Upload: make([]byte, 4096), Opts: w2explorer.BackupOptions{AllowRestore: true, MaxRestoreFileSize: 1024}, Expected: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			db := openTestFile(t)
+
+			test.Opts.TempDir = t.TempDir()
+			w := httptest.NewRecorder()
+			w2explorer.SQLiteRestoreHTTPHandler(db, test.Opts)(w, upload(t, test.Upload))
+
+			if w.Code != test.Expected {
+				t.Errorf("❌ Expected status %d, got: %d %s", test.Expected, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package w2explorer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/dv1x3r/w2go/w2"
+	"github.com/dv1x3r/w2go/w2db"
+	"github.com/huandu/go-sqlbuilder"
+)
+
+// SQLBlobRequest is the JSON request body for downloading one full cell value
+// of a SQL explorer result.
+type SQLBlobRequest struct {
+	// Query is the single read-only statement that produced the result.
+	Query string `json:"query"`
+
+	// Row is the zero-based row index in the result.
+	Row int `json:"row"`
+
+	// Column is the result column name.
+	Column string `json:"column"`
+
+	// Params holds the parameter values the query ran with.
+	Params []SQLParam `json:"params"`
+
+	// Grid holds the w2grid sort and search of a paged result, so Row points
+	// to the same row the grid shows.
+	Grid w2.GetGridRequest `json:"-"`
+}
+
+// UnmarshalJSON decodes the cell fields and the w2grid request fields from
+// the same JSON object.
+func (req *SQLBlobRequest) UnmarshalJSON(data []byte) error {
+	type alias SQLBlobRequest
+	if err := json.Unmarshal(data, (*alias)(req)); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &req.Grid)
+}
+
+// ErrCellNotFound is returned when the requested row or column does not exist.
+var ErrCellNotFound = errors.New("cell not found")
+
+// SQLSelectBlob executes req.Query again and returns the full value of one
+// result cell, such as a BLOB shown as a preview in the result grid.
+//
+// The query must be a single read-only statement that passes opts, because
+// it is executed a second time, on a connection with PRAGMA query_only
+// enabled like SQLExec in read-only mode. opts.Timeout and opts.Monitor apply
+// to the second execution, and opts.Audit records it. Text values are
+// returned as their bytes and NULL returns a nil slice.
+func SQLSelectBlob(ctx context.Context, db *sql.DB, req SQLBlobRequest, opts SQLExecOptions) ([]byte, error) {
+	begin := time.Now()
+	value, err := sqlSelectBlob(ctx, db, req, opts)
+
+	record := AuditRecord{Action: AuditBlob, Query: req.Query, Params: req.Params, Elapsed: time.Since(begin).Seconds()}
+	if err == nil {
+		record.Rows = 1
+	}
+	opts.audit(ctx, record, err)
+
+	return value, err
+}
+
+func sqlSelectBlob(ctx context.Context, db *sql.DB, req SQLBlobRequest, opts SQLExecOptions) ([]byte, error) {
+	statements := splitStatements(req.Query)
+	if len(statements) != 1 {
+		return nil, errors.New("query must contain exactly one statement")
+	}
+
+	if err := opts.Check(SQLExecRequest{Query: req.Query}); err != nil {
+		return nil, err
+	}
+
+	stmt := statements[0]
+	if !stmt.IsReadOnly() || !stmt.ReturnsRows() {
+		return nil, fmt.Errorf("%w: cell values are only read from read-only queries", ErrStatementNotAllowed)
+	}
+
+	if err := opts.authorize(ctx, SQLExecRequest{Query: req.Query, Params: req.Params}); err != nil {
+		return nil, err
+	}
+
+	if req.Row < 0 {
+		return nil, ErrCellNotFound
+	}
+
+	params, err := sqlParamValues(req.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, _, done, err := opts.begin(ctx, "", req.Query)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	value, err := selectBlob(ctx, db, stmt, params, req)
+	if cause := interruption(ctx); err != nil && cause != nil {
+		return nil, cause
+	}
+
+	return value, err
+}
+
+// selectBlob runs the query on a connection with PRAGMA query_only enabled,
+// so a statement misclassified as read-only still cannot write.
+func selectBlob(ctx context.Context, db *sql.DB, stmt statement, params map[string]any, req SQLBlobRequest) ([]byte, error) {
+	conn, release, err := readOnlyConn(ctx, db, true)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if stmt.Kind() == StatementSelect && (len(req.Grid.Sort) > 0 || len(req.Grid.Search) > 0) {
+		return selectPagedCell(ctx, conn, stmt.Text, params, req)
+	}
+
+	return selectCell(ctx, conn, stmt.Text, params, req)
+}
+
+// selectCell scans the query rows up to req.Row and returns the value of req.Column.
+func selectCell(ctx context.Context, db w2db.QueryExecer, query string, params map[string]any, req SQLBlobRequest) ([]byte, error) {
+	query, args, err := bindArgs(query, params)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	index := slices.Index(columns, req.Column)
+	if index < 0 {
+		return nil, ErrCellNotFound
+	}
+
+	for row := 0; rows.Next(); row++ {
+		if row < req.Row {
+			continue
+		}
+
+		values := make([]any, len(columns))
+		valuePtrs := make([]any, len(columns))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+
+		var value []byte
+		valuePtrs[index] = &value
+
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, err
+		}
+
+		return value, nil
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, ErrCellNotFound
+}
+
+// selectPagedCell applies the w2grid sort and search of a paged result and
+// returns the value of req.Column at offset req.Row.
+func selectPagedCell(ctx context.Context, db w2db.QueryExecer, query string, params map[string]any, req SQLBlobRequest) ([]byte, error) {
+	subquery := "(\n" + query + "\n)"
+
+	bound, args, err := bindArgs(subquery, params)
+	if err != nil {
+		return nil, err
+	}
+
+	columns, err := selectColumns(ctx, db, "SELECT * FROM "+bound+" LIMIT 0", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	mapping := make(map[string]string, len(columns))
+	for _, column := range columns {
+		mapping[column.Name] = quoteIdent(column.Name)
+	}
+
+	if _, ok := mapping[req.Column]; !ok {
+		return nil, ErrCellNotFound
+	}
+
+	grid := req.Grid
+	grid.Limit = 1
+	grid.Offset = req.Row
+
+	res, err := w2db.GetGridContext(ctx, db, grid, w2db.GetGridOptions[[]byte]{
+		From:    sqlbuilder.Escape(subquery),
+		Select:  []string{mapping[req.Column]},
+		Where:   mapping,
+		OrderBy: mapping,
+		Build: func(sb *sqlbuilder.SelectBuilder) {
+			if len(args) > 0 {
+				from, _ := bindParams(subquery, params, sqlbuilder.Escape, sb.Var)
+				sb.From(from)
+			}
+		},
+		Scan: func(rows *sql.Rows, record *[]byte) error {
+			return rows.Scan(record)
+		},
+		Flavor: sqlbuilder.SQLite,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res.Records) == 0 {
+		return nil, ErrCellNotFound
+	}
+
+	return res.Records[0], nil
+}
+
+// SQLBlobHandler returns a cell download handler that reports errors to the
+// caller instead of writing error responses itself.
+func SQLBlobHandler(db *sql.DB, opts SQLExecOptions) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var req SQLBlobRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return err
+		}
+
+		value, err := SQLSelectBlob(r.Context(), db, req, opts)
+		if err != nil {
+			return err
+		}
+
+		writeBlob(w, req, value)
+		return nil
+	}
+}
+
+// SQLBlobHTTPHandler returns an http.HandlerFunc that sends one full cell
+// value of a SQL explorer result as an application/octet-stream attachment.
+//
+// Rejected queries return 403 Forbidden, invalid or missing parameter values
+// return 400 Bad Request, missing rows or columns return 404 Not Found, and
+// queries over the limits of opts.Monitor return 429 Too Many Requests.
+func SQLBlobHTTPHandler(db *sql.DB, opts SQLExecOptions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req SQLBlobRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusBadRequest)
+			return
+		}
+
+		value, err := SQLSelectBlob(r.Context(), db, req, opts)
+		if errors.Is(err, ErrStatementNotAllowed) || errors.Is(err, ErrConfirmRequired) || errors.Is(err, ErrAccessDenied) {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusForbidden)
+			return
+		} else if errors.Is(err, ErrInvalidParam) || errors.Is(err, ErrMissingParam) {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusBadRequest)
+			return
+		} else if errors.Is(err, ErrCellNotFound) {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusNotFound)
+			return
+		} else if errors.Is(err, ErrTooManyQueries) {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusTooManyRequests)
+			return
+		} else if err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusInternalServerError)
+			return
+		}
+
+		writeBlob(w, req, value)
+	}
+}
+
+func writeBlob(w http.ResponseWriter, req SQLBlobRequest, value []byte) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("row-%d.bin", req.Row+1)))
+	w.Write(value)
+}
@@ -0,0 +1,54 @@
+package w2explorer_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/dv1x3r/w2go/w2explorer"
+)
+
+func TestSQLSelectBlob(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t, exportSetup...)
+	db.SetMaxOpenConns(1)
+
+	tests := []struct {
+		Name     string
+		Request  string
+		Expected []byte
+		Error    error
+	}{
+		{Name: "Blob", Request: `{"query": "SELECT * FROM item", "row": 0, "column": "data"}`, Expected: []byte{0x00, 0xff}},
+		{Name: "Text", Request: `{"query": "SELECT * FROM item", "row": 1, "column": "name"}`, Expected: []byte("a,b")},
+		{Name: "Null", Request: `{"query": "SELECT * FROM item", "row": 1, "column": "data"}`},
+		{Name: "Paged", Request: `{"query": "SELECT * FROM item", "row": 0, "column": "name", "sort": [{"field": "id", "direction": "desc"}]}`},
+		{Name: "PagedSearch", Request: `{"query": "SELECT * FROM item", "row": 0, "column": "name", "search": [{"field": "id", "type": "int", "operator": "is", "value": 2}]}`, Expected: []byte("a,b")},
+		{Name: "QueryOnly", Request: `{"query": "PRAGMA query_only", "row": 0, "column": "query_only"}`, Expected: []byte("1")},
+		{Name: "Row", Request: `{"query": "SELECT * FROM item", "row": 3, "column": "data"}`, Error: w2explorer.ErrCellNotFound},
+		{Name: "Column", Request: `{"query": "SELECT * FROM item", "row": 0, "column": "size"}`, Error: w2explorer.ErrCellNotFound},
+		{Name: "Write", Request: `{"query": "DELETE FROM item RETURNING data", "row": 0, "column": "data"}`, Error: w2explorer.ErrStatementNotAllowed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var req w2explorer.SQLBlobRequest
+			if err := json.Unmarshal([]byte(test.Request), &req); err != nil {
+				t.Fatal(err)
+			}
+
+			value, err := w2explorer.SQLSelectBlob(ctx, db, req, w2explorer.SQLExecOptions{})
+			if test.Error != nil && !errors.Is(err, test.Error) {
+				t.Errorf("❌ Expected %v, got: %q %v", test.Error, value, err)
+			} else if test.Error == nil && (err != nil || string(value) != string(test.Expected)) {
+				t.Errorf("❌ Expected %q, got: %q %v", test.Expected, value, err)
+			}
+		})
+	}
+
+	// the pooled connection is reset after the read
+	if _, err := db.Exec("DELETE FROM item WHERE id = 3"); err != nil {
+		t.Errorf("❌ Expected the delete to succeed, got: %v", err)
+	}
+}
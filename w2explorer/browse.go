@@ -0,0 +1,533 @@
+package w2explorer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dv1x3r/w2go/w2"
+	"github.com/dv1x3r/w2go/w2db"
+	"github.com/huandu/go-sqlbuilder"
+)
+
+// TableBrowserOptions configures NewTableBrowser.
+type TableBrowserOptions struct {
+	// ReadOnly disables Insert and Remove for every table.
+	ReadOnly bool
+
+	// Exec applies the explorer permissions to the statements the browser
+	// generates, as if they were typed into the editor: Exec.Check and
+	// Exec.Authorize must accept them, and Exec.Audit records them.
+	// Exec.ReadOnly also disables Insert and Remove.
+	Exec SQLExecOptions
+}
+
+// TableBrowser serves paged, searchable, and sortable w2grid records for any
+// SQLite table or view, with the grid options built from the table metadata.
+//
+// Every column is whitelisted for search and sort. Tables with a primary key
+// also support inserting and removing rows.
+//
+// Reads are checked as "SELECT * FROM schema.table" against
+// TableBrowserOptions.Exec, and inserts and deletes as the statements that
+// run, so a browser cannot reach what the explorer rejects.
+type TableBrowser struct {
+	db   *sql.DB
+	opts TableBrowserOptions
+}
+
+// NewTableBrowser returns a table browser backed by db.
+func NewTableBrowser(db *sql.DB, opts TableBrowserOptions) *TableBrowser {
+	return &TableBrowser{db: db, opts: opts}
+}
+
+// BrowseTable describes a browsed table or view and the w2grid columns and
+// searches for it.
+type BrowseTable struct {
+	Schema string `json:"schema"`
+	Name   string `json:"name"`
+
+	// Type is "table", "view", "virtual", or "shadow".
+	Type string `json:"type"`
+
+	Columns []BrowseColumn `json:"columns"`
+
+	// Key lists the primary key columns. It is empty for views and tables
+	// without a primary key.
+	Key []string `json:"key"`
+
+	// CanInsert and CanDelete are true for tables with a primary key unless
+	// the browser is read-only.
+	CanInsert bool `json:"canInsert"`
+	CanDelete bool `json:"canDelete"`
+
+	withoutRowID bool
+}
+
+// BrowseColumn is one column of a BrowseTable.
+type BrowseColumn struct {
+	SchemaColumn
+
+	// SearchType is the w2ui search type derived from the declared type:
+	// "int", "float", "date", "datetime", "time", "toggle", or "text".
+	SearchType string `json:"searchType"`
+
+	// Operators lists the search operators accepted for SearchType.
+	Operators []string `json:"operators"`
+}
+
+// browseOperators lists the accepted w2ui search operators by search type.
+var browseOperators = map[string][]string{
+	"text":     {"is", "begins", "contains", "ends", "null", "not null"},
+	"int":      {"=", "between", ">", "<", ">=", "<=", "null", "not null"},
+	"float":    {"=", "between", ">", "<", ">=", "<=", "null", "not null"},
+	"date":     {"is", "between", "more", "less", "null", "not null"},
+	"datetime": {"is", "between", "more", "less", "null", "not null"},
+	"time":     {"is", "between", "more", "less", "null", "not null"},
+	"toggle":   {"is", "null", "not null"},
+}
+
+// searchType returns the w2ui search type of a declared column type.
+func searchType(declared string) string {
+	t := strings.ToUpper(declared)
+	switch {
+	case strings.Contains(t, "BOOL"):
+		return "toggle"
+	case strings.Contains(t, "DATETIME"), strings.Contains(t, "TIMESTAMP"):
+		return "datetime"
+	case strings.Contains(t, "DATE"):
+		return "date"
+	case strings.Contains(t, "TIME"):
+		return "time"
+	}
+
+	switch typeAffinity(declared) {
+	case "INTEGER":
+		return "int"
+	case "REAL", "NUMERIC":
+		return "float"
+	default:
+		return "text"
+	}
+}
+
+// ErrTableNotFound is returned when the browsed table or view does not exist.
+var ErrTableNotFound = errors.New("table not found")
+
+// ErrTableReadOnly is returned when rows are inserted into or removed from a
+// view, a table without a primary key, or a read-only browser.
+var ErrTableReadOnly = errors.New("table is read-only")
+
+// Table returns the metadata of a table or view. An empty schema resolves
+// the name like SQLite does: temp first, then main and attached databases.
+//
+// CanInsert and CanDelete are only set when TableBrowserOptions.Exec accepts
+// an INSERT into and a DELETE from the table.
+func (b *TableBrowser) Table(ctx context.Context, schema, name string) (BrowseTable, error) {
+	table, err := b.table(ctx, schema, name)
+	if err != nil {
+		return table, err
+	}
+
+	if err := b.allow(ctx, table.selectStatement()); err != nil {
+		return table, err
+	}
+
+	return table, nil
+}
+
+func (b *TableBrowser) table(ctx context.Context, schema, name string) (BrowseTable, error) {
+	var table BrowseTable
+
+	err := b.db.QueryRowContext(ctx, `
+SELECT t.schema, t.name, t.type, t.wr
+FROM pragma_table_list t
+JOIN pragma_database_list d ON d.name = t.schema
+WHERE t.name = ?1 AND (?2 = '' OR t.schema = ?2) AND t.name NOT LIKE 'sqlite_%'
+ORDER BY t.schema <> 'temp', d.seq
+LIMIT 1`, name, schema).Scan(&table.Schema, &table.Name, &table.Type, &table.withoutRowID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return table, fmt.Errorf("%w: %s", ErrTableNotFound, name)
+	} else if err != nil {
+		return table, err
+	}
+
+	columns, err := sqliteColumns(ctx, b.db, table.Schema, table.Name)
+	if err != nil {
+		return table, err
+	}
+
+	table.Columns = make([]BrowseColumn, len(columns))
+	for i, column := range columns {
+		st := searchType(column.Type)
+		table.Columns[i] = BrowseColumn{SchemaColumn: column, SearchType: st, Operators: browseOperators[st]}
+	}
+
+	if table.Type != "view" {
+		for _, column := range slices.SortedFunc(slices.Values(columns), func(a, b SchemaColumn) int { return a.PK - b.PK }) {
+			if column.PK > 0 {
+				table.Key = append(table.Key, column.Name)
+			}
+		}
+	}
+
+	if table.Key == nil {
+		table.Key = []string{}
+	}
+
+	writable := !b.opts.ReadOnly && !b.opts.Exec.ReadOnly && table.Type == "table" && len(table.Key) > 0
+	table.CanInsert = writable && b.allow(ctx, "INSERT INTO "+table.from()+" DEFAULT VALUES;") == nil
+	table.CanDelete = writable && b.allow(ctx, "DELETE FROM "+table.from()+" WHERE "+table.recidExpr()+" IN (NULL);") == nil
+	return table, nil
+}
+
+// allow checks a statement generated by the browser against
+// TableBrowserOptions.Exec and its Authorize hook.
+func (b *TableBrowser) allow(ctx context.Context, query string) error {
+	req := SQLExecRequest{Query: query}
+	if err := b.opts.Exec.Check(req); err != nil {
+		return err
+	}
+	return b.opts.Exec.authorize(ctx, req)
+}
+
+// selectStatement is the statement a read of the table is checked and
+// audited as.
+func (table BrowseTable) selectStatement() string {
+	return "SELECT * FROM " + table.from() + ";"
+}
+
+// recidExpr returns the SQL expression of the w2grid recid: the row number
+// of a view, a JSON array of the primary key of a WITHOUT ROWID table, or
+// the rowid of any other table.
+func (table BrowseTable) recidExpr() string {
+	switch {
+	case table.Type == "view":
+		return "row_number() OVER ()"
+	case table.withoutRowID:
+		quoted := make([]string, len(table.Key))
+		for i, key := range table.Key {
+			quoted[i] = quoteIdent(key)
+		}
+		return "json_array(" + strings.Join(quoted, ", ") + ")"
+	default:
+		return "_rowid_"
+	}
+}
+
+// from returns the quoted schema-qualified table name.
+func (table BrowseTable) from() string {
+	return quoteIdent(table.Schema) + "." + quoteIdent(table.Name)
+}
+
+// Records returns one page of rows of a table or view for a w2grid request.
+//
+// Search rules with an operator that does not fit the column type are
+// ignored. Without a sort, rows are ordered by recid so paging is stable.
+func (b *TableBrowser) Records(ctx context.Context, schema, name string, req w2.GetGridRequest) (w2.GetGridResponse[SQLExecRow], error) {
+	begin := time.Now()
+	table, err := b.table(ctx, schema, name)
+	if err != nil {
+		return w2.GetGridResponse[SQLExecRow]{}, err
+	}
+
+	res, err := b.records(ctx, table, req)
+
+	record := AuditRecord{Action: AuditBrowse, Query: table.selectStatement(), Elapsed: time.Since(begin).Seconds(), Rows: int64(len(res.Records))}
+	b.opts.Exec.audit(ctx, record, err)
+
+	return res, err
+}
+
+func (b *TableBrowser) records(ctx context.Context, table BrowseTable, req w2.GetGridRequest) (w2.GetGridResponse[SQLExecRow], error) {
+	if err := b.allow(ctx, table.selectStatement()); err != nil {
+		return w2.GetGridResponse[SQLExecRow]{}, err
+	}
+
+	selects := []string{table.recidExpr() + " AS recid"}
+	mapping := map[string]string{}
+	orderBy := map[string]string{"recid": "recid"}
+	operators := map[string][]string{}
+	for _, column := range table.Columns {
+		selects = append(selects, quoteIdent(column.Name))
+		mapping[column.Name] = quoteIdent(column.Name)
+		orderBy[column.Name] = quoteIdent(column.Name)
+		operators[column.Name] = column.Operators
+	}
+
+	req.Search = slices.DeleteFunc(slices.Clone(req.Search), func(s w2.GridSearch) bool {
+		return !slices.Contains(operators[s.Field], s.Operator)
+	})
+
+	if len(req.Sort) == 0 && table.Type != "view" {
+		req.Sort = []w2.GridSort{{Field: "recid", Direction: "asc"}}
+	}
+
+	var columns []SQLExecColumn
+	return w2db.GetGridContext(ctx, b.db, req, w2db.GetGridOptions[SQLExecRow]{
+		From:    table.from(),
+		Select:  selects,
+		Where:   mapping,
+		OrderBy: orderBy,
+		Scan: func(rows *sql.Rows, record *SQLExecRow) error {
+			if columns == nil {
+				types, err := rows.ColumnTypes()
+				if err != nil {
+					return err
+				}
+				columns = newSQLExecColumns(types)
+			}
+			row, err := scanRow(rows, columns)
+			*record = row
+			return err
+		},
+		Flavor: sqlbuilder.SQLite,
+	})
+}
+
+// Insert inserts one row into a table with a primary key and returns its
+// recid for rowid tables. Columns missing from record get their default.
+//
+// The INSERT must pass TableBrowserOptions.Exec, which records it.
+func (b *TableBrowser) Insert(ctx context.Context, schema, name string, record map[string]any) (int, error) {
+	begin := time.Now()
+	statement, id, err := b.insert(ctx, schema, name, record)
+
+	if statement != "" {
+		audit := AuditRecord{Action: AuditBrowseWrite, Query: statement, Elapsed: time.Since(begin).Seconds()}
+		if err == nil {
+			audit.Rows = 1
+		}
+		b.opts.Exec.audit(ctx, audit, err)
+	}
+
+	return id, err
+}
+
+func (b *TableBrowser) insert(ctx context.Context, schema, name string, record map[string]any) (string, int, error) {
+	table, err := b.table(ctx, schema, name)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if !table.CanInsert {
+		return "", 0, fmt.Errorf("%w: %s", ErrTableReadOnly, table.Name)
+	}
+
+	values := map[string]any{}
+	for field, value := range record {
+		i := slices.IndexFunc(table.Columns, func(c BrowseColumn) bool { return c.Name == field })
+		if i < 0 {
+			return "", 0, fmt.Errorf("unknown column %q", field)
+		}
+		values[quoteIdent(field)] = insertValue(value)
+	}
+
+	statement := insertStatement(table.from(), values)
+	if err := b.allow(ctx, statement); err != nil {
+		return statement, 0, err
+	}
+
+	if len(values) == 0 {
+		_, err := b.db.ExecContext(ctx, "INSERT INTO "+table.from()+" DEFAULT VALUES")
+		return statement, 0, err
+	}
+
+	id, err := w2db.InsertContext(ctx, b.db, w2db.InsertOptions{
+		Into:   table.from(),
+		Values: values,
+		Flavor: sqlbuilder.SQLite,
+	})
+	return statement, id, err
+}
+
+// insertStatement returns the INSERT statement of values with the values
+// inlined, in a stable column order.
+func insertStatement(into string, values map[string]any) string {
+	if len(values) == 0 {
+		return "INSERT INTO " + into + " DEFAULT VALUES;"
+	}
+
+	builder := sqlbuilder.InsertInto(into)
+	columns := sortedKeys(values)
+	row := make([]any, len(columns))
+	for i, column := range columns {
+		row[i] = values[column]
+	}
+	builder.Cols(columns...).Values(row...)
+
+	query, args := builder.BuildWithFlavor(sqlbuilder.SQLite)
+	statement, err := sqlbuilder.SQLite.Interpolate(query, args)
+	if err != nil {
+		return query + ";"
+	}
+
+	return statement + ";"
+}
+
+// insertValue converts a whole JSON number to int64, so columns without type
+// affinity store an integer rather than a real.
+func insertValue(value any) any {
+	if f, ok := value.(float64); ok && f == math.Trunc(f) && math.Abs(f) <= maxSafeInteger {
+		return int64(f)
+	}
+	return value
+}
+
+// Remove deletes the rows with the given recids from a table with a primary
+// key and returns the number of deleted rows.
+//
+// The DELETE must pass TableBrowserOptions.Exec, which records it.
+func (b *TableBrowser) Remove(ctx context.Context, schema, name string, ids []any) (int, error) {
+	begin := time.Now()
+	statement, affected, err := b.remove(ctx, schema, name, ids)
+
+	if statement != "" {
+		audit := AuditRecord{Action: AuditBrowseWrite, Query: statement, Elapsed: time.Since(begin).Seconds(), Rows: int64(affected)}
+		b.opts.Exec.audit(ctx, audit, err)
+	}
+
+	return affected, err
+}
+
+func (b *TableBrowser) remove(ctx context.Context, schema, name string, ids []any) (string, int, error) {
+	table, err := b.table(ctx, schema, name)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if !table.CanDelete {
+		return "", 0, fmt.Errorf("%w: %s", ErrTableReadOnly, table.Name)
+	}
+
+	if len(ids) == 0 {
+		return "", 0, nil
+	}
+
+	builder := sqlbuilder.DeleteFrom(table.from())
+	builder.Where(builder.In(table.recidExpr(), ids...))
+	query, args := builder.BuildWithFlavor(sqlbuilder.SQLite)
+
+	statement, err := sqlbuilder.SQLite.Interpolate(query, args)
+	if err != nil {
+		statement = query
+	}
+	statement += ";"
+
+	if err := b.allow(ctx, statement); err != nil {
+		return statement, 0, err
+	}
+
+	result, err := b.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return statement, 0, err
+	}
+
+	affected, _ := result.RowsAffected()
+	return statement, int(affected), nil
+}
+
+// browseError writes err with the status code of its kind.
+func browseError(w http.ResponseWriter, err error) {
+	res := w2.NewErrorResponse(err.Error())
+	switch {
+	case errors.Is(err, ErrTableNotFound):
+		res.Write(w, http.StatusNotFound)
+	case errors.Is(err, ErrTableReadOnly), errors.Is(err, ErrStatementNotAllowed), errors.Is(err, ErrAccessDenied):
+		res.Write(w, http.StatusForbidden)
+	default:
+		res.Write(w, http.StatusInternalServerError)
+	}
+}
+
+// TableHTTPHandler returns an http.HandlerFunc that writes the BrowseTable
+// JSON of the "schema" and "table" query parameters.
+func (b *TableBrowser) TableHTTPHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		table, err := b.Table(r.Context(), r.URL.Query().Get("schema"), r.URL.Query().Get("table"))
+		if err != nil {
+			browseError(w, err)
+			return
+		}
+
+		writeJSON(w, table)
+	}
+}
+
+// RecordsHTTPHandler returns an http.HandlerFunc that writes the rows of the
+// "schema" and "table" query parameters for the w2grid request in the
+// "request" query parameter.
+func (b *TableBrowser) RecordsHTTPHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, err := w2.ParseGetGridRequest(r.URL.Query().Get("request"))
+		if err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusBadRequest)
+			return
+		}
+
+		res, err := b.Records(r.Context(), r.URL.Query().Get("schema"), r.URL.Query().Get("table"), req)
+		if err != nil {
+			browseError(w, err)
+			return
+		}
+
+		res.Write(w)
+	}
+}
+
+// InsertHTTPHandler returns an http.HandlerFunc that inserts the record of a
+// w2form save request into the "schema" and "table" query parameters.
+func (b *TableBrowser) InsertHTTPHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, err := w2.ParseSaveFormRequest[map[string]any](r.Body)
+		if err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusBadRequest)
+			return
+		}
+
+		id, err := b.Insert(r.Context(), r.URL.Query().Get("schema"), r.URL.Query().Get("table"), req.Record)
+		if err != nil {
+			browseError(w, err)
+			return
+		}
+
+		res := w2.NewSaveFormResponse(id)
+		res.Write(w)
+	}
+}
+
+// RemoveHTTPHandler returns an http.HandlerFunc that deletes the rows of a
+// w2grid remove request from the "schema" and "table" query parameters.
+//
+// The grid sends the selected recids under "recid". Unlike
+// w2.RemoveGridRequest, they may be strings, which is how rows of WITHOUT
+// ROWID tables are identified.
+func (b *TableBrowser) RemoveHTTPHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			RecID []any `json:"recid"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusBadRequest)
+			return
+		}
+
+		if _, err := b.Remove(r.Context(), r.URL.Query().Get("schema"), r.URL.Query().Get("table"), req.RecID); err != nil {
+			browseError(w, err)
+			return
+		}
+
+		res := w2.NewSuccessResponse()
+		res.Write(w, http.StatusOK)
+	}
+}
@@ -0,0 +1,189 @@
+package w2explorer_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dv1x3r/w2go/w2"
+	"github.com/dv1x3r/w2go/w2explorer"
+	_ "modernc.org/sqlite"
+)
+
+// discardLogger keeps the audit records of the tests out of the test output.
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// openTestDB returns an in-memory database shared by all connections of the
+// pool and runs the setup statements on it.
+func openTestDB(t *testing.T, setup ...string) *sql.DB {
+	t.Helper()
+	return openTestDSN(t, "file:/"+url.PathEscape(t.Name())+"?vfs=memdb", setup...)
+}
+
+// openTestFile returns a database file in a temporary directory, for tests
+// that attach or copy database files, and runs the setup statements on it.
+func openTestFile(t *testing.T, setup ...string) *sql.DB {
+	t.Helper()
+	return openTestDSN(t, filepath.Join(t.TempDir(), "test.db"), setup...)
+}
+
+func openTestDSN(t *testing.T, dsn string, setup ...string) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	for _, query := range setup {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatalf("❌ Setup %q: %v", query, err)
+		}
+	}
+
+	return db
+}
+
+// auditTrail returns an audit log that stores its records in db.
+func auditTrail(t *testing.T, db *sql.DB) *w2explorer.AuditLog {
+	t.Helper()
+
+	audit := w2explorer.NewAuditLog(w2explorer.AuditLogOptions{DB: db, Logger: discardLogger})
+	if err := audit.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	return audit
+}
+
+// auditActions returns the action and error of every stored audit record.
+func auditActions(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+
+	rows, err := db.Query("SELECT action, error FROM w2explorer_audit ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var actions []string
+	for rows.Next() {
+		var action, message string
+		if err := rows.Scan(&action, &message); err != nil {
+			t.Fatal(err)
+		}
+		if message != "" {
+			action += " (error)"
+		}
+		actions = append(actions, action)
+	}
+
+	return actions
+}
+
+func TestTableBrowser(t *testing.T) {
+	setup := []string{
+		`CREATE TABLE todo (id INTEGER PRIMARY KEY, name TEXT, done BOOLEAN)`,
+		`INSERT INTO todo (name, done) VALUES ('a', 0), ('b', 1), ('c', 0)`,
+		`CREATE TABLE secret (id INTEGER PRIMARY KEY, value TEXT)`,
+	}
+
+	ctx := context.Background()
+
+	t.Run("Records", func(t *testing.T) {
+		db := openTestDB(t, setup...)
+		tables := w2explorer.NewTableBrowser(db, w2explorer.TableBrowserOptions{})
+
+		req := w2.GetGridRequest{Limit: 2, Search: []w2.GridSearch{{Field: "done", Operator: "is", Value: false}}, SearchLogic: "AND"}
+		res, err := tables.Records(ctx, "", "todo", req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.Total != 2 || len(res.Records) != 2 || res.Records[1]["name"] != "c" {
+			t.Errorf("❌ Expected rows a and c, got: %d %v", res.Total, res.Records)
+		}
+
+		if _, err := tables.Records(ctx, "", "missing", req); !errors.Is(err, w2explorer.ErrTableNotFound) {
+			t.Errorf("❌ Expected ErrTableNotFound, got: %v", err)
+		}
+	})
+
+	t.Run("InsertRemove", func(t *testing.T) {
+		db := openTestDB(t, setup...)
+		tables := w2explorer.NewTableBrowser(db, w2explorer.TableBrowserOptions{})
+
+		id, err := tables.Insert(ctx, "main", "todo", map[string]any{"name": "d", "done": float64(1)})
+		if err != nil || id != 4 {
+			t.Fatalf("❌ Expected recid 4, got: %d %v", id, err)
+		}
+
+		if _, err := tables.Insert(ctx, "main", "todo", map[string]any{"missing": 1}); err == nil {
+			t.Error("❌ Expected an error for an unknown column")
+		}
+
+		removed, err := tables.Remove(ctx, "main", "todo", []any{float64(1), float64(4)})
+		if err != nil || removed != 2 {
+			t.Errorf("❌ Expected 2 removed rows, got: %d %v", removed, err)
+		}
+	})
+
+	t.Run("ExecOptions", func(t *testing.T) {
+		db := openTestDB(t, setup...)
+		opts := w2explorer.SQLExecOptions{
+			Allow: []w2explorer.StatementKind{w2explorer.StatementSelect},
+			Authorize: func(ctx context.Context, req w2explorer.SQLExecRequest) error {
+				if strings.Contains(req.Query, "secret") {
+					return errors.New("secret is hidden")
+				}
+				return nil
+			},
+			Audit: auditTrail(t, db),
+		}
+		tables := w2explorer.NewTableBrowser(db, w2explorer.TableBrowserOptions{Exec: opts})
+
+		table, err := tables.Table(ctx, "", "todo")
+		if err != nil || table.CanInsert || table.CanDelete {
+			t.Errorf("❌ Expected a table without inserts and deletes, got: %+v %v", table, err)
+		}
+
+		if _, err := tables.Records(ctx, "", "todo", w2.GetGridRequest{}); err != nil {
+			t.Errorf("❌ Unexpected error: %v", err)
+		}
+
+		if _, err := tables.Table(ctx, "", "secret"); !errors.Is(err, w2explorer.ErrAccessDenied) {
+			t.Errorf("❌ Expected ErrAccessDenied for the table, got: %v", err)
+		}
+
+		if _, err := tables.Records(ctx, "", "secret", w2.GetGridRequest{}); !errors.Is(err, w2explorer.ErrAccessDenied) {
+			t.Errorf("❌ Expected ErrAccessDenied for the records, got: %v", err)
+		}
+
+		if _, err := tables.Remove(ctx, "main", "todo", []any{float64(1)}); !errors.Is(err, w2explorer.ErrTableReadOnly) {
+			t.Errorf("❌ Expected ErrTableReadOnly, got: %v", err)
+		}
+
+		opts.Allow = nil
+		tables = w2explorer.NewTableBrowser(db, w2explorer.TableBrowserOptions{Exec: opts})
+
+		if _, err := tables.Insert(ctx, "main", "todo", map[string]any{"name": "d"}); err != nil {
+			t.Errorf("❌ Unexpected error: %v", err)
+		}
+
+		if _, err := tables.Remove(ctx, "main", "secret", []any{float64(1)}); !errors.Is(err, w2explorer.ErrTableReadOnly) {
+			t.Errorf("❌ Expected ErrTableReadOnly for the hidden table, got: %v", err)
+		}
+
+		expected := []string{"browse", "browse (error)", "browse-write"}
+		if actual := auditActions(t, db); strings.Join(actual, ",") != strings.Join(expected, ",") {
+			t.Errorf("❌ Expected audit records %v, got: %v", expected, actual)
+		}
+	})
+}
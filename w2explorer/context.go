@@ -0,0 +1,35 @@
+package w2explorer
+
+import "context"
+
+type userKey struct{}
+
+type sourceKey struct{}
+
+// WithUser returns a copy of ctx that carries the name of the user running
+// SQL explorer queries. Query history records this name.
+//
+// Set it in an authentication middleware in front of the explorer handlers.
+func WithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFromContext returns the user set by WithUser, or an empty string.
+func UserFromContext(ctx context.Context) string {
+	user, _ := ctx.Value(userKey{}).(string)
+	return user
+}
+
+// WithSource returns a copy of ctx that carries the name of the data source
+// a query runs on. Audit records include this name.
+//
+// SourceRegistry handlers set it to the selected source.
+func WithSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, sourceKey{}, source)
+}
+
+// SourceFromContext returns the data source set by WithSource, or an empty string.
+func SourceFromContext(ctx context.Context) string {
+	source, _ := ctx.Value(sourceKey{}).(string)
+	return source
+}
@@ -0,0 +1,636 @@
+package w2explorer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dv1x3r/w2go/w2"
+	"github.com/dv1x3r/w2go/w2db"
+	"github.com/huandu/go-sqlbuilder"
+)
+
+// SQLEditTarget describes the table behind an editable SQL explorer result.
+type SQLEditTarget struct {
+	Schema string `json:"schema"`
+	Table  string `json:"table"`
+
+	// Key lists the columns that identify a row: the INTEGER PRIMARY KEY or
+	// "rowid" of a rowid table, or the primary key of a WITHOUT ROWID table.
+	Key []string `json:"key"`
+
+	// Columns maps editable result column names to table column names.
+	// Expressions and ambiguous result columns are left out.
+	Columns map[string]string `json:"columns"`
+}
+
+// editKeyPrefix names the key columns added to an editable SELECT.
+const editKeyPrefix = "__w2explorer_key_"
+
+// aggregateFunctions collapse rows, so their results cannot be written back.
+var aggregateFunctions = []string{
+	"COUNT", "SUM", "TOTAL", "AVG", "MIN", "MAX", "GROUP_CONCAT", "STRING_AGG",
+	"JSON_GROUP_ARRAY", "JSON_GROUP_OBJECT", "JSONB_GROUP_ARRAY", "JSONB_GROUP_OBJECT",
+}
+
+// editSelect is a parsed simple single-table SELECT.
+type editSelect struct {
+	ref       tableRef
+	qualifier string
+
+	// items holds the select list items in order.
+	items []editItem
+
+	// insertAt is the byte offset in the statement text after SELECT, where
+	// the key columns are inserted.
+	insertAt int
+}
+
+// editItem is one select list item. Column is empty for an expression.
+type editItem struct {
+	name   string
+	column string
+	star   bool
+}
+
+// parseEditSelect parses "SELECT list FROM table [alias] [WHERE ...]
+// [ORDER BY ...] [LIMIT ...]". Joins, compound selects, grouping, DISTINCT,
+// and aggregates are rejected because their rows do not map to table rows.
+func parseEditSelect(stmt statement) (editSelect, bool) {
+	var sel editSelect
+	tokens := stmt.Tokens
+
+	if len(tokens) < 4 || !tokens[0].is("SELECT") {
+		return sel, false
+	}
+
+	i := 1
+	if tokens[i].is("DISTINCT") {
+		return sel, false
+	} else if tokens[i].is("ALL") {
+		i++
+	}
+	sel.insertAt = tokens[i-1].Pos - stmt.Pos + len(tokens[i-1].Text)
+
+	// split the select list at top-level commas
+	var items [][]token
+	start := i
+	depth := 0
+	for ; i < len(tokens); i++ {
+		t := tokens[i]
+		switch {
+		case t.Text == "(":
+			depth++
+			if i > 0 && tokens[i-1].Kind == tokenWord && slices.ContainsFunc(aggregateFunctions, tokens[i-1].is) {
+				return sel, false
+			}
+		case t.Text == ")":
+			depth--
+		case depth == 0 && t.Text == ",":
+			items = append(items, tokens[start:i])
+			start = i + 1
+		}
+
+		if depth == 0 && t.is("FROM") {
+			break
+		}
+	}
+
+	if i >= len(tokens) || start == i {
+		return sel, false
+	}
+	items = append(items, tokens[start:i])
+
+	// FROM [schema.]table [[AS] alias]
+	i++
+	if i >= len(tokens) || !isIdent(tokens[i]) {
+		return sel, false
+	}
+
+	sel.ref = tableRef{Name: identName(tokens[i])}
+	i++
+
+	if i+1 < len(tokens) && tokens[i].Text == "." && isIdent(tokens[i+1]) {
+		sel.ref.Schema = sel.ref.Name
+		sel.ref.Name = identName(tokens[i+1])
+		i += 2
+	}
+
+	sel.qualifier = sel.ref.Name
+	if i < len(tokens) && tokens[i].is("AS") {
+		i++
+	}
+
+	if i < len(tokens) && isIdent(tokens[i]) && !isClauseKeyword(tokens[i]) {
+		sel.qualifier = identName(tokens[i])
+		i++
+	}
+
+	if i < len(tokens) && !tokens[i].is("WHERE") && !tokens[i].is("ORDER") && !tokens[i].is("LIMIT") {
+		return sel, false
+	}
+
+	depth = 0
+	for _, t := range tokens[i:] {
+		switch {
+		case t.Text == "(":
+			depth++
+		case t.Text == ")":
+			depth--
+		case depth == 0 && (t.is("GROUP") || t.is("HAVING") || t.is("WINDOW") || t.is("UNION") ||
+			t.is("EXCEPT") || t.is("INTERSECT") || t.is("JOIN")):
+			return sel, false
+		}
+	}
+
+	for _, item := range items {
+		sel.items = append(sel.items, sel.parseItem(item))
+	}
+
+	return sel, true
+}
+
+// parseItem parses one select list item: *, qualifier.*, or a possibly
+// qualified column with an optional alias. Other items are expressions.
+func (sel editSelect) parseItem(item []token) editItem {
+	n := len(item)
+
+	if n == 1 && item[0].Text == "*" {
+		return editItem{star: true}
+	}
+
+	if n == 3 && item[1].Text == "." && item[2].Text == "*" {
+		return editItem{star: strings.EqualFold(identName(item[0]), sel.qualifier)}
+	}
+
+	var alias string
+	switch {
+	case n >= 3 && item[n-2].is("AS"):
+		alias = identName(item[n-1])
+		item = item[:n-2]
+	case n >= 2 && (isIdent(item[n-1]) || item[n-1].Kind == tokenString) && isAliasAfter(item[n-2]):
+		alias = identName(item[n-1])
+		item = item[:n-1]
+	}
+
+	if len(item) == 0 || !isIdent(item[0]) {
+		return editItem{name: alias}
+	}
+
+	ref, next := parseColumnRef(item, 0)
+	if next != len(item) || ref.Qualifier != "" && !strings.EqualFold(ref.Qualifier, sel.qualifier) {
+		return editItem{name: alias}
+	}
+
+	if alias == "" {
+		alias = ref.Name
+	}
+
+	return editItem{name: alias, column: ref.Name}
+}
+
+// isAliasAfter reports whether an identifier after t is an alias without AS,
+// as in "name n" or "count(*) total", rather than part of an expression.
+func isAliasAfter(t token) bool {
+	if t.Kind == tokenPunct {
+		return t.Text == ")"
+	}
+	return !isExprKeyword(t)
+}
+
+// editTarget returns the table, key, and editable columns of a simple
+// single-table SELECT, and the statement rewritten to also select the key.
+// It returns nil when the result cannot be written back.
+func editTarget(ctx context.Context, db w2db.QueryExecer, stmt statement) (*SQLEditTarget, string, error) {
+	sel, ok := parseEditSelect(stmt)
+	if !ok {
+		return nil, "", nil
+	}
+
+	// an unqualified name resolves to temp first, then main and the attached databases
+	var tableType string
+	var withoutRowID bool
+	err := db.QueryRowContext(ctx, `
+SELECT t.schema, t.name, t.type, t.wr
+FROM pragma_table_list t
+JOIN pragma_database_list d ON d.name = t.schema
+WHERE t.name = ?1 COLLATE NOCASE AND (?2 = '' OR t.schema = ?2 COLLATE NOCASE)
+ORDER BY t.schema <> 'temp', d.seq
+LIMIT 1`, sel.ref.Name, sel.ref.Schema).Scan(&sel.ref.Schema, &sel.ref.Name, &tableType, &withoutRowID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, "", nil
+	} else if err != nil {
+		return nil, "", err
+	}
+
+	if tableType != "table" {
+		return nil, "", nil
+	}
+
+	info, err := tableColumns(ctx, db, sel.ref)
+	if err != nil {
+		return nil, "", err
+	}
+
+	target := &SQLEditTarget{Schema: sel.ref.Schema, Table: sel.ref.Name, Columns: map[string]string{}}
+
+	switch {
+	case withoutRowID:
+		target.Key, err = primaryKeyColumns(ctx, db, sel.ref)
+		if err != nil {
+			return nil, "", err
+		}
+	case info.rowID != "":
+		target.Key = []string{info.rowID}
+	case info.has("rowid"):
+		// a column named rowid hides the real rowid
+		return nil, "", nil
+	default:
+		target.Key = []string{"rowid"}
+	}
+
+	// result columns that appear more than once are read-only, because the
+	// result row holds only one of their values
+	var names, columns []string
+	for _, item := range sel.items {
+		if item.star {
+			names = append(names, info.columns...)
+			columns = append(columns, info.columns...)
+		} else {
+			names = append(names, item.name)
+			columns = append(columns, info.name(item.column))
+		}
+	}
+
+	counts := map[string]int{}
+	for _, name := range names {
+		counts[name]++
+	}
+
+	for i, name := range names {
+		if columns[i] != "" && name != "" && counts[name] == 1 {
+			target.Columns[name] = columns[i]
+		}
+	}
+
+	keys := make([]string, len(target.Key))
+	for i, key := range target.Key {
+		keys[i] = fmt.Sprintf("%s.%s AS %s", quoteIdent(sel.qualifier), quoteIdent(key), quoteIdent(fmt.Sprintf("%s%d", editKeyPrefix, i)))
+	}
+
+	query := stmt.Text[:sel.insertAt] + " " + strings.Join(keys, ", ") + "," + stmt.Text[sel.insertAt:]
+	return target, query, nil
+}
+
+// primaryKeyColumns returns the primary key columns of a table in key order.
+func primaryKeyColumns(ctx context.Context, db w2db.QueryExecer, ref tableRef) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT name FROM pragma_table_info(?, ?) WHERE pk > 0 ORDER BY pk", ref.Name, ref.Schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
+
+// moveKeys removes the key columns added by editTarget from result and
+// stores their values in result.Keys.
+func moveKeys(result *SQLExecResult, target *SQLEditTarget) {
+	n := len(target.Key)
+	if len(result.Columns) < n {
+		return
+	}
+
+	result.Columns = result.Columns[n:]
+	if len(result.ColumnTypes) >= n {
+		result.ColumnTypes = result.ColumnTypes[n:]
+	}
+
+	result.Keys = make([][]any, len(result.Records))
+	for i, record := range result.Records {
+		key := make([]any, n)
+		for j := range n {
+			name := fmt.Sprintf("%s%d", editKeyPrefix, j)
+			key[j] = record[name]
+			delete(record, name)
+		}
+		result.Keys[i] = key
+	}
+
+	result.Editable = target
+}
+
+// SQLSaveRequest is the JSON request body for saving edited SQL explorer
+// result cells.
+type SQLSaveRequest struct {
+	// Query is the SELECT statement that produced the edited result.
+	Query string `json:"query"`
+
+	// Changes lists the edited rows.
+	Changes []SQLSaveChange `json:"changes"`
+
+	// Preview returns the UPDATE statements without executing them.
+	Preview bool `json:"preview"`
+}
+
+// SQLSaveChange holds the new values of one edited result row.
+type SQLSaveChange struct {
+	// Key holds the SQLEditTarget.Key values of the row, as sent in SQLExecResult.Keys.
+	Key []any `json:"key"`
+
+	// Values holds the new cell values keyed by result column name.
+	Values map[string]any `json:"values"`
+}
+
+// SQLSaveResponse is the JSON response of SQLSave.
+type SQLSaveResponse struct {
+	Status w2.Status `json:"status"`
+
+	// Statements lists the UPDATE statements with their values inlined for display.
+	Statements []string `json:"statements"`
+
+	// RowsAffected is the number of updated rows. It is zero for a preview.
+	RowsAffected int `json:"rowsAffected"`
+}
+
+// Write sends the save response as application/json.
+func (res SQLSaveResponse) Write(w http.ResponseWriter) error {
+	data, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(data)
+	return err
+}
+
+// ErrNotEditable is returned when a query result or column cannot be written back.
+var ErrNotEditable = errors.New("result is not editable")
+
+// ErrRowChanged is returned when an edited row no longer exists.
+var ErrRowChanged = errors.New("row was changed or removed")
+
+// SQLSave writes edited result cells back to the table of a simple
+// single-table SELECT, with one UPDATE per row keyed by rowid or primary key.
+//
+// All updates run in one transaction through w2db.SaveGrid, and the
+// transaction is rolled back with ErrRowChanged unless every key matches
+// exactly one row. Saving requires opts.Editable and UPDATE statements that
+// pass opts, and returns ErrNotEditable without opts.Editable.
+//
+// opts.Authorize receives the UPDATE statements before they run, and
+// opts.Audit records them. Previews are neither authorized nor recorded.
+func SQLSave(ctx context.Context, db *sql.DB, req SQLSaveRequest, opts SQLExecOptions) (SQLSaveResponse, error) {
+	begin := time.Now()
+	res, err := sqlSave(ctx, db, req, opts)
+
+	if !req.Preview {
+		record := AuditRecord{
+			Action:  AuditSave,
+			Query:   strings.Join(res.Statements, ";\n"),
+			Elapsed: time.Since(begin).Seconds(),
+			Rows:    int64(res.RowsAffected),
+		}
+		if record.Query == "" {
+			record.Query = req.Query
+		}
+		opts.audit(ctx, record, err)
+	}
+
+	return res, err
+}
+
+func sqlSave(ctx context.Context, db *sql.DB, req SQLSaveRequest, opts SQLExecOptions) (SQLSaveResponse, error) {
+	if !opts.Editable {
+		return SQLSaveResponse{}, fmt.Errorf("%w: editing is disabled", ErrNotEditable)
+	}
+
+	statements := splitStatements(req.Query)
+	if len(statements) != 1 || statements[0].Kind() != StatementSelect {
+		return SQLSaveResponse{}, fmt.Errorf("%w: query must be a single SELECT statement", ErrNotEditable)
+	}
+
+	if err := opts.Check(SQLExecRequest{Query: req.Query}); err != nil {
+		return SQLSaveResponse{}, err
+	}
+
+	if !opts.allowsEdit() {
+		return SQLSaveResponse{}, fmt.Errorf("%w: UPDATE", ErrStatementNotAllowed)
+	}
+
+	target, _, err := editTarget(ctx, db, statements[0])
+	if err != nil {
+		return SQLSaveResponse{}, err
+	} else if target == nil {
+		return SQLSaveResponse{}, fmt.Errorf("%w: query must select from one table without joins or grouping", ErrNotEditable)
+	}
+
+	changes := make([]w2db.UpdateOptions, 0, len(req.Changes))
+	res := SQLSaveResponse{Status: w2.StatusSuccess, Statements: []string{}}
+
+	for _, change := range req.Changes {
+		if len(change.Values) == 0 {
+			continue
+		}
+
+		update, err := target.updateOptions(change)
+		if err != nil {
+			return SQLSaveResponse{}, err
+		}
+
+		changes = append(changes, update)
+		res.Statements = append(res.Statements, updateStatement(update))
+	}
+
+	if req.Preview || len(changes) == 0 {
+		return res, nil
+	}
+
+	if err := opts.authorize(ctx, SQLExecRequest{Query: strings.Join(res.Statements, ";\n")}); err != nil {
+		return res, err
+	}
+
+	err = w2db.WithinTransactionContext(ctx, db, func(ctx context.Context, tx *sql.Tx) error {
+		affected, err := w2db.SaveGridContext(ctx, tx, w2.SaveGridRequest[w2db.UpdateOptions]{Changes: changes}, w2db.SaveGridOptions[w2db.UpdateOptions]{
+			BuildOptions: func(change w2db.UpdateOptions) w2db.UpdateOptions { return change },
+		})
+		if err != nil {
+			return err
+		}
+
+		if affected != len(changes) {
+			return fmt.Errorf("%w: %d of %d rows updated, no changes were saved", ErrRowChanged, affected, len(changes))
+		}
+
+		res.RowsAffected = affected
+		return nil
+	})
+
+	return res, err
+}
+
+// allowsEdit reports whether opts accept the UPDATE statements of SQLSave.
+func (opts SQLExecOptions) allowsEdit() bool {
+	return !opts.ReadOnly && (len(opts.Allow) == 0 || slices.Contains(opts.Allow, StatementUpdate))
+}
+
+// updateOptions converts change to an UPDATE of one row with trusted,
+// quoted column names.
+func (target SQLEditTarget) updateOptions(change SQLSaveChange) (w2db.UpdateOptions, error) {
+	if len(change.Key) != len(target.Key) {
+		return w2db.UpdateOptions{}, fmt.Errorf("%w: expected %d key values, got %d", ErrNotEditable, len(target.Key), len(change.Key))
+	}
+
+	update := w2db.UpdateOptions{
+		Update: quoteIdent(target.Schema) + "." + quoteIdent(target.Table),
+		Values: map[string]any{},
+		Where:  map[string]any{},
+		Flavor: sqlbuilder.SQLite,
+	}
+
+	for name, value := range change.Values {
+		column, ok := target.Columns[name]
+		if !ok {
+			return w2db.UpdateOptions{}, fmt.Errorf("%w: column %q", ErrNotEditable, name)
+		}
+		update.Values[quoteIdent(column)] = saveValue(value)
+	}
+
+	for i, key := range target.Key {
+		update.Where[quoteIdent(key)] = saveValue(change.Key[i])
+	}
+
+	return update, nil
+}
+
+// saveValue binds a json.Number as int64 or float64, so columns without type
+// affinity store numbers rather than text. Numbers that do not fit either
+// type are bound as text.
+func saveValue(value any) any {
+	number, ok := value.(json.Number)
+	if !ok {
+		return value
+	}
+
+	if n, err := number.Int64(); err == nil {
+		return n
+	}
+
+	// an integer beyond int64 would lose digits as a float
+	if f, err := number.Float64(); err == nil && strings.ContainsAny(number.String(), ".eE") {
+		return f
+	}
+
+	return number.String()
+}
+
+// updateStatement returns the UPDATE statement of update with its values
+// inlined, in a stable column order.
+func updateStatement(update w2db.UpdateOptions) string {
+	builder := sqlbuilder.Update(update.Update)
+
+	for _, column := range sortedKeys(update.Values) {
+		builder.SetMore(builder.Assign(column, update.Values[column]))
+	}
+
+	for _, column := range sortedKeys(update.Where) {
+		builder.Where(builder.EQ(column, update.Where[column]))
+	}
+
+	query, args := builder.BuildWithFlavor(sqlbuilder.SQLite)
+	statement, err := sqlbuilder.SQLite.Interpolate(query, args)
+	if err != nil {
+		return query
+	}
+
+	return statement + ";"
+}
+
+func sortedKeys(values map[string]any) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// decodeSaveRequest decodes numbers as json.Number, so integers keep their
+// precision and SQLite applies the column affinity to them.
+func decodeSaveRequest(r *http.Request) (SQLSaveRequest, error) {
+	var req SQLSaveRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	return req, decoder.Decode(&req)
+}
+
+// SQLSaveHandler returns an edit save handler that reports errors to the
+// caller instead of writing error responses itself.
+func SQLSaveHandler(db *sql.DB, opts SQLExecOptions) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decodeSaveRequest(r)
+		if err != nil {
+			return err
+		}
+
+		res, err := SQLSave(r.Context(), db, req, opts)
+		if err != nil {
+			return err
+		}
+
+		return res.Write(w)
+	}
+}
+
+// SQLSaveHTTPHandler returns an http.HandlerFunc that saves edited SQL
+// explorer result cells, or previews the UPDATE statements.
+//
+// Rejected statements return 403 Forbidden, results that cannot be written
+// back return 400 Bad Request, and rows that no longer match their key
+// return 409 Conflict.
+func SQLSaveHTTPHandler(db *sql.DB, opts SQLExecOptions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, err := decodeSaveRequest(r)
+		if err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusBadRequest)
+			return
+		}
+
+		res, err := SQLSave(r.Context(), db, req, opts)
+		if errors.Is(err, ErrStatementNotAllowed) || errors.Is(err, ErrConfirmRequired) || errors.Is(err, ErrAccessDenied) {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusForbidden)
+			return
+		} else if errors.Is(err, ErrNotEditable) {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusBadRequest)
+			return
+		} else if errors.Is(err, ErrRowChanged) {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusConflict)
+			return
+		} else if err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusInternalServerError)
+			return
+		}
+
+		res.Write(w)
+	}
+}
@@ -0,0 +1,164 @@
+package w2explorer_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/dv1x3r/w2go/w2explorer"
+)
+
+func TestSQLSave(t *testing.T) {
+	ctx := context.Background()
+	setup := []string{
+		`CREATE TABLE todo (id INTEGER PRIMARY KEY, name TEXT, quantity INTEGER)`,
+		`INSERT INTO todo (name, quantity) VALUES ('a', 1), ('b', 2), ('c', 3)`,
+	}
+	query := "SELECT id, name, quantity * 2 AS double FROM todo ORDER BY id"
+
+	// edit executes query and returns the row keys of its editable result
+	edit := func(t *testing.T, db *sql.DB, opts w2explorer.SQLExecOptions) [][]any {
+		t.Helper()
+
+		res, err := w2explorer.SQLExec(ctx, db, w2explorer.SQLExecRequest{Query: query}, opts)
+		if err != nil || len(res.Results) != 1 {
+			t.Fatalf("❌ Unexpected result: %+v %v", res, err)
+		}
+
+		result := res.Results[0]
+		if result.Editable == nil || result.Editable.Table != "todo" || len(result.Keys) != 3 {
+			t.Fatalf("❌ Expected an editable result, got: %+v", result)
+		}
+
+		if _, ok := result.Editable.Columns["double"]; ok {
+			t.Errorf("❌ Expected the expression column to be read-only, got: %v", result.Editable.Columns)
+		}
+
+		return result.Keys
+	}
+
+	names := func(t *testing.T, db *sql.DB) string {
+		t.Helper()
+
+		var names string
+		if err := db.QueryRow("SELECT group_concat(name, ',') FROM (SELECT name FROM todo ORDER BY id)").Scan(&names); err != nil {
+			t.Fatal(err)
+		}
+		return names
+	}
+
+	editable := w2explorer.SQLExecOptions{Editable: true}
+
+	t.Run("Save", func(t *testing.T) {
+		db := openTestDB(t, setup...)
+		keys := edit(t, db, editable)
+
+		req := w2explorer.SQLSaveRequest{Query: query, Changes: []w2explorer.SQLSaveChange{
+			{Key: keys[0], Values: map[string]any{"name": "x"}},
+			{Key: keys[2], Values: map[string]any{"name": "z"}},
+		}}
+
+		req.Preview = true
+		res, err := w2explorer.SQLSave(ctx, db, req, editable)
+		if err != nil || len(res.Statements) != 2 || res.RowsAffected != 0 || names(t, db) != "a,b,c" {
+			t.Errorf("❌ Expected a preview of 2 statements, got: %+v %v", res, err)
+		}
+
+		req.Preview = false
+		res, err = w2explorer.SQLSave(ctx, db, req, editable)
+		if err != nil || res.RowsAffected != 2 {
+			t.Errorf("❌ Expected 2 saved rows, got: %+v %v", res, err)
+		}
+
+		if actual := names(t, db); actual != "x,b,z" {
+			t.Errorf("❌ Expected names x,b,z, got: %s", actual)
+		}
+	})
+
+	t.Run("StaleRow", func(t *testing.T) {
+		db := openTestDB(t, setup...)
+		keys := edit(t, db, editable)
+
+		if _, err := db.Exec("DELETE FROM todo WHERE name = 'b'"); err != nil {
+			t.Fatal(err)
+		}
+
+		req := w2explorer.SQLSaveRequest{Query: query, Changes: []w2explorer.SQLSaveChange{
+			{Key: keys[0], Values: map[string]any{"name": "x"}},
+			{Key: keys[1], Values: map[string]any{"name": "y"}},
+		}}
+
+		if _, err := w2explorer.SQLSave(ctx, db, req, editable); !errors.Is(err, w2explorer.ErrRowChanged) {
+			t.Errorf("❌ Expected ErrRowChanged, got: %v", err)
+		}
+
+		if actual := names(t, db); actual != "a,c" {
+			t.Errorf("❌ Expected the transaction to be rolled back, got: %s", actual)
+		}
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		db := openTestDB(t, setup...)
+		keys := edit(t, db, editable)
+		change := []w2explorer.SQLSaveChange{{Key: keys[0], Values: map[string]any{"name": "x"}}}
+
+		tests := []struct {
+			Name     string
+			Req      w2explorer.SQLSaveRequest
+			Opts     w2explorer.SQLExecOptions
+			Expected error
+		}{
+			{
+				Name:     "NotEditable",
+				Req:      w2explorer.SQLSaveRequest{Query: query, Changes: change},
+				Opts:     w2explorer.SQLExecOptions{},
+				Expected: w2explorer.ErrNotEditable,
+			},
+			{
+				Name:     "ReadOnly",
+				Req:      w2explorer.SQLSaveRequest{Query: query, Changes: change},
+				Opts:     w2explorer.SQLExecOptions{Editable: true, ReadOnly: true},
+				Expected: w2explorer.ErrStatementNotAllowed,
+			},
+			{
+				Name:     "AllowSelect",
+				Req:      w2explorer.SQLSaveRequest{Query: query, Changes: change},
+				Opts:     w2explorer.SQLExecOptions{Editable: true, Allow: []w2explorer.StatementKind{w2explorer.StatementSelect}},
+				Expected: w2explorer.ErrStatementNotAllowed,
+			},
+			{
+				Name: "Authorize",
+				Req:  w2explorer.SQLSaveRequest{Query: query, Changes: change},
+				Opts: w2explorer.SQLExecOptions{Editable: true, Authorize: func(ctx context.Context, req w2explorer.SQLExecRequest) error {
+					return errors.New("no edits")
+				}},
+				Expected: w2explorer.ErrAccessDenied,
+			},
+			{
+				Name:     "ExpressionColumn",
+				Req:      w2explorer.SQLSaveRequest{Query: query, Changes: []w2explorer.SQLSaveChange{{Key: keys[0], Values: map[string]any{"double": 4}}}},
+				Opts:     editable,
+				Expected: w2explorer.ErrNotEditable,
+			},
+			{
+				Name:     "Join",
+				Req:      w2explorer.SQLSaveRequest{Query: "SELECT a.id, a.name FROM todo a JOIN todo b ON a.id = b.id", Changes: change},
+				Opts:     editable,
+				Expected: w2explorer.ErrNotEditable,
+			},
+		}
+
+		for _, test := range tests {
+			t.Run(test.Name, func(t *testing.T) {
+				if _, err := w2explorer.SQLSave(ctx, db, test.Req, test.Opts); !errors.Is(err, test.Expected) {
+					t.Errorf("❌ Expected %v, got: %v", test.Expected, err)
+				}
+
+				if actual := names(t, db); actual != "a,b,c" {
+					t.Errorf("❌ Expected no changes, got: %s", actual)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,517 @@
+package w2explorer
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dv1x3r/w2go/w2"
+)
+
+// ERDiagramOptions selects the tables and columns of an ERDiagram.
+type ERDiagramOptions struct {
+	// Databases limits the diagram to these attached databases. Empty
+	// includes every database.
+	Databases []string
+
+	// Include limits the diagram to tables matching one of these path.Match
+	// patterns, such as "todo" or "main.todo_*". Empty includes every table.
+	Include []string
+
+	// Exclude removes tables matching one of these path.Match patterns.
+	Exclude []string
+
+	// KeysOnly keeps only primary key, foreign key, and referenced columns.
+	KeysOnly bool
+
+	// GroupByDatabase lays out each attached database as its own cluster in
+	// DOT and qualifies entity names with the database name in Mermaid,
+	// which has no clusters.
+	GroupByDatabase bool
+}
+
+// ERDiagram is an entity-relationship graph built from the tables and
+// foreign keys of a Schema.
+type ERDiagram struct {
+	Entities      []EREntity       `json:"entities"`
+	Relationships []ERRelationship `json:"relationships"`
+
+	// Grouped is set by ERDiagramOptions.GroupByDatabase.
+	Grouped bool `json:"grouped"`
+}
+
+// EREntity is a table of an ERDiagram.
+type EREntity struct {
+	Database string     `json:"database"`
+	Name     string     `json:"name"`
+	Columns  []ERColumn `json:"columns"`
+}
+
+// ERColumn is a column of an EREntity.
+type ERColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	PK   bool   `json:"pk"`
+	FK   bool   `json:"fk"`
+}
+
+// ERRelationship is a foreign key from a child table to a parent table in
+// the same database.
+type ERRelationship struct {
+	Database string `json:"database"`
+
+	// From is the child table and To is the referenced parent table.
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	FromColumns []string `json:"fromColumns"`
+	ToColumns   []string `json:"toColumns"`
+
+	// Optional is true when a child foreign key column is nullable.
+	Optional bool `json:"optional"`
+
+	// Unique is true when the child columns are unique, so each parent row
+	// has at most one child row.
+	Unique bool `json:"unique"`
+}
+
+// NewERDiagram builds an entity-relationship graph from the tables of schema.
+//
+// Views, virtual tables, and shadow tables are left out. Foreign keys that
+// reference a table outside of the diagram are dropped.
+func NewERDiagram(schema Schema, opts ERDiagramOptions) ERDiagram {
+	diagram := ERDiagram{
+		Entities:      []EREntity{},
+		Relationships: []ERRelationship{},
+		Grouped:       opts.GroupByDatabase,
+	}
+
+	for _, database := range schema.Databases {
+		if len(opts.Databases) > 0 && !slices.Contains(opts.Databases, database.Name) {
+			continue
+		}
+
+		tables := map[string]SchemaTable{}
+		for _, table := range database.Tables {
+			if opts.includes(database.Name, table.Name) {
+				tables[table.Name] = table
+			}
+		}
+
+		// referenced holds the parent columns of every foreign key, by table
+		referenced := map[string][]string{}
+		for _, table := range database.Tables {
+			if _, ok := tables[table.Name]; !ok {
+				continue
+			}
+
+			for _, key := range table.ForeignKeys {
+				parent, ok := tables[key.Table]
+				if !ok {
+					continue
+				}
+
+				to := key.To
+				if len(to) == 0 {
+					to = primaryKey(parent)
+				}
+
+				referenced[parent.Name] = append(referenced[parent.Name], to...)
+				diagram.Relationships = append(diagram.Relationships, ERRelationship{
+					Database:    database.Name,
+					From:        table.Name,
+					To:          parent.Name,
+					FromColumns: key.From,
+					ToColumns:   to,
+					Optional:    isNullable(table, key.From),
+					Unique:      isUnique(table, key.From),
+				})
+			}
+		}
+
+		for _, table := range database.Tables {
+			if _, ok := tables[table.Name]; !ok {
+				continue
+			}
+
+			entity := EREntity{Database: database.Name, Name: table.Name, Columns: []ERColumn{}}
+			for _, column := range table.Columns {
+				fk := slices.ContainsFunc(table.ForeignKeys, func(key SchemaForeignKey) bool {
+					_, ok := tables[key.Table]
+					return ok && slices.Contains(key.From, column.Name)
+				})
+
+				if opts.KeysOnly && column.PK == 0 && !fk && !slices.Contains(referenced[table.Name], column.Name) {
+					continue
+				}
+
+				entity.Columns = append(entity.Columns, ERColumn{
+					Name: column.Name,
+					Type: column.Type,
+					PK:   column.PK > 0,
+					FK:   fk,
+				})
+			}
+
+			diagram.Entities = append(diagram.Entities, entity)
+		}
+	}
+
+	return diagram
+}
+
+func (opts ERDiagramOptions) includes(database, table string) bool {
+	matches := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, table); ok {
+				return true
+			}
+			if ok, _ := path.Match(pattern, database+"."+table); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(opts.Include) > 0 && !matches(opts.Include) {
+		return false
+	}
+
+	return !matches(opts.Exclude)
+}
+
+// primaryKey returns the primary key columns of table in key order.
+func primaryKey(table SchemaTable) []string {
+	columns := slices.Clone(table.Columns)
+	slices.SortFunc(columns, func(a, b SchemaColumn) int { return a.PK - b.PK })
+
+	var names []string
+	for _, column := range columns {
+		if column.PK > 0 {
+			names = append(names, column.Name)
+		}
+	}
+	return names
+}
+
+func isNullable(table SchemaTable, names []string) bool {
+	for _, column := range table.Columns {
+		if slices.Contains(names, column.Name) && !column.NotNull && column.PK == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// isUnique reports whether the primary key or a unique index of table covers
+// exactly the columns in names.
+func isUnique(table SchemaTable, names []string) bool {
+	sameColumns := func(columns []string) bool {
+		return len(columns) == len(names) && !slices.ContainsFunc(columns, func(c string) bool {
+			return !slices.Contains(names, c)
+		})
+	}
+
+	if sameColumns(primaryKey(table)) {
+		return true
+	}
+
+	return slices.ContainsFunc(table.Indexes, func(index SchemaIndex) bool {
+		return index.Unique && index.Where == "" && sameColumns(index.Columns)
+	})
+}
+
+// entityName returns the name an entity is shown with.
+func (d ERDiagram) entityName(database, table string) string {
+	if d.Grouped || len(d.databases()) > 1 {
+		return database + "." + table
+	}
+	return table
+}
+
+// databases returns the databases of the diagram entities in order.
+func (d ERDiagram) databases() []string {
+	var databases []string
+	for _, entity := range d.Entities {
+		if !slices.Contains(databases, entity.Database) {
+			databases = append(databases, entity.Database)
+		}
+	}
+	return databases
+}
+
+// Mermaid renders the diagram as a Mermaid erDiagram.
+//
+// Names that are not valid Mermaid identifiers are replaced by a sanitized
+// identifier and shown through an entity alias.
+func (d ERDiagram) Mermaid() string {
+	var sb strings.Builder
+	sb.WriteString("erDiagram\n")
+
+	for _, entity := range d.Entities {
+		name := d.entityName(entity.Database, entity.Name)
+		id := mermaidIdent(name)
+
+		sb.WriteString("    " + id)
+		if id != name {
+			sb.WriteString("[" + strconv.Quote(name) + "]")
+		}
+
+		// Mermaid rejects an empty attribute block
+		if len(entity.Columns) == 0 {
+			sb.WriteString("\n")
+			continue
+		}
+		sb.WriteString(" {\n")
+
+		for _, column := range entity.Columns {
+			columnType := column.Type
+			if columnType == "" {
+				columnType = "ANY"
+			}
+
+			sb.WriteString("        " + mermaidIdent(columnType) + " " + mermaidIdent(column.Name))
+
+			var keys []string
+			if column.PK {
+				keys = append(keys, "PK")
+			}
+			if column.FK {
+				keys = append(keys, "FK")
+			}
+			if len(keys) > 0 {
+				sb.WriteString(" " + strings.Join(keys, ", "))
+			}
+
+			if mermaidIdent(column.Name) != column.Name {
+				sb.WriteString(" " + strconv.Quote(column.Name))
+			}
+			sb.WriteString("\n")
+		}
+
+		sb.WriteString("    }\n")
+	}
+
+	for _, rel := range d.Relationships {
+		// the parent side is always exactly one row, the child side zero or more, or zero or one when unique
+		child := "}o"
+		if rel.Unique {
+			child = "|o"
+		}
+
+		parent := "||"
+		if rel.Optional {
+			parent = "o|"
+		}
+
+		from := mermaidIdent(d.entityName(rel.Database, rel.From))
+		to := mermaidIdent(d.entityName(rel.Database, rel.To))
+		label := strconv.Quote(strings.Join(rel.FromColumns, ", "))
+		fmt.Fprintf(&sb, "    %s %s--%s %s : %s\n", from, child, parent, to, label)
+	}
+
+	return sb.String()
+}
+
+// mermaidIdent replaces characters that Mermaid does not accept in entity,
+// attribute, and type names with underscores.
+func mermaidIdent(name string) string {
+	ident := []rune(name)
+	for i, r := range ident {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+		case r >= '0' && r <= '9', r == '-', r == '(', r == ')', r == '[', r == ']':
+			if i == 0 {
+				ident[i] = '_'
+			}
+		default:
+			ident[i] = '_'
+		}
+	}
+	return string(ident)
+}
+
+// DOT renders the diagram as a Graphviz digraph with one HTML-like table
+// label per entity and one edge per foreign key.
+func (d ERDiagram) DOT() string {
+	var sb strings.Builder
+	sb.WriteString("digraph erd {\n")
+	sb.WriteString("    graph [rankdir=LR];\n")
+	sb.WriteString("    node [shape=plaintext];\n")
+	sb.WriteString("    edge [arrowhead=none, arrowtail=crow, dir=both];\n")
+
+	writeEntity := func(entity EREntity, indent string) {
+		fmt.Fprintf(&sb, "%s%s [label=<<table border=\"0\" cellborder=\"1\" cellspacing=\"0\">", indent, dotID(entity.Database, entity.Name))
+		fmt.Fprintf(&sb, "<tr><td bgcolor=\"lightgrey\"><b>%s</b></td></tr>", html.EscapeString(entity.Name))
+
+		for i, column := range entity.Columns {
+			text := html.EscapeString(column.Name)
+			if column.PK {
+				text = "<u>" + text + "</u>"
+			}
+			if column.Type != "" {
+				text += " " + html.EscapeString(column.Type)
+			}
+			if column.FK {
+				text += " (FK)"
+			}
+			fmt.Fprintf(&sb, "<tr><td align=\"left\" port=\"c%d\">%s</td></tr>", i, text)
+		}
+
+		sb.WriteString("</table>>];\n")
+	}
+
+	if d.Grouped {
+		for _, database := range d.databases() {
+			fmt.Fprintf(&sb, "    subgraph %s {\n", strconv.Quote("cluster_"+database))
+			fmt.Fprintf(&sb, "        label=%s;\n", strconv.Quote(database))
+			for _, entity := range d.Entities {
+				if entity.Database == database {
+					writeEntity(entity, "        ")
+				}
+			}
+			sb.WriteString("    }\n")
+		}
+	} else {
+		for _, entity := range d.Entities {
+			writeEntity(entity, "    ")
+		}
+	}
+
+	for _, rel := range d.Relationships {
+		from := dotID(rel.Database, rel.From) + d.dotPort(rel.Database, rel.From, rel.FromColumns)
+		to := dotID(rel.Database, rel.To) + d.dotPort(rel.Database, rel.To, rel.ToColumns)
+
+		style := ""
+		if rel.Optional {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(&sb, "    %s -> %s [label=%s%s];\n", from, to, strconv.Quote(strings.Join(rel.FromColumns, ", ")), style)
+	}
+
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// dotID returns the quoted node ID of a table, which is unique across databases.
+func dotID(database, table string) string {
+	return strconv.Quote(database + "." + table)
+}
+
+// dotPort returns the port of the first column in columns, or an empty
+// string when the column is not shown.
+func (d ERDiagram) dotPort(database, table string, columns []string) string {
+	if len(columns) == 0 {
+		return ""
+	}
+
+	for _, entity := range d.Entities {
+		if entity.Database != database || entity.Name != table {
+			continue
+		}
+
+		for i, column := range entity.Columns {
+			if column.Name == columns[0] {
+				return fmt.Sprintf(":c%d", i)
+			}
+		}
+	}
+
+	return ""
+}
+
+// ERDiagramHandler returns an ER diagram handler that reports errors to the
+// caller instead of writing error responses itself.
+func ERDiagramHandler(provider SchemaProvider) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		format, opts, err := parseERDiagramQuery(r)
+		if err != nil {
+			return err
+		}
+
+		schema, err := provider.Schema(r.Context(), SchemaOptions{})
+		if err != nil {
+			return err
+		}
+
+		return writeERDiagram(w, NewERDiagram(schema, opts), format)
+	}
+}
+
+// ERDiagramHTTPHandler returns an http.HandlerFunc that writes the schema as
+// a Mermaid or DOT entity-relationship diagram.
+//
+// The query parameters are "format" ("mermaid" by default, or "dot"),
+// "database", "include", and "exclude", which may be repeated or comma
+// separated, and the "keysOnly" and "group" booleans.
+func ERDiagramHTTPHandler(provider SchemaProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format, opts, err := parseERDiagramQuery(r)
+		if err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusBadRequest)
+			return
+		}
+
+		schema, err := provider.Schema(r.Context(), SchemaOptions{})
+		if err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusInternalServerError)
+			return
+		}
+
+		writeERDiagram(w, NewERDiagram(schema, opts), format)
+	}
+}
+
+func parseERDiagramQuery(r *http.Request) (string, ERDiagramOptions, error) {
+	query := r.URL.Query()
+
+	list := func(key string) []string {
+		var values []string
+		for _, value := range query[key] {
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					values = append(values, item)
+				}
+			}
+		}
+		return values
+	}
+
+	format := query.Get("format")
+	if format == "" {
+		format = "mermaid"
+	}
+
+	if format != "mermaid" && format != "dot" {
+		return "", ERDiagramOptions{}, fmt.Errorf("unknown diagram format %q", format)
+	}
+
+	keysOnly, _ := strconv.ParseBool(query.Get("keysOnly"))
+	group, _ := strconv.ParseBool(query.Get("group"))
+
+	return format, ERDiagramOptions{
+		Databases:       list("database"),
+		Include:         list("include"),
+		Exclude:         list("exclude"),
+		KeysOnly:        keysOnly,
+		GroupByDatabase: group,
+	}, nil
+}
+
+func writeERDiagram(w http.ResponseWriter, diagram ERDiagram, format string) error {
+	text, name := diagram.Mermaid(), "schema.mmd"
+	if format == "dot" {
+		text, name = diagram.DOT(), "schema.dot"
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", name))
+	_, err := w.Write([]byte(text))
+	return err
+}
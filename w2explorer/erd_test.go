@@ -0,0 +1,97 @@
+package w2explorer_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dv1x3r/w2go/w2explorer"
+)
+
+func TestNewERDiagram(t *testing.T) {
+	schema := w2explorer.Schema{Databases: []w2explorer.SchemaDatabase{{
+		Name: "main",
+		Tables: []w2explorer.SchemaTable{
+			{
+				Name:    "status",
+				Columns: []w2explorer.SchemaColumn{{Name: "id", Type: "INTEGER", PK: 1}, {Name: "name", Type: "TEXT"}},
+			},
+			{
+				Name: "todo",
+				Columns: []w2explorer.SchemaColumn{
+					{Name: "id", Type: "INTEGER", PK: 1},
+					{Name: "name", Type: "TEXT"},
+					{Name: "status_id", Type: "INTEGER", NotNull: true},
+					{Name: "owner id", Type: "INTEGER"},
+				},
+				ForeignKeys: []w2explorer.SchemaForeignKey{
+					{Table: "status", From: []string{"status_id"}, To: []string{"id"}},
+					{Table: "user", From: []string{"owner id"}, To: []string{}},
+				},
+			},
+			{
+				Name:        "user",
+				Columns:     []w2explorer.SchemaColumn{{Name: "id", Type: "INTEGER", PK: 1}},
+				ForeignKeys: []w2explorer.SchemaForeignKey{},
+			},
+		},
+	}}}
+
+	t.Run("Mermaid", func(t *testing.T) {
+		diagram := w2explorer.NewERDiagram(schema, w2explorer.ERDiagramOptions{})
+		expected := `erDiagram
+    status {
+        INTEGER id PK
+        TEXT name
+    }
+    todo {
+        INTEGER id PK
+        TEXT name
+        INTEGER status_id FK
+        INTEGER owner_id FK "owner id"
+    }
+    user {
+        INTEGER id PK
+    }
+    todo }o--|| status : "status_id"
+    todo }o--o| user : "owner id"
+`
+		if actual := diagram.Mermaid(); actual != expected {
+			t.Errorf("❌ Expected:\n%s\nActual:\n%s", expected, actual)
+		}
+	})
+
+	t.Run("KeysOnly", func(t *testing.T) {
+		diagram := w2explorer.NewERDiagram(schema, w2explorer.ERDiagramOptions{KeysOnly: true, Exclude: []string{"main.user"}})
+		if len(diagram.Entities) != 2 || len(diagram.Relationships) != 1 {
+			t.Fatalf("❌ Expected 2 entities and 1 relationship, got: %+v", diagram)
+		}
+		for _, entity := range diagram.Entities {
+			for _, column := range entity.Columns {
+				if column.Name == "name" || column.Name == "owner id" {
+					t.Errorf("❌ Unexpected column %s.%s", entity.Name, column.Name)
+				}
+			}
+		}
+	})
+
+	t.Run("Include", func(t *testing.T) {
+		diagram := w2explorer.NewERDiagram(schema, w2explorer.ERDiagramOptions{Include: []string{"t*"}})
+		if len(diagram.Entities) != 1 || len(diagram.Relationships) != 0 {
+			t.Errorf("❌ Expected only todo without relationships, got: %+v", diagram)
+		}
+	})
+
+	t.Run("DOT", func(t *testing.T) {
+		diagram := w2explorer.NewERDiagram(schema, w2explorer.ERDiagramOptions{GroupByDatabase: true})
+		dot := diagram.DOT()
+		for _, expected := range []string{
+			`subgraph "cluster_main" {`,
+			`"main.todo":c2 -> "main.status":c0 [label="status_id"];`,
+			`"main.todo":c3 -> "main.user":c0 [label="owner id", style=dashed];`,
+		} {
+			if !strings.Contains(dot, expected) {
+				t.Errorf("❌ Expected %q in:\n%s", expected, dot)
+			}
+		}
+	})
+}
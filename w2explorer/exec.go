@@ -0,0 +1,467 @@
+package w2explorer
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/dv1x3r/w2go/w2"
+	"github.com/dv1x3r/w2go/w2db"
+	"github.com/huandu/go-sqlbuilder"
+)
+
+// SQLExec checks req.Query against opts and executes it statement by
+// statement for the SQL explorer.
+//
+// Statements run in order on one dedicated connection, so BEGIN and COMMIT
+// in the script work as expected. Statements that return rows are scanned
+// into result sets, and other statements report RowsAffected and
+// LastInsertID. Execution stops at the first failed statement, whose error is
+// reported in the response rather than returned. The returned error is only
+// set when the script is rejected by opts or cannot be started.
+//
+// In read-only mode the connection has PRAGMA query_only enabled, which is
+// reset before the connection returns to the pool. A transaction the script
+// left open, such as BEGIN followed by a failed statement, is rolled back.
+//
+// opts.Timeout and opts.Monitor cancel the context of a query that runs too
+// long or is killed, and the driver interrupts the running statement. The
+// failed statement then reports ErrQueryTimeout or ErrQueryKilled.
+//
+// When opts.History is set, the script is recorded after the connection is
+// released. A failure to record it is logged and does not fail the request.
+// opts.Audit records the script the same way, with secrets redacted.
+func SQLExec(ctx context.Context, db *sql.DB, req SQLExecRequest, opts SQLExecOptions) (SQLExecResponse, error) {
+	res, err := sqlExec(ctx, db, req, opts)
+
+	isPaging := req.Grid.Offset > 0 || len(req.Grid.Sort) > 0 || len(req.Grid.Search) > 0
+	if opts.History != nil && !isPaging && strings.TrimSpace(req.Query) != "" {
+		entry := HistoryEntry{
+			User:    UserFromContext(ctx),
+			Query:   req.Query,
+			Elapsed: res.Elapsed,
+			Rows:    res.rows(),
+		}
+
+		if err != nil {
+			entry.Error = err.Error()
+		} else {
+			entry.Error = res.Message
+		}
+
+		if err := opts.History.Record(context.WithoutCancel(ctx), entry); err != nil {
+			slog.ErrorContext(ctx, "w2explorer: record query history", "error", err)
+		}
+	}
+
+	if strings.TrimSpace(req.Query) != "" {
+		record := AuditRecord{
+			Action:  AuditExec,
+			Query:   req.Query,
+			Params:  req.Params,
+			Elapsed: res.Elapsed,
+			Rows:    res.rows(),
+			Error:   res.Message,
+		}
+		opts.audit(ctx, record, err)
+	}
+
+	return res, err
+}
+
+func sqlExec(ctx context.Context, db *sql.DB, req SQLExecRequest, opts SQLExecOptions) (SQLExecResponse, error) {
+	if strings.TrimSpace(req.Query) == "" {
+		return SQLExecResponse{}, errors.New("query is empty")
+	}
+
+	if err := opts.Check(req); err != nil {
+		return SQLExecResponse{}, err
+	}
+
+	if err := opts.authorize(ctx, req); err != nil {
+		return SQLExecResponse{}, err
+	}
+
+	params, err := sqlParamValues(req.Params)
+	if err != nil {
+		return SQLExecResponse{}, err
+	}
+
+	ctx, id, done, err := opts.begin(ctx, req.QueryID, req.Query)
+	if err != nil {
+		return SQLExecResponse{}, err
+	}
+	defer done()
+
+	res, err := sqlExecConn(ctx, db, req, params, opts)
+	if cause := interruption(ctx); cause != nil {
+		if err != nil {
+			err = cause
+		} else if res.Status == w2.StatusError {
+			res.Message = cause.Error()
+			if last := len(res.Results) - 1; last >= 0 && res.Results[last].Status == w2.StatusError {
+				res.Results[last].Message = cause.Error()
+			}
+		}
+	}
+
+	res.QueryID = id
+	return res, err
+}
+
+// readOnlyConn returns a dedicated connection of db and a function that
+// returns it to the pool. When readOnly is set, the connection has PRAGMA
+// query_only enabled until it is released.
+//
+// Release rolls back a transaction that a script left open with BEGIN or
+// SAVEPOINT, so the next user of the pooled connection does not see its
+// uncommitted changes. A connection that cannot be reset is discarded.
+func readOnlyConn(ctx context.Context, db *sql.DB, readOnly bool) (*sql.Conn, func(), error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if readOnly {
+		if _, err := conn.ExecContext(ctx, "PRAGMA query_only = ON"); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+	}
+
+	release := func() {
+		// use a fresh context so a cancelled request still resets the pooled connection
+		ctx := context.Background()
+
+		// BEGIN fails when a transaction is still open, and either way
+		// ROLLBACK leaves the connection in autocommit mode
+		conn.ExecContext(ctx, "BEGIN")
+		_, err := conn.ExecContext(ctx, "ROLLBACK")
+
+		if err == nil && readOnly {
+			_, err = conn.ExecContext(ctx, "PRAGMA query_only = OFF")
+		}
+
+		if err != nil {
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}
+
+	return conn, release, nil
+}
+
+func sqlExecConn(ctx context.Context, db *sql.DB, req SQLExecRequest, params map[string]any, opts SQLExecOptions) (SQLExecResponse, error) {
+	conn, release, err := readOnlyConn(ctx, db, opts.ReadOnly)
+	if err != nil {
+		return SQLExecResponse{}, err
+	}
+	defer release()
+
+	if !req.Transaction {
+		return sqlExecScript(ctx, conn, req, params, opts), nil
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return SQLExecResponse{}, err
+	}
+	defer tx.Rollback()
+
+	res := sqlExecScript(ctx, tx, req, params, opts)
+	if res.Status == w2.StatusError {
+		res.RolledBack = tx.Rollback() == nil
+		return res, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		res.Status = w2.StatusError
+		res.Message = err.Error()
+	}
+
+	return res, nil
+}
+
+func sqlExecScript(ctx context.Context, db w2db.QueryExecer, req SQLExecRequest, params map[string]any, opts SQLExecOptions) SQLExecResponse {
+	statements := splitStatements(req.Query)
+
+	res := SQLExecResponse{
+		Status:  w2.StatusSuccess,
+		Results: make([]SQLExecResult, 0, len(statements)),
+	}
+
+	begin := time.Now()
+
+	for _, stmt := range statements {
+		result := sqlExecStatement(ctx, db, stmt, req.Grid, params, opts, len(statements) == 1)
+		res.Results = append(res.Results, result)
+
+		if result.Status == w2.StatusError {
+			res.Status = w2.StatusError
+			res.Message = result.Message
+			break
+		}
+	}
+
+	res.Elapsed = time.Since(begin).Seconds()
+	return res
+}
+
+// rows returns the number of rows returned plus the number of rows affected
+// by all statements.
+func (res SQLExecResponse) rows() int64 {
+	var rows int64
+	for _, result := range res.Results {
+		rows += int64(result.Total) + result.RowsAffected
+	}
+	return rows
+}
+
+// sqlExecStatement executes one statement with the parameter values it uses.
+// A single SELECT statement is paged when the w2grid request asks for it.
+func sqlExecStatement(ctx context.Context, db w2db.QueryExecer, stmt statement, grid w2.GetGridRequest, params map[string]any, opts SQLExecOptions, single bool) SQLExecResult {
+	var result SQLExecResult
+	var err error
+
+	kind := stmt.Kind()
+	begin := time.Now()
+
+	// an editable SELECT also selects the row keys, and a query that cannot
+	// be analyzed simply stays read-only
+	query := stmt.Text
+	var target *SQLEditTarget
+	if opts.Editable && opts.allowsEdit() && kind == StatementSelect {
+		if editable, edited, err := editTarget(ctx, db, stmt); err == nil && editable != nil {
+			target, query = editable, edited
+		}
+	}
+
+	switch {
+	case single && kind == StatementSelect && (grid.Limit > 0 || len(grid.Sort) > 0 || len(grid.Search) > 0):
+		result, err = sqlExecPaged(ctx, db, query, params, grid, opts.MaxRows)
+	case stmt.ReturnsRows():
+		result, err = sqlExecQuery(ctx, db, query, params, opts.MaxRows)
+	default:
+		result, err = sqlExecExec(ctx, db, query, params, kind)
+	}
+
+	if err != nil {
+		result = NewSQLExecResult([]string{}, []SQLExecRow{}, 0)
+		result.Status = w2.StatusError
+		result.Message = err.Error()
+	} else if target != nil {
+		moveKeys(&result, target)
+	}
+
+	result.Statement = stmt.Text
+	result.Kind = kind
+	result.Elapsed = time.Since(begin).Seconds()
+	return result
+}
+
+// SQLExecQuery executes query and scans the returned rows for the SQL explorer.
+//
+// Empty queries return an error. The query text is executed as-is, so callers
+// must restrict access to this function when query text comes from a user.
+func SQLExecQuery(ctx context.Context, db w2db.QueryExecer, query string) (SQLExecResult, error) {
+	if strings.TrimSpace(query) == "" {
+		return SQLExecResult{}, errors.New("query is empty")
+	}
+
+	return sqlExecQuery(ctx, db, query, nil, 0)
+}
+
+func sqlExecQuery(ctx context.Context, db w2db.QueryExecer, query string, params map[string]any, maxRows int) (SQLExecResult, error) {
+	query, args, err := bindArgs(query, params)
+	if err != nil {
+		return SQLExecResult{}, err
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return SQLExecResult{}, err
+	}
+	defer rows.Close()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return SQLExecResult{}, err
+	}
+
+	columns := newSQLExecColumns(types)
+	records := []SQLExecRow{}
+	truncated := false
+
+	for rows.Next() {
+		if maxRows > 0 && len(records) == maxRows {
+			truncated = true
+			break
+		}
+
+		record, err := scanRow(rows, columns)
+		if err != nil {
+			return SQLExecResult{}, err
+		}
+
+		records = append(records, record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return SQLExecResult{}, err
+	}
+
+	res := NewSQLExecResult(columnNames(columns), records, len(records))
+	res.ColumnTypes = columns
+	res.Truncated = truncated
+	return res, nil
+}
+
+// sqlExecExec executes a statement that returns no rows and reports the rows
+// affected by INSERT, UPDATE, and DELETE and the last rowid of INSERT.
+func sqlExecExec(ctx context.Context, db w2db.QueryExecer, query string, params map[string]any, kind StatementKind) (SQLExecResult, error) {
+	query, args, err := bindArgs(query, params)
+	if err != nil {
+		return SQLExecResult{}, err
+	}
+
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return SQLExecResult{}, err
+	}
+
+	res := NewSQLExecResult([]string{}, []SQLExecRow{}, 0)
+
+	// SQLite keeps the change count of the last DML statement,
+	// so other statements would report a stale value
+	switch kind {
+	case StatementInsert:
+		res.RowsAffected, _ = result.RowsAffected()
+		res.LastInsertID, _ = result.LastInsertId()
+	case StatementUpdate, StatementDelete:
+		res.RowsAffected, _ = result.RowsAffected()
+	}
+
+	return res, nil
+}
+
+// sqlExecPaged wraps a single SELECT statement as a subquery and applies the
+// w2grid limit, offset, sort, and search to it with w2db.GetGrid. Every
+// result column is whitelisted for search and sort.
+func sqlExecPaged(ctx context.Context, db w2db.QueryExecer, query string, params map[string]any, grid w2.GetGridRequest, maxRows int) (SQLExecResult, error) {
+	// the newlines keep a trailing line comment from swallowing the closing parenthesis
+	subquery := "(\n" + query + "\n)"
+
+	bound, args, err := bindArgs(subquery, params)
+	if err != nil {
+		return SQLExecResult{}, err
+	}
+
+	columns, err := selectColumns(ctx, db, "SELECT * FROM "+bound+" LIMIT 0", args...)
+	if err != nil {
+		return SQLExecResult{}, err
+	}
+
+	mapping := make(map[string]string, len(columns))
+	for _, column := range columns {
+		mapping[column.Name] = quoteIdent(column.Name)
+	}
+
+	scanned := false
+
+	truncated := false
+	if maxRows > 0 && (grid.Limit <= 0 || grid.Limit > maxRows) {
+		grid.Limit = maxRows
+		truncated = true
+	}
+
+	res, err := w2db.GetGridContext(ctx, db, grid, w2db.GetGridOptions[SQLExecRow]{
+		// Escape keeps "$" in the query text from being read as a builder placeholder
+		From:    sqlbuilder.Escape(subquery),
+		Select:  []string{"*"},
+		Where:   mapping,
+		OrderBy: mapping,
+		Build: func(sb *sqlbuilder.SelectBuilder) {
+			// parameters become builder variables, which are bound before the search values
+			if len(args) > 0 {
+				from, _ := bindParams(subquery, params, sqlbuilder.Escape, sb.Var)
+				sb.From(from)
+			}
+		},
+		Scan: func(rows *sql.Rows, record *SQLExecRow) error {
+			// the driver reports scan types only once it has a row
+			if !scanned {
+				if types, err := rows.ColumnTypes(); err == nil {
+					columns = newSQLExecColumns(types)
+				}
+				scanned = true
+			}
+			row, err := scanRow(rows, columns)
+			*record = row
+			return err
+		},
+		Flavor: sqlbuilder.SQLite,
+	})
+	if err != nil {
+		return SQLExecResult{}, err
+	}
+
+	result := NewSQLExecResult(columnNames(columns), res.Records, res.Total)
+	result.ColumnTypes = columns
+	result.Truncated = truncated && res.Total > grid.Offset+len(res.Records)
+	result.Paged = true
+	return result, nil
+}
+
+// selectColumns returns the result columns of query.
+func selectColumns(ctx context.Context, db w2db.QueryExecer, query string, args ...any) ([]SQLExecColumn, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	return newSQLExecColumns(types), nil
+}
+
+// columnNames returns the names of columns.
+func columnNames(columns []SQLExecColumn) []string {
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = column.Name
+	}
+	return names
+}
+
+// scanRow scans the current row into a record keyed by column name.
+func scanRow(rows *sql.Rows, columns []SQLExecColumn) (SQLExecRow, error) {
+	values := make([]any, len(columns))
+	valuePtrs := make([]any, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+
+	if err := rows.Scan(valuePtrs...); err != nil {
+		return nil, err
+	}
+
+	record := SQLExecRow{}
+	for i, column := range columns {
+		record[column.Name] = resultValue(values[i], column)
+	}
+
+	return record, nil
+}
+
+// quoteIdent quotes name as a SQLite identifier.
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,287 @@
+package w2explorer_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dv1x3r/w2go/w2"
+	"github.com/dv1x3r/w2go/w2explorer"
+)
+
+func TestSQLExec(t *testing.T) {
+	ctx := context.Background()
+	setup := []string{
+		`CREATE TABLE todo (id INTEGER PRIMARY KEY, name TEXT, quantity INTEGER)`,
+		`INSERT INTO todo (name, quantity) VALUES ('a', 5), ('b', 2), ('c', 4), ('d', 1), ('e', 3)`,
+	}
+
+	// names returns the name column of a result
+	names := func(result w2explorer.SQLExecResult) []any {
+		var names []any
+		for _, record := range result.Records {
+			names = append(names, record["name"])
+		}
+		return names
+	}
+
+	t.Run("Paging", func(t *testing.T) {
+		db := openTestDB(t, setup...)
+
+		tests := []struct {
+			Name      string
+			Query     string
+			Grid      w2.GetGridRequest
+			MaxRows   int
+			Expected  []any
+			Total     int
+			Paged     bool
+			Truncated bool
+		}{
+			{
+				Name:     "All",
+				Query:    "SELECT name FROM todo ORDER BY id",
+				Expected: []any{"a", "b", "c", "d", "e"},
+				Total:    5,
+			},
+			{
+				Name:      "MaxRows",
+				Query:     "SELECT name FROM todo ORDER BY id",
+				MaxRows:   2,
+				Expected:  []any{"a", "b"},
+				Total:     2,
+				Truncated: true,
+			},
+			{
+				Name:     "LimitOffset",
+				Query:    "SELECT name FROM todo ORDER BY id",
+				Grid:     w2.GetGridRequest{Limit: 2, Offset: 1},
+				Expected: []any{"b", "c"},
+				Total:    5,
+				Paged:    true,
+			},
+			{
+				Name:     "Sort",
+				Query:    "SELECT name, quantity FROM todo",
+				Grid:     w2.GetGridRequest{Limit: 3, Sort: []w2.GridSort{{Field: "quantity", Direction: "desc"}}},
+				Expected: []any{"a", "c", "e"},
+				Total:    5,
+				Paged:    true,
+			},
+			{
+				Name:     "Search",
+				Query:    "SELECT name, quantity FROM todo -- trailing comment",
+				Grid:     w2.GetGridRequest{Limit: 10, Search: []w2.GridSearch{{Field: "quantity", Operator: ">", Value: 2}}, SearchLogic: "AND"},
+				Expected: []any{"a", "c", "e"},
+				Total:    3,
+				Paged:    true,
+			},
+			{
+				Name:      "PagedMaxRows",
+				Query:     "SELECT name FROM todo ORDER BY id",
+				Grid:      w2.GetGridRequest{Limit: 10, Offset: 1},
+				MaxRows:   3,
+				Expected:  []any{"b", "c", "d"},
+				Total:     5,
+				Paged:     true,
+				Truncated: true,
+			},
+		}
+
+		for _, test := range tests {
+			t.Run(test.Name, func(t *testing.T) {
+				req := w2explorer.SQLExecRequest{Query: test.Query, Grid: test.Grid}
+				res, err := w2explorer.SQLExec(ctx, db, req, w2explorer.SQLExecOptions{MaxRows: test.MaxRows})
+				if err != nil || res.Status != w2.StatusSuccess || len(res.Results) != 1 {
+					t.Fatalf("❌ Unexpected response: %+v %v", res, err)
+				}
+
+				result := res.Results[0]
+				if actual := names(result); !equalValues(actual, test.Expected) {
+					t.Errorf("❌ Expected %v, got: %v", test.Expected, actual)
+				}
+
+				if result.Total != test.Total || result.Paged != test.Paged || result.Truncated != test.Truncated {
+					t.Errorf("❌ Expected total %d, paged %t, truncated %t, got: %d %t %t", test.Total, test.Paged, test.Truncated, result.Total, result.Paged, result.Truncated)
+				}
+			})
+		}
+	})
+
+	t.Run("ReadOnly", func(t *testing.T) {
+		db := openTestDB(t, setup...)
+		db.SetMaxOpenConns(1)
+		opts := w2explorer.SQLExecOptions{ReadOnly: true}
+
+		for _, query := range []string{"DELETE FROM todo", "SELECT 1; UPDATE todo SET name = 'x'", "PRAGMA user_version = 1", "CREATE TABLE x (id)"} {
+			if _, err := w2explorer.SQLExec(ctx, db, w2explorer.SQLExecRequest{Query: query}, opts); !errors.Is(err, w2explorer.ErrStatementNotAllowed) {
+				t.Errorf("❌ Expected ErrStatementNotAllowed for %q, got: %v", query, err)
+			}
+		}
+
+		res, err := w2explorer.SQLExec(ctx, db, w2explorer.SQLExecRequest{Query: "PRAGMA query_only"}, opts)
+		if err != nil || len(res.Results) != 1 || len(res.Results[0].Records) != 1 || res.Results[0].Records[0]["query_only"] != int64(1) {
+			t.Errorf("❌ Expected query_only to be on, got: %+v %v", res, err)
+		}
+
+		// the pooled connection is reset after a read-only execution
+		res, err = w2explorer.SQLExec(ctx, db, w2explorer.SQLExecRequest{Query: "DELETE FROM todo WHERE id = 1"}, w2explorer.SQLExecOptions{})
+		if err != nil || res.Status != w2.StatusSuccess || res.Results[0].RowsAffected != 1 {
+			t.Errorf("❌ Expected the delete to succeed, got: %+v %v", res, err)
+		}
+	})
+
+	t.Run("Allow", func(t *testing.T) {
+		db := openTestDB(t, setup...)
+		opts := w2explorer.SQLExecOptions{
+			Allow:              []w2explorer.StatementKind{w2explorer.StatementSelect, w2explorer.StatementUpdate},
+			ConfirmDestructive: true,
+		}
+
+		tests := []struct {
+			Query    string
+			Confirm  bool
+			Expected error
+		}{
+			{Query: "SELECT * FROM todo"},
+			{Query: "UPDATE todo SET name = 'x' WHERE id = 1"},
+			{Query: "UPDATE todo SET name = 'x'", Expected: w2explorer.ErrConfirmRequired},
+			{Query: "UPDATE todo SET name = 'x'", Confirm: true},
+			{Query: "DELETE FROM todo WHERE id = 1", Expected: w2explorer.ErrStatementNotAllowed},
+			{Query: "SELECT 1; INSERT INTO todo (name) VALUES ('f')", Expected: w2explorer.ErrStatementNotAllowed},
+		}
+
+		for _, test := range tests {
+			_, err := w2explorer.SQLExec(ctx, db, w2explorer.SQLExecRequest{Query: test.Query, Confirm: test.Confirm}, opts)
+			if !errors.Is(err, test.Expected) {
+				t.Errorf("❌ Expected %v for %q, got: %v", test.Expected, test.Query, err)
+			}
+		}
+
+		var count int
+		if err := db.QueryRow("SELECT count(*) FROM todo").Scan(&count); err != nil || count != 5 {
+			t.Errorf("❌ Expected the rejected statements not to run, got: %d %v", count, err)
+		}
+	})
+
+	t.Run("Script", func(t *testing.T) {
+		db := openTestDB(t, setup...)
+
+		query := `INSERT INTO todo (name) VALUES ('f'), ('g');
+UPDATE todo SET quantity = 0 WHERE name IN ('f', 'g');
+SELECT count(*) AS total FROM todo;
+SELECT name FROM todo WHERE quantity = 0 ORDER BY id`
+
+		res, err := w2explorer.SQLExec(ctx, db, w2explorer.SQLExecRequest{Query: query}, w2explorer.SQLExecOptions{})
+		if err != nil || res.Status != w2.StatusSuccess || len(res.Results) != 4 {
+			t.Fatalf("❌ Expected 4 results, got: %+v %v", res, err)
+		}
+
+		insert, update, count, rows := res.Results[0], res.Results[1], res.Results[2], res.Results[3]
+		if insert.Kind != w2explorer.StatementInsert || insert.RowsAffected != 2 || insert.LastInsertID != 7 {
+			t.Errorf("❌ Expected 2 inserted rows up to rowid 7, got: %+v", insert)
+		}
+		if update.Kind != w2explorer.StatementUpdate || update.RowsAffected != 2 || update.Statement != "UPDATE todo SET quantity = 0 WHERE name IN ('f', 'g')" {
+			t.Errorf("❌ Expected 2 updated rows, got: %+v", update)
+		}
+		if len(count.Records) != 1 || count.Records[0]["total"] != int64(7) {
+			t.Errorf("❌ Expected a count of 7, got: %+v", count.Records)
+		}
+		if actual := names(rows); !equalValues(actual, []any{"f", "g"}) {
+			t.Errorf("❌ Expected f and g, got: %v", actual)
+		}
+	})
+
+	t.Run("FailedStatement", func(t *testing.T) {
+		db := openTestDB(t, setup...)
+
+		query := "DELETE FROM todo WHERE id = 1; SELECT * FROM missing; DELETE FROM todo WHERE id = 2"
+		res, err := w2explorer.SQLExec(ctx, db, w2explorer.SQLExecRequest{Query: query}, w2explorer.SQLExecOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.Status != w2.StatusError || len(res.Results) != 2 || res.Results[1].Status != w2.StatusError || res.Message != res.Results[1].Message {
+			t.Errorf("❌ Expected the script to stop at the second statement, got: %+v", res)
+		}
+
+		var count int
+		if err := db.QueryRow("SELECT count(*) FROM todo").Scan(&count); err != nil || count != 4 {
+			t.Errorf("❌ Expected only the first delete to run, got: %d %v", count, err)
+		}
+	})
+
+	t.Run("Transaction", func(t *testing.T) {
+		db := openTestDB(t, setup...)
+
+		query := "DELETE FROM todo WHERE id = 1; SELECT * FROM missing"
+		res, err := w2explorer.SQLExec(ctx, db, w2explorer.SQLExecRequest{Query: query, Transaction: true}, w2explorer.SQLExecOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.Status != w2.StatusError || !res.RolledBack {
+			t.Errorf("❌ Expected a rolled back script, got: %+v", res)
+		}
+
+		var count int
+		if err := db.QueryRow("SELECT count(*) FROM todo").Scan(&count); err != nil || count != 5 {
+			t.Errorf("❌ Expected the delete to be rolled back, got: %d %v", count, err)
+		}
+
+		res, err = w2explorer.SQLExec(ctx, db, w2explorer.SQLExecRequest{Query: "DELETE FROM todo WHERE id = 1; DELETE FROM todo WHERE id = 2", Transaction: true}, w2explorer.SQLExecOptions{})
+		if err != nil || res.Status != w2.StatusSuccess || res.RolledBack {
+			t.Errorf("❌ Expected a committed script, got: %+v %v", res, err)
+		}
+
+		if err := db.QueryRow("SELECT count(*) FROM todo").Scan(&count); err != nil || count != 3 {
+			t.Errorf("❌ Expected the deletes to be committed, got: %d %v", count, err)
+		}
+	})
+
+	t.Run("OpenTransaction", func(t *testing.T) {
+		db := openTestDB(t, setup...)
+		db.SetMaxOpenConns(1)
+
+		query := "BEGIN; INSERT INTO todo (name) VALUES ('x'); SELECT * FROM missing"
+		res, err := w2explorer.SQLExec(ctx, db, w2explorer.SQLExecRequest{Query: query}, w2explorer.SQLExecOptions{})
+		if err != nil || res.Status != w2.StatusError {
+			t.Fatalf("❌ Expected the script to fail, got: %+v %v", res, err)
+		}
+
+		// the pooled connection is back in autocommit mode without the insert
+		if _, err := db.Exec("BEGIN"); err != nil {
+			t.Fatalf("❌ Expected no open transaction, got: %v", err)
+		}
+		if _, err := db.Exec("ROLLBACK"); err != nil {
+			t.Fatal(err)
+		}
+
+		var count int
+		if err := db.QueryRow("SELECT count(*) FROM todo WHERE name = 'x'").Scan(&count); err != nil || count != 0 {
+			t.Errorf("❌ Expected the insert to be rolled back, got: %d %v", count, err)
+		}
+
+		res, err = w2explorer.SQLExec(ctx, db, w2explorer.SQLExecRequest{Query: "BEGIN; DELETE FROM todo WHERE id = 1; COMMIT"}, w2explorer.SQLExecOptions{})
+		if err != nil || res.Status != w2.StatusSuccess {
+			t.Fatalf("❌ Expected a committed script, got: %+v %v", res, err)
+		}
+
+		if err := db.QueryRow("SELECT count(*) FROM todo").Scan(&count); err != nil || count != 4 {
+			t.Errorf("❌ Expected the committed delete to be kept, got: %d %v", count, err)
+		}
+	})
+}
+
+// equalValues reports whether two slices hold the same values.
+func equalValues(a, b []any) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,828 @@
+package w2explorer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dv1x3r/w2go/w2"
+	"github.com/dv1x3r/w2go/w2db"
+)
+
+// SQLExplainRequest is the JSON request body for explaining a query.
+type SQLExplainRequest struct {
+	// Query is the single statement to explain. A leading EXPLAIN or
+	// EXPLAIN QUERY PLAN is ignored.
+	Query string `json:"query"`
+
+	// Opcodes also returns the virtual machine program from EXPLAIN.
+	Opcodes bool `json:"opcodes"`
+}
+
+// Plan node highlights.
+const (
+	// PlanFullScan marks a SCAN of a table that reads every row.
+	PlanFullScan = "full-scan"
+
+	// PlanTempBTree marks a temporary B-tree built for ORDER BY, GROUP BY, or DISTINCT.
+	PlanTempBTree = "temp-btree"
+)
+
+// SQLExplainNode is one EXPLAIN QUERY PLAN row.
+type SQLExplainNode struct {
+	// ID and Parent link the node to its parent. Top-level nodes have Parent 0.
+	ID     int `json:"id"`
+	Parent int `json:"parent"`
+
+	// Detail is the plan text, such as "SCAN todo" or "SEARCH status USING INTEGER PRIMARY KEY (rowid=?)".
+	Detail string `json:"detail"`
+
+	// Highlight is PlanFullScan, PlanTempBTree, or empty.
+	Highlight string `json:"highlight,omitempty"`
+
+	// Nodes are the child nodes in plan order.
+	Nodes []SQLExplainNode `json:"nodes,omitempty"`
+}
+
+// SQLExplainOpcode is one EXPLAIN row of the virtual machine program.
+type SQLExplainOpcode struct {
+	Addr    int    `json:"addr"`
+	Opcode  string `json:"opcode"`
+	P1      int    `json:"p1"`
+	P2      int    `json:"p2"`
+	P3      int    `json:"p3"`
+	P4      string `json:"p4"`
+	P5      int    `json:"p5"`
+	Comment string `json:"comment"`
+}
+
+// SQLIndexSuggestion is a candidate index for a table the plan scans in full
+// or sorts with a temporary B-tree.
+type SQLIndexSuggestion struct {
+	Schema  string   `json:"schema"`
+	Table   string   `json:"table"`
+	Columns []string `json:"columns"`
+
+	// Reason explains which plan step the index would help.
+	Reason string `json:"reason"`
+
+	// SQL is the CREATE INDEX statement for the suggestion.
+	SQL string `json:"sql"`
+}
+
+// SQLExplainResult is the JSON response of the explain handlers.
+type SQLExplainResult struct {
+	Status w2.Status `json:"status"`
+
+	// Plan contains the top-level plan nodes with their children.
+	Plan []SQLExplainNode `json:"plan"`
+
+	// Opcodes is set when SQLExplainRequest.Opcodes is true.
+	Opcodes []SQLExplainOpcode `json:"opcodes,omitempty"`
+
+	// Suggestions lists candidate indexes for the columns used in WHERE, ON,
+	// and ORDER BY of the scanned or sorted tables.
+	Suggestions []SQLIndexSuggestion `json:"suggestions"`
+}
+
+// Write sends the explain result as application/json.
+func (res SQLExplainResult) Write(w http.ResponseWriter) error {
+	data, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(data)
+	return err
+}
+
+// SQLExplain returns the query plan of req.Query as a tree, highlights full
+// scans and temporary B-trees, and suggests indexes.
+//
+// The statement is explained, not executed, but the EXPLAIN statement must
+// still pass opts and opts.Authorize. opts.Timeout and opts.Monitor apply to
+// the EXPLAIN queries, and opts.Audit records them. Index suggestions are
+// heuristics derived from the statement text and the schema, so review them
+// before creating an index.
+func SQLExplain(ctx context.Context, db *sql.DB, req SQLExplainRequest, opts SQLExecOptions) (SQLExplainResult, error) {
+	begin := time.Now()
+	res, err := sqlExplain(ctx, db, req, opts)
+	opts.audit(ctx, AuditRecord{Action: AuditExplain, Query: req.Query, Elapsed: time.Since(begin).Seconds()}, err)
+
+	return res, err
+}
+
+func sqlExplain(ctx context.Context, db *sql.DB, req SQLExplainRequest, opts SQLExecOptions) (SQLExplainResult, error) {
+	statements := splitStatements(req.Query)
+	if len(statements) != 1 {
+		return SQLExplainResult{}, errors.New("query must contain exactly one statement")
+	}
+
+	stmt := stripExplain(statements[0])
+	if len(stmt.Tokens) == 0 {
+		return SQLExplainResult{}, errors.New("query is empty")
+	}
+
+	query := "EXPLAIN QUERY PLAN " + stmt.Text
+	if err := opts.Check(SQLExecRequest{Query: query}); err != nil {
+		return SQLExplainResult{}, err
+	}
+
+	if err := opts.authorize(ctx, SQLExecRequest{Query: query}); err != nil {
+		return SQLExplainResult{}, err
+	}
+
+	ctx, _, done, err := opts.begin(ctx, "", query)
+	if err != nil {
+		return SQLExplainResult{}, err
+	}
+	defer done()
+
+	res, err := explain(ctx, db, stmt, req.Opcodes)
+	if cause := interruption(ctx); err != nil && cause != nil {
+		return SQLExplainResult{}, cause
+	}
+
+	return res, err
+}
+
+func explain(ctx context.Context, db *sql.DB, stmt statement, opcodes bool) (SQLExplainResult, error) {
+	args := nullArgs(stmt)
+
+	plan, err := explainPlan(ctx, db, stmt.Text, args)
+	if err != nil {
+		return SQLExplainResult{}, err
+	}
+
+	res := SQLExplainResult{
+		Status:      w2.StatusSuccess,
+		Plan:        planTree(plan, 0),
+		Suggestions: []SQLIndexSuggestion{},
+	}
+
+	if opcodes {
+		if res.Opcodes, err = explainOpcodes(ctx, db, stmt.Text, args); err != nil {
+			return SQLExplainResult{}, err
+		}
+	}
+
+	if res.Suggestions, err = suggestIndexes(ctx, db, stmt, plan); err != nil {
+		return SQLExplainResult{}, err
+	}
+
+	return res, nil
+}
+
+// stripExplain removes a leading EXPLAIN or EXPLAIN QUERY PLAN from stmt.
+func stripExplain(stmt statement) statement {
+	skip := 0
+	if len(stmt.Tokens) > 0 && stmt.Tokens[0].is("EXPLAIN") {
+		skip = 1
+		if len(stmt.Tokens) > 2 && stmt.Tokens[1].is("QUERY") && stmt.Tokens[2].is("PLAN") {
+			skip = 3
+		}
+	}
+
+	if skip == 0 {
+		return stmt
+	}
+
+	if skip == len(stmt.Tokens) {
+		return statement{}
+	}
+
+	start := stmt.Tokens[skip].Pos - stmt.Pos
+	return statement{
+		Text:   stmt.Text[start:],
+		Pos:    stmt.Pos + start,
+		Tokens: stmt.Tokens[skip:],
+	}
+}
+
+// nullArgs returns NULL arguments for every parameter of stmt, so statements
+// with parameters can be explained without values.
+func nullArgs(stmt statement) []any {
+	var args []any
+	var named []string
+	positional := 0
+
+	for _, t := range stmt.Tokens {
+		if t.Kind != tokenParam {
+			continue
+		}
+
+		positional++
+		if t.Text[0] == '?' {
+			if n, err := strconv.Atoi(t.Text[1:]); err == nil {
+				positional = max(positional, n)
+			}
+		} else if name := t.Text[1:]; !slices.Contains(named, name) {
+			named = append(named, name)
+			args = append(args, sql.Named(name, nil))
+		}
+	}
+
+	return append(make([]any, positional), args...)
+}
+
+func explainPlan(ctx context.Context, db w2db.QueryExecer, query string, args []any) ([]SQLExplainNode, error) {
+	rows, err := db.QueryContext(ctx, "EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var plan []SQLExplainNode
+	for rows.Next() {
+		var node SQLExplainNode
+		var notUsed int
+		if err := rows.Scan(&node.ID, &node.Parent, &notUsed, &node.Detail); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case strings.Contains(node.Detail, "TEMP B-TREE"):
+			node.Highlight = PlanTempBTree
+		case planScanTarget(node.Detail) != "":
+			node.Highlight = PlanFullScan
+		}
+
+		plan = append(plan, node)
+	}
+
+	return plan, rows.Err()
+}
+
+// planScanTarget returns the table or alias of a "SCAN name" plan detail,
+// which reads every row of the table or of the index it uses, or an empty
+// string. Constant rows and subquery scans are not table scans.
+func planScanTarget(detail string) string {
+	name, ok := strings.CutPrefix(detail, "SCAN ")
+	if !ok || name == "CONSTANT ROW" || strings.HasPrefix(name, "(") {
+		return ""
+	}
+
+	if before, _, found := strings.Cut(name, " "); found {
+		name = before
+	}
+
+	return name
+}
+
+// planTree nests the flat plan rows under their parents.
+func planTree(plan []SQLExplainNode, parent int) []SQLExplainNode {
+	nodes := []SQLExplainNode{}
+	for _, node := range plan {
+		if node.Parent == parent {
+			node.Nodes = planTree(plan, node.ID)
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+func explainOpcodes(ctx context.Context, db w2db.QueryExecer, query string, args []any) ([]SQLExplainOpcode, error) {
+	rows, err := db.QueryContext(ctx, "EXPLAIN "+query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	opcodes := []SQLExplainOpcode{}
+	for rows.Next() {
+		var op SQLExplainOpcode
+		var p4, comment sql.NullString
+		if err := rows.Scan(&op.Addr, &op.Opcode, &op.P1, &op.P2, &op.P3, &p4, &op.P5, &comment); err != nil {
+			return nil, err
+		}
+
+		op.P4 = p4.String
+		op.Comment = comment.String
+		opcodes = append(opcodes, op)
+	}
+
+	return opcodes, rows.Err()
+}
+
+// SQLExplainHandler returns an explain handler that reports errors to the
+// caller instead of writing error responses itself.
+func SQLExplainHandler(db *sql.DB, opts SQLExecOptions) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var req SQLExplainRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return err
+		}
+
+		res, err := SQLExplain(r.Context(), db, req, opts)
+		if err != nil {
+			return err
+		}
+
+		return res.Write(w)
+	}
+}
+
+// SQLExplainHTTPHandler returns an http.HandlerFunc that writes the query plan
+// tree and index suggestions for the SQL explorer.
+//
+// Statements rejected by opts or opts.Authorize return 403 Forbidden, queries
+// over the limits of opts.Monitor return 429 Too Many Requests, and
+// statements SQLite cannot prepare return 400 Bad Request.
+func SQLExplainHTTPHandler(db *sql.DB, opts SQLExecOptions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req SQLExplainRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusBadRequest)
+			return
+		}
+
+		res, err := SQLExplain(r.Context(), db, req, opts)
+		if errors.Is(err, ErrStatementNotAllowed) || errors.Is(err, ErrConfirmRequired) || errors.Is(err, ErrAccessDenied) {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusForbidden)
+			return
+		} else if errors.Is(err, ErrTooManyQueries) {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusTooManyRequests)
+			return
+		} else if err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusBadRequest)
+			return
+		}
+
+		res.Write(w)
+	}
+}
+
+// tableRef is a table named in FROM or JOIN.
+type tableRef struct {
+	Schema string
+	Name   string
+}
+
+// columnRef is a possibly qualified column used in a predicate or ORDER BY.
+type columnRef struct {
+	Qualifier string
+	Name      string
+	Equality  bool
+}
+
+// queryColumns collects the tables of stmt keyed by alias and name, the
+// columns compared in WHERE and ON, and the columns of ORDER BY.
+func queryColumns(stmt statement) (map[string]tableRef, []columnRef, []columnRef) {
+	tables := map[string]tableRef{}
+	var where, order []columnRef
+
+	const (
+		regionNone = iota
+		regionWhere
+		regionOrder
+	)
+
+	tokens := stmt.Tokens
+	region := regionNone
+	var stack []int
+
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+
+		switch {
+		case t.Text == "(":
+			stack = append(stack, region)
+			region = regionNone
+			continue
+		case t.Text == ")":
+			if len(stack) > 0 {
+				region = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		case t.is("FROM"), t.is("JOIN"), t.is("UPDATE"), t.is("INTO"):
+			i = parseTableRefs(tokens, i+1, tables) - 1
+			region = regionNone
+			continue
+		case t.is("WHERE"), t.is("ON"):
+			region = regionWhere
+			continue
+		case t.is("ORDER") && i+1 < len(tokens) && tokens[i+1].is("BY"):
+			region = regionOrder
+			i++
+			continue
+		case t.is("GROUP"), t.is("HAVING"), t.is("LIMIT"), t.is("WINDOW"), t.is("UNION"),
+			t.is("EXCEPT"), t.is("INTERSECT"), t.is("RETURNING"), t.is("SELECT"), t.is("SET"):
+			region = regionNone
+			continue
+		}
+
+		if region == regionNone || !isIdent(t) {
+			continue
+		}
+
+		ref, next := parseColumnRef(tokens, i)
+		if next < len(tokens) && tokens[next].Text == "(" {
+			// function call
+			i = next - 1
+			continue
+		}
+
+		switch region {
+		case regionWhere:
+			if op, ok := comparison(tokens, next); ok {
+				ref.Equality = op
+				where = append(where, ref)
+			} else if i > 0 {
+				if op, ok := comparisonBefore(tokens, i); ok {
+					ref.Equality = op
+					where = append(where, ref)
+				}
+			}
+		case regionOrder:
+			if next == len(tokens) || tokens[next].Text == "," || tokens[next].is("ASC") ||
+				tokens[next].is("DESC") || tokens[next].is("NULLS") || tokens[next].is("COLLATE") ||
+				tokens[next].Text == ")" || tokens[next].is("LIMIT") {
+				order = append(order, ref)
+			}
+		}
+
+		i = next - 1
+	}
+
+	return tables, where, order
+}
+
+// parseTableRefs parses a comma-separated list of table references starting
+// at i and returns the index after it.
+func parseTableRefs(tokens []token, i int, tables map[string]tableRef) int {
+	for i < len(tokens) && isIdent(tokens[i]) {
+		ref := tableRef{Schema: "main", Name: identName(tokens[i])}
+		i++
+
+		if i+1 < len(tokens) && tokens[i].Text == "." && isIdent(tokens[i+1]) {
+			ref.Schema = ref.Name
+			ref.Name = identName(tokens[i+1])
+			i += 2
+		}
+
+		tables[strings.ToLower(ref.Name)] = ref
+
+		if i < len(tokens) && tokens[i].is("AS") {
+			i++
+		}
+
+		if i < len(tokens) && isIdent(tokens[i]) && !isClauseKeyword(tokens[i]) {
+			tables[strings.ToLower(identName(tokens[i]))] = ref
+			i++
+		}
+
+		if i >= len(tokens) || tokens[i].Text != "," {
+			break
+		}
+		i++
+	}
+
+	return i
+}
+
+// parseColumnRef parses "column" or "qualifier.column" at i and returns the
+// index after it.
+func parseColumnRef(tokens []token, i int) (columnRef, int) {
+	ref := columnRef{Name: identName(tokens[i])}
+	i++
+
+	if i+1 < len(tokens) && tokens[i].Text == "." && isIdent(tokens[i+1]) {
+		ref.Qualifier = ref.Name
+		ref.Name = identName(tokens[i+1])
+		i += 2
+	}
+
+	return ref, i
+}
+
+// comparison reports whether tokens[i] starts a comparison that can use an
+// index, and whether it is an equality comparison.
+func comparison(tokens []token, i int) (bool, bool) {
+	if i >= len(tokens) {
+		return false, false
+	}
+
+	t := tokens[i]
+	switch {
+	case t.Text == "=", t.Text == "==", t.is("IN"), t.is("IS"):
+		return true, true
+	case t.Text == "<", t.Text == ">", t.Text == "<=", t.Text == ">=", t.is("BETWEEN"), t.is("LIKE"), t.is("GLOB"):
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// comparisonBefore reports whether the column at i is the right side of a
+// comparison, such as "5 < quantity" or the join condition "s.id = t.status_id".
+func comparisonBefore(tokens []token, i int) (bool, bool) {
+	if i < 2 {
+		return false, false
+	}
+
+	switch tokens[i-2].Kind {
+	case tokenNumber, tokenString, tokenParam, tokenWord, tokenQuoted:
+	default:
+		return false, false
+	}
+
+	switch tokens[i-1].Text {
+	case "=", "==":
+		return true, true
+	case "<", ">", "<=", ">=":
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+func isIdent(t token) bool {
+	return t.Kind == tokenQuoted || t.Kind == tokenWord && !isExprKeyword(t)
+}
+
+// identName returns the identifier text without quotes.
+func identName(t token) string {
+	if t.Kind != tokenQuoted || len(t.Text) < 2 {
+		return t.Text
+	}
+
+	quote := t.Text[0]
+	name := t.Text[1 : len(t.Text)-1]
+	if quote == '[' {
+		return name
+	}
+
+	return strings.ReplaceAll(name, string(quote)+string(quote), string(quote))
+}
+
+var exprKeywords = []string{
+	"AND", "OR", "NOT", "NULL", "IS", "IN", "LIKE", "GLOB", "BETWEEN", "EXISTS",
+	"CASE", "WHEN", "THEN", "ELSE", "END", "TRUE", "FALSE", "CAST", "ESCAPE",
+	"COLLATE", "ASC", "DESC", "NULLS", "FIRST", "LAST", "DISTINCT", "ALL",
+}
+
+var clauseKeywords = []string{
+	"WHERE", "JOIN", "INNER", "LEFT", "RIGHT", "FULL", "OUTER", "CROSS", "NATURAL",
+	"ON", "USING", "GROUP", "ORDER", "LIMIT", "HAVING", "WINDOW", "UNION", "EXCEPT",
+	"INTERSECT", "INDEXED", "NOT", "RETURNING", "SET", "VALUES", "DEFAULT", "SELECT",
+}
+
+func isExprKeyword(t token) bool {
+	return slices.ContainsFunc(exprKeywords, t.is)
+}
+
+func isClauseKeyword(t token) bool {
+	return slices.ContainsFunc(clauseKeywords, t.is)
+}
+
+// suggestIndexes proposes one index per table that the plan scans in full or
+// sorts with a temporary B-tree. Equality columns come first, followed by the
+// ORDER BY columns of a sorted single-table query or else one range column.
+func suggestIndexes(ctx context.Context, db w2db.QueryExecer, stmt statement, plan []SQLExplainNode) ([]SQLIndexSuggestion, error) {
+	tables, where, order := queryColumns(stmt)
+	suggestions := []SQLIndexSuggestion{}
+
+	sorted := slices.ContainsFunc(plan, func(node SQLExplainNode) bool {
+		return node.Highlight == PlanTempBTree && strings.Contains(node.Detail, "ORDER BY")
+	})
+
+	var targets []string
+	for _, node := range plan {
+		if alias := strings.ToLower(planScanTarget(node.Detail)); alias != "" {
+			if _, ok := tables[alias]; ok && !slices.Contains(targets, alias) {
+				targets = append(targets, alias)
+			}
+		}
+	}
+
+	infos := map[tableRef]tableInfo{}
+	for _, ref := range tables {
+		if _, ok := infos[ref]; ok {
+			continue
+		}
+		info, err := tableColumns(ctx, db, ref)
+		if err != nil {
+			return nil, err
+		}
+		infos[ref] = info
+	}
+
+	// a temporary B-tree for ORDER BY of a single table can be avoided by an index
+	if sorted && len(infos) == 1 && len(targets) == 0 && len(order) > 0 {
+		for alias := range tables {
+			targets = append(targets, alias)
+			break
+		}
+	}
+
+	seen := map[tableRef]bool{}
+	for _, alias := range targets {
+		ref := tables[alias]
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+
+		info := infos[ref]
+		belongs := func(col columnRef) bool {
+			if col.Qualifier != "" {
+				return tables[strings.ToLower(col.Qualifier)] == ref
+			}
+			// an unqualified column belongs to this table when no other table has it
+			if !info.has(col.Name) {
+				return false
+			}
+			for otherRef, otherInfo := range infos {
+				if otherRef != ref && otherInfo.has(col.Name) {
+					return false
+				}
+			}
+			return true
+		}
+
+		var columns, reasons []string
+		add := func(name string) {
+			name = info.name(name)
+			if name != "" && !info.isRowID(name) && !slices.Contains(columns, name) {
+				columns = append(columns, name)
+			}
+		}
+
+		for _, col := range where {
+			if col.Equality && belongs(col) {
+				add(col.Name)
+			}
+		}
+		if len(columns) > 0 {
+			reasons = append(reasons, fmt.Sprintf("filters %s, which the plan scans in full", ref.Name))
+		}
+
+		// after the equality columns, an index either serves the ORDER BY
+		// or one range comparison, but not both
+		before := len(columns)
+		if sorted && len(infos) == 1 {
+			for _, col := range order {
+				add(col.Name)
+			}
+			if len(columns) > before {
+				reasons = append(reasons, "returns rows in ORDER BY order without a temporary B-tree")
+			}
+		}
+		if len(columns) == before {
+			for _, col := range where {
+				if !col.Equality && belongs(col) {
+					add(col.Name)
+					break
+				}
+			}
+			if before == 0 && len(columns) > 0 {
+				reasons = append(reasons, fmt.Sprintf("filters %s, which the plan scans in full", ref.Name))
+			}
+		}
+
+		if len(columns) == 0 || info.hasIndexPrefix(columns) {
+			continue
+		}
+
+		suggestions = append(suggestions, newIndexSuggestion(ref, columns, strings.Join(reasons, " and ")))
+	}
+
+	return suggestions, nil
+}
+
+func newIndexSuggestion(ref tableRef, columns []string, reason string) SQLIndexSuggestion {
+	name := "idx_" + ref.Name + "_" + strings.Join(columns, "_")
+
+	quoted := make([]string, len(columns))
+	for i, col := range columns {
+		quoted[i] = quoteIdent(col)
+	}
+
+	schema := ""
+	if !strings.EqualFold(ref.Schema, "main") {
+		schema = quoteIdent(ref.Schema) + "."
+	}
+
+	return SQLIndexSuggestion{
+		Schema:  ref.Schema,
+		Table:   ref.Name,
+		Columns: columns,
+		Reason:  "An index " + reason + ".",
+		SQL: fmt.Sprintf("CREATE INDEX %s%s ON %s (%s);",
+			schema, quoteIdent(name), quoteIdent(ref.Name), strings.Join(quoted, ", ")),
+	}
+}
+
+// tableInfo holds the columns and indexes of one table.
+type tableInfo struct {
+	columns []string
+	rowID   string
+	indexes [][]string
+}
+
+// name returns the declared spelling of column, or an empty string when the
+// table has no such column.
+func (info tableInfo) name(column string) string {
+	for _, c := range info.columns {
+		if strings.EqualFold(c, column) {
+			return c
+		}
+	}
+	return ""
+}
+
+func (info tableInfo) has(column string) bool {
+	return info.name(column) != ""
+}
+
+// isRowID reports whether column is the INTEGER PRIMARY KEY rowid alias,
+// which is already indexed.
+func (info tableInfo) isRowID(column string) bool {
+	return info.rowID != "" && strings.EqualFold(info.rowID, column)
+}
+
+// hasIndexPrefix reports whether an existing index starts with columns.
+func (info tableInfo) hasIndexPrefix(columns []string) bool {
+	for _, index := range info.indexes {
+		if len(index) >= len(columns) && slices.EqualFunc(index[:len(columns)], columns, strings.EqualFold) {
+			return true
+		}
+	}
+	return false
+}
+
+func tableColumns(ctx context.Context, db w2db.QueryExecer, ref tableRef) (tableInfo, error) {
+	var info tableInfo
+
+	rows, err := db.QueryContext(ctx, "SELECT name, type, pk FROM pragma_table_info(?, ?) ORDER BY cid", ref.Name, ref.Schema)
+	if err != nil {
+		return info, err
+	}
+	defer rows.Close()
+
+	pkCount := 0
+	for rows.Next() {
+		var name, typ string
+		var pk int
+		if err := rows.Scan(&name, &typ, &pk); err != nil {
+			return info, err
+		}
+
+		info.columns = append(info.columns, name)
+		if pk > 0 {
+			pkCount++
+			if strings.EqualFold(typ, "INTEGER") {
+				info.rowID = name
+			}
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return info, err
+	}
+
+	if pkCount != 1 {
+		info.rowID = ""
+	}
+
+	rows, err = db.QueryContext(ctx, `
+SELECT il.name, ii.name
+FROM pragma_index_list(?1, ?2) il
+JOIN pragma_index_info(il.name, ?2) ii
+ORDER BY il.name, ii.seqno`, ref.Name, ref.Schema)
+	if err != nil {
+		return info, err
+	}
+	defer rows.Close()
+
+	current := ""
+	for rows.Next() {
+		var index string
+		var column sql.NullString
+		if err := rows.Scan(&index, &column); err != nil {
+			return info, err
+		}
+
+		if index != current || len(info.indexes) == 0 {
+			info.indexes = append(info.indexes, nil)
+			current = index
+		}
+
+		last := len(info.indexes) - 1
+		info.indexes[last] = append(info.indexes[last], column.String)
+	}
+
+	return info, rows.Err()
+}
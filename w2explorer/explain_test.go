@@ -0,0 +1,77 @@
+package w2explorer_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dv1x3r/w2go/w2explorer"
+)
+
+func TestSQLExplain(t *testing.T) {
+	ctx := context.Background()
+	setup := []string{
+		`CREATE TABLE todo (id INTEGER PRIMARY KEY, name TEXT, status_id INTEGER)`,
+		`CREATE TABLE secret (id INTEGER PRIMARY KEY, value TEXT)`,
+	}
+
+	t.Run("Plan", func(t *testing.T) {
+		db := openTestDB(t, setup...)
+
+		res, err := w2explorer.SQLExplain(ctx, db, w2explorer.SQLExplainRequest{Query: "SELECT * FROM todo WHERE status_id = :status"}, w2explorer.SQLExecOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(res.Plan) != 1 || res.Plan[0].Highlight != w2explorer.PlanFullScan {
+			t.Errorf("❌ Expected one full scan, got: %+v", res.Plan)
+		}
+
+		if len(res.Suggestions) != 1 || strings.Join(res.Suggestions[0].Columns, ",") != "status_id" {
+			t.Errorf("❌ Expected an index on status_id, got: %+v", res.Suggestions)
+		}
+	})
+
+	t.Run("AuthorizeAudit", func(t *testing.T) {
+		db := openTestDB(t, setup...)
+		opts := w2explorer.SQLExecOptions{
+			Authorize: func(ctx context.Context, req w2explorer.SQLExecRequest) error {
+				if strings.Contains(req.Query, "secret") {
+					return errors.New("secret is hidden")
+				}
+				return nil
+			},
+			Audit: auditTrail(t, db),
+		}
+
+		if _, err := w2explorer.SQLExplain(ctx, db, w2explorer.SQLExplainRequest{Query: "SELECT * FROM todo"}, opts); err != nil {
+			t.Errorf("❌ Unexpected error: %v", err)
+		}
+
+		if _, err := w2explorer.SQLExplain(ctx, db, w2explorer.SQLExplainRequest{Query: "SELECT * FROM secret"}, opts); !errors.Is(err, w2explorer.ErrAccessDenied) {
+			t.Errorf("❌ Expected ErrAccessDenied, got: %v", err)
+		}
+
+		expected := []string{"explain", "explain (error)"}
+		if actual := auditActions(t, db); strings.Join(actual, ",") != strings.Join(expected, ",") {
+			t.Errorf("❌ Expected audit records %v, got: %v", expected, actual)
+		}
+	})
+
+	t.Run("Monitor", func(t *testing.T) {
+		db := openTestDB(t, setup...)
+		monitor := w2explorer.NewQueryMonitor(w2explorer.QueryMonitorOptions{MaxConcurrent: 1})
+		opts := w2explorer.SQLExecOptions{Monitor: monitor}
+
+		_, _, done, err := monitor.Start(ctx, "", "SELECT 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer done()
+
+		if _, err := w2explorer.SQLExplain(ctx, db, w2explorer.SQLExplainRequest{Query: "SELECT * FROM todo"}, opts); !errors.Is(err, w2explorer.ErrTooManyQueries) {
+			t.Errorf("❌ Expected ErrTooManyQueries, got: %v", err)
+		}
+	})
+}
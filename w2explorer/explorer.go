@@ -5,46 +5,239 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
-	"strings"
+	"slices"
+	"time"
 
 	"github.com/dv1x3r/w2go/w2"
 )
 
-// SQLExecField is the cell type returned by the SQL explorer result grid.
+// SQLExecField is the cell type previously returned by the SQL explorer result grid.
+//
+// Deprecated: result rows hold JSON-native values, see SQLExecRow.
 type SQLExecField = w2.Field[string]
 
 // SQLExecRow is one SQL explorer result row keyed by column name.
-type SQLExecRow map[string]SQLExecField
+//
+// Values are nil for NULL, int64 or float64 for numbers, bool for integers in
+// BOOLEAN columns, string for text, time.Time for values the driver parses
+// from DATE and DATETIME columns, and SQLExecBlob for BLOBs.
+type SQLExecRow map[string]any
 
-// SQLExecResult is the JSON response returned by SQL explorer query execution.
+// SQLExecResult is the result of one SQL explorer statement.
 type SQLExecResult struct {
-	// Status is set to w2.StatusSuccess by NewSQLExecResult.
+	// Status is set to w2.StatusSuccess by NewSQLExecResult, or to
+	// w2.StatusError when the statement failed.
 	Status w2.Status `json:"status"`
 
+	// Message is the error message of a failed statement.
+	Message string `json:"message,omitempty"`
+
+	// Statement is the executed statement text.
+	Statement string `json:"statement,omitempty"`
+
+	// Kind is the statement kind.
+	Kind StatementKind `json:"kind,omitempty"`
+
 	// Columns contains the result column names in display order.
 	Columns []string `json:"columns"`
 
+	// ColumnTypes describes the result columns in display order.
+	ColumnTypes []SQLExecColumn `json:"columnTypes"`
+
 	// Records contains the scanned query rows.
 	Records []SQLExecRow `json:"records"`
 
-	// Total is the number of result rows.
+	// Total is the number of result rows. For paged results it is the number
+	// of rows matching the search across all pages.
 	Total int `json:"total"`
+
+	// Truncated is true when SQLExecOptions.MaxRows cut the result short.
+	Truncated bool `json:"truncated"`
+
+	// Paged is true when the query was wrapped as a subquery, so limit, offset,
+	// sort, and search of the request were applied in SQL.
+	Paged bool `json:"paged"`
+
+	// RowsAffected is the number of rows changed by a statement that returns no rows.
+	RowsAffected int64 `json:"rowsAffected"`
+
+	// LastInsertID is the last inserted rowid of an INSERT statement.
+	LastInsertID int64 `json:"lastInsertId"`
+
+	// Editable describes the table behind a simple single-table SELECT when
+	// SQLExecOptions.Editable is set. It is nil for other results.
+	Editable *SQLEditTarget `json:"editable,omitempty"`
+
+	// Keys holds the Editable.Key values of each record, in Records order.
+	Keys [][]any `json:"keys,omitempty"`
+
+	// Elapsed is the statement execution time in seconds.
+	Elapsed float64 `json:"elapsed"`
+}
+
+// SQLExecResponse is the JSON response returned by SQL explorer script execution.
+type SQLExecResponse struct {
+	// Status is w2.StatusError when a statement failed.
+	Status w2.Status `json:"status"`
+
+	// Message is the error message of the failed statement.
+	Message string `json:"message,omitempty"`
+
+	// Results contains one result per executed statement. Execution stops at
+	// the first failed statement, which is the last result.
+	Results []SQLExecResult `json:"results"`
+
+	// RolledBack is true when a script executed in a transaction failed and
+	// the changes of its earlier statements were rolled back.
+	RolledBack bool `json:"rolledBack"`
+
+	// Elapsed is the script execution time in seconds.
+	Elapsed float64 `json:"elapsed"`
+
+	// QueryID is the ID the query ran under.
+	QueryID string `json:"queryId,omitempty"`
+}
+
+// Write sends the SQL explorer response as application/json.
+func (res SQLExecResponse) Write(w http.ResponseWriter) error {
+	data, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(data)
+	return err
 }
 
 // SQLExecRequest is the JSON request body for SQL explorer query execution.
 type SQLExecRequest struct {
 	// Query is the SQL text to execute.
 	Query string `json:"query"`
+
+	// Confirm acknowledges destructive statements when
+	// SQLExecOptions.ConfirmDestructive is set.
+	Confirm bool `json:"confirm"`
+
+	// Transaction runs all statements in one transaction that is rolled back
+	// when a statement fails.
+	Transaction bool `json:"transaction"`
+
+	// QueryID identifies the running query for QueryMonitor.Kill. A random
+	// ID is generated when it is empty.
+	QueryID string `json:"queryId"`
+
+	// Params holds the values of the query parameters, see QueryParams.
+	// They are bound to every statement that uses them.
+	Params []SQLParam `json:"params"`
+
+	// Grid holds the w2grid limit, offset, sort, and search sent next to the
+	// query. They are applied when the query is a single SELECT statement.
+	Grid w2.GetGridRequest `json:"-"`
+}
+
+// UnmarshalJSON decodes the query fields and the w2grid request fields from
+// the same JSON object.
+func (req *SQLExecRequest) UnmarshalJSON(data []byte) error {
+	type alias SQLExecRequest
+	if err := json.Unmarshal(data, (*alias)(req)); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &req.Grid)
+}
+
+// SQLExecOptions restricts which statements the SQL explorer may execute.
+//
+// The zero value executes any statement.
+type SQLExecOptions struct {
+	// ReadOnly rejects every statement that is not SELECT, EXPLAIN, or a
+	// read-only PRAGMA, and runs the query on a connection with
+	// PRAGMA query_only enabled. For a stronger guarantee, pass a *sql.DB
+	// opened in SQLite read-only mode, such as "file:app.db?mode=ro".
+	ReadOnly bool
+
+	// Allow lists the accepted statement kinds. Empty means every kind.
+	Allow []StatementKind
+
+	// ConfirmDestructive rejects UPDATE and DELETE statements without a WHERE
+	// clause with ErrConfirmRequired unless SQLExecRequest.Confirm is set.
+	ConfirmDestructive bool
+
+	// MaxRows caps the number of rows returned by one execution and sets
+	// SQLExecResult.Truncated when more rows exist. Zero means no limit.
+	MaxRows int
+
+	// Editable adds the table, row keys, and editable columns of simple
+	// single-table SELECT results, so edited cells can be written back with
+	// SQLSave. It has no effect in read-only mode or when Allow rejects UPDATE.
+	Editable bool
+
+	// History records every executed or rejected script when non-nil.
+	// Requests that only page, sort, or search a previous result are not recorded.
+	History *QueryStore
+
+	// Timeout cancels a query that runs longer than this duration and reports
+	// ErrQueryTimeout as its error. Zero means no timeout.
+	Timeout time.Duration
+
+	// Monitor tracks running queries, limits how many run at once, and kills
+	// them by query ID when non-nil.
+	Monitor *QueryMonitor
+
+	// Authorize is called with every script, cell download, and result edit
+	// that passes the other options, before it runs. A non-nil error rejects
+	// it with ErrAccessDenied. Use UserFromContext and SourceFromContext to
+	// decide.
+	Authorize func(ctx context.Context, req SQLExecRequest) error
+
+	// Audit records every execution, including rejected ones, when non-nil.
+	Audit *AuditLog
+}
+
+// ErrStatementNotAllowed is returned when SQLExecOptions reject a statement.
+var ErrStatementNotAllowed = errors.New("statement is not allowed")
+
+// ErrConfirmRequired is returned when a destructive statement was not confirmed.
+var ErrConfirmRequired = errors.New("statement requires confirmation")
+
+// Check validates every statement of req.Query against opts.
+//
+// The whole script is checked because the SQLite driver executes all
+// statements of a multi-statement query. A statement whose text still holds
+// another statement after splitting is rejected, so nothing runs unchecked.
+func (opts SQLExecOptions) Check(req SQLExecRequest) error {
+	for _, stmt := range splitStatements(req.Query) {
+		if stmt.hasTail() {
+			return fmt.Errorf("%w: statement text holds more than one statement", ErrStatementNotAllowed)
+		}
+
+		kind := stmt.Kind()
+
+		if opts.ReadOnly && !stmt.IsReadOnly() {
+			return fmt.Errorf("%w in read-only mode: %s", ErrStatementNotAllowed, kind)
+		}
+
+		if len(opts.Allow) > 0 && !slices.Contains(opts.Allow, kind) {
+			return fmt.Errorf("%w: %s", ErrStatementNotAllowed, kind)
+		}
+
+		if opts.ConfirmDestructive && !req.Confirm && stmt.IsDestructive() {
+			return fmt.Errorf("%w: %s without WHERE changes every row", ErrConfirmRequired, kind)
+		}
+	}
+
+	return nil
 }
 
 // NewSQLExecResult returns a successful SQL explorer result.
 func NewSQLExecResult(columns []string, records []SQLExecRow, total int) SQLExecResult {
 	return SQLExecResult{
-		Status:  w2.StatusSuccess,
-		Columns: columns,
-		Records: records,
-		Total:   total,
+		Status:      w2.StatusSuccess,
+		Columns:     columns,
+		ColumnTypes: []SQLExecColumn{},
+		Records:     records,
+		Total:       total,
 	}
 }
 
@@ -61,14 +254,14 @@ func (res SQLExecResult) Write(w http.ResponseWriter) error {
 
 // SQLExecHandler returns a query-execution handler that reports errors to the
 // caller instead of writing error responses itself.
-func SQLExecHandler(db *sql.DB) func(w http.ResponseWriter, r *http.Request) error {
+func SQLExecHandler(db *sql.DB, opts SQLExecOptions) func(w http.ResponseWriter, r *http.Request) error {
 	return func(w http.ResponseWriter, r *http.Request) error {
 		var req SQLExecRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			return err
 		}
 
-		res, err := SQLExecQuery(r.Context(), db, req.Query)
+		res, err := SQLExec(r.Context(), db, req, opts)
 		if err != nil {
 			return err
 		}
@@ -77,10 +270,17 @@ func SQLExecHandler(db *sql.DB) func(w http.ResponseWriter, r *http.Request) err
 	}
 }
 
-// SQLExecHTTPHandler returns an http.HandlerFunc for SQL explorer query execution.
+// SQLExecHTTPHandler returns an http.HandlerFunc for SQL explorer script execution.
 //
-// The handler writes JSON error responses for malformed requests and query failures.
-func SQLExecHTTPHandler(db *sql.DB) http.HandlerFunc {
+// The handler writes JSON error responses for malformed requests and
+// connection failures. Failed statements are reported in the results of a
+// 200 OK response, so the results of earlier statements are kept. Statements
+// rejected by opts or opts.Authorize return 403 Forbidden, and unconfirmed
+// destructive statements return 409 Conflict so the widget can ask the user.
+// Parameter values that do not match their type and query IDs of queries
+// that are still running return 400 Bad Request.
+// Queries over the limits of opts.Monitor return 429 Too Many Requests.
+func SQLExecHTTPHandler(db *sql.DB, opts SQLExecOptions) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req SQLExecRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -89,159 +289,29 @@ func SQLExecHTTPHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		res, err := SQLExecQuery(r.Context(), db, req.Query)
-		if err != nil {
+		res, err := SQLExec(r.Context(), db, req, opts)
+		if errors.Is(err, ErrStatementNotAllowed) || errors.Is(err, ErrAccessDenied) {
 			res := w2.NewErrorResponse(err.Error())
-			res.Write(w, http.StatusInternalServerError)
+			res.Write(w, http.StatusForbidden)
 			return
-		}
-
-		res.Write(w)
-	}
-}
-
-// SQLExecQuery executes query and scans the returned rows for the SQL explorer.
-//
-// Empty queries return an error. The query text is executed as-is, so callers
-// must restrict access to this function when query text comes from a user.
-func SQLExecQuery(ctx context.Context, db *sql.DB, query string) (SQLExecResult, error) {
-	if strings.TrimSpace(query) == "" {
-		return SQLExecResult{}, errors.New("query is empty")
-	}
-
-	rows, err := db.QueryContext(ctx, query)
-	if err != nil {
-		return SQLExecResult{}, err
-	}
-	defer rows.Close()
-
-	columns, err := rows.Columns()
-	if err != nil {
-		return SQLExecResult{}, err
-	}
-
-	if columns == nil {
-		columns = []string{}
-	}
-
-	records := []SQLExecRow{}
-
-	for rows.Next() {
-		values := make([]SQLExecField, len(columns))
-		valuePtrs := make([]any, len(columns))
-		for i := range values {
-			valuePtrs[i] = &values[i]
-		}
-
-		if err := rows.Scan(valuePtrs...); err != nil {
-			return SQLExecResult{}, err
-		}
-
-		record := SQLExecRow{}
-		for i, column := range columns {
-			record[column] = values[i]
-		}
-
-		records = append(records, record)
-	}
-
-	if err := rows.Err(); err != nil {
-		return SQLExecResult{}, err
-	}
-
-	return NewSQLExecResult(columns, records, len(records)), nil
-}
-
-// SQLiteSchemaHandler returns a SQLite schema handler that reports errors to the caller instead of writing error responses itself.
-func SQLiteSchemaHandler(db *sql.DB) func(w http.ResponseWriter, r *http.Request) error {
-	return func(w http.ResponseWriter, r *http.Request) error {
-		res, err := SQLiteSelectSchema(r.Context(), db)
-		if err != nil {
-			return err
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		_, err = w.Write([]byte(res))
-		return err
-	}
-}
-
-// SQLiteSchemaHTTPHandler returns an http.HandlerFunc that writes the SQLite schema JSON used by the SQL explorer sidebar.
-func SQLiteSchemaHTTPHandler(db *sql.DB) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		res, err := SQLiteSelectSchema(r.Context(), db)
-		if err != nil {
+		} else if errors.Is(err, ErrConfirmRequired) {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusConflict)
+			return
+		} else if errors.Is(err, ErrInvalidParam) || errors.Is(err, ErrQueryIDInUse) {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusBadRequest)
+			return
+		} else if errors.Is(err, ErrTooManyQueries) {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusTooManyRequests)
+			return
+		} else if err != nil {
 			res := w2.NewErrorResponse(err.Error())
 			res.Write(w, http.StatusInternalServerError)
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(res))
+		res.Write(w)
 	}
 }
-
-// SQLiteSelectSchema returns SQLite database, table, and column metadata as a JSON document for the SQL explorer sidebar.
-func SQLiteSelectSchema(ctx context.Context, db *sql.DB) (string, error) {
-	const query = `
-WITH objects AS (
-  SELECT
-    db.[name] AS db_name,
-    t.[name],
-    t.[type],
-    json_object(
-      'name', t.[name],
-      'columns', (
-        SELECT json_group_array(
-          json_object(
-            'name', col.[name],
-            'type', col.[type],
-            'notnull', col.[notnull],
-            'default', col.[dflt_value],
-            'pk', col.[pk]
-          )
-        )
-        FROM pragma_table_info(t.[name]) col
-      )
-    ) AS obj
-  FROM pragma_database_list db
-  JOIN pragma_table_list t
-    ON t.[schema] = db.[name]
-  WHERE t.[name] NOT LIKE 'sqlite_%'
-)
-SELECT json_object(
-  'databases', (
-    SELECT json_group_array(
-      json_object(
-        'name', db.[name],
-        'tables', (
-          SELECT json_group_array(json(obj) ORDER BY name)
-          FROM objects
-          WHERE db_name = db.[name] AND type = 'table'
-        ),
-        'views', (
-          SELECT json_group_array(json(obj) ORDER BY name)
-          FROM objects
-          WHERE db_name = db.[name] AND type = 'view'
-        ),
-        'virtual', (
-          SELECT json_group_array(json(obj) ORDER BY name)
-          FROM objects
-          WHERE db_name = db.[name] AND type = 'virtual'
-        ),
-        'shadow', (
-          SELECT json_group_array(json(obj) ORDER BY name)
-          FROM objects
-          WHERE db_name = db.[name] AND type = 'shadow'
-        )
-      )
-    )
-    FROM pragma_database_list db
-  )
-) AS [schema];
-		`
-
-	var schema string
-	row := db.QueryRowContext(ctx, query)
-	return schema, row.Scan(&schema)
-}
@@ -0,0 +1,548 @@
+package w2explorer
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dv1x3r/w2go/w2"
+	"github.com/dv1x3r/w2go/w2db"
+	"github.com/dv1x3r/w2go/w2sql"
+	"github.com/huandu/go-sqlbuilder"
+)
+
+// SQLExportFormat is the file format of a SQL explorer export.
+type SQLExportFormat string
+
+// SQL explorer export formats.
+const (
+	ExportCSV    SQLExportFormat = "csv"
+	ExportJSON   SQLExportFormat = "json"
+	ExportNDJSON SQLExportFormat = "ndjson"
+	ExportSQL    SQLExportFormat = "sql"
+)
+
+// ErrExportFormat is returned for an unknown export format.
+var ErrExportFormat = errors.New("unknown export format")
+
+// SQLExportRequest is the JSON request body for exporting a SQL explorer result.
+type SQLExportRequest struct {
+	// Query is the single read-only statement whose rows are exported.
+	Query string `json:"query"`
+
+	// Params holds the parameter values the query runs with.
+	Params []SQLParam `json:"params"`
+
+	// Format is ExportCSV, ExportJSON, ExportNDJSON, or ExportSQL.
+	Format SQLExportFormat `json:"format"`
+
+	// Table names the target table of ExportSQL INSERT statements. It
+	// defaults to the table of a simple single-table SELECT, or "export".
+	Table string `json:"table"`
+
+	// Grid holds the w2grid sort and search of a paged result, so the export
+	// has the rows in the order the grid shows. Limit and offset are ignored.
+	Grid w2.GetGridRequest `json:"-"`
+}
+
+// UnmarshalJSON decodes the export fields and the w2grid request fields from
+// the same JSON object.
+func (req *SQLExportRequest) UnmarshalJSON(data []byte) error {
+	type alias SQLExportRequest
+	if err := json.Unmarshal(data, (*alias)(req)); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &req.Grid)
+}
+
+// table returns req.Table, the table of a simple single-table SELECT, or "export".
+func (req SQLExportRequest) table() string {
+	if req.Table != "" {
+		return req.Table
+	}
+
+	if statements := splitStatements(req.Query); len(statements) == 1 {
+		if sel, ok := parseEditSelect(statements[0]); ok {
+			return sel.ref.Name
+		}
+	}
+
+	return "export"
+}
+
+// exportFormat describes the file of an export format.
+type exportFormat struct {
+	contentType string
+	extension   string
+}
+
+var exportFormats = map[SQLExportFormat]exportFormat{
+	ExportCSV:    {"text/csv; charset=utf-8", "csv"},
+	ExportJSON:   {"application/json", "json"},
+	ExportNDJSON: {"application/x-ndjson", "ndjson"},
+	ExportSQL:    {"application/sql; charset=utf-8", "sql"},
+}
+
+// SQLExport executes req.Query and writes its rows to w in req.Format, and
+// returns the number of rows written.
+//
+// Rows are written as they are scanned, so the result is never held in
+// memory and opts.MaxRows does not apply. The query must be a single
+// read-only statement that passes opts, and it runs on a connection with
+// PRAGMA query_only enabled, like SQLExec in read-only mode. opts.Timeout
+// and opts.Monitor apply to the export, and opts.Audit records it.
+//
+// CSV has a header row and writes NULL as an empty field. JSON writes an
+// array of objects with the keys in column order, and NDJSON writes one object
+// per line. ExportSQL writes one INSERT statement per row. BLOBs are base64 in
+// CSV and JSON and hex literals in SQL, and times use RFC 3339.
+func SQLExport(ctx context.Context, db *sql.DB, w io.Writer, req SQLExportRequest, opts SQLExecOptions) (int64, error) {
+	begin := time.Now()
+	rows, err := sqlExport(ctx, db, w, req, opts)
+
+	record := AuditRecord{Action: AuditExport, Query: req.Query, Params: req.Params, Elapsed: time.Since(begin).Seconds(), Rows: rows}
+	opts.audit(ctx, record, err)
+
+	return rows, err
+}
+
+func sqlExport(ctx context.Context, db *sql.DB, w io.Writer, req SQLExportRequest, opts SQLExecOptions) (int64, error) {
+	if _, ok := exportFormats[req.Format]; !ok {
+		return 0, fmt.Errorf("%w %q", ErrExportFormat, req.Format)
+	}
+
+	statements := splitStatements(req.Query)
+	if len(statements) != 1 {
+		return 0, errors.New("query must contain exactly one statement")
+	}
+
+	if err := opts.Check(SQLExecRequest{Query: req.Query}); err != nil {
+		return 0, err
+	}
+
+	stmt := statements[0]
+	if !stmt.IsReadOnly() || !stmt.ReturnsRows() {
+		return 0, fmt.Errorf("%w: only read-only queries are exported", ErrStatementNotAllowed)
+	}
+
+	if err := opts.authorize(ctx, SQLExecRequest{Query: req.Query, Params: req.Params}); err != nil {
+		return 0, err
+	}
+
+	params, err := sqlParamValues(req.Params)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, _, done, err := opts.begin(ctx, "", req.Query)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	// query_only keeps a statement misclassified as read-only from writing
+	conn, release, err := readOnlyConn(ctx, db, true)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	rows, err := exportRows(ctx, conn, w, stmt, params, req)
+	if cause := interruption(ctx); err != nil && cause != nil {
+		return rows, cause
+	}
+
+	return rows, err
+}
+
+// exportRows runs the export query and writes every row it scans.
+func exportRows(ctx context.Context, db w2db.QueryExecer, w io.Writer, stmt statement, params map[string]any, req SQLExportRequest) (int64, error) {
+	query, args, err := exportQuery(ctx, db, stmt, params, req.Grid)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+
+	buf := bufio.NewWriter(w)
+	exporter := newExporter(buf, req.Format, req.table())
+	if err := exporter.begin(columns); err != nil {
+		return 0, err
+	}
+
+	values := make([]any, len(columns))
+	valuePtrs := make([]any, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+
+	var count int64
+	for rows.Next() {
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return count, err
+		}
+
+		if err := exporter.row(values); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+
+	if err := exporter.end(); err != nil {
+		return count, err
+	}
+
+	return count, buf.Flush()
+}
+
+// exportQuery returns the query and arguments that select the exported rows.
+// The w2grid sort and search of a paged SELECT are applied to it as a
+// subquery, like in sqlExecPaged.
+func exportQuery(ctx context.Context, db w2db.QueryExecer, stmt statement, params map[string]any, grid w2.GetGridRequest) (string, []any, error) {
+	if stmt.Kind() != StatementSelect || (len(grid.Sort) == 0 && len(grid.Search) == 0) {
+		return bindArgs(stmt.Text, params)
+	}
+
+	subquery := "(\n" + stmt.Text + "\n)"
+
+	bound, args, err := bindArgs(subquery, params)
+	if err != nil {
+		return "", nil, err
+	}
+
+	columns, err := selectColumns(ctx, db, "SELECT * FROM "+bound+" LIMIT 0", args...)
+	if err != nil {
+		return "", nil, err
+	}
+
+	mapping := make(map[string]string, len(columns))
+	for _, column := range columns {
+		mapping[column.Name] = quoteIdent(column.Name)
+	}
+
+	sb := sqlbuilder.Select("*")
+	sb.SetFlavor(sqlbuilder.SQLite)
+	from, _ := bindParams(subquery, params, sqlbuilder.Escape, sb.Var)
+	sb.From(from)
+	w2sql.Where(sb, grid, mapping)
+	w2sql.OrderBy(sb, grid, mapping)
+
+	query, args := sb.Build()
+	return query, args, nil
+}
+
+// exporter writes the rows of one export format.
+type exporter interface {
+	begin(columns []string) error
+	row(values []any) error
+	end() error
+}
+
+func newExporter(w *bufio.Writer, format SQLExportFormat, table string) exporter {
+	switch format {
+	case ExportCSV:
+		return &csvExporter{w: csv.NewWriter(w)}
+	case ExportSQL:
+		return &sqlExporter{w: w, table: quoteIdent(table)}
+	default:
+		return &jsonExporter{w: w, lines: format == ExportNDJSON}
+	}
+}
+
+type csvExporter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func (e *csvExporter) begin(columns []string) error {
+	e.record = make([]string, len(columns))
+	return e.w.Write(columns)
+}
+
+func (e *csvExporter) row(values []any) error {
+	for i, value := range values {
+		e.record[i] = exportText(value)
+	}
+	return e.w.Write(e.record)
+}
+
+func (e *csvExporter) end() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// exportText formats a value as a CSV field.
+func exportText(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+type jsonExporter struct {
+	w       *bufio.Writer
+	lines   bool
+	columns [][]byte
+	count   int
+}
+
+func (e *jsonExporter) begin(columns []string) error {
+	e.columns = make([][]byte, len(columns))
+	for i, column := range columns {
+		key, err := json.Marshal(column)
+		if err != nil {
+			return err
+		}
+		e.columns[i] = key
+	}
+
+	if !e.lines {
+		_, err := e.w.WriteString("[")
+		return err
+	}
+	return nil
+}
+
+func (e *jsonExporter) row(values []any) error {
+	if !e.lines && e.count > 0 {
+		e.w.WriteString(",")
+	}
+	if !e.lines {
+		e.w.WriteString("\n")
+	}
+	e.count++
+
+	// an object built by hand keeps the keys in column order
+	e.w.WriteString("{")
+	for i, value := range values {
+		if i > 0 {
+			e.w.WriteString(",")
+		}
+
+		switch v := value.(type) {
+		case float64:
+			if math.IsInf(v, 0) || math.IsNaN(v) {
+				value = strconv.FormatFloat(v, 'g', -1, 64)
+			}
+		case []byte:
+			// the driver scans an empty BLOB as a nil slice, which is not NULL
+			value = base64.StdEncoding.EncodeToString(v)
+		}
+
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+
+		e.w.Write(e.columns[i])
+		e.w.WriteString(":")
+		e.w.Write(data)
+	}
+	e.w.WriteString("}")
+
+	if e.lines {
+		e.w.WriteString("\n")
+	}
+	return nil
+}
+
+func (e *jsonExporter) end() error {
+	if e.lines {
+		return nil
+	}
+	if e.count > 0 {
+		e.w.WriteString("\n")
+	}
+	_, err := e.w.WriteString("]\n")
+	return err
+}
+
+type sqlExporter struct {
+	w      *bufio.Writer
+	table  string
+	prefix string
+}
+
+func (e *sqlExporter) begin(columns []string) error {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = quoteIdent(column)
+	}
+	e.prefix = "INSERT INTO " + e.table + " (" + strings.Join(quoted, ", ") + ") VALUES ("
+	return nil
+}
+
+func (e *sqlExporter) row(values []any) error {
+	e.w.WriteString(e.prefix)
+	for i, value := range values {
+		if i > 0 {
+			e.w.WriteString(", ")
+		}
+		e.w.WriteString(sqlLiteral(value))
+	}
+	_, err := e.w.WriteString(");\n")
+	return err
+}
+
+func (e *sqlExporter) end() error {
+	return nil
+}
+
+// sqlLiteral formats a value as a SQLite literal.
+func sqlLiteral(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return "NULL"
+		case math.IsInf(v, 1):
+			return "9e999"
+		case math.IsInf(v, -1):
+			return "-9e999"
+		}
+		// a whole number keeps a decimal point so it stays REAL
+		literal := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(literal, ".e") {
+			literal += ".0"
+		}
+		return literal
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case []byte:
+		return "X'" + hex.EncodeToString(v) + "'"
+	default:
+		return "'" + strings.ReplaceAll(exportText(v), "'", "''") + "'"
+	}
+}
+
+// SQLExportHandler returns an export handler that reports errors to the
+// caller instead of writing error responses itself. Errors after the
+// download started are handled like in SQLExportHTTPHandler.
+func SQLExportHandler(db *sql.DB, opts SQLExecOptions) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var req SQLExportRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return err
+		}
+
+		return writeExport(w, r, db, req, opts)
+	}
+}
+
+// SQLExportHTTPHandler returns an http.HandlerFunc that streams a SQL explorer
+// result as a file attachment named after the table and format, such as
+// "todo.csv", which w2download saves under that name.
+//
+// Rejected queries return 403 Forbidden, unknown formats and invalid or
+// missing parameter values return 400 Bad Request, and queries over the
+// limits of opts.Monitor return 429 Too Many Requests. A query that fails
+// after the first rows were sent aborts the response, so the client never
+// saves a truncated file.
+func SQLExportHTTPHandler(db *sql.DB, opts SQLExecOptions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req SQLExportRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusBadRequest)
+			return
+		}
+
+		err := writeExport(w, r, db, req, opts)
+		if errors.Is(err, ErrStatementNotAllowed) || errors.Is(err, ErrConfirmRequired) || errors.Is(err, ErrAccessDenied) {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusForbidden)
+			return
+		} else if errors.Is(err, ErrExportFormat) || errors.Is(err, ErrInvalidParam) || errors.Is(err, ErrMissingParam) {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusBadRequest)
+			return
+		} else if errors.Is(err, ErrTooManyQueries) {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusTooManyRequests)
+			return
+		} else if err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// writeExport runs SQLExport into w. It returns the error of an export that
+// failed before anything was sent, and aborts the response otherwise.
+func writeExport(w http.ResponseWriter, r *http.Request, db *sql.DB, req SQLExportRequest, opts SQLExecOptions) error {
+	res := &exportResponse{ResponseWriter: w, req: req}
+
+	_, err := SQLExport(r.Context(), db, res, req, opts)
+	if err != nil && res.started {
+		slog.ErrorContext(r.Context(), "w2explorer: export", "error", err)
+		panic(http.ErrAbortHandler)
+	}
+
+	return err
+}
+
+// exportResponse sets the download headers on the first write, so an export
+// that fails before it writes can still send an error response.
+type exportResponse struct {
+	http.ResponseWriter
+	req     SQLExportRequest
+	started bool
+}
+
+func (res *exportResponse) Write(p []byte) (int, error) {
+	if !res.started {
+		res.started = true
+
+		format := exportFormats[res.req.Format]
+		res.Header().Set("Content-Type", format.contentType)
+		res.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", res.req.table()+"."+format.extension))
+	}
+
+	return res.ResponseWriter.Write(p)
+}
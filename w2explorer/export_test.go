@@ -0,0 +1,252 @@
+package w2explorer_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dv1x3r/w2go/w2explorer"
+)
+
+var exportSetup = []string{
+	"CREATE TABLE item (id INTEGER PRIMARY KEY, name TEXT, score REAL, data BLOB)",
+	"INSERT INTO item VALUES (1, 'it''s', 2.0, x'00ff'), (2, 'a,b', 1.5, NULL), (3, NULL, NULL, x'')",
+}
+
+func TestSQLExport(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t, exportSetup...)
+
+	tests := []struct {
+		Name     string
+		Request  w2explorer.SQLExportRequest
+		Expected string
+	}{
+		{
+			Name:    "CSV",
+			Request: w2explorer.SQLExportRequest{Query: "SELECT * FROM item", Format: w2explorer.ExportCSV},
+			Expected: "id,name,score,data\n" +
+				"1,it's,2,AP8=\n" +
+				"2,\"a,b\",1.5,\n" +
+				"3,,,\n",
+		},
+		{
+			Name:    "JSON",
+			Request: w2explorer.SQLExportRequest{Query: "SELECT * FROM item", Format: w2explorer.ExportJSON},
+			Expected: "[\n" +
+				`{"id":1,"name":"it's","score":2,"data":"AP8="},` + "\n" +
+				`{"id":2,"name":"a,b","score":1.5,"data":null},` + "\n" +
+				`{"id":3,"name":null,"score":null,"data":""}` + "\n" +
+				"]\n",
+		},
+		{
+			Name:     "JSONEmpty",
+			Request:  w2explorer.SQLExportRequest{Query: "SELECT * FROM item WHERE id > 3", Format: w2explorer.ExportJSON},
+			Expected: "[]\n",
+		},
+		{
+			Name:    "NDJSON",
+			Request: w2explorer.SQLExportRequest{Query: "SELECT id, name FROM item", Format: w2explorer.ExportNDJSON},
+			Expected: `{"id":1,"name":"it's"}` + "\n" +
+				`{"id":2,"name":"a,b"}` + "\n" +
+				`{"id":3,"name":null}` + "\n",
+		},
+		{
+			Name:    "SQL",
+			Request: w2explorer.SQLExportRequest{Query: "SELECT * FROM item", Format: w2explorer.ExportSQL},
+			Expected: `INSERT INTO "item" ("id", "name", "score", "data") VALUES (1, 'it''s', 2.0, X'00ff');` + "\n" +
+				`INSERT INTO "item" ("id", "name", "score", "data") VALUES (2, 'a,b', 1.5, NULL);` + "\n" +
+				`INSERT INTO "item" ("id", "name", "score", "data") VALUES (3, NULL, NULL, X'');` + "\n",
+		},
+		{
+			Name:     "SQLTable",
+			Request:  w2explorer.SQLExportRequest{Query: "SELECT id AS \"my id\" FROM item WHERE id = 1", Format: w2explorer.ExportSQL, Table: `copy"1`},
+			Expected: `INSERT INTO "copy""1" ("my id") VALUES (1);` + "\n",
+		},
+		{
+			Name:     "SQLDefaultTable",
+			Request:  w2explorer.SQLExportRequest{Query: "SELECT 1 AS one UNION SELECT 2", Format: w2explorer.ExportSQL},
+			Expected: `INSERT INTO "export" ("one") VALUES (1);` + "\n" + `INSERT INTO "export" ("one") VALUES (2);` + "\n",
+		},
+		{
+			Name: "Params",
+			Request: w2explorer.SQLExportRequest{
+				Query:  "SELECT id FROM item WHERE id >= :min",
+				Params: []w2explorer.SQLParam{{Name: ":min", Type: w2explorer.ParamInteger, Value: "2"}},
+				Format: w2explorer.ExportCSV,
+			},
+			Expected: "id\n2\n3\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if _, err := w2explorer.SQLExport(ctx, db, &buf, test.Request, w2explorer.SQLExecOptions{}); err != nil {
+				t.Fatal(err)
+			}
+
+			if buf.String() != test.Expected {
+				t.Errorf("❌ Expected:\n%s\ngot:\n%s", test.Expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestSQLExportReadOnly(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t, exportSetup...)
+	db.SetMaxOpenConns(1)
+
+	var buf bytes.Buffer
+	req := w2explorer.SQLExportRequest{Query: "PRAGMA query_only", Format: w2explorer.ExportCSV}
+	if _, err := w2explorer.SQLExport(ctx, db, &buf, req, w2explorer.SQLExecOptions{}); err != nil || buf.String() != "query_only\n1\n" {
+		t.Errorf("❌ Expected query_only to be on, got: %q %v", buf.String(), err)
+	}
+
+	// the pooled connection is reset after the export
+	if _, err := db.Exec("DELETE FROM item WHERE id = 3"); err != nil {
+		t.Errorf("❌ Expected the delete to succeed, got: %v", err)
+	}
+}
+
+func TestSQLExportGrid(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t, exportSetup...)
+
+	var req w2explorer.SQLExportRequest
+	body := `{"query": "SELECT id, name FROM item", "format": "csv", "limit": 1, "offset": 1,
+		"sort": [{"field": "id", "direction": "desc"}],
+		"search": [{"field": "id", "type": "int", "operator": "less", "value": 3}]}`
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	rows, err := w2explorer.SQLExport(ctx, db, &buf, req, w2explorer.SQLExecOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// limit and offset are ignored, so every matching row is exported
+	if expected := "id,name\n2,\"a,b\"\n1,it's\n"; rows != 2 || buf.String() != expected {
+		t.Errorf("❌ Expected 2 rows:\n%s\ngot %d:\n%s", expected, rows, buf.String())
+	}
+}
+
+func TestSQLExportRejected(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t, exportSetup...)
+
+	tests := []struct {
+		Name     string
+		Request  w2explorer.SQLExportRequest
+		Options  w2explorer.SQLExecOptions
+		Expected error
+	}{
+		{
+			Name:     "Format",
+			Request:  w2explorer.SQLExportRequest{Query: "SELECT * FROM item", Format: "xlsx"},
+			Expected: w2explorer.ErrExportFormat,
+		},
+		{
+			Name:     "Write",
+			Request:  w2explorer.SQLExportRequest{Query: "DELETE FROM item RETURNING *", Format: w2explorer.ExportCSV},
+			Expected: w2explorer.ErrStatementNotAllowed,
+		},
+		{
+			Name:     "ReadOnly",
+			Request:  w2explorer.SQLExportRequest{Query: "UPDATE item SET name = 'x' RETURNING *", Format: w2explorer.ExportCSV},
+			Options:  w2explorer.SQLExecOptions{ReadOnly: true},
+			Expected: w2explorer.ErrStatementNotAllowed,
+		},
+		{
+			Name:     "Authorize",
+			Request:  w2explorer.SQLExportRequest{Query: "SELECT * FROM item", Format: w2explorer.ExportCSV},
+			Options:  w2explorer.SQLExecOptions{Authorize: func(context.Context, w2explorer.SQLExecRequest) error { return errors.New("no exports") }},
+			Expected: w2explorer.ErrAccessDenied,
+		},
+		{
+			Name:     "Param",
+			Request:  w2explorer.SQLExportRequest{Query: "SELECT * FROM item WHERE id = :id", Params: []w2explorer.SQLParam{{Name: ":id", Type: w2explorer.ParamInteger, Value: "x"}}, Format: w2explorer.ExportCSV},
+			Expected: w2explorer.ErrInvalidParam,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if _, err := w2explorer.SQLExport(ctx, db, &buf, test.Request, test.Options); !errors.Is(err, test.Expected) {
+				t.Errorf("❌ Expected %v, got: %v", test.Expected, err)
+			}
+
+			if buf.Len() > 0 {
+				t.Errorf("❌ Expected no output, got: %q", buf.String())
+			}
+		})
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT count(*) FROM item").Scan(&count); err != nil || count != 3 {
+		t.Errorf("❌ Expected the rows to be kept, got: %d %v", count, err)
+	}
+}
+
+func TestSQLExportHTTPHandler(t *testing.T) {
+	db := openTestDB(t, exportSetup...)
+	handler := w2explorer.SQLExportHTTPHandler(db, w2explorer.SQLExecOptions{})
+
+	tests := []struct {
+		Name        string
+		Body        string
+		Code        int
+		ContentType string
+		Disposition string
+	}{
+		{
+			Name:        "CSV",
+			Body:        `{"query": "SELECT * FROM item", "format": "csv"}`,
+			Code:        http.StatusOK,
+			ContentType: "text/csv; charset=utf-8",
+			Disposition: `attachment; filename="item.csv"`,
+		},
+		{
+			Name:        "SQL",
+			Body:        `{"query": "SELECT 1", "format": "sql", "table": "backup"}`,
+			Code:        http.StatusOK,
+			ContentType: "application/sql; charset=utf-8",
+			Disposition: `attachment; filename="backup.sql"`,
+		},
+		{Name: "Format", Body: `{"query": "SELECT 1", "format": "xml"}`, Code: http.StatusBadRequest},
+		{Name: "Missing", Body: `{"query": "SELECT :a", "format": "csv"}`, Code: http.StatusBadRequest},
+		{Name: "Write", Body: `{"query": "DELETE FROM item", "format": "csv"}`, Code: http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler(w, httptest.NewRequest(http.MethodPost, "/export", strings.NewReader(test.Body)))
+
+			if w.Code != test.Code {
+				t.Fatalf("❌ Expected %d, got: %d %s", test.Code, w.Code, w.Body.String())
+			}
+
+			if test.Code != http.StatusOK {
+				return
+			}
+
+			if contentType := w.Header().Get("Content-Type"); contentType != test.ContentType {
+				t.Errorf("❌ Expected Content-Type %q, got: %q", test.ContentType, contentType)
+			}
+
+			if disposition := w.Header().Get("Content-Disposition"); disposition != test.Disposition {
+				t.Errorf("❌ Expected Content-Disposition %q, got: %q", test.Disposition, disposition)
+			}
+		})
+	}
+}
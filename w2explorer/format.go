@@ -0,0 +1,555 @@
+package w2explorer
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/dv1x3r/w2go/w2"
+)
+
+// SQLKeywordCase is the casing FormatSQL applies to SQL keywords.
+type SQLKeywordCase string
+
+// SQL keyword casings. The empty casing is KeywordUpper.
+const (
+	KeywordUpper    SQLKeywordCase = "upper"
+	KeywordLower    SQLKeywordCase = "lower"
+	KeywordPreserve SQLKeywordCase = "preserve"
+)
+
+// SQLFormatOptions configures FormatSQL.
+type SQLFormatOptions struct {
+	// KeywordCase is the casing of keywords. Identifiers, literals, and
+	// comments are never changed.
+	KeywordCase SQLKeywordCase `json:"keywordCase"`
+
+	// Indent is the number of spaces per indentation level. It defaults to 2.
+	Indent int `json:"indent"`
+}
+
+// sqlKeywords are the SQLite keywords FormatSQL changes the case of. Keywords
+// that are common column names, such as KEY and ROWID, are only keywords
+// after the words listed in contextKeywords.
+var sqlKeywords = []string{
+	"ABORT", "ADD", "AFTER", "ALL", "ALTER", "ALWAYS", "ANALYZE", "AND", "AS", "ASC",
+	"ATTACH", "AUTOINCREMENT", "BEFORE", "BEGIN", "BETWEEN", "BY", "CASCADE", "CASE",
+	"CAST", "CHECK", "COLLATE", "COLUMN", "COMMIT", "CONFLICT", "CONSTRAINT", "CREATE",
+	"CROSS", "CURRENT", "CURRENT_DATE", "CURRENT_TIME", "CURRENT_TIMESTAMP", "DEFAULT",
+	"DEFERRABLE", "DEFERRED", "DELETE", "DESC", "DETACH", "DISTINCT", "DO", "DROP",
+	"EACH", "ELSE", "END", "ESCAPE", "EXCEPT", "EXCLUDE", "EXCLUSIVE", "EXISTS",
+	"EXPLAIN", "FAIL", "FALSE", "FILTER", "FOLLOWING", "FOR", "FOREIGN", "FROM", "FULL",
+	"GENERATED", "GLOB", "GROUP", "GROUPS", "HAVING", "IF", "IGNORE", "IMMEDIATE", "IN",
+	"INDEX", "INDEXED", "INITIALLY", "INNER", "INSERT", "INSTEAD", "INTERSECT", "INTO",
+	"IS", "ISNULL", "JOIN", "LEFT", "LIKE", "LIMIT", "MATCH", "MATERIALIZED", "NATURAL",
+	"NOT", "NOTHING", "NOTNULL", "NULL", "NULLS", "OF", "OFFSET", "ON", "OR", "ORDER",
+	"OTHERS", "OUTER", "OVER", "PARTITION", "PRAGMA", "PRECEDING", "PRIMARY", "RAISE",
+	"RANGE", "RECURSIVE", "REFERENCES", "REGEXP", "REINDEX", "RELEASE", "RENAME",
+	"REPLACE", "RESTRICT", "RETURNING", "RIGHT", "ROLLBACK", "ROWS", "SAVEPOINT",
+	"SELECT", "SET", "TABLE", "TEMP", "TEMPORARY", "THEN", "TIES", "TO", "TRANSACTION",
+	"TRIGGER", "TRUE", "UNBOUNDED", "UNION", "UNIQUE", "UPDATE", "USING", "VACUUM",
+	"VALUES", "VIEW", "VIRTUAL", "WHEN", "WHERE", "WINDOW", "WITH", "WITHOUT",
+}
+
+// contextKeywords maps keywords that are common column names to the words
+// they follow when they are used as keywords.
+var contextKeywords = map[string][]string{
+	"KEY":      {"PRIMARY", "FOREIGN"},
+	"ROWID":    {"WITHOUT"},
+	"FIRST":    {"NULLS"},
+	"LAST":     {"NULLS"},
+	"ROW":      {"CURRENT", "EACH"},
+	"QUERY":    {"EXPLAIN"},
+	"PLAN":     {"QUERY"},
+	"DATABASE": {"ATTACH", "DETACH"},
+	"ACTION":   {"NO"},
+	"NO":       {"DELETE", "UPDATE"},
+	"STRICT":   {")"},
+}
+
+// isKeyword reports whether the word at tokens[i] is used as a SQL keyword.
+// A word in a qualified name or after AS is an identifier.
+func isKeyword(tokens []token, i int) bool {
+	t := tokens[i]
+	if t.Kind != tokenWord {
+		return false
+	}
+
+	prev := prevToken(tokens, i)
+	next := nextToken(tokens, i)
+	if prev.Text == "." || next.Text == "." {
+		return false
+	}
+
+	word := strings.ToUpper(t.Text)
+	if prev.is("AS") && !slices.Contains([]string{"SELECT", "VALUES", "WITH", "NOT", "MATERIALIZED"}, word) {
+		return false
+	}
+
+	if after, ok := contextKeywords[word]; ok {
+		return slices.ContainsFunc(after, func(s string) bool { return prev.Text == s || prev.is(s) })
+	}
+
+	return slices.Contains(sqlKeywords, word)
+}
+
+// prevIndex returns the index of the significant token before tokens[i], or -1.
+func prevIndex(tokens []token, i int) int {
+	for i--; i >= 0; i-- {
+		if tokens[i].Kind != tokenSpace && tokens[i].Kind != tokenComment {
+			return i
+		}
+	}
+	return -1
+}
+
+// prevToken returns the significant token before tokens[i], or an empty token.
+func prevToken(tokens []token, i int) token {
+	if i = prevIndex(tokens, i); i < 0 {
+		return token{}
+	}
+	return tokens[i]
+}
+
+// nextToken returns the significant token after tokens[i], or an empty token.
+func nextToken(tokens []token, i int) token {
+	for i++; i < len(tokens); i++ {
+		if tokens[i].Kind != tokenSpace && tokens[i].Kind != tokenComment {
+			return tokens[i]
+		}
+	}
+	return token{}
+}
+
+// formatClauses start a new line at the indentation of their query.
+var formatClauses = []string{
+	"SELECT", "FROM", "WHERE", "GROUP", "HAVING", "ORDER", "LIMIT", "WINDOW", "UNION",
+	"EXCEPT", "INTERSECT", "VALUES", "SET", "RETURNING",
+}
+
+// joinWords start a join, which goes on its own line below FROM.
+var joinWords = []string{"LEFT", "RIGHT", "FULL", "INNER", "CROSS", "NATURAL"}
+
+type frameKind int
+
+const (
+	// frameQuery is a statement or a parenthesized subquery, whose clauses
+	// go on their own lines.
+	frameQuery frameKind = iota
+
+	// frameList is the column list of CREATE TABLE, one definition per line.
+	frameList
+
+	// frameInline is any other parenthesized expression.
+	frameInline
+
+	// frameTrigger is the BEGIN...END body of CREATE TRIGGER.
+	frameTrigger
+)
+
+type formatFrame struct {
+	kind frameKind
+
+	// indent is the indentation level of the clause lines of the frame.
+	indent int
+
+	// clause is the clause keyword the frame is in, such as "WHERE".
+	clause string
+
+	// between is set after BETWEEN, whose AND stays on the same line.
+	between bool
+
+	// cases counts the open CASE expressions.
+	cases int
+}
+
+type formatter struct {
+	opts   SQLFormatOptions
+	sb     strings.Builder
+	frames []formatFrame
+
+	// lineIndent is the indentation level of the current line.
+	lineIndent int
+
+	// atLineStart is set when nothing was written on the current line.
+	atLineStart bool
+
+	// ended is set after a semicolon, so the next statement starts after a
+	// blank line while a trailing comment stays on the line of the semicolon.
+	ended bool
+
+	// prev and prevPrev are the last written significant tokens of the statement.
+	prev, prevPrev token
+
+	// create is the object type of a CREATE statement, such as "TABLE".
+	create string
+}
+
+// FormatSQL formats a script with one clause per line.
+//
+// Clauses start a line at the indentation of their query, joins and the AND
+// and OR conditions of WHERE, ON, and HAVING are indented one level deeper,
+// subqueries and the column definitions of CREATE TABLE are indented inside
+// their parentheses, and statements are separated by a blank line.
+// Keywords are cased by opts.KeywordCase. Comments, identifiers, and literals
+// are kept as written, and the formatted script tokenizes to the same tokens.
+func FormatSQL(query string, opts SQLFormatOptions) string {
+	if opts.Indent <= 0 {
+		opts.Indent = 2
+	}
+
+	f := &formatter{opts: opts, atLineStart: true}
+	f.resetStatement()
+
+	tokens := tokenize(query)
+	for i, t := range tokens {
+		switch t.Kind {
+		case tokenSpace:
+			continue
+		case tokenComment:
+			f.comment(tokens, i)
+			continue
+		}
+
+		f.token(tokens, i)
+		if f.ended {
+			f.resetStatement()
+		} else {
+			f.prevPrev, f.prev = f.prev, t
+		}
+	}
+
+	formatted := strings.TrimRight(f.sb.String(), " \n")
+	if formatted == "" {
+		return ""
+	}
+	return formatted + "\n"
+}
+
+func (f *formatter) resetStatement() {
+	f.frames = []formatFrame{{kind: frameQuery}}
+	f.prev, f.prevPrev = token{}, token{}
+	f.create = ""
+}
+
+func (f *formatter) frame() *formatFrame {
+	return &f.frames[len(f.frames)-1]
+}
+
+// newline ends the current line unless it is empty, and indents the next one.
+func (f *formatter) newline(indent int) {
+	if !f.atLineStart {
+		text := strings.TrimRight(f.sb.String(), " ")
+		f.sb.Reset()
+		f.sb.WriteString(text)
+		f.sb.WriteString("\n")
+		f.atLineStart = true
+	}
+	f.lineIndent = indent
+}
+
+// write writes text, preceded by the indentation at the start of a line or
+// by a space when space is set.
+func (f *formatter) write(text string, space bool) {
+	if f.atLineStart {
+		f.sb.WriteString(strings.Repeat(" ", f.lineIndent*f.opts.Indent))
+		f.atLineStart = false
+	} else if space {
+		f.sb.WriteString(" ")
+	}
+	f.sb.WriteString(text)
+}
+
+// comment keeps a comment on its own line when it was written on its own
+// line, and after the previous token otherwise. A line comment ends the line.
+func (f *formatter) comment(tokens []token, i int) {
+	t := tokens[i]
+	ownLine := i == 0 || tokens[i-1].Kind == tokenSpace && (i == 1 || strings.Contains(tokens[i-1].Text, "\n"))
+	if ownLine {
+		f.endStatement()
+		f.newline(f.lineIndent)
+	}
+
+	f.write(strings.TrimRight(t.Text, " \t\r\n"), true)
+
+	if strings.HasPrefix(t.Text, "--") {
+		f.newline(f.lineIndent)
+	}
+}
+
+// endStatement starts the next statement after a blank line.
+func (f *formatter) endStatement() {
+	if f.ended {
+		f.newline(0)
+		f.sb.WriteString("\n")
+		f.ended = false
+	}
+}
+
+func (f *formatter) token(tokens []token, i int) {
+	f.endStatement()
+
+	t := tokens[i]
+	frame := f.frame()
+	text := t.Text
+
+	if t.Kind == tokenWord && isKeyword(tokens, i) {
+		switch f.opts.KeywordCase {
+		case KeywordLower:
+			text = strings.ToLower(text)
+		case KeywordPreserve:
+		default:
+			text = strings.ToUpper(text)
+		}
+	}
+
+	switch {
+	case t.Text == ";":
+		if frame.kind == frameTrigger {
+			f.write(";", false)
+			frame.clause = ""
+			f.newline(frame.indent)
+			return
+		}
+		f.write(";", false)
+		f.ended = true
+		return
+
+	case t.Text == "(":
+		f.openParen(tokens, i)
+		return
+
+	case t.Text == ")":
+		f.closeParen()
+		return
+
+	case t.Text == ",":
+		f.write(",", false)
+		if frame.kind == frameList || frame.kind != frameInline && frame.clause == "WITH" {
+			f.newline(frame.indent)
+		}
+		return
+
+	case t.Text == ".":
+		f.write(".", false)
+		return
+	}
+
+	if t.Kind == tokenWord {
+		f.keyword(tokens, i)
+	}
+
+	f.write(text, f.spaceBefore())
+
+	if t.is("BEGIN") && f.create == "TRIGGER" && frame.kind == frameQuery {
+		f.frames = append(f.frames, formatFrame{kind: frameTrigger, indent: f.lineIndent + 1})
+		f.newline(f.lineIndent + 1)
+	}
+}
+
+// spaceBefore reports whether the next token is separated from the previous one.
+func (f *formatter) spaceBefore() bool {
+	switch {
+	case f.prev.Text == "", f.prev.Text == "(", f.prev.Text == ".":
+		return false
+	case f.prev.Kind == tokenPunct && (f.prev.Text == "-" || f.prev.Text == "+" || f.prev.Text == "~") && isUnary(f.prevPrev):
+		return false
+	}
+	return true
+}
+
+// isUnary reports whether a sign after prev is a unary operator.
+func isUnary(prev token) bool {
+	switch prev.Kind {
+	case tokenPunct:
+		return prev.Text != ")"
+	case tokenWord:
+		return slices.Contains(sqlKeywords, strings.ToUpper(prev.Text)) && !prev.is("END")
+	default:
+		return prev.Text == ""
+	}
+}
+
+// keyword starts a new line before clauses, joins, and conditions.
+func (f *formatter) keyword(tokens []token, i int) {
+	t := tokens[i]
+	frame := f.frame()
+	word := strings.ToUpper(t.Text)
+	next := nextToken(tokens, i)
+
+	if len(f.frames) == 1 && f.prev.Text == "" && t.is("CREATE") {
+		f.create = "CREATE"
+	} else if f.create == "CREATE" && slices.Contains([]string{"TABLE", "VIEW", "INDEX", "TRIGGER"}, word) {
+		f.create = word
+	}
+
+	if !isKeyword(tokens, i) {
+		return
+	}
+
+	switch {
+	case t.is("CASE"):
+		frame.cases++
+		return
+	case t.is("END") && frame.cases > 0:
+		frame.cases--
+		return
+	case t.is("BETWEEN"):
+		frame.between = true
+		return
+	}
+
+	if frame.kind != frameQuery && frame.kind != frameTrigger || frame.cases > 0 {
+		return
+	}
+
+	switch {
+	case t.is("END") && frame.kind == frameTrigger:
+		f.frames = f.frames[:len(f.frames)-1]
+		f.newline(f.frame().indent)
+
+	case t.is("WITH") && f.prev.Text == "" || t.is("WITH") && f.prev.Text == "(":
+		frame.clause = "WITH"
+
+	case slices.Contains(formatClauses, word):
+		if f.continuesClause(t) {
+			return
+		}
+		frame.clause = word
+		frame.between = false
+		f.newline(frame.indent)
+
+	case t.is("INSERT") || t.is("REPLACE") && !f.prev.is("OR") && next.Text != "(" || t.is("UPDATE") || t.is("DELETE"):
+		// the main statement after a WITH clause, or a statement of a trigger body
+		if f.prev.Text == ")" && frame.clause == "WITH" || f.prev.Text == "" {
+			frame.clause = word
+			f.newline(frame.indent)
+		}
+
+	case t.is("ON") && next.is("CONFLICT"):
+		frame.clause = "CONFLICT"
+		f.newline(frame.indent)
+
+	case slices.Contains(joinWords, word) && !slices.ContainsFunc(joinWords, f.prev.is) && (next.is("JOIN") || next.is("OUTER") || slices.ContainsFunc(joinWords, next.is)),
+		t.is("JOIN") && !slices.ContainsFunc(joinWords, f.prev.is) && !f.prev.is("OUTER"):
+		frame.clause = "JOIN"
+		f.newline(frame.indent + 1)
+
+	case t.is("ON") && frame.clause == "JOIN":
+		frame.clause = "ON"
+
+	case (t.is("AND") || t.is("OR")) && slices.Contains([]string{"WHERE", "HAVING", "ON"}, frame.clause):
+		if frame.between && t.is("AND") {
+			frame.between = false
+			return
+		}
+		indent := frame.indent + 1
+		if frame.clause == "ON" {
+			indent++
+		}
+		f.newline(indent)
+	}
+}
+
+// continuesClause reports whether a clause keyword continues the previous
+// words instead of starting a clause, as in DELETE FROM, IS DISTINCT FROM,
+// DEFAULT VALUES, UNION ALL SELECT, and a SELECT that opens a subquery.
+func (f *formatter) continuesClause(t token) bool {
+	switch {
+	case t.is("FROM"):
+		return f.prev.is("DELETE") || f.prev.is("DISTINCT")
+	case t.is("VALUES"):
+		return f.prev.is("DEFAULT")
+	}
+	return false
+}
+
+func (f *formatter) openParen(tokens []token, i int) {
+	frame := f.frame()
+	next := nextToken(tokens, i)
+	prev := prevIndex(tokens, i)
+
+	space := f.spaceBefore()
+	table := false
+	switch {
+	case f.prev.Kind == tokenWord && !isKeyword(tokens, prev) || f.prev.Kind == tokenQuoted:
+		// a function call, unless the name is a table with a column list
+		table = f.namesTable(tokens, prev)
+		space = table
+	case slices.ContainsFunc([]string{"CAST", "RAISE", "REPLACE", "LIKE", "GLOB", "MATCH", "REGEXP"}, f.prev.is):
+		space = false
+	}
+
+	f.write("(", space)
+
+	switch {
+	case next.is("SELECT") || next.is("WITH") || next.is("VALUES"):
+		f.frames = append(f.frames, formatFrame{kind: frameQuery, indent: f.lineIndent + 1})
+		f.newline(f.lineIndent + 1)
+	case f.create == "TABLE" && table && len(f.frames) == 1:
+		f.frames = append(f.frames, formatFrame{kind: frameList, indent: f.lineIndent + 1})
+		f.newline(f.lineIndent + 1)
+	default:
+		f.frames = append(f.frames, formatFrame{kind: frameInline, indent: frame.indent})
+	}
+}
+
+func (f *formatter) closeParen() {
+	if len(f.frames) == 1 {
+		f.write(")", false)
+		return
+	}
+
+	frame := f.frame()
+	f.frames = f.frames[:len(f.frames)-1]
+
+	if frame.kind == frameQuery || frame.kind == frameList {
+		f.newline(frame.indent - 1)
+	}
+	f.write(")", false)
+}
+
+// namesTable reports whether the possibly qualified name at tokens[i] is the
+// table of INSERT INTO, CREATE TABLE, CREATE VIEW, CREATE INDEX ... ON, or
+// REFERENCES, which is followed by a column list.
+func (f *formatter) namesTable(tokens []token, i int) bool {
+	before := prevIndex(tokens, i)
+	if before >= 0 && tokens[before].Text == "." {
+		before = prevIndex(tokens, prevIndex(tokens, before))
+	}
+	if before < 0 {
+		return false
+	}
+
+	t := tokens[before]
+	return t.is("INTO") || t.is("TABLE") || t.is("VIEW") || t.is("EXISTS") || t.is("REFERENCES") ||
+		t.is("ON") && f.create == "INDEX"
+}
+
+// SQLFormatRequest is the JSON request body for formatting a script.
+type SQLFormatRequest struct {
+	Query string `json:"query"`
+	SQLFormatOptions
+}
+
+// SQLFormatResponse holds a script formatted by FormatSQL.
+type SQLFormatResponse struct {
+	Status w2.Status `json:"status"`
+	Query  string    `json:"query"`
+}
+
+// SQLFormatHTTPHandler returns an http.HandlerFunc that formats the script in
+// the request body with FormatSQL. The script is only parsed.
+func SQLFormatHTTPHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req SQLFormatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, SQLFormatResponse{Status: w2.StatusSuccess, Query: FormatSQL(req.Query, req.SQLFormatOptions)})
+	}
+}
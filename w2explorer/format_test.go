@@ -0,0 +1,62 @@
+package w2explorer_test
+
+import (
+	"testing"
+
+	"github.com/dv1x3r/w2go/w2explorer"
+)
+
+func TestFormatSQL(t *testing.T) {
+	tests := []struct {
+		Query    string
+		Opts     w2explorer.SQLFormatOptions
+		Expected string
+	}{
+		{
+			Query: `select t.id, count(*) n from todo t left join status s on s.id = t.status_id and s.active ` +
+				`where t.quantity between 1 and 5 group by t.id order by n desc limit 10`,
+			Expected: `SELECT t.id, count(*) n
+FROM todo t
+  LEFT JOIN status s ON s.id = t.status_id
+    AND s.active
+WHERE t.quantity BETWEEN 1 AND 5
+GROUP BY t.id
+ORDER BY n DESC
+LIMIT 10
+`,
+		},
+		{
+			Query: `SELECT id FROM todo WHERE status_id IN (SELECT id FROM status WHERE name = 'Done -- select')`,
+			Opts:  w2explorer.SQLFormatOptions{KeywordCase: w2explorer.KeywordLower, Indent: 4},
+			Expected: `select id
+from todo
+where status_id in (
+    select id
+    from status
+    where name = 'Done -- select'
+)
+`,
+		},
+		{
+			Query: "update todo set name = 'x', quantity = quantity + 1 -- bump\nwhere id = :id; delete from todo",
+			Expected: `UPDATE todo
+SET name = 'x', quantity = quantity + 1 -- bump
+WHERE id = :id;
+
+DELETE FROM todo
+`,
+		},
+		{Query: " -- only a comment", Expected: "-- only a comment\n"},
+		{Query: "  ", Expected: ""},
+	}
+
+	for _, test := range tests {
+		actual := w2explorer.FormatSQL(test.Query, test.Opts)
+		if actual != test.Expected {
+			t.Errorf("❌ Expected:\n%s\nActual:\n%s", test.Expected, actual)
+		}
+		if again := w2explorer.FormatSQL(actual, test.Opts); again != actual {
+			t.Errorf("❌ Formatting is not idempotent:\n%s\nAgain:\n%s", actual, again)
+		}
+	}
+}
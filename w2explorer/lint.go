@@ -0,0 +1,801 @@
+package w2explorer
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/dv1x3r/w2go/w2"
+)
+
+// Lint rules reported in SQLLintMarker.Rule.
+const (
+	LintSelectStar    = "select-star"
+	LintMissingWhere  = "missing-where"
+	LintCrossJoin     = "cross-join"
+	LintUnknownTable  = "unknown-table"
+	LintUnknownColumn = "unknown-column"
+)
+
+// Lint severities, as used by the CodeMirror lint addon.
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+// SQLLintOptions configures LintSQL.
+type SQLLintOptions struct {
+	// LargeTableRows is the row count from which SELECT * is reported.
+	// Defaults to 10000.
+	LargeTableRows int64
+}
+
+// SQLLintPos is a 0-based position in the script, like CodeMirror.Pos.
+// Ch counts UTF-16 code units, as JavaScript strings do.
+type SQLLintPos struct {
+	Line int `json:"line"`
+	Ch   int `json:"ch"`
+}
+
+// SQLLintMarker is one problem found by LintSQL, in the annotation format of
+// the CodeMirror lint addon.
+type SQLLintMarker struct {
+	From     SQLLintPos `json:"from"`
+	To       SQLLintPos `json:"to"`
+	Message  string     `json:"message"`
+	Severity string     `json:"severity"`
+	Rule     string     `json:"rule"`
+}
+
+func (opts SQLLintOptions) largeTableRows() int64 {
+	if opts.LargeTableRows <= 0 {
+		return 10000
+	}
+	return opts.LargeTableRows
+}
+
+// lintSource is a table, view, subquery, or table function named in FROM,
+// JOIN, INTO, or UPDATE.
+type lintSource struct {
+	schema string
+	name   string
+	alias  string
+
+	// table is set when the columns of the source are known.
+	table *SchemaTable
+
+	// opaque sources have columns that cannot be checked, such as
+	// subqueries, CTEs, and tables created by the script.
+	opaque   bool
+	function bool
+
+	clause int // index of the FROM, JOIN, INTO, or UPDATE keyword
+	comma  int // index of the comma of a comma join, or -1
+	start  int // first token of the reference
+	end    int // last token of the name, for markers
+	next   int // index after the reference
+	depth  int
+
+	columns []int // INSERT column list
+}
+
+func (src *lintSource) qualifier() string {
+	return cmp.Or(src.alias, src.name)
+}
+
+func (src *lintSource) label() string {
+	return cmp.Or(src.name, src.alias, "subquery")
+}
+
+type linter struct {
+	query   string
+	lines   []int
+	schema  Schema
+	opts    SQLLintOptions
+	created map[string]bool
+	markers []SQLLintMarker
+}
+
+// LintSQL checks every SELECT, INSERT, UPDATE, and DELETE statement of the
+// script against schema and returns the problems sorted by position:
+//
+//   - SELECT * on tables with at least LargeTableRows rows, which requires
+//     Schema to be read with SchemaOptions.RowCounts
+//   - UPDATE and DELETE without WHERE
+//   - implicit cross joins, such as comma joins without a join condition
+//     and JOINs without ON or USING
+//   - tables and columns that do not exist in schema
+//
+// Tables created, altered, or dropped earlier in the script are not checked,
+// and neither are the columns of subqueries, CTEs, and virtual tables. The
+// script is only parsed.
+func LintSQL(query string, schema Schema, opts SQLLintOptions) []SQLLintMarker {
+	opts.LargeTableRows = opts.largeTableRows()
+
+	l := &linter{
+		query:   query,
+		lines:   []int{0},
+		schema:  schema,
+		opts:    opts,
+		created: map[string]bool{},
+		markers: []SQLLintMarker{},
+	}
+
+	for i := 0; i < len(query); i++ {
+		if query[i] == '\n' {
+			l.lines = append(l.lines, i+1)
+		}
+	}
+
+	for _, stmt := range splitStatements(query) {
+		l.statement(stmt)
+	}
+
+	slices.SortStableFunc(l.markers, func(a, b SQLLintMarker) int {
+		return cmp.Or(cmp.Compare(a.From.Line, b.From.Line), cmp.Compare(a.From.Ch, b.From.Ch))
+	})
+
+	return l.markers
+}
+
+// pos converts a byte offset of the script to a CodeMirror position.
+func (l *linter) pos(offset int) SQLLintPos {
+	line := sort.SearchInts(l.lines, offset+1) - 1
+	ch := 0
+	for _, r := range l.query[l.lines[line]:offset] {
+		ch += utf16.RuneLen(r)
+	}
+	return SQLLintPos{Line: line, Ch: ch}
+}
+
+// mark reports a problem spanning tokens[from] to tokens[to].
+func (l *linter) mark(tokens []token, from, to int, rule, severity, message string) {
+	l.markers = append(l.markers, SQLLintMarker{
+		From:     l.pos(tokens[from].Pos),
+		To:       l.pos(tokens[to].Pos + len(tokens[to].Text)),
+		Message:  message,
+		Severity: severity,
+		Rule:     rule,
+	})
+}
+
+func (l *linter) statement(stmt statement) {
+	switch stmt.Kind() {
+	case StatementSelect, StatementInsert, StatementUpdate, StatementDelete:
+	case StatementCreate, StatementAlter, StatementDrop:
+		l.define(stmt.Tokens)
+		return
+	default:
+		return
+	}
+
+	tokens := stmt.Tokens
+	depth := make([]int, len(tokens))
+	d := 0
+	for i, t := range tokens {
+		if t.Text == ")" && d > 0 {
+			d--
+		}
+		depth[i] = d
+		if t.Text == "(" {
+			d++
+		}
+	}
+
+	skip := map[int]bool{}
+	ctes := cteNames(tokens, skip)
+	sources := l.sources(stmt, depth, skip)
+
+	for _, src := range sources {
+		l.resolve(tokens, src, ctes)
+	}
+
+	l.selectStar(tokens, depth, sources)
+	l.missingWhere(stmt, sources)
+	l.crossJoins(tokens, depth, sources)
+	l.columns(tokens, sources, skip)
+}
+
+// define remembers the table or view named by CREATE, ALTER, or DROP, so
+// later statements of the script do not report it as unknown.
+func (l *linter) define(tokens []token) {
+	i := slices.IndexFunc(tokens, func(t token) bool { return t.is("TABLE") || t.is("VIEW") })
+	if i < 0 {
+		return
+	}
+
+	for i++; i < len(tokens) && (tokens[i].is("IF") || tokens[i].is("NOT") || tokens[i].is("EXISTS")); i++ {
+	}
+
+	if i+2 < len(tokens) && tokens[i+1].Text == "." {
+		i += 2
+	}
+	if i < len(tokens) && isIdent(tokens[i]) {
+		l.created[strings.ToLower(identName(tokens[i]))] = true
+	}
+
+	if j := slices.IndexFunc(tokens, func(t token) bool { return t.is("TO") }); j > 0 && j+1 < len(tokens) && tokens[j-1].is("RENAME") {
+		l.created[strings.ToLower(identName(tokens[j+1]))] = true
+	}
+}
+
+// cteNames returns the names of the common table expressions of tokens and
+// marks their names and column lists in skip.
+func cteNames(tokens []token, skip map[int]bool) map[string]bool {
+	ctes := map[string]bool{}
+
+	for i, t := range tokens {
+		if !t.is("WITH") {
+			continue
+		}
+
+		j := i + 1
+		if j < len(tokens) && tokens[j].is("RECURSIVE") {
+			j++
+		}
+
+		for j < len(tokens) && isIdent(tokens[j]) {
+			ctes[strings.ToLower(identName(tokens[j]))] = true
+			skip[j] = true
+			j++
+
+			if j < len(tokens) && tokens[j].Text == "(" {
+				end := closingParen(tokens, j)
+				for k := j + 1; k < end; k++ {
+					skip[k] = true
+				}
+				j = end + 1
+			}
+
+			for j < len(tokens) && (tokens[j].is("AS") || tokens[j].is("NOT") || tokens[j].is("MATERIALIZED")) {
+				j++
+			}
+
+			if j >= len(tokens) || tokens[j].Text != "(" {
+				break
+			}
+			j = closingParen(tokens, j) + 1
+
+			if j >= len(tokens) || tokens[j].Text != "," {
+				break
+			}
+			j++
+		}
+	}
+
+	return ctes
+}
+
+// closingParen returns the index of the parenthesis that closes tokens[i],
+// or the last index when it is not closed.
+func closingParen(tokens []token, i int) int {
+	depth := 0
+	for j := i; j < len(tokens); j++ {
+		switch tokens[j].Text {
+		case "(":
+			depth++
+		case ")":
+			if depth--; depth == 0 {
+				return j
+			}
+		}
+	}
+	return len(tokens) - 1
+}
+
+// sources collects the table references of stmt at any depth and marks their
+// names and aliases in skip.
+func (l *linter) sources(stmt statement, depth []int, skip map[int]bool) []*lintSource {
+	tokens := stmt.Tokens
+	main := stmt.mainKeyword()
+	var sources []*lintSource
+
+	for i, t := range tokens {
+		switch {
+		case t.is("FROM") && !prevToken(tokens, i).is("DISTINCT"):
+			comma := -1
+			for j := i + 1; ; {
+				src, ok := parseSource(tokens, j, skip)
+				if !ok {
+					break
+				}
+
+				src.clause, src.comma, src.depth = i, comma, depth[i]
+				sources = append(sources, src)
+
+				if j = src.next; j >= len(tokens) || tokens[j].Text != "," {
+					break
+				}
+				comma = j
+				j++
+			}
+
+		case t.is("JOIN"):
+			src, ok := parseSource(tokens, i+1, skip)
+			if !ok {
+				continue
+			}
+
+			src.clause, src.comma, src.depth = i, -1, depth[i]
+			sources = append(sources, src)
+
+			start, cross := i, false
+			for start > 0 && slices.ContainsFunc(joinWords, tokens[start-1].is) || start > 0 && tokens[start-1].is("OUTER") {
+				start--
+				cross = cross || tokens[start].is("CROSS") || tokens[start].is("NATURAL")
+			}
+
+			next := nextToken(tokens, src.next-1)
+			if !cross && !next.is("ON") && !next.is("USING") {
+				l.mark(tokens, start, src.end, LintCrossJoin, LintWarning,
+					fmt.Sprintf("JOIN %q has no ON or USING and pairs every row with every row; use CROSS JOIN if that is intended", src.label()))
+			}
+
+		case t.is("INTO"), t.is("UPDATE") && i == main:
+			j := i + 1
+			if t.is("UPDATE") && j+1 < len(tokens) && tokens[j].is("OR") {
+				j += 2
+			}
+
+			if j >= len(tokens) || !isIdent(tokens[j]) {
+				continue
+			}
+
+			src := &lintSource{name: identName(tokens[j]), clause: i, comma: -1, start: j, end: j, depth: depth[i]}
+			skip[j] = true
+			j++
+
+			if j+1 < len(tokens) && tokens[j].Text == "." && isIdent(tokens[j+1]) {
+				src.schema, src.name, src.end = src.name, identName(tokens[j+1]), j+1
+				skip[j+1] = true
+				j += 2
+			}
+
+			if j+1 < len(tokens) && tokens[j].is("AS") && isIdent(tokens[j+1]) {
+				src.alias = identName(tokens[j+1])
+				skip[j+1] = true
+				j += 2
+			}
+
+			if t.is("INTO") && j < len(tokens) && tokens[j].Text == "(" {
+				end := closingParen(tokens, j)
+				for k := j + 1; k < end; k++ {
+					if isIdent(tokens[k]) {
+						src.columns = append(src.columns, k)
+						skip[k] = true
+					}
+				}
+				j = end + 1
+			}
+
+			src.next = j
+			sources = append(sources, src)
+		}
+	}
+
+	return sources
+}
+
+// parseSource parses one table reference of FROM or JOIN at i.
+func parseSource(tokens []token, i int, skip map[int]bool) (*lintSource, bool) {
+	if i >= len(tokens) {
+		return nil, false
+	}
+
+	src := &lintSource{start: i, end: i}
+
+	switch t := tokens[i]; {
+	case t.Text == "(":
+		src.opaque = true
+		src.end = closingParen(tokens, i)
+		i = src.end + 1
+	case isIdent(t) && !isClauseKeyword(t):
+		src.name = identName(t)
+		skip[i] = true
+		i++
+
+		if i+1 < len(tokens) && tokens[i].Text == "." && isIdent(tokens[i+1]) {
+			src.schema, src.name, src.end = src.name, identName(tokens[i+1]), i+1
+			skip[i+1] = true
+			i += 2
+		}
+
+		if i < len(tokens) && tokens[i].Text == "(" {
+			src.function = true
+			i = closingParen(tokens, i) + 1
+		}
+	default:
+		return nil, false
+	}
+
+	if i < len(tokens) && tokens[i].is("AS") {
+		i++
+	}
+
+	if i < len(tokens) && isIdent(tokens[i]) && !isClauseKeyword(tokens[i]) {
+		src.alias = identName(tokens[i])
+		skip[i] = true
+		i++
+	}
+
+	switch {
+	case i+2 < len(tokens) && tokens[i].is("INDEXED") && tokens[i+1].is("BY"):
+		skip[i+2] = true
+		i += 3
+	case i+1 < len(tokens) && tokens[i].is("NOT") && tokens[i+1].is("INDEXED"):
+		i += 2
+	}
+
+	src.next = i
+	return src, true
+}
+
+// resolve looks up src in the schema and reports unknown tables.
+func (l *linter) resolve(tokens []token, src *lintSource, ctes map[string]bool) {
+	key := strings.ToLower(src.name)
+	switch {
+	case src.opaque:
+		return
+	case src.function, src.schema == "" && ctes[key], l.created[key], strings.HasPrefix(key, "sqlite_"):
+		src.opaque = true
+		return
+	}
+
+	table, known := l.lookup(src.schema, src.name)
+	switch {
+	case !known:
+		src.opaque = true
+	case table == nil:
+		src.opaque = true
+		l.mark(tokens, src.start, src.end, LintUnknownTable, LintError, fmt.Sprintf("unknown table %q", src.name))
+	case table.Type == "virtual", table.Type == "view" && len(table.Columns) == 0:
+		// virtual tables have hidden columns, and broken views have none
+		src.opaque = true
+	default:
+		src.table = table
+	}
+
+	if src.table == nil {
+		return
+	}
+
+	for _, i := range src.columns {
+		if name := identName(tokens[i]); !hasColumn(src.table, name) {
+			l.mark(tokens, i, i, LintUnknownColumn, LintError, fmt.Sprintf("unknown column %q in %q", name, src.table.Name))
+		}
+	}
+}
+
+// lookup finds a table or view the way SQLite resolves names: in the given
+// database, or else in temp, main, and the attached databases in order. It
+// reports false when the database is not part of the schema.
+func (l *linter) lookup(database, name string) (*SchemaTable, bool) {
+	databases := slices.Clone(l.schema.Databases)
+	if len(databases) == 0 {
+		return nil, false
+	}
+
+	if database != "" {
+		databases = slices.DeleteFunc(databases, func(db SchemaDatabase) bool { return !strings.EqualFold(db.Name, database) })
+		if len(databases) == 0 {
+			return nil, false
+		}
+	} else {
+		if i := slices.IndexFunc(databases, func(db SchemaDatabase) bool { return db.Name == "temp" }); i > 0 {
+			temp := databases[i]
+			databases = slices.Insert(slices.Delete(databases, i, i+1), 0, temp)
+		}
+	}
+
+	for _, db := range databases {
+		for _, group := range [][]SchemaTable{db.Tables, db.Views, db.Virtual, db.Shadow} {
+			for i := range group {
+				if strings.EqualFold(group[i].Name, name) {
+					return &group[i], true
+				}
+			}
+		}
+	}
+
+	return nil, true
+}
+
+// hasColumn reports whether table has the column, including the rowid
+// aliases of rowid tables.
+func hasColumn(table *SchemaTable, name string) bool {
+	if table == nil {
+		return false
+	}
+
+	if slices.ContainsFunc(table.Columns, func(c SchemaColumn) bool { return strings.EqualFold(c.Name, name) }) {
+		return true
+	}
+
+	if table.Type == "view" || table.WithoutRowID {
+		return false
+	}
+
+	return slices.ContainsFunc([]string{"rowid", "oid", "_rowid_"}, func(s string) bool { return strings.EqualFold(s, name) })
+}
+
+// selectStar reports SELECT * and t.* on large tables.
+func (l *linter) selectStar(tokens []token, depth []int, sources []*lintSource) {
+	for i, t := range tokens {
+		if t.Text != "*" || i == 0 {
+			continue
+		}
+
+		var candidates []*lintSource
+		start := i
+		prev := tokens[i-1]
+
+		switch {
+		case prev.is("SELECT"), prev.is("DISTINCT"), prev.is("ALL"), prev.Text == ",":
+			end := boundary(tokens, depth, i, "UNION", "EXCEPT", "INTERSECT")
+			for _, src := range sources {
+				if src.depth == depth[i] && src.clause > i && src.clause < end {
+					candidates = append(candidates, src)
+				}
+			}
+		case prev.Text == "." && i >= 2:
+			start = i - 2
+			if src := findSource(sources, identName(tokens[i-2])); src != nil {
+				candidates = append(candidates, src)
+			}
+		default:
+			continue
+		}
+
+		var large []string
+		for _, src := range candidates {
+			if src.table != nil && src.table.RowCount != nil && *src.table.RowCount >= l.opts.LargeTableRows {
+				large = append(large, fmt.Sprintf("%q", src.table.Name))
+			}
+		}
+
+		if len(large) > 0 {
+			l.mark(tokens, start, i, LintSelectStar, LintWarning,
+				fmt.Sprintf("SELECT * reads every column of %s with %d or more rows; list the columns you need", strings.Join(large, ", "), l.opts.LargeTableRows))
+		}
+	}
+}
+
+// boundary returns the index of the first token after i that ends the query
+// level of tokens[i]: a closing parenthesis or one of the keywords at the
+// same depth.
+func boundary(tokens []token, depth []int, i int, keywords ...string) int {
+	for j := i + 1; j < len(tokens); j++ {
+		if depth[j] < depth[i] || depth[j] == depth[i] && slices.ContainsFunc(keywords, tokens[j].is) {
+			return j
+		}
+	}
+	return len(tokens)
+}
+
+// findSource returns the source with the given alias, or the unaliased
+// source with the given name.
+func findSource(sources []*lintSource, qualifier string) *lintSource {
+	for _, src := range sources {
+		if strings.EqualFold(src.qualifier(), qualifier) {
+			return src
+		}
+	}
+	return nil
+}
+
+// missingWhere reports UPDATE and DELETE statements without WHERE.
+func (l *linter) missingWhere(stmt statement, sources []*lintSource) {
+	if !stmt.IsDestructive() {
+		return
+	}
+
+	main := stmt.mainKeyword()
+	end := main
+	for _, src := range sources {
+		if src.depth == 0 && src.clause >= main && src.clause <= main+1 {
+			end = src.end
+			break
+		}
+	}
+
+	word := strings.ToUpper(stmt.Tokens[main].Text)
+	l.mark(stmt.Tokens, main, end, LintMissingWhere, LintWarning, word+" without WHERE affects every row")
+}
+
+// crossJoins reports comma joins whose WHERE clause does not mention the
+// joined table. A WHERE clause with unqualified columns is assumed to join.
+func (l *linter) crossJoins(tokens []token, depth []int, sources []*lintSource) {
+	for _, src := range sources {
+		if src.comma < 0 {
+			continue
+		}
+
+		end := boundary(tokens, depth, src.clause,
+			"GROUP", "HAVING", "ORDER", "LIMIT", "WINDOW", "UNION", "EXCEPT", "INTERSECT", "RETURNING")
+
+		where := slices.IndexFunc(tokens[src.clause:end], func(t token) bool { return t.is("WHERE") })
+		joined := false
+
+		if where >= 0 {
+			for i := src.clause + where + 1; i < end && !joined; i++ {
+				if !isIdent(tokens[i]) || isKeyword(tokens, i) {
+					continue
+				}
+
+				prev, next := prevToken(tokens, i), nextToken(tokens, i)
+				switch {
+				case next.Text == ".":
+					joined = src.qualifier() != "" && strings.EqualFold(identName(tokens[i]), src.qualifier())
+				case prev.Text != "." && next.Text != "(":
+					joined = true
+				}
+			}
+		}
+
+		if !joined {
+			l.mark(tokens, src.comma, src.end, LintCrossJoin, LintWarning,
+				fmt.Sprintf("%q is joined without a join condition and pairs every row with every row; add one to WHERE or use CROSS JOIN", src.label()))
+		}
+	}
+}
+
+// columns reports qualified columns that do not exist in their table, and
+// unqualified columns that exist in none of the tables of the statement.
+// Unqualified columns are only checked when every table is known.
+func (l *linter) columns(tokens []token, sources []*lintSource, skip map[int]bool) {
+	checkAll := len(sources) > 0 && !slices.ContainsFunc(sources, func(src *lintSource) bool { return src.opaque })
+
+	aliases := map[string]bool{}
+	for i := range tokens {
+		if !skip[i] && isIdent(tokens[i]) && isAlias(tokens, i) {
+			aliases[strings.ToLower(identName(tokens[i]))] = true
+		}
+	}
+
+	var target *lintSource
+	for _, src := range sources {
+		if tokens[src.clause].is("INTO") {
+			target = src
+		}
+	}
+
+	for i, t := range tokens {
+		if skip[i] || !isIdent(t) || isKeyword(tokens, i) {
+			continue
+		}
+
+		prev, next := prevToken(tokens, i), nextToken(tokens, i)
+		if prev.Text == "." || next.Text == "(" {
+			continue
+		}
+
+		if next.Text == "." {
+			l.qualifiedColumn(tokens, i, sources, target)
+			continue
+		}
+
+		name := identName(t)
+		if !checkAll || aliases[strings.ToLower(name)] || isAlias(tokens, i) ||
+			prev.is("COLLATE") || prev.is("OVER") || prev.is("WINDOW") || prev.is("IN") {
+			continue
+		}
+
+		if slices.ContainsFunc(sources, func(src *lintSource) bool { return hasColumn(src.table, name) }) {
+			continue
+		}
+
+		if strings.HasPrefix(t.Text, `"`) {
+			l.mark(tokens, i, i, LintUnknownColumn, LintWarning, fmt.Sprintf("unknown column %q; SQLite reads it as a string, use single quotes for strings", name))
+		} else {
+			l.mark(tokens, i, i, LintUnknownColumn, LintError, fmt.Sprintf("unknown column %q", name))
+		}
+	}
+}
+
+// qualifiedColumn checks "qualifier.column" or "schema.table.column" at i.
+func (l *linter) qualifiedColumn(tokens []token, i int, sources []*lintSource, target *lintSource) {
+	q, col := i, i+2
+	if col+2 < len(tokens) && tokens[col+1].Text == "." {
+		q, col = col, col+2
+	}
+
+	if col >= len(tokens) || tokens[col].Kind != tokenWord && tokens[col].Kind != tokenQuoted {
+		return
+	}
+
+	qualifier := identName(tokens[q])
+	src := findSource(sources, qualifier)
+	if src == nil && target != nil && strings.EqualFold(qualifier, "excluded") {
+		src = target
+	}
+
+	if src == nil {
+		if !slices.ContainsFunc(sources, func(src *lintSource) bool { return strings.EqualFold(src.name, qualifier) }) {
+			l.mark(tokens, q, q, LintUnknownTable, LintError, fmt.Sprintf("unknown table or alias %q", qualifier))
+		}
+		return
+	}
+
+	if name := identName(tokens[col]); src.table != nil && !hasColumn(src.table, name) {
+		l.mark(tokens, col, col, LintUnknownColumn, LintError, fmt.Sprintf("unknown column %q in %q", name, src.table.Name))
+	}
+}
+
+// isAlias reports whether the identifier at i names a result column, as in
+// "expr AS alias" or "expr alias".
+func isAlias(tokens []token, i int) bool {
+	if i == 0 {
+		return false
+	}
+
+	prev := tokens[i-1]
+	switch prev.Kind {
+	case tokenString, tokenNumber, tokenParam, tokenQuoted:
+		return true
+	case tokenWord:
+		return prev.is("AS") || prev.is("END") || !isKeyword(tokens, i-1) && isIdent(prev)
+	default:
+		return prev.Text == ")"
+	}
+}
+
+// SQLLintRequest is the body accepted by SQLLintHTTPHandler.
+type SQLLintRequest struct {
+	Query string `json:"query"`
+}
+
+// SQLLintResponse holds the markers found by LintSQL.
+type SQLLintResponse struct {
+	Status  w2.Status       `json:"status"`
+	Markers []SQLLintMarker `json:"markers"`
+}
+
+// SQLLintHandler returns an http handler that lints the script in the request
+// body with LintSQL against the schema of provider.
+func SQLLintHandler(provider SchemaProvider, opts SQLLintOptions) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var req SQLLintRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return err
+		}
+
+		schema, err := provider.Schema(r.Context(), lintSchemaOptions(opts))
+		if err != nil {
+			return err
+		}
+
+		return writeJSON(w, SQLLintResponse{Status: w2.StatusSuccess, Markers: LintSQL(req.Query, schema, opts)})
+	}
+}
+
+// SQLLintHTTPHandler returns an http.HandlerFunc that lints the script in the
+// request body with LintSQL against the schema of provider. Row counts stop
+// at LargeTableRows, so large tables are not scanned in full.
+func SQLLintHTTPHandler(provider SchemaProvider, opts SQLLintOptions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req SQLLintRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusBadRequest)
+			return
+		}
+
+		schema, err := provider.Schema(r.Context(), lintSchemaOptions(opts))
+		if err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, SQLLintResponse{Status: w2.StatusSuccess, Markers: LintSQL(req.Query, schema, opts)})
+	}
+}
+
+func lintSchemaOptions(opts SQLLintOptions) SchemaOptions {
+	return SchemaOptions{RowCounts: true, RowCountLimit: opts.largeTableRows()}
+}
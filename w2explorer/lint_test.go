@@ -0,0 +1,66 @@
+package w2explorer_test
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/dv1x3r/w2go/w2explorer"
+)
+
+func TestLintSQL(t *testing.T) {
+	rows := int64(50000)
+	schema := w2explorer.Schema{Databases: []w2explorer.SchemaDatabase{{
+		Name: "main",
+		Tables: []w2explorer.SchemaTable{
+			{
+				Name:    "status",
+				Type:    "table",
+				Columns: []w2explorer.SchemaColumn{{Name: "id", PK: 1}, {Name: "name"}},
+			},
+			{
+				Name:     "todo",
+				Type:     "table",
+				Columns:  []w2explorer.SchemaColumn{{Name: "id", PK: 1}, {Name: "name"}, {Name: "status_id"}},
+				RowCount: &rows,
+			},
+		},
+	}}}
+
+	tests := []struct {
+		Query    string
+		Expected []string
+	}{
+		{Query: `SELECT id, name FROM todo WHERE status_id = 1`},
+		{Query: `SELECT * FROM status`},
+		{Query: `SELECT t.id, s.name AS status FROM todo t JOIN status s ON s.id = t.status_id ORDER BY status`},
+		{Query: `SELECT rowid, count(*) n FROM todo GROUP BY rowid HAVING n > 1`},
+		{Query: `WITH done AS (SELECT id FROM status) SELECT done.id, x FROM done`},
+		{Query: `SELECT a.id FROM todo a, status b WHERE b.id = a.status_id`},
+		{Query: `SELECT 1 FROM todo CROSS JOIN status`},
+		{Query: `CREATE TABLE tmp (a); SELECT a FROM tmp`},
+		{Query: `INSERT INTO todo (name) VALUES ('a') ON CONFLICT (id) DO UPDATE SET name = excluded.name`},
+		{Query: `SELECT * FROM todo`, Expected: []string{"0:7-0:8 select-star"}},
+		{Query: `SELECT t.* FROM todo t`, Expected: []string{"0:7-0:10 select-star"}},
+		{Query: `DELETE FROM todo`, Expected: []string{"0:0-0:16 missing-where"}},
+		{Query: `UPDATE todo SET name = ''`, Expected: []string{"0:0-0:11 missing-where"}},
+		{Query: `SELECT 1 FROM todo, status`, Expected: []string{"0:18-0:26 cross-join"}},
+		{Query: `SELECT 1 FROM todo JOIN status`, Expected: []string{"0:19-0:30 cross-join"}},
+		{Query: `SELECT 1 FROM todos`, Expected: []string{"0:14-0:19 unknown-table"}},
+		{Query: "SELECT id,\n  nam FROM todo", Expected: []string{"1:2-1:5 unknown-column"}},
+		{Query: `SELECT x.id, t.nam FROM todo t`, Expected: []string{"0:7-0:8 unknown-table", "0:15-0:18 unknown-column"}},
+		{Query: `INSERT INTO todo (nam) VALUES ('a')`, Expected: []string{"0:18-0:21 unknown-column"}},
+		{Query: `SELECT '🙂', nam FROM todo`, Expected: []string{"0:13-0:16 unknown-column"}},
+	}
+
+	for _, test := range tests {
+		var actual []string
+		for _, m := range w2explorer.LintSQL(test.Query, schema, w2explorer.SQLLintOptions{}) {
+			actual = append(actual, fmt.Sprintf("%d:%d-%d:%d %s", m.From.Line, m.From.Ch, m.To.Line, m.To.Ch, m.Rule))
+		}
+
+		if !slices.Equal(actual, test.Expected) {
+			t.Errorf("❌ Expected %v for %q, got: %v", test.Expected, test.Query, actual)
+		}
+	}
+}
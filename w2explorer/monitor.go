@@ -0,0 +1,317 @@
+package w2explorer
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dv1x3r/w2go/w2"
+)
+
+// QueryMonitorOptions configures NewQueryMonitor.
+type QueryMonitorOptions struct {
+	// MaxConcurrent limits the number of queries running at the same time.
+	// Zero means no limit.
+	MaxConcurrent int
+
+	// MaxConcurrentPerUser limits the number of queries one user set by
+	// WithUser runs at the same time. Requests without a user count as one
+	// user. Zero means no limit.
+	MaxConcurrentPerUser int
+
+	// Admin reports whether the user of ctx may list and kill the queries of
+	// every user in RunningHTTPHandler and KillHTTPHandler. Other users only
+	// see and kill their own queries. Nil means nobody is an admin.
+	Admin func(ctx context.Context) bool
+
+	// Redact overrides DefaultRedactRules for the queries listed by
+	// RunningHTTPHandler. Use an empty RedactRules to list them as they are.
+	Redact *RedactRules
+}
+
+// QueryMonitor tracks the running SQL explorer queries, limits how many run
+// at once, and kills them by query ID.
+//
+// Share one monitor between the handlers that should count against the same
+// limits by setting it as SQLExecOptions.Monitor. Killing a query cancels its
+// context, and the SQLite drivers interrupt the running statement when the
+// context of a query is cancelled.
+type QueryMonitor struct {
+	opts QueryMonitorOptions
+
+	mu      sync.Mutex
+	running map[string]*runningQuery
+	users   map[string]int
+}
+
+// NewQueryMonitor returns a query monitor without running queries.
+func NewQueryMonitor(opts QueryMonitorOptions) *QueryMonitor {
+	if opts.Redact == nil {
+		opts.Redact = &DefaultRedactRules
+	}
+
+	return &QueryMonitor{opts: opts, running: map[string]*runningQuery{}, users: map[string]int{}}
+}
+
+// RunningQuery is one query tracked by a QueryMonitor.
+type RunningQuery struct {
+	// ID is the query ID sent by the client or generated by the server.
+	ID string `json:"recid"`
+
+	// User is the user set by WithUser when the query started.
+	User string `json:"user"`
+
+	// Query is the SQL text.
+	Query string `json:"query"`
+
+	// StartedAt is the UTC start time in ISO 8601 format.
+	StartedAt string `json:"startedAt"`
+
+	// Elapsed is the running time in seconds.
+	Elapsed float64 `json:"elapsed"`
+}
+
+type runningQuery struct {
+	RunningQuery
+	started time.Time
+	cancel  context.CancelCauseFunc
+}
+
+// ErrTooManyQueries is returned when a QueryMonitor limit is reached.
+var ErrTooManyQueries = errors.New("too many running queries")
+
+// ErrQueryIDInUse is returned when a query starts with the ID of a query that
+// is still running.
+var ErrQueryIDInUse = errors.New("query ID is already in use")
+
+// ErrQueryNotFound is returned when no running query has the requested ID.
+var ErrQueryNotFound = errors.New("query not found")
+
+// ErrQueryKilled is the cancellation cause of a killed query.
+var ErrQueryKilled = errors.New("query was killed")
+
+// ErrQueryTimeout is the cancellation cause of a query that ran longer than
+// SQLExecOptions.Timeout.
+var ErrQueryTimeout = errors.New("query timed out")
+
+// newQueryID returns a random query ID.
+func newQueryID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Start registers a query and returns a context that is cancelled when the
+// query is killed, and a function that must be called when it finishes.
+// An empty id is replaced by a random one, which Start returns.
+func (m *QueryMonitor) Start(ctx context.Context, id, query string) (context.Context, string, func(), error) {
+	if id == "" {
+		id = newQueryID()
+	}
+
+	user := UserFromContext(ctx)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.running[id]; ok {
+		return ctx, id, func() {}, fmt.Errorf("%w: %s", ErrQueryIDInUse, id)
+	}
+
+	if m.opts.MaxConcurrent > 0 && len(m.running) >= m.opts.MaxConcurrent {
+		return ctx, id, func() {}, fmt.Errorf("%w: %d queries are running", ErrTooManyQueries, len(m.running))
+	}
+
+	if m.opts.MaxConcurrentPerUser > 0 && m.users[user] >= m.opts.MaxConcurrentPerUser {
+		return ctx, id, func() {}, fmt.Errorf("%w: %d queries of this user are running", ErrTooManyQueries, m.users[user])
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	started := time.Now()
+	m.running[id] = &runningQuery{
+		RunningQuery: RunningQuery{
+			ID:        id,
+			User:      user,
+			Query:     query,
+			StartedAt: started.UTC().Format(time.RFC3339),
+		},
+		started: started,
+		cancel:  cancel,
+	}
+	m.users[user]++
+
+	done := func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		if q, ok := m.running[id]; ok && q.started == started {
+			delete(m.running, id)
+			if m.users[user]--; m.users[user] == 0 {
+				delete(m.users, user)
+			}
+		}
+		cancel(nil)
+	}
+
+	return ctx, id, done, nil
+}
+
+// Running returns the running queries, oldest first.
+func (m *QueryMonitor) Running() []RunningQuery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	queries := make([]RunningQuery, 0, len(m.running))
+	for _, q := range m.running {
+		running := q.RunningQuery
+		running.Elapsed = time.Since(q.started).Seconds()
+		queries = append(queries, running)
+	}
+
+	slices.SortFunc(queries, func(a, b RunningQuery) int {
+		return cmp.Or(cmp.Compare(b.Elapsed, a.Elapsed), cmp.Compare(a.ID, b.ID))
+	})
+	return queries
+}
+
+// Kill cancels the running query with the given ID. The query returns with
+// ErrQueryKilled as the cause of its cancelled context.
+func (m *QueryMonitor) Kill(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	q, ok := m.running[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrQueryNotFound, id)
+	}
+
+	q.cancel(ErrQueryKilled)
+	return nil
+}
+
+// RunningHTTPHandler returns an http.HandlerFunc that writes the running
+// queries as a w2grid response. Admins see the queries of every user and
+// other users their own, with secrets redacted.
+func (m *QueryMonitor) RunningHTTPHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		queries := slices.DeleteFunc(m.Running(), func(q RunningQuery) bool { return !m.owns(r.Context(), q.User) })
+		for i := range queries {
+			queries[i].Query = m.opts.Redact.Query(queries[i].Query)
+		}
+
+		res := w2.NewGetGridResponse(queries, len(queries))
+		res.Write(w)
+	}
+}
+
+// KillHTTPHandler returns an http.HandlerFunc that kills the queries of a
+// w2grid remove request, whose recids are query IDs. Admins kill the queries
+// of every user and other users their own. It returns 404 Not Found when none
+// of the queries is running anymore, and 403 Forbidden without killing any
+// query when one of them belongs to another user.
+func (m *QueryMonitor) KillHTTPHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			RecID []string `json:"recid"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusBadRequest)
+			return
+		}
+
+		killed, err := m.killOwned(r.Context(), req.RecID)
+		if errors.Is(err, ErrAccessDenied) {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusForbidden)
+			return
+		}
+
+		if len(req.RecID) > 0 && killed == 0 {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusNotFound)
+			return
+		}
+
+		res := w2.NewSuccessResponse()
+		res.Write(w, http.StatusOK)
+	}
+}
+
+// killOwned cancels the running queries with the given IDs and returns how
+// many were running. The ownership check and the cancellation happen under one
+// lock, so no query is killed when one of them belongs to another user.
+func (m *QueryMonitor) killOwned(ctx context.Context, ids []string) (int, error) {
+	user := UserFromContext(ctx)
+	admin := m.opts.Admin != nil && m.opts.Admin(ctx)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range ids {
+		if q, ok := m.running[id]; ok && q.User != user && !admin {
+			return 0, fmt.Errorf("%w: query %s belongs to another user", ErrAccessDenied, id)
+		}
+	}
+
+	var killed int
+	var errs []error
+	for _, id := range ids {
+		q, ok := m.running[id]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%w: %s", ErrQueryNotFound, id))
+			continue
+		}
+		q.cancel(ErrQueryKilled)
+		killed++
+	}
+
+	return killed, errors.Join(errs...)
+}
+
+// owns reports whether the user of ctx may see and kill the queries of user.
+func (m *QueryMonitor) owns(ctx context.Context, user string) bool {
+	return UserFromContext(ctx) == user || (m.opts.Admin != nil && m.opts.Admin(ctx))
+}
+
+// begin applies opts.Timeout to ctx and registers the query with
+// opts.Monitor. It returns the query ID and a function that must be called
+// when the query finishes.
+func (opts SQLExecOptions) begin(ctx context.Context, id, query string) (context.Context, string, func(), error) {
+	cancelTimeout := func() {}
+	if opts.Timeout > 0 {
+		ctx, cancelTimeout = context.WithTimeoutCause(ctx, opts.Timeout, fmt.Errorf("%w after %s", ErrQueryTimeout, opts.Timeout))
+	}
+
+	if opts.Monitor == nil {
+		if id == "" {
+			id = newQueryID()
+		}
+		return ctx, id, cancelTimeout, nil
+	}
+
+	ctx, id, done, err := opts.Monitor.Start(ctx, id, query)
+	if err != nil {
+		cancelTimeout()
+		return ctx, id, func() {}, err
+	}
+
+	return ctx, id, func() { done(); cancelTimeout() }, nil
+}
+
+// interruption returns the timeout or kill error of a query whose context
+// was cancelled by begin, and nil otherwise.
+func interruption(ctx context.Context) error {
+	if cause := context.Cause(ctx); errors.Is(cause, ErrQueryTimeout) || errors.Is(cause, ErrQueryKilled) {
+		return cause
+	}
+	return nil
+}
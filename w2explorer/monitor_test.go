@@ -0,0 +1,204 @@
+package w2explorer_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dv1x3r/w2go/w2explorer"
+)
+
+// endlessQuery runs until its context is cancelled.
+const endlessQuery = "WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n) SELECT count(*) FROM n"
+
+func TestQueryMonitor(t *testing.T) {
+	ctx := context.Background()
+	alice := w2explorer.WithUser(ctx, "alice")
+	bob := w2explorer.WithUser(ctx, "bob")
+
+	t.Run("Limits", func(t *testing.T) {
+		monitor := w2explorer.NewQueryMonitor(w2explorer.QueryMonitorOptions{MaxConcurrent: 3, MaxConcurrentPerUser: 2})
+
+		_, _, doneA1, err := monitor.Start(alice, "a1", "SELECT 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, _, err := monitor.Start(alice, "a1", "SELECT 1"); !errors.Is(err, w2explorer.ErrQueryIDInUse) {
+			t.Errorf("❌ Expected ErrQueryIDInUse, got: %v", err)
+		}
+
+		_, _, doneA2, err := monitor.Start(alice, "a2", "SELECT 2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, _, err := monitor.Start(alice, "a3", "SELECT 3"); !errors.Is(err, w2explorer.ErrTooManyQueries) {
+			t.Errorf("❌ Expected ErrTooManyQueries per user, got: %v", err)
+		}
+
+		_, _, doneB1, err := monitor.Start(bob, "b1", "SELECT 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, _, err := monitor.Start(bob, "b2", "SELECT 2"); !errors.Is(err, w2explorer.ErrTooManyQueries) {
+			t.Errorf("❌ Expected ErrTooManyQueries in total, got: %v", err)
+		}
+
+		if running := monitor.Running(); len(running) != 3 {
+			t.Errorf("❌ Expected 3 running queries, got: %v", running)
+		}
+
+		doneA1()
+		doneA1()
+		if _, id, done, err := monitor.Start(alice, "", "SELECT 3"); err != nil || id == "" {
+			t.Errorf("❌ Expected a generated query ID, got: %q %v", id, err)
+		} else {
+			done()
+		}
+
+		doneA2()
+		doneB1()
+		if running := monitor.Running(); len(running) != 0 {
+			t.Errorf("❌ Expected no running queries, got: %v", running)
+		}
+	})
+
+	t.Run("Kill", func(t *testing.T) {
+		monitor := w2explorer.NewQueryMonitor(w2explorer.QueryMonitorOptions{})
+
+		queryCtx, id, done, err := monitor.Start(alice, "", "SELECT 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer done()
+
+		if err := monitor.Kill(id); err != nil {
+			t.Fatal(err)
+		}
+		if cause := context.Cause(queryCtx); !errors.Is(cause, w2explorer.ErrQueryKilled) {
+			t.Errorf("❌ Expected ErrQueryKilled, got: %v", cause)
+		}
+
+		if err := monitor.Kill("missing"); !errors.Is(err, w2explorer.ErrQueryNotFound) {
+			t.Errorf("❌ Expected ErrQueryNotFound, got: %v", err)
+		}
+	})
+
+	t.Run("KillExec", func(t *testing.T) {
+		db := openTestDB(t)
+		monitor := w2explorer.NewQueryMonitor(w2explorer.QueryMonitorOptions{})
+		opts := w2explorer.SQLExecOptions{Monitor: monitor}
+
+		go func() {
+			for monitor.Kill("endless") != nil {
+				time.Sleep(time.Millisecond)
+			}
+		}()
+
+		res, err := w2explorer.SQLExec(ctx, db, w2explorer.SQLExecRequest{Query: endlessQuery, QueryID: "endless"}, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.QueryID != "endless" || len(res.Results) != 1 || res.Message != w2explorer.ErrQueryKilled.Error() {
+			t.Errorf("❌ Expected the query to be killed, got: %+v", res)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		db := openTestDB(t)
+		opts := w2explorer.SQLExecOptions{Timeout: 20 * time.Millisecond}
+
+		res, err := w2explorer.SQLExec(ctx, db, w2explorer.SQLExecRequest{Query: endlessQuery}, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.HasPrefix(res.Message, w2explorer.ErrQueryTimeout.Error()) {
+			t.Errorf("❌ Expected the query to time out, got: %+v", res)
+		}
+	})
+
+	t.Run("HTTPHandlers", func(t *testing.T) {
+		monitor := w2explorer.NewQueryMonitor(w2explorer.QueryMonitorOptions{
+			Admin: func(ctx context.Context) bool { return w2explorer.UserFromContext(ctx) == "admin" },
+		})
+
+		_, _, doneA, _ := monitor.Start(alice, "a1", "SELECT * FROM users WHERE password = 'hunter2'")
+		defer doneA()
+		_, _, doneB, _ := monitor.Start(bob, "b1", "SELECT 1")
+		defer doneB()
+
+		running := func(user string) []w2explorer.RunningQuery {
+			t.Helper()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/sql/running", nil)
+			monitor.RunningHTTPHandler()(w, r.WithContext(w2explorer.WithUser(r.Context(), user)))
+
+			var res struct {
+				Records []w2explorer.RunningQuery `json:"records"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+				t.Fatal(err)
+			}
+			return res.Records
+		}
+
+		kill := func(user string, ids ...string) int {
+			body, _ := json.Marshal(map[string][]string{"recid": ids})
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/sql/running/kill", bytes.NewReader(body))
+			monitor.KillHTTPHandler()(w, r.WithContext(w2explorer.WithUser(r.Context(), user)))
+			return w.Code
+		}
+
+		if queries := running("alice"); len(queries) != 1 || queries[0].Query != "SELECT * FROM users WHERE password = '***'" {
+			t.Errorf("❌ Expected the redacted query of alice, got: %+v", queries)
+		}
+
+		if queries := running("admin"); len(queries) != 2 {
+			t.Errorf("❌ Expected every query for the admin, got: %+v", queries)
+		}
+
+		if code := kill("alice", "b1"); code != http.StatusForbidden {
+			t.Errorf("❌ Expected status 403, got: %d", code)
+		}
+
+		ctxA2, _, doneA2, _ := monitor.Start(alice, "a2", "SELECT 2")
+		defer doneA2()
+
+		if code := kill("alice", "a2", "b1"); code != http.StatusForbidden {
+			t.Errorf("❌ Expected status 403, got: %d", code)
+		}
+
+		if cause := context.Cause(ctxA2); cause != nil {
+			t.Errorf("❌ Expected a2 to keep running after 403, got: %v", cause)
+		}
+
+		if code := kill("alice", "a2", "missing"); code != http.StatusOK {
+			t.Errorf("❌ Expected status 200, got: %d", code)
+		}
+
+		if cause := context.Cause(ctxA2); !errors.Is(cause, w2explorer.ErrQueryKilled) {
+			t.Errorf("❌ Expected ErrQueryKilled, got: %v", cause)
+		}
+
+		if code := kill("bob", "b1"); code != http.StatusOK {
+			t.Errorf("❌ Expected status 200, got: %d", code)
+		}
+
+		if code := kill("admin", "a1"); code != http.StatusOK {
+			t.Errorf("❌ Expected status 200, got: %d", code)
+		}
+
+		if code := kill("alice", "missing"); code != http.StatusNotFound {
+			t.Errorf("❌ Expected status 404, got: %d", code)
+		}
+	})
+}
@@ -0,0 +1,291 @@
+package w2explorer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dv1x3r/w2go/w2"
+)
+
+// SQLParamType is the type a SQL explorer parameter value is bound as.
+type SQLParamType string
+
+// SQL explorer parameter types. An empty type binds the JSON value as-is,
+// with whole numbers as integers.
+const (
+	ParamText     SQLParamType = "text"
+	ParamInteger  SQLParamType = "integer"
+	ParamReal     SQLParamType = "real"
+	ParamBoolean  SQLParamType = "boolean"
+	ParamDate     SQLParamType = "date"
+	ParamDatetime SQLParamType = "datetime"
+	ParamNull     SQLParamType = "null"
+)
+
+// SQLParam is a typed value for one query parameter.
+type SQLParam struct {
+	// Name is the parameter as written in the query, such as ":from",
+	// "@id", "$name", or "?1". An anonymous "?" is named by its position,
+	// so the second "?" of a statement is "?2".
+	Name string `json:"name"`
+
+	// Type converts Value before it is bound.
+	Type SQLParamType `json:"type"`
+
+	// Value is a JSON string, number, boolean, or null.
+	Value any `json:"value"`
+}
+
+// ErrInvalidParam is returned when a parameter value does not match its type.
+var ErrInvalidParam = errors.New("invalid parameter")
+
+// ErrMissingParam is returned when a statement uses a parameter without a value.
+var ErrMissingParam = errors.New("missing parameter")
+
+// QueryParams returns the parameters of every statement of query in order of
+// first appearance, named like SQLParam.Name.
+//
+// Parameters are detected the way SQLite numbers them: "?NNN" has index NNN,
+// a named parameter takes the next index unless it appeared before in the
+// same statement, and an anonymous "?" takes the next index.
+func QueryParams(query string) []string {
+	names := []string{}
+	for _, stmt := range splitStatements(query) {
+		for _, name := range statementParams(stmt.Text) {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// statementParams returns the parameter names of one statement in order of
+// appearance, with repeated names included.
+func statementParams(query string) []string {
+	var names []string
+	maxIndex := 0
+	seen := map[string]bool{}
+
+	for _, t := range tokenize(query) {
+		if t.Kind != tokenParam {
+			continue
+		}
+
+		name := t.Text
+		switch {
+		case name == "?":
+			maxIndex++
+			name = "?" + strconv.Itoa(maxIndex)
+		case name[0] == '?':
+			if index, err := strconv.Atoi(name[1:]); err == nil {
+				maxIndex = max(maxIndex, index)
+				name = "?" + strconv.Itoa(index)
+			}
+		case !seen[name]:
+			maxIndex++
+		}
+
+		seen[name] = true
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// bindParams replaces the parameters of query with the placeholders returned
+// by bind, called with the parameter values in order of appearance. The
+// other query text is passed through escape when it is non-nil.
+//
+// Values are always bound, never written into the query text, and the
+// placeholders keep the query independent of how a driver matches names.
+func bindParams(query string, params map[string]any, escape func(string) string, bind func(value any) string) (string, error) {
+	if escape == nil {
+		escape = func(text string) string { return text }
+	}
+
+	names := statementParams(query)
+	if len(names) == 0 {
+		return escape(query), nil
+	}
+
+	var sb strings.Builder
+	i := 0
+	for _, t := range tokenize(query) {
+		if t.Kind != tokenParam {
+			sb.WriteString(escape(t.Text))
+			continue
+		}
+
+		value, ok := params[names[i]]
+		if !ok {
+			return "", fmt.Errorf("%w: %s", ErrMissingParam, names[i])
+		}
+
+		sb.WriteString(bind(value))
+		i++
+	}
+
+	return sb.String(), nil
+}
+
+// bindArgs replaces the parameters of query with anonymous placeholders and
+// returns the values to pass as query arguments.
+func bindArgs(query string, params map[string]any) (string, []any, error) {
+	var args []any
+	bound, err := bindParams(query, params, nil, func(value any) string {
+		args = append(args, value)
+		return "?"
+	})
+	return bound, args, err
+}
+
+// sqlParamValues converts params to the values bound by name.
+func sqlParamValues(params []SQLParam) (map[string]any, error) {
+	values := make(map[string]any, len(params))
+	for _, param := range params {
+		value, err := param.bindValue()
+		if err != nil {
+			return nil, err
+		}
+		values[param.Name] = value
+	}
+	return values, nil
+}
+
+var paramDatetimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// bindValue converts the parameter value to its type. Dates and datetimes
+// are bound as text in the SQLite date function formats, and datetimes with
+// a time zone are converted to UTC.
+func (p SQLParam) bindValue() (any, error) {
+	invalid := func() (any, error) {
+		return nil, fmt.Errorf("%w: %s is not a valid %s: %v", ErrInvalidParam, p.Name, p.Type, p.Value)
+	}
+
+	if p.Value == nil || p.Type == ParamNull {
+		return nil, nil
+	}
+
+	text, isText := p.Value.(string)
+
+	switch p.Type {
+	case "":
+		if number, ok := p.Value.(float64); ok && number == math.Trunc(number) && math.Abs(number) <= maxSafeInteger {
+			return int64(number), nil
+		}
+		switch p.Value.(type) {
+		case string, float64, bool:
+			return p.Value, nil
+		}
+		return invalid()
+
+	case ParamText:
+		if isText {
+			return text, nil
+		}
+		return fmt.Sprint(p.Value), nil
+
+	case ParamInteger:
+		if number, ok := p.Value.(float64); ok && number == math.Trunc(number) && math.Abs(number) <= maxSafeInteger {
+			return int64(number), nil
+		}
+		if number, err := strconv.ParseInt(strings.TrimSpace(text), 10, 64); isText && err == nil {
+			return number, nil
+		}
+		return invalid()
+
+	case ParamReal:
+		if number, ok := p.Value.(float64); ok {
+			return number, nil
+		}
+		if number, err := strconv.ParseFloat(strings.TrimSpace(text), 64); isText && err == nil {
+			return number, nil
+		}
+		return invalid()
+
+	case ParamBoolean:
+		if b, ok := p.Value.(bool); ok {
+			return b, nil
+		}
+		if number, ok := p.Value.(float64); ok && (number == 0 || number == 1) {
+			return number == 1, nil
+		}
+		if b, err := strconv.ParseBool(strings.TrimSpace(text)); isText && err == nil {
+			return b, nil
+		}
+		return invalid()
+
+	case ParamDate:
+		if date, err := time.Parse(time.DateOnly, strings.TrimSpace(text)); isText && err == nil {
+			return date.Format(time.DateOnly), nil
+		}
+		return invalid()
+
+	case ParamDatetime:
+		if !isText {
+			return invalid()
+		}
+		for _, layout := range paramDatetimeLayouts {
+			if datetime, err := time.Parse(layout, strings.TrimSpace(text)); err == nil {
+				return datetime.UTC().Format(time.DateTime), nil
+			}
+		}
+		return invalid()
+	}
+
+	return nil, fmt.Errorf("%w: %s has unknown type %q", ErrInvalidParam, p.Name, p.Type)
+}
+
+// validateParams checks that every parameter has a name and a value that
+// matches its type.
+func validateParams(params []SQLParam) error {
+	for _, param := range params {
+		if strings.TrimSpace(param.Name) == "" {
+			return fmt.Errorf("%w: parameter name is required", ErrInvalidParam)
+		}
+	}
+	_, err := sqlParamValues(params)
+	return err
+}
+
+// SQLParamsRequest is the JSON request body for parameter detection.
+type SQLParamsRequest struct {
+	Query string `json:"query"`
+}
+
+// SQLParamsResponse lists the parameters of a query, see QueryParams.
+type SQLParamsResponse struct {
+	Status w2.Status `json:"status"`
+	Params []string  `json:"params"`
+}
+
+// SQLParamsHTTPHandler returns an http.HandlerFunc that reports the
+// parameters of the query in the request body, so the widget can prompt for
+// their values before it runs the query. The query is only parsed.
+func SQLParamsHTTPHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req SQLParamsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, SQLParamsResponse{Status: w2.StatusSuccess, Params: QueryParams(req.Query)})
+	}
+}
@@ -0,0 +1,144 @@
+package w2explorer_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dv1x3r/w2go/w2"
+	"github.com/dv1x3r/w2go/w2explorer"
+)
+
+func TestQueryParams(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Expected []string
+	}{
+		{Input: "SELECT 1", Expected: []string{}},
+		{Input: "SELECT * FROM todo WHERE id = ? OR id = ?", Expected: []string{"?1", "?2"}},
+		{Input: "SELECT :a, @b, $c, :a", Expected: []string{":a", "@b", "$c"}},
+		{Input: "SELECT ?2, ?, :a, ?", Expected: []string{"?2", "?3", ":a", "?5"}},
+		{Input: "SELECT ?; SELECT ?, :x", Expected: []string{"?1", ":x"}},
+		{Input: "SELECT ':a', \"@b\" -- :c\n/* $d */", Expected: []string{}},
+	}
+
+	for _, test := range tests {
+		if output := w2explorer.QueryParams(test.Input); strings.Join(output, ",") != strings.Join(test.Expected, ",") {
+			t.Errorf("❌ Expected %v for %q, got: %v", test.Expected, test.Input, output)
+		}
+	}
+}
+
+func TestSQLExecParams(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	t.Run("Types", func(t *testing.T) {
+		tests := []struct {
+			Param        w2explorer.SQLParam
+			ExpectedType string
+			Expected     any
+		}{
+			{Param: w2explorer.SQLParam{Value: float64(42)}, ExpectedType: "integer", Expected: int64(42)},
+			{Param: w2explorer.SQLParam{Value: 1.5}, ExpectedType: "real", Expected: 1.5},
+			{Param: w2explorer.SQLParam{Value: "x"}, ExpectedType: "text", Expected: "x"},
+			{Param: w2explorer.SQLParam{Value: nil}, ExpectedType: "null", Expected: nil},
+			{Param: w2explorer.SQLParam{Type: w2explorer.ParamText, Value: float64(7)}, ExpectedType: "text", Expected: "7"},
+			{Param: w2explorer.SQLParam{Type: w2explorer.ParamInteger, Value: " 12 "}, ExpectedType: "integer", Expected: int64(12)},
+			{Param: w2explorer.SQLParam{Type: w2explorer.ParamReal, Value: "2.5"}, ExpectedType: "real", Expected: 2.5},
+			{Param: w2explorer.SQLParam{Type: w2explorer.ParamBoolean, Value: "true"}, ExpectedType: "integer", Expected: int64(1)},
+			{Param: w2explorer.SQLParam{Type: w2explorer.ParamDate, Value: "2024-02-29"}, ExpectedType: "text", Expected: "2024-02-29"},
+			{Param: w2explorer.SQLParam{Type: w2explorer.ParamDatetime, Value: "2024-02-29T10:30:00+02:00"}, ExpectedType: "text", Expected: "2024-02-29 08:30:00"},
+			{Param: w2explorer.SQLParam{Type: w2explorer.ParamNull, Value: "ignored"}, ExpectedType: "null", Expected: nil},
+		}
+
+		for _, test := range tests {
+			test.Param.Name = ":v"
+			req := w2explorer.SQLExecRequest{Query: "SELECT typeof(:v) AS type, :v AS value", Params: []w2explorer.SQLParam{test.Param}}
+
+			res, err := w2explorer.SQLExec(ctx, db, req, w2explorer.SQLExecOptions{})
+			if err != nil || res.Status != w2.StatusSuccess {
+				t.Errorf("❌ Unexpected response for %+v: %+v %v", test.Param, res, err)
+				continue
+			}
+
+			record := res.Results[0].Records[0]
+			if record["type"] != test.ExpectedType || record["value"] != test.Expected {
+				t.Errorf("❌ Expected %s %v for %+v, got: %v %v", test.ExpectedType, test.Expected, test.Param, record["type"], record["value"])
+			}
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		tests := []w2explorer.SQLParam{
+			{Name: ":v", Type: w2explorer.ParamInteger, Value: "1.5"},
+			{Name: ":v", Type: w2explorer.ParamReal, Value: "abc"},
+			{Name: ":v", Type: w2explorer.ParamBoolean, Value: float64(2)},
+			{Name: ":v", Type: w2explorer.ParamDate, Value: "2023-02-29"},
+			{Name: ":v", Type: w2explorer.ParamDatetime, Value: float64(1)},
+			{Name: ":v", Type: "uuid", Value: "x"},
+			{Name: ":v", Value: []any{1}},
+		}
+
+		for _, param := range tests {
+			req := w2explorer.SQLExecRequest{Query: "SELECT :v", Params: []w2explorer.SQLParam{param}}
+			if _, err := w2explorer.SQLExec(ctx, db, req, w2explorer.SQLExecOptions{}); !errors.Is(err, w2explorer.ErrInvalidParam) {
+				t.Errorf("❌ Expected ErrInvalidParam for %+v, got: %v", param, err)
+			}
+		}
+	})
+
+	t.Run("Statements", func(t *testing.T) {
+		req := w2explorer.SQLExecRequest{
+			Query:  "SELECT ? AS a, :name AS b; SELECT ? AS c",
+			Params: []w2explorer.SQLParam{{Name: "?1", Value: "first"}, {Name: ":name", Value: "named"}},
+		}
+
+		res, err := w2explorer.SQLExec(ctx, db, req, w2explorer.SQLExecOptions{})
+		if err != nil || len(res.Results) != 2 {
+			t.Fatalf("❌ Unexpected response: %+v %v", res, err)
+		}
+
+		if record := res.Results[0].Records[0]; record["a"] != "first" || record["b"] != "named" {
+			t.Errorf("❌ Expected the bound values, got: %v", record)
+		}
+
+		// the second statement numbers its own "?" from 1 again
+		if record := res.Results[1].Records[0]; record["c"] != "first" {
+			t.Errorf("❌ Expected the first value in the second statement, got: %v", record)
+		}
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		req := w2explorer.SQLExecRequest{Query: "SELECT :a, :b", Params: []w2explorer.SQLParam{{Name: ":a", Value: "x"}}}
+
+		res, err := w2explorer.SQLExec(ctx, db, req, w2explorer.SQLExecOptions{})
+		if err != nil || res.Status != w2.StatusError || !strings.Contains(res.Message, w2explorer.ErrMissingParam.Error()) {
+			t.Errorf("❌ Expected a missing :b, got: %+v %v", res, err)
+		}
+	})
+
+	t.Run("SavedValues", func(t *testing.T) {
+		store := w2explorer.NewQueryStore(db, w2explorer.QueryStoreOptions{})
+		if err := store.Init(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		params := []w2explorer.SQLParam{{Name: ":from", Type: w2explorer.ParamDate, Value: "2024-01-01"}}
+		id, err := store.SaveQuery(ctx, w2explorer.SavedQuery{Name: "since", Query: "SELECT :from", Params: params})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		saved, err := store.SavedQuery(ctx, id)
+		if err != nil || len(saved.Params) != 1 || saved.Params[0] != params[0] {
+			t.Errorf("❌ Expected the saved values, got: %+v %v", saved.Params, err)
+		}
+
+		invalid := []w2explorer.SQLParam{{Name: ":from", Type: w2explorer.ParamDate, Value: "yesterday"}}
+		if _, err := store.SaveQuery(ctx, w2explorer.SavedQuery{Name: "since", Query: "SELECT :from", Params: invalid}); !errors.Is(err, w2explorer.ErrInvalidParam) {
+			t.Errorf("❌ Expected ErrInvalidParam, got: %v", err)
+		}
+	})
+}
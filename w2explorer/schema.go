@@ -0,0 +1,507 @@
+package w2explorer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dv1x3r/w2go/w2"
+	"github.com/dv1x3r/w2go/w2db"
+)
+
+// SchemaProvider reads database metadata for the SQL explorer sidebar.
+//
+// SQLiteSchemaProvider is the built-in implementation. Other databases can
+// be supported by implementing this interface.
+type SchemaProvider interface {
+	Schema(ctx context.Context, opts SchemaOptions) (Schema, error)
+}
+
+// SchemaOptions configures SchemaProvider.Schema.
+type SchemaOptions struct {
+	// RowCounts counts the rows of every table. It scans each table, so it is
+	// only done on demand.
+	RowCounts bool
+
+	// RowCountLimit stops counting at this many rows when it is positive, so
+	// that large tables are not scanned in full.
+	RowCountLimit int64
+}
+
+// Schema describes every attached database.
+type Schema struct {
+	Databases []SchemaDatabase `json:"databases"`
+}
+
+// SchemaDatabase describes one attached database, such as main or temp.
+type SchemaDatabase struct {
+	Name string `json:"name"`
+
+	// Tables, Views, Virtual, and Shadow contain the objects of each type,
+	// sorted by name. Internal sqlite_ tables are omitted.
+	Tables  []SchemaTable `json:"tables"`
+	Views   []SchemaTable `json:"views"`
+	Virtual []SchemaTable `json:"virtual"`
+	Shadow  []SchemaTable `json:"shadow"`
+}
+
+// SchemaTable describes a table or view.
+type SchemaTable struct {
+	Name string `json:"name"`
+
+	// Type is "table", "view", "virtual", or "shadow".
+	Type string `json:"type"`
+
+	// SQL is the original CREATE statement. It is empty for objects without
+	// one, such as shadow tables of some virtual table modules.
+	SQL string `json:"sql"`
+
+	WithoutRowID bool `json:"withoutRowid"`
+	Strict       bool `json:"strict"`
+
+	Columns     []SchemaColumn     `json:"columns"`
+	Indexes     []SchemaIndex      `json:"indexes"`
+	ForeignKeys []SchemaForeignKey `json:"foreignKeys"`
+	Triggers    []SchemaTrigger    `json:"triggers"`
+
+	// RowCount is set when SchemaOptions.RowCounts is true.
+	RowCount *int64 `json:"rowCount,omitempty"`
+}
+
+// SchemaColumn describes a table or view column.
+type SchemaColumn struct {
+	Name    string  `json:"name"`
+	Type    string  `json:"type"`
+	NotNull bool    `json:"notnull"`
+	Default *string `json:"default"`
+
+	// PK is the 1-based position of the column in the primary key, or 0.
+	PK int `json:"pk"`
+}
+
+// SchemaIndex describes an index of a table.
+type SchemaIndex struct {
+	Name   string `json:"name"`
+	Unique bool   `json:"unique"`
+
+	// Origin is "c" for CREATE INDEX, "u" for a UNIQUE constraint, and "pk"
+	// for a PRIMARY KEY constraint.
+	Origin string `json:"origin"`
+
+	// Columns lists the indexed columns in order. Expressions are shown as "<expr>".
+	Columns []string `json:"columns"`
+
+	// Where is the predicate of a partial index.
+	Where string `json:"where,omitempty"`
+
+	// SQL is the CREATE INDEX statement. It is empty for indexes created by
+	// UNIQUE and PRIMARY KEY constraints.
+	SQL string `json:"sql"`
+}
+
+// SchemaForeignKey describes a foreign key constraint of a table.
+type SchemaForeignKey struct {
+	// Table is the referenced parent table.
+	Table string `json:"table"`
+
+	// From and To list the child and parent columns in order. To is empty
+	// when the constraint references the parent primary key implicitly.
+	From []string `json:"from"`
+	To   []string `json:"to"`
+
+	OnUpdate string `json:"onUpdate"`
+	OnDelete string `json:"onDelete"`
+}
+
+// SchemaTrigger describes a trigger on a table or view.
+type SchemaTrigger struct {
+	Name string `json:"name"`
+	SQL  string `json:"sql"`
+}
+
+// SQLiteSchemaProvider reads the schema of a SQLite database with
+// pragma table-valued functions and sqlite_schema.
+type SQLiteSchemaProvider struct {
+	DB *sql.DB
+}
+
+// Schema implements SchemaProvider.
+func (p SQLiteSchemaProvider) Schema(ctx context.Context, opts SchemaOptions) (Schema, error) {
+	conn, err := p.DB.Conn(ctx)
+	if err != nil {
+		return Schema{}, err
+	}
+	defer conn.Close()
+
+	names, err := queryStrings(ctx, conn, "SELECT name FROM pragma_database_list ORDER BY seq")
+	if err != nil {
+		return Schema{}, err
+	}
+
+	schema := Schema{Databases: []SchemaDatabase{}}
+	for _, name := range names {
+		database, err := sqliteDatabase(ctx, conn, name, opts)
+		if err != nil {
+			return Schema{}, fmt.Errorf("%s: %w", name, err)
+		}
+		schema.Databases = append(schema.Databases, database)
+	}
+
+	return schema, nil
+}
+
+type schemaObject struct {
+	Type    string
+	Name    string
+	Table   string
+	SQL     string
+	Partial string
+}
+
+func sqliteDatabase(ctx context.Context, conn *sql.Conn, name string, opts SchemaOptions) (SchemaDatabase, error) {
+	database := SchemaDatabase{
+		Name:    name,
+		Tables:  []SchemaTable{},
+		Views:   []SchemaTable{},
+		Virtual: []SchemaTable{},
+		Shadow:  []SchemaTable{},
+	}
+
+	objects, err := sqliteObjects(ctx, conn, name)
+	if err != nil {
+		return database, err
+	}
+
+	rows, err := conn.QueryContext(ctx, `
+SELECT name, type, wr, strict
+FROM pragma_table_list
+WHERE schema = ? AND name NOT LIKE 'sqlite_%'
+ORDER BY name`, name)
+	if err != nil {
+		return database, err
+	}
+
+	var tables []SchemaTable
+	for rows.Next() {
+		var table SchemaTable
+		if err := rows.Scan(&table.Name, &table.Type, &table.WithoutRowID, &table.Strict); err != nil {
+			rows.Close()
+			return database, err
+		}
+		tables = append(tables, table)
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return database, err
+	}
+
+	for _, table := range tables {
+		table.Columns = []SchemaColumn{}
+		table.Indexes = []SchemaIndex{}
+		table.ForeignKeys = []SchemaForeignKey{}
+		table.Triggers = []SchemaTrigger{}
+
+		for _, object := range objects {
+			switch {
+			case object.Name == table.Name && object.Type != "index" && object.Type != "trigger":
+				table.SQL = object.SQL
+			case object.Table == table.Name && object.Type == "trigger":
+				table.Triggers = append(table.Triggers, SchemaTrigger{Name: object.Name, SQL: object.SQL})
+			}
+		}
+
+		if table.Columns, err = sqliteColumns(ctx, conn, name, table.Name); err != nil && table.Type != "view" {
+			return database, fmt.Errorf("%s: %w", table.Name, err)
+		}
+
+		// a view that references a dropped table cannot be inspected, but should not hide the schema
+		if table.Columns == nil {
+			table.Columns = []SchemaColumn{}
+		}
+
+		if table.Type != "view" {
+			if table.Indexes, err = sqliteIndexes(ctx, conn, name, table.Name, objects); err != nil {
+				return database, fmt.Errorf("%s: %w", table.Name, err)
+			}
+
+			if table.ForeignKeys, err = sqliteForeignKeys(ctx, conn, name, table.Name); err != nil {
+				return database, fmt.Errorf("%s: %w", table.Name, err)
+			}
+		}
+
+		if opts.RowCounts && table.Type != "view" {
+			var count int64
+			query := "SELECT count(*) FROM " + quoteIdent(name) + "." + quoteIdent(table.Name)
+			if opts.RowCountLimit > 0 {
+				query = "SELECT count(*) FROM (SELECT 1 FROM " + quoteIdent(name) + "." + quoteIdent(table.Name) +
+					" LIMIT " + strconv.FormatInt(opts.RowCountLimit, 10) + ")"
+			}
+			if err := conn.QueryRowContext(ctx, query).Scan(&count); err != nil {
+				return database, fmt.Errorf("%s: %w", table.Name, err)
+			}
+			table.RowCount = &count
+		}
+
+		switch table.Type {
+		case "view":
+			database.Views = append(database.Views, table)
+		case "virtual":
+			database.Virtual = append(database.Virtual, table)
+		case "shadow":
+			database.Shadow = append(database.Shadow, table)
+		default:
+			database.Tables = append(database.Tables, table)
+		}
+	}
+
+	return database, nil
+}
+
+// sqliteObjects reads sqlite_schema of database and extracts the WHERE
+// predicate of partial indexes.
+func sqliteObjects(ctx context.Context, conn *sql.Conn, database string) ([]schemaObject, error) {
+	query := "SELECT type, name, tbl_name, coalesce(sql, '') FROM " + quoteIdent(database) + ".sqlite_schema"
+
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var objects []schemaObject
+	for rows.Next() {
+		var object schemaObject
+		if err := rows.Scan(&object.Type, &object.Name, &object.Table, &object.SQL); err != nil {
+			return nil, err
+		}
+
+		if object.Type == "index" {
+			object.Partial = indexPredicate(object.SQL)
+		}
+
+		objects = append(objects, object)
+	}
+
+	return objects, rows.Err()
+}
+
+// indexPredicate returns the WHERE clause text of a CREATE INDEX statement.
+func indexPredicate(ddl string) string {
+	statements := splitStatements(ddl)
+	if len(statements) == 0 {
+		return ""
+	}
+
+	stmt := statements[0]
+	depth := 0
+	for _, t := range stmt.Tokens {
+		switch {
+		case t.Text == "(":
+			depth++
+		case t.Text == ")":
+			depth--
+		case depth == 0 && t.is("WHERE"):
+			return strings.TrimSpace(stmt.Text[t.Pos-stmt.Pos+len(t.Text):])
+		}
+	}
+
+	return ""
+}
+
+func sqliteColumns(ctx context.Context, conn w2db.QueryExecer, database, table string) ([]SchemaColumn, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT name, type, "notnull", dflt_value, pk FROM pragma_table_info(?, ?) ORDER BY cid`, table, database)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := []SchemaColumn{}
+	for rows.Next() {
+		var column SchemaColumn
+		var def sql.NullString
+		if err := rows.Scan(&column.Name, &column.Type, &column.NotNull, &def, &column.PK); err != nil {
+			return nil, err
+		}
+
+		if def.Valid {
+			column.Default = &def.String
+		}
+
+		columns = append(columns, column)
+	}
+
+	return columns, rows.Err()
+}
+
+func sqliteIndexes(ctx context.Context, conn *sql.Conn, database, table string, objects []schemaObject) ([]SchemaIndex, error) {
+	rows, err := conn.QueryContext(ctx, `
+SELECT il.name, il."unique", il.origin, ii.name
+FROM pragma_index_list(?1, ?2) il
+JOIN pragma_index_info(il.name, ?2) ii
+ORDER BY il.name, ii.seqno`, table, database)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	indexes := []SchemaIndex{}
+	for rows.Next() {
+		var index SchemaIndex
+		var column sql.NullString
+		if err := rows.Scan(&index.Name, &index.Unique, &index.Origin, &column); err != nil {
+			return nil, err
+		}
+
+		if !column.Valid {
+			column.String = "<expr>"
+		}
+
+		if n := len(indexes); n > 0 && indexes[n-1].Name == index.Name {
+			indexes[n-1].Columns = append(indexes[n-1].Columns, column.String)
+			continue
+		}
+
+		index.Columns = []string{column.String}
+		for _, object := range objects {
+			if object.Type == "index" && object.Name == index.Name {
+				index.SQL = object.SQL
+				index.Where = object.Partial
+			}
+		}
+
+		indexes = append(indexes, index)
+	}
+
+	return indexes, rows.Err()
+}
+
+func sqliteForeignKeys(ctx context.Context, conn *sql.Conn, database, table string) ([]SchemaForeignKey, error) {
+	rows, err := conn.QueryContext(ctx, `
+SELECT id, "table", "from", "to", on_update, on_delete
+FROM pragma_foreign_key_list(?, ?)
+ORDER BY id, seq`, table, database)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []SchemaForeignKey{}
+	lastID := -1
+	for rows.Next() {
+		var id int
+		var key SchemaForeignKey
+		var from string
+		var to sql.NullString
+		if err := rows.Scan(&id, &key.Table, &from, &to, &key.OnUpdate, &key.OnDelete); err != nil {
+			return nil, err
+		}
+
+		if id != lastID {
+			key.From = []string{}
+			key.To = []string{}
+			keys = append(keys, key)
+			lastID = id
+		}
+
+		last := &keys[len(keys)-1]
+		last.From = append(last.From, from)
+		if to.Valid {
+			last.To = append(last.To, to.String)
+		}
+	}
+
+	return keys, rows.Err()
+}
+
+func queryStrings(ctx context.Context, conn w2db.QueryExecer, query string, args ...any) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	return values, rows.Err()
+}
+
+// SchemaHandler returns a schema handler that reports errors to the caller
+// instead of writing error responses itself.
+//
+// The "rowCounts" query parameter enables SchemaOptions.RowCounts.
+func SchemaHandler(provider SchemaProvider) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		rowCounts, _ := strconv.ParseBool(r.URL.Query().Get("rowCounts"))
+
+		res, err := provider.Schema(r.Context(), SchemaOptions{RowCounts: rowCounts})
+		if err != nil {
+			return err
+		}
+
+		return writeJSON(w, res)
+	}
+}
+
+// SchemaHTTPHandler returns an http.HandlerFunc that writes the schema JSON
+// used by the SQL explorer sidebar.
+//
+// The "rowCounts" query parameter enables SchemaOptions.RowCounts.
+func SchemaHTTPHandler(provider SchemaProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rowCounts, _ := strconv.ParseBool(r.URL.Query().Get("rowCounts"))
+
+		res, err := provider.Schema(r.Context(), SchemaOptions{RowCounts: rowCounts})
+		if err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, res)
+	}
+}
+
+// SQLiteSchemaHandler returns a SQLite schema handler that reports errors to the caller instead of writing error responses itself.
+func SQLiteSchemaHandler(db *sql.DB) func(w http.ResponseWriter, r *http.Request) error {
+	return SchemaHandler(SQLiteSchemaProvider{DB: db})
+}
+
+// SQLiteSchemaHTTPHandler returns an http.HandlerFunc that writes the SQLite schema JSON used by the SQL explorer sidebar.
+func SQLiteSchemaHTTPHandler(db *sql.DB) http.HandlerFunc {
+	return SchemaHTTPHandler(SQLiteSchemaProvider{DB: db})
+}
+
+// SQLiteSelectSchema returns SQLite database, table, and column metadata as a JSON document for the SQL explorer sidebar.
+//
+// Deprecated: use SQLiteSchemaProvider, which returns a typed Schema.
+func SQLiteSelectSchema(ctx context.Context, db *sql.DB) (string, error) {
+	schema, err := SQLiteSchemaProvider{DB: db}.Schema(ctx, SchemaOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(schema)
+	return string(data), err
+}
+
+func writeJSON(w http.ResponseWriter, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,501 @@
+package w2explorer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"sync"
+
+	"github.com/dv1x3r/w2go/w2"
+)
+
+// DataSourceOptions configures a DataSource of a SourceRegistry.
+type DataSourceOptions struct {
+	// Exec holds the permissions of the source. They apply to every
+	// handler of the source and to the databases attached to it.
+	Exec SQLExecOptions
+
+	// AllowAttach lets clients attach other sources of the registry through
+	// AttachHTTPHandler and detach them through DetachHTTPHandler.
+	AllowAttach bool
+
+	// Backup configures BackupHTTPHandler and RestoreHTTPHandler. Its Exec
+	// is replaced by the Exec of the source, so restoring a read-only source
+	// is never allowed.
+	Backup BackupOptions
+}
+
+// DataSource is one named database of a SourceRegistry.
+type DataSource struct {
+	name      string
+	db        *sql.DB
+	opts      DataSourceOptions
+	tables    *TableBrowser
+	connector *attachConnector
+}
+
+// Name returns the name of the source.
+func (s *DataSource) Name() string { return s.name }
+
+// DB returns the database of the source.
+func (s *DataSource) DB() *sql.DB { return s.db }
+
+// Options returns the options of the source.
+func (s *DataSource) Options() DataSourceOptions { return s.opts }
+
+// ErrAttachUnsupported is returned when databases are attached to a source
+// that was registered with an existing *sql.DB instead of opened by the registry.
+var ErrAttachUnsupported = errors.New("data source does not manage attached databases")
+
+// Attach attaches the SQLite database file to every connection of the
+// source as alias, so queries can join its tables as alias.table. File may
+// be a URI filename such as "file:archive.db?mode=ro".
+//
+// The attachment is checked on a pooled connection and undone when it fails.
+func (s *DataSource) Attach(ctx context.Context, alias, file string) error {
+	if s.connector == nil {
+		return fmt.Errorf("%w: %s", ErrAttachUnsupported, s.name)
+	}
+
+	if alias == "" || alias == "main" || alias == "temp" {
+		return fmt.Errorf("invalid database alias %q", alias)
+	}
+
+	attached, _ := s.connector.snapshot()
+	if _, ok := attached[alias]; ok {
+		return fmt.Errorf("database %q is already attached", alias)
+	}
+
+	attached[alias] = file
+	previous := s.connector.set(attached)
+
+	if err := s.db.PingContext(ctx); err != nil {
+		s.connector.set(previous)
+		return err
+	}
+
+	return nil
+}
+
+// ErrAttachNotAllowed is returned when a source is attached to a source with
+// a weaker policy.
+var ErrAttachNotAllowed = errors.New("data source cannot be attached")
+
+// AttachSource attaches the main database file of another source as alias.
+// A read-only source is attached in SQLite read-only mode.
+//
+// Queries of the attached tables run with the options of s and are audited
+// under the name of s, so AttachSource returns ErrAttachNotAllowed unless
+// s enforces the policy of source: every statement kind that s allows must
+// be allowed by source, and source must have no Authorize hook or the same
+// hook as s, such as when both share one SQLExecOptions.
+func (s *DataSource) AttachSource(ctx context.Context, alias string, source *DataSource) error {
+	if err := s.opts.Exec.enforces(source.opts.Exec); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrAttachNotAllowed, source.name, err)
+	}
+
+	var file string
+	if err := source.db.QueryRowContext(ctx, "SELECT file FROM pragma_database_list WHERE name = 'main'").Scan(&file); err != nil {
+		return err
+	}
+
+	if file == "" {
+		return fmt.Errorf("data source %q has no database file", source.name)
+	}
+
+	if source.opts.Exec.ReadOnly {
+		file = (&url.URL{Scheme: "file", Path: file, RawQuery: "mode=ro"}).String()
+	}
+
+	return s.Attach(ctx, alias, file)
+}
+
+// enforces returns an error unless opts are at least as strict as the Allow
+// and Authorize of other.
+func (opts SQLExecOptions) enforces(other SQLExecOptions) error {
+	if len(other.Allow) > 0 {
+		if len(opts.Allow) == 0 {
+			return errors.New("it restricts the statement kinds")
+		}
+		for _, kind := range opts.Allow {
+			if !slices.Contains(other.Allow, kind) {
+				return fmt.Errorf("it does not allow %s", kind)
+			}
+		}
+	}
+
+	if other.Authorize != nil && (opts.Authorize == nil || reflect.ValueOf(opts.Authorize).Pointer() != reflect.ValueOf(other.Authorize).Pointer()) {
+		return errors.New("it has its own Authorize hook")
+	}
+
+	return nil
+}
+
+// Detach detaches the database attached as alias from every connection of
+// the source.
+func (s *DataSource) Detach(ctx context.Context, alias string) error {
+	if s.connector == nil {
+		return fmt.Errorf("%w: %s", ErrAttachUnsupported, s.name)
+	}
+
+	attached, _ := s.connector.snapshot()
+	if _, ok := attached[alias]; !ok {
+		return fmt.Errorf("database %q is not attached", alias)
+	}
+
+	delete(attached, alias)
+	s.connector.set(attached)
+	return s.db.PingContext(ctx)
+}
+
+// Attached returns the attached databases of the source by alias.
+func (s *DataSource) Attached() map[string]string {
+	if s.connector == nil {
+		return map[string]string{}
+	}
+	attached, _ := s.connector.snapshot()
+	return attached
+}
+
+// SourceRegistry serves one SQL explorer for several named databases, such
+// as separate SQLite files for application data, analytics, and archives.
+//
+// The handlers of the registry select the source from the "source" query
+// parameter and fall back to the first registered source.
+type SourceRegistry struct {
+	mu      sync.RWMutex
+	sources []*DataSource
+}
+
+// NewSourceRegistry returns an empty source registry.
+func NewSourceRegistry() *SourceRegistry {
+	return &SourceRegistry{}
+}
+
+// ErrSourceNotFound is returned when no source has the requested name.
+var ErrSourceNotFound = errors.New("data source not found")
+
+// Open opens a database with the named driver and registers it as a source.
+// Sources opened by the registry can attach other databases, which is why
+// the registry owns the connection pool. Close the registry to close it.
+func (r *SourceRegistry) Open(name, driverName, dsn string, opts DataSourceOptions) (*DataSource, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	connector, err := newAttachConnector(db.Driver(), dsn)
+	db.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	source := &DataSource{name: name, db: sql.OpenDB(connector), opts: opts, connector: connector}
+	if err := r.add(source); err != nil {
+		source.db.Close()
+		return nil, err
+	}
+
+	return source, nil
+}
+
+// Register registers an existing database as a source. Its databases cannot
+// be attached through the registry.
+func (r *SourceRegistry) Register(name string, db *sql.DB, opts DataSourceOptions) (*DataSource, error) {
+	source := &DataSource{name: name, db: db, opts: opts}
+	return source, r.add(source)
+}
+
+func (r *SourceRegistry) add(source *DataSource) error {
+	if source.name == "" {
+		return errors.New("data source name is empty")
+	}
+
+	source.tables = NewTableBrowser(source.db, TableBrowserOptions{Exec: source.opts.Exec})
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if slices.ContainsFunc(r.sources, func(s *DataSource) bool { return s.name == source.name }) {
+		return fmt.Errorf("data source %q is already registered", source.name)
+	}
+
+	r.sources = append(r.sources, source)
+	return nil
+}
+
+// Source returns the source with the given name, or the first registered
+// source when name is empty.
+func (r *SourceRegistry) Source(name string) (*DataSource, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, source := range r.sources {
+		if name == "" || source.name == name {
+			return source, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrSourceNotFound, name)
+}
+
+// Sources returns the registered sources in registration order.
+func (r *SourceRegistry) Sources() []*DataSource {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.sources)
+}
+
+// Close closes the databases opened by the registry. Databases passed to
+// Register stay open.
+func (r *SourceRegistry) Close() error {
+	var errs []error
+	for _, source := range r.Sources() {
+		if source.connector != nil {
+			errs = append(errs, source.db.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SourceInfo describes a source for the widget source selector. Attached
+// lists the aliases of the attached databases, not their file paths.
+type SourceInfo struct {
+	Name        string   `json:"name"`
+	ReadOnly    bool     `json:"readOnly"`
+	AllowAttach bool     `json:"allowAttach"`
+	Attached    []string `json:"attached"`
+}
+
+// handle returns an http.HandlerFunc that runs the handler of the source
+// named by the "source" query parameter, with the source name set by WithSource.
+func (r *SourceRegistry) handle(handler func(source *DataSource) http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		source, err := r.Source(req.URL.Query().Get("source"))
+		if err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusNotFound)
+			return
+		}
+
+		handler(source)(w, req.WithContext(WithSource(req.Context(), source.name)))
+	}
+}
+
+// SourcesHTTPHandler returns an http.HandlerFunc that writes the SourceInfo
+// JSON array of the registered sources.
+func (r *SourceRegistry) SourcesHTTPHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		infos := []SourceInfo{}
+		for _, source := range r.Sources() {
+			attached := slices.Sorted(maps.Keys(source.Attached()))
+			if attached == nil {
+				attached = []string{}
+			}
+
+			infos = append(infos, SourceInfo{
+				Name:        source.name,
+				ReadOnly:    source.opts.Exec.ReadOnly,
+				AllowAttach: source.opts.AllowAttach && source.connector != nil,
+				Attached:    attached,
+			})
+		}
+
+		writeJSON(w, infos)
+	}
+}
+
+// SchemaHTTPHandler returns SchemaHTTPHandler for the selected source.
+func (r *SourceRegistry) SchemaHTTPHandler() http.HandlerFunc {
+	return r.handle(func(source *DataSource) http.HandlerFunc {
+		return SchemaHTTPHandler(SQLiteSchemaProvider{DB: source.db})
+	})
+}
+
+// ERDiagramHTTPHandler returns ERDiagramHTTPHandler for the selected source.
+func (r *SourceRegistry) ERDiagramHTTPHandler() http.HandlerFunc {
+	return r.handle(func(source *DataSource) http.HandlerFunc {
+		return ERDiagramHTTPHandler(SQLiteSchemaProvider{DB: source.db})
+	})
+}
+
+// SQLLintHTTPHandler returns SQLLintHTTPHandler for the selected source.
+func (r *SourceRegistry) SQLLintHTTPHandler() http.HandlerFunc {
+	return r.handle(func(source *DataSource) http.HandlerFunc {
+		return SQLLintHTTPHandler(SQLiteSchemaProvider{DB: source.db}, SQLLintOptions{})
+	})
+}
+
+// SQLExecHTTPHandler returns SQLExecHTTPHandler for the selected source.
+func (r *SourceRegistry) SQLExecHTTPHandler() http.HandlerFunc {
+	return r.handle(func(source *DataSource) http.HandlerFunc {
+		return SQLExecHTTPHandler(source.db, source.opts.Exec)
+	})
+}
+
+// SQLBlobHTTPHandler returns SQLBlobHTTPHandler for the selected source.
+func (r *SourceRegistry) SQLBlobHTTPHandler() http.HandlerFunc {
+	return r.handle(func(source *DataSource) http.HandlerFunc {
+		return SQLBlobHTTPHandler(source.db, source.opts.Exec)
+	})
+}
+
+// SQLExportHTTPHandler returns SQLExportHTTPHandler for the selected source.
+func (r *SourceRegistry) SQLExportHTTPHandler() http.HandlerFunc {
+	return r.handle(func(source *DataSource) http.HandlerFunc {
+		return SQLExportHTTPHandler(source.db, source.opts.Exec)
+	})
+}
+
+// SQLExplainHTTPHandler returns SQLExplainHTTPHandler for the selected source.
+func (r *SourceRegistry) SQLExplainHTTPHandler() http.HandlerFunc {
+	return r.handle(func(source *DataSource) http.HandlerFunc {
+		return SQLExplainHTTPHandler(source.db, source.opts.Exec)
+	})
+}
+
+// SQLSaveHTTPHandler returns SQLSaveHTTPHandler for the selected source.
+func (r *SourceRegistry) SQLSaveHTTPHandler() http.HandlerFunc {
+	return r.handle(func(source *DataSource) http.HandlerFunc {
+		return SQLSaveHTTPHandler(source.db, source.opts.Exec)
+	})
+}
+
+// TableHTTPHandler returns TableBrowser.TableHTTPHandler for the selected source.
+func (r *SourceRegistry) TableHTTPHandler() http.HandlerFunc {
+	return r.handle(func(source *DataSource) http.HandlerFunc { return source.tables.TableHTTPHandler() })
+}
+
+// RecordsHTTPHandler returns TableBrowser.RecordsHTTPHandler for the selected source.
+func (r *SourceRegistry) RecordsHTTPHandler() http.HandlerFunc {
+	return r.handle(func(source *DataSource) http.HandlerFunc { return source.tables.RecordsHTTPHandler() })
+}
+
+// InsertHTTPHandler returns TableBrowser.InsertHTTPHandler for the selected source.
+func (r *SourceRegistry) InsertHTTPHandler() http.HandlerFunc {
+	return r.handle(func(source *DataSource) http.HandlerFunc { return source.tables.InsertHTTPHandler() })
+}
+
+// RemoveHTTPHandler returns TableBrowser.RemoveHTTPHandler for the selected source.
+func (r *SourceRegistry) RemoveHTTPHandler() http.HandlerFunc {
+	return r.handle(func(source *DataSource) http.HandlerFunc { return source.tables.RemoveHTTPHandler() })
+}
+
+// BackupHTTPHandler returns SQLiteBackupHTTPHandler for the selected source.
+func (r *SourceRegistry) BackupHTTPHandler() http.HandlerFunc {
+	return r.handle(func(source *DataSource) http.HandlerFunc {
+		opts := source.opts.Backup
+		opts.Exec = source.opts.Exec
+		return SQLiteBackupHTTPHandler(source.db, opts)
+	})
+}
+
+// RestoreHTTPHandler returns SQLiteRestoreHTTPHandler for the selected source.
+func (r *SourceRegistry) RestoreHTTPHandler() http.HandlerFunc {
+	return r.handle(func(source *DataSource) http.HandlerFunc {
+		opts := source.opts.Backup
+		opts.Exec = source.opts.Exec
+		return SQLiteRestoreHTTPHandler(source.db, opts)
+	})
+}
+
+// AttachRequest is the body of AttachHTTPHandler and DetachHTTPHandler.
+type AttachRequest struct {
+	// Alias is the schema name of the attached database. It defaults to the
+	// name of the attached source.
+	Alias string `json:"alias"`
+
+	// Source is the name of the registered source to attach.
+	Source string `json:"source"`
+}
+
+// AttachHTTPHandler returns an http.HandlerFunc that attaches another
+// registered source to the selected source. Clients can only attach
+// registered sources, never arbitrary files.
+//
+// It returns 403 Forbidden unless the selected source allows attaching and
+// enforces the policy of the attached source, see DataSource.AttachSource.
+func (r *SourceRegistry) AttachHTTPHandler() http.HandlerFunc {
+	return r.handle(func(source *DataSource) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			body, ok := decodeAttachRequest(w, req, source)
+			if !ok {
+				return
+			}
+
+			attached, err := r.Source(body.Source)
+			if err != nil || body.Source == "" {
+				res := w2.NewErrorResponse(fmt.Errorf("%w: %s", ErrSourceNotFound, body.Source).Error())
+				res.Write(w, http.StatusNotFound)
+				return
+			}
+
+			if body.Alias == "" {
+				body.Alias = attached.name
+			}
+
+			if err := source.AttachSource(req.Context(), body.Alias, attached); errors.Is(err, ErrAttachNotAllowed) {
+				res := w2.NewErrorResponse(err.Error())
+				res.Write(w, http.StatusForbidden)
+				return
+			} else if err != nil {
+				res := w2.NewErrorResponse(err.Error())
+				res.Write(w, http.StatusBadRequest)
+				return
+			}
+
+			res := w2.NewSuccessResponse()
+			res.Write(w, http.StatusOK)
+		}
+	})
+}
+
+// DetachHTTPHandler returns an http.HandlerFunc that detaches the database
+// attached as alias from the selected source.
+//
+// It returns 403 Forbidden unless the selected source allows attaching.
+func (r *SourceRegistry) DetachHTTPHandler() http.HandlerFunc {
+	return r.handle(func(source *DataSource) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			body, ok := decodeAttachRequest(w, req, source)
+			if !ok {
+				return
+			}
+
+			if err := source.Detach(req.Context(), body.Alias); err != nil {
+				res := w2.NewErrorResponse(err.Error())
+				res.Write(w, http.StatusBadRequest)
+				return
+			}
+
+			res := w2.NewSuccessResponse()
+			res.Write(w, http.StatusOK)
+		}
+	})
+}
+
+// decodeAttachRequest decodes an AttachRequest and writes the error response
+// when the request is malformed or the source does not allow attaching.
+func decodeAttachRequest(w http.ResponseWriter, r *http.Request, source *DataSource) (AttachRequest, bool) {
+	var req AttachRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		res := w2.NewErrorResponse(err.Error())
+		res.Write(w, http.StatusBadRequest)
+		return req, false
+	}
+
+	if !source.opts.AllowAttach || source.connector == nil {
+		res := w2.NewErrorResponse(fmt.Sprintf("data source %q does not allow attaching databases", source.name))
+		res.Write(w, http.StatusForbidden)
+		return req, false
+	}
+
+	return req, true
+}
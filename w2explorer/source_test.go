@@ -0,0 +1,125 @@
+package w2explorer_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dv1x3r/w2go/w2explorer"
+)
+
+func TestDataSourceAttachSource(t *testing.T) {
+	ctx := context.Background()
+
+	authorize := func(ctx context.Context, req w2explorer.SQLExecRequest) error { return nil }
+	otherAuthorize := func(ctx context.Context, req w2explorer.SQLExecRequest) error { return errors.New("denied") }
+	selectOnly := []w2explorer.StatementKind{w2explorer.StatementSelect}
+	selectInsert := []w2explorer.StatementKind{w2explorer.StatementSelect, w2explorer.StatementInsert}
+
+	// open returns a registry with a host source and a source to attach
+	open := func(t *testing.T, host, attached w2explorer.SQLExecOptions) (*w2explorer.SourceRegistry, *w2explorer.DataSource, *w2explorer.DataSource) {
+		t.Helper()
+
+		dir := t.TempDir()
+		sources := w2explorer.NewSourceRegistry()
+		t.Cleanup(func() { sources.Close() })
+
+		hostSource, err := sources.Open("host", "sqlite", filepath.Join(dir, "host.db"), w2explorer.DataSourceOptions{Exec: host, AllowAttach: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		archive, err := sources.Open("archive", "sqlite", filepath.Join(dir, "archive.db"), w2explorer.DataSourceOptions{Exec: attached})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := archive.DB().Exec(`CREATE TABLE event (id INTEGER PRIMARY KEY, name TEXT); INSERT INTO event (name) VALUES ('a')`); err != nil {
+			t.Fatal(err)
+		}
+
+		return sources, hostSource, archive
+	}
+
+	tests := []struct {
+		Name     string
+		Host     w2explorer.SQLExecOptions
+		Attached w2explorer.SQLExecOptions
+		Error    bool
+	}{
+		{Name: "NoPolicy"},
+		{Name: "SameAuthorize", Host: w2explorer.SQLExecOptions{Authorize: authorize}, Attached: w2explorer.SQLExecOptions{Authorize: authorize}},
+		{Name: "HostAuthorize", Host: w2explorer.SQLExecOptions{Authorize: authorize}},
+		{Name: "OtherAuthorize", Host: w2explorer.SQLExecOptions{Authorize: authorize}, Attached: w2explorer.SQLExecOptions{Authorize: otherAuthorize}, Error: true},
+		{Name: "AttachedAuthorize", Attached: w2explorer.SQLExecOptions{Authorize: authorize}, Error: true},
+		{Name: "HostAllowSubset", Host: w2explorer.SQLExecOptions{Allow: selectOnly}, Attached: w2explorer.SQLExecOptions{Allow: selectInsert}},
+		{Name: "AttachedAllowSubset", Host: w2explorer.SQLExecOptions{Allow: selectInsert}, Attached: w2explorer.SQLExecOptions{Allow: selectOnly}, Error: true},
+		{Name: "AttachedAllow", Attached: w2explorer.SQLExecOptions{Allow: selectOnly}, Error: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			_, host, archive := open(t, test.Host, test.Attached)
+
+			err := host.AttachSource(ctx, "archive", archive)
+			if test.Error {
+				if !errors.Is(err, w2explorer.ErrAttachNotAllowed) {
+					t.Errorf("❌ Expected ErrAttachNotAllowed, got: %v", err)
+				}
+				if len(host.Attached()) != 0 {
+					t.Errorf("❌ Expected no attached databases, got: %v", host.Attached())
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("❌ Unexpected error: %v", err)
+			}
+
+			var name string
+			if err := host.DB().QueryRow("SELECT name FROM archive.event").Scan(&name); err != nil || name != "a" {
+				t.Errorf("❌ Expected the attached row, got: %q %v", name, err)
+			}
+		})
+	}
+
+	t.Run("ReadOnly", func(t *testing.T) {
+		_, host, archive := open(t, w2explorer.SQLExecOptions{}, w2explorer.SQLExecOptions{ReadOnly: true})
+
+		if err := host.AttachSource(ctx, "archive", archive); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := host.DB().Exec("DELETE FROM archive.event"); err == nil {
+			t.Error("❌ Expected an error for a write to a read-only source")
+		}
+	})
+
+	t.Run("Registered", func(t *testing.T) {
+		sources := w2explorer.NewSourceRegistry()
+		host, err := sources.Register("host", openTestDB(t), w2explorer.DataSourceOptions{AllowAttach: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := host.Attach(ctx, "other", "other.db"); !errors.Is(err, w2explorer.ErrAttachUnsupported) {
+			t.Errorf("❌ Expected ErrAttachUnsupported, got: %v", err)
+		}
+	})
+
+	t.Run("AttachHTTPHandler", func(t *testing.T) {
+		sources, _, _ := open(t, w2explorer.SQLExecOptions{}, w2explorer.SQLExecOptions{Allow: selectOnly})
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/sql/sources/attach?source=host", strings.NewReader(`{"source": "archive"}`))
+		sources.AttachHTTPHandler()(w, r)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("❌ Expected status 403, got: %d %s", w.Code, w.Body.String())
+		}
+	})
+}
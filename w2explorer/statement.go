@@ -0,0 +1,476 @@
+package w2explorer
+
+import (
+	"slices"
+	"strings"
+)
+
+// StatementKind identifies the type of a SQL statement by its leading keyword.
+type StatementKind string
+
+const (
+	// StatementSelect covers SELECT, VALUES, and WITH queries that end in a SELECT.
+	StatementSelect StatementKind = "SELECT"
+
+	// StatementInsert covers INSERT and REPLACE statements.
+	StatementInsert StatementKind = "INSERT"
+
+	// StatementUpdate covers UPDATE statements, including WITH ... UPDATE.
+	StatementUpdate StatementKind = "UPDATE"
+
+	// StatementDelete covers DELETE statements, including WITH ... DELETE.
+	StatementDelete StatementKind = "DELETE"
+
+	// StatementCreate covers CREATE TABLE, VIEW, INDEX, TRIGGER, and virtual tables.
+	StatementCreate StatementKind = "CREATE"
+
+	// StatementAlter covers ALTER TABLE statements.
+	StatementAlter StatementKind = "ALTER"
+
+	// StatementDrop covers DROP statements.
+	StatementDrop StatementKind = "DROP"
+
+	// StatementPragma covers PRAGMA statements.
+	StatementPragma StatementKind = "PRAGMA"
+
+	// StatementExplain covers EXPLAIN and EXPLAIN QUERY PLAN.
+	StatementExplain StatementKind = "EXPLAIN"
+
+	// StatementAttach covers ATTACH DATABASE statements.
+	StatementAttach StatementKind = "ATTACH"
+
+	// StatementDetach covers DETACH DATABASE statements.
+	StatementDetach StatementKind = "DETACH"
+
+	// StatementVacuum covers VACUUM and VACUUM INTO.
+	StatementVacuum StatementKind = "VACUUM"
+
+	// StatementAnalyze covers ANALYZE statements.
+	StatementAnalyze StatementKind = "ANALYZE"
+
+	// StatementReindex covers REINDEX statements.
+	StatementReindex StatementKind = "REINDEX"
+
+	// StatementTransaction covers BEGIN, COMMIT, END, ROLLBACK, SAVEPOINT, and RELEASE.
+	StatementTransaction StatementKind = "TRANSACTION"
+)
+
+type tokenKind int
+
+const (
+	tokenSpace tokenKind = iota
+	tokenComment
+	tokenWord
+	tokenQuoted
+	tokenString
+	tokenNumber
+	tokenParam
+	tokenPunct
+)
+
+// token is a lexical SQL token. Pos is the byte offset of Text in the query.
+type token struct {
+	Kind tokenKind
+	Text string
+	Pos  int
+}
+
+// is reports whether t is the keyword word, compared case-insensitively.
+func (t token) is(word string) bool {
+	return t.Kind == tokenWord && strings.EqualFold(t.Text, word)
+}
+
+// tokenize splits query into SQLite tokens. It never fails: unterminated
+// strings and comments run to the end of the query.
+func tokenize(query string) []token {
+	var tokens []token
+
+	for pos := 0; pos < len(query); {
+		start := pos
+		kind := tokenPunct
+		c := query[pos]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			kind = tokenSpace
+			for pos < len(query) && strings.IndexByte(" \t\n\r\f", query[pos]) >= 0 {
+				pos++
+			}
+
+		case c == '-' && strings.HasPrefix(query[pos:], "--"):
+			kind = tokenComment
+			if end := strings.IndexByte(query[pos:], '\n'); end >= 0 {
+				pos += end
+			} else {
+				pos = len(query)
+			}
+
+		case c == '/' && strings.HasPrefix(query[pos:], "/*"):
+			kind = tokenComment
+			if end := strings.Index(query[pos+2:], "*/"); end >= 0 {
+				pos += end + 4
+			} else {
+				pos = len(query)
+			}
+
+		case c == '\'':
+			kind = tokenString
+			pos = scanQuoted(query, pos, '\'')
+
+		case (c == 'x' || c == 'X') && pos+1 < len(query) && query[pos+1] == '\'':
+			kind = tokenString
+			pos = scanQuoted(query, pos+1, '\'')
+
+		case c == '"' || c == '`':
+			kind = tokenQuoted
+			pos = scanQuoted(query, pos, c)
+
+		case c == '[':
+			kind = tokenQuoted
+			if end := strings.IndexByte(query[pos:], ']'); end >= 0 {
+				pos += end + 1
+			} else {
+				pos = len(query)
+			}
+
+		case isDigit(c) || c == '.' && pos+1 < len(query) && isDigit(query[pos+1]):
+			kind = tokenNumber
+			pos = scanNumber(query, pos)
+
+		case isWordStart(c):
+			kind = tokenWord
+			for pos < len(query) && isWordPart(query[pos]) {
+				pos++
+			}
+
+		case c == '?':
+			kind = tokenParam
+			pos++
+			for pos < len(query) && isDigit(query[pos]) {
+				pos++
+			}
+
+		case (c == ':' || c == '@' || c == '$') && pos+1 < len(query) && isWordPart(query[pos+1]):
+			kind = tokenParam
+			pos++
+			for pos < len(query) && isWordPart(query[pos]) {
+				pos++
+			}
+
+		default:
+			pos++
+			// two-character operators
+			if pos < len(query) {
+				switch query[start : pos+1] {
+				case "||", "<=", ">=", "<>", "!=", "==", "<<", ">>", "->":
+					pos++
+					if query[start:pos] == "->" && pos < len(query) && query[pos] == '>' {
+						pos++
+					}
+				}
+			}
+		}
+
+		tokens = append(tokens, token{Kind: kind, Text: query[start:pos], Pos: start})
+	}
+
+	return tokens
+}
+
+// scanQuoted returns the position after the quoted token starting at pos.
+// Doubled quote characters are treated as escapes.
+func scanQuoted(query string, pos int, quote byte) int {
+	for pos++; pos < len(query); pos++ {
+		if query[pos] == quote {
+			if pos+1 < len(query) && query[pos+1] == quote {
+				pos++
+				continue
+			}
+			return pos + 1
+		}
+	}
+	return len(query)
+}
+
+// scanNumber returns the position after the numeric literal starting at pos.
+func scanNumber(query string, pos int) int {
+	if strings.HasPrefix(query[pos:], "0x") || strings.HasPrefix(query[pos:], "0X") {
+		pos += 2
+		for pos < len(query) && strings.IndexByte("0123456789abcdefABCDEF_", query[pos]) >= 0 {
+			pos++
+		}
+		return pos
+	}
+
+	for pos < len(query) && (isDigit(query[pos]) || query[pos] == '.' || query[pos] == '_') {
+		pos++
+	}
+
+	if pos < len(query) && (query[pos] == 'e' || query[pos] == 'E') {
+		next := pos + 1
+		if next < len(query) && (query[next] == '+' || query[next] == '-') {
+			next++
+		}
+		if next < len(query) && isDigit(query[next]) {
+			pos = next
+			for pos < len(query) && isDigit(query[pos]) {
+				pos++
+			}
+		}
+	}
+
+	return pos
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isWordStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func isWordPart(c byte) bool {
+	return isWordStart(c) || isDigit(c) || c == '$'
+}
+
+// statement is one SQL statement of a script.
+type statement struct {
+	// Text is the statement source without the trailing semicolon.
+	Text string
+
+	// Pos is the byte offset of Text in the script.
+	Pos int
+
+	// Tokens are the significant tokens, without spaces and comments.
+	Tokens []token
+}
+
+// splitStatements splits a script into statements at top-level semicolons.
+//
+// Semicolons inside the BEGIN...END body of CREATE [TEMP] TRIGGER do not end
+// the statement. TRIGGER and BEGIN elsewhere, such as column names, do not
+// start a body. Statements that only contain spaces and comments are dropped.
+func splitStatements(script string) []statement {
+	var statements []statement
+	var current []token
+	start := 0
+	isTrigger := false
+	blockDepth := 0
+
+	flush := func(end int) {
+		if len(current) > 0 {
+			text := strings.TrimSpace(script[start:end])
+			pos := start + strings.Index(script[start:end], text)
+			statements = append(statements, statement{Text: text, Pos: pos, Tokens: current})
+		}
+		current = nil
+		isTrigger = false
+		blockDepth = 0
+	}
+
+	for _, t := range tokenize(script) {
+		if t.Kind == tokenSpace || t.Kind == tokenComment {
+			continue
+		}
+
+		if t.Text == ";" && blockDepth == 0 {
+			flush(t.Pos)
+			start = t.Pos + 1
+			continue
+		}
+
+		if t.is("TRIGGER") && isTriggerPrefix(current) {
+			isTrigger = true
+		}
+
+		if isTrigger {
+			switch {
+			case t.is("BEGIN") && blockDepth == 0, t.is("CASE") && blockDepth > 0:
+				blockDepth++
+			case t.is("END") && blockDepth > 0:
+				blockDepth--
+				// the trigger body has ended, so a later BEGIN is not another body
+				isTrigger = blockDepth > 0
+			}
+		}
+
+		current = append(current, t)
+	}
+
+	flush(len(script))
+	return statements
+}
+
+// isTriggerPrefix reports whether tokens are the leading CREATE [TEMP] of a
+// CREATE TRIGGER statement, so TRIGGER anywhere else is not mistaken for one.
+func isTriggerPrefix(tokens []token) bool {
+	switch len(tokens) {
+	case 1:
+		return tokens[0].is("CREATE")
+	case 2:
+		return tokens[0].is("CREATE") && (tokens[1].is("TEMP") || tokens[1].is("TEMPORARY"))
+	default:
+		return false
+	}
+}
+
+// isTrigger reports whether s is a CREATE [TEMP] TRIGGER statement.
+func (s statement) isTrigger() bool {
+	for i := 1; i <= 2 && i < len(s.Tokens); i++ {
+		if s.Tokens[i].is("TRIGGER") && isTriggerPrefix(s.Tokens[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTail reports whether s contains more than one statement. The driver runs
+// every statement of a query text, so a tail would run without being checked.
+//
+// Only the BEGIN...END body of CREATE TRIGGER may contain semicolons.
+func (s statement) hasTail() bool {
+	semicolon := slices.IndexFunc(s.Tokens, func(t token) bool { return t.Text == ";" })
+	if semicolon < 0 {
+		return false
+	}
+
+	if !s.isTrigger() {
+		return true
+	}
+
+	begin := slices.IndexFunc(s.Tokens, func(t token) bool { return t.is("BEGIN") })
+	return begin < 0 || semicolon < begin || !s.Tokens[len(s.Tokens)-1].is("END")
+}
+
+// mainKeyword returns the index of the keyword that determines the statement
+// kind, skipping a leading WITH clause. It returns -1 for empty statements.
+func (s statement) mainKeyword() int {
+	if len(s.Tokens) == 0 {
+		return -1
+	}
+
+	if !s.Tokens[0].is("WITH") {
+		return 0
+	}
+
+	depth := 0
+	for i, t := range s.Tokens {
+		switch {
+		case t.Text == "(":
+			depth++
+		case t.Text == ")":
+			depth--
+		case depth == 0 && (t.is("SELECT") || t.is("VALUES") || t.is("INSERT") ||
+			t.is("REPLACE") || t.is("UPDATE") || t.is("DELETE")):
+			return i
+		}
+	}
+
+	return 0
+}
+
+// Kind returns the statement kind derived from its leading keyword.
+func (s statement) Kind() StatementKind {
+	i := s.mainKeyword()
+	if i < 0 {
+		return ""
+	}
+
+	word := strings.ToUpper(s.Tokens[i].Text)
+	switch word {
+	case "SELECT", "VALUES", "WITH":
+		return StatementSelect
+	case "INSERT", "REPLACE":
+		return StatementInsert
+	case "BEGIN", "COMMIT", "END", "ROLLBACK", "SAVEPOINT", "RELEASE":
+		return StatementTransaction
+	default:
+		return StatementKind(word)
+	}
+}
+
+// hasTopLevel reports whether keyword appears outside parentheses after the
+// main keyword.
+func (s statement) hasTopLevel(keyword string) bool {
+	i := s.mainKeyword()
+	if i < 0 {
+		return false
+	}
+
+	depth := 0
+	for _, t := range s.Tokens[i:] {
+		switch {
+		case t.Text == "(":
+			depth++
+		case t.Text == ")":
+			depth--
+		case depth == 0 && t.is(keyword):
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsDestructive reports whether s is an UPDATE or DELETE without a WHERE clause.
+func (s statement) IsDestructive() bool {
+	kind := s.Kind()
+	return (kind == StatementUpdate || kind == StatementDelete) && !s.hasTopLevel("WHERE")
+}
+
+// readOnlyPragmas lists pragmas that only read, even when given an argument.
+var readOnlyPragmas = []string{
+	"collation_list", "compile_options", "database_list", "foreign_key_check",
+	"foreign_key_list", "function_list", "index_info", "index_list",
+	"index_xinfo", "integrity_check", "module_list", "pragma_list",
+	"quick_check", "table_info", "table_list", "table_xinfo",
+}
+
+// actionPragmas lists pragmas that change state even without an argument.
+var actionPragmas = []string{
+	"incremental_vacuum", "optimize", "shrink_memory", "wal_checkpoint",
+}
+
+// IsReadOnly reports whether s only reads data.
+//
+// SELECT and EXPLAIN statements are read-only. A PRAGMA is read-only when it
+// queries a value without setting it, or when it is a known introspection
+// pragma such as table_info.
+func (s statement) IsReadOnly() bool {
+	switch s.Kind() {
+	case StatementSelect, StatementExplain:
+		return true
+	case StatementPragma:
+	default:
+		return false
+	}
+
+	// PRAGMA [schema.]name [= value | (value)]
+	args := s.Tokens[1:]
+	if len(args) >= 2 && args[1].Text == "." {
+		args = args[2:]
+	}
+
+	if len(args) == 0 {
+		return false
+	}
+
+	name := strings.ToLower(strings.Trim(args[0].Text, "\"`[]"))
+	if slices.Contains(readOnlyPragmas, name) {
+		return true
+	}
+
+	return len(args) == 1 && !slices.Contains(actionPragmas, name)
+}
+
+// ReturnsRows reports whether s produces a result set, such as a SELECT or a
+// statement with a RETURNING clause.
+func (s statement) ReturnsRows() bool {
+	switch s.Kind() {
+	case StatementSelect, StatementExplain, StatementPragma:
+		return true
+	default:
+		return s.hasTopLevel("RETURNING")
+	}
+}
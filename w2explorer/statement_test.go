@@ -0,0 +1,126 @@
+package w2explorer_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dv1x3r/w2go/w2explorer"
+)
+
+func TestSQLExecOptionsCheck(t *testing.T) {
+	t.Run("ReadOnly", func(t *testing.T) {
+		tests := []struct {
+			Query         string
+			ExpectedError bool
+		}{
+			{Query: `SELECT * FROM todo`},
+			{Query: `with t as (select 1) select * from t`},
+			{Query: `VALUES (1), (2)`},
+			{Query: `EXPLAIN QUERY PLAN DELETE FROM todo`},
+			{Query: `PRAGMA table_info('todo')`},
+			{Query: `PRAGMA main.user_version`},
+			{Query: `-- comment; DELETE FROM todo` + "\n" + `SELECT 'a; DELETE FROM todo'`},
+			{Query: `SELECT 1; DELETE FROM todo`, ExpectedError: true},
+			{Query: `WITH t AS (SELECT 1) DELETE FROM todo WHERE id IN t`, ExpectedError: true},
+			{Query: `INSERT INTO todo DEFAULT VALUES`, ExpectedError: true},
+			{Query: `PRAGMA user_version = 3`, ExpectedError: true},
+			{Query: `PRAGMA journal_mode(WAL)`, ExpectedError: true},
+			{Query: `PRAGMA optimize`, ExpectedError: true},
+			{Query: `ATTACH 'other.db' AS other`, ExpectedError: true},
+			{Query: `BEGIN; SELECT 1; COMMIT`, ExpectedError: true},
+		}
+
+		opts := w2explorer.SQLExecOptions{ReadOnly: true}
+		for _, test := range tests {
+			err := opts.Check(w2explorer.SQLExecRequest{Query: test.Query})
+			if test.ExpectedError && !errors.Is(err, w2explorer.ErrStatementNotAllowed) {
+				t.Errorf("❌ Expected ErrStatementNotAllowed for %q, got: %v", test.Query, err)
+			} else if !test.ExpectedError && err != nil {
+				t.Errorf("❌ Unexpected error for %q: %v", test.Query, err)
+			}
+		}
+	})
+
+	t.Run("Allow", func(t *testing.T) {
+		tests := []struct {
+			Query         string
+			ExpectedError bool
+		}{
+			{Query: `SELECT 1`},
+			{Query: `insert into todo (name) values ('a')`},
+			{Query: `REPLACE INTO todo (id, name) VALUES (1, 'a')`},
+			{Query: `UPDATE todo SET name = 'a' WHERE id = 1`, ExpectedError: true},
+			{
+				Query: `CREATE TRIGGER t AFTER INSERT ON todo BEGIN
+					UPDATE todo SET name = CASE WHEN new.name = '' THEN 'x' ELSE new.name END WHERE id = new.id;
+					DELETE FROM log;
+				END; SELECT 1`,
+				ExpectedError: true,
+			},
+		}
+
+		opts := w2explorer.SQLExecOptions{Allow: []w2explorer.StatementKind{
+			w2explorer.StatementSelect,
+			w2explorer.StatementInsert,
+		}}
+		for _, test := range tests {
+			err := opts.Check(w2explorer.SQLExecRequest{Query: test.Query})
+			if test.ExpectedError && !errors.Is(err, w2explorer.ErrStatementNotAllowed) {
+				t.Errorf("❌ Expected ErrStatementNotAllowed for %q, got: %v", test.Query, err)
+			} else if !test.ExpectedError && err != nil {
+				t.Errorf("❌ Unexpected error for %q: %v", test.Query, err)
+			}
+		}
+	})
+
+	t.Run("Trigger", func(t *testing.T) {
+		tests := []struct {
+			Query         string
+			ExpectedError bool
+		}{
+			{Query: `CREATE TRIGGER t AFTER INSERT ON todo BEGIN DELETE FROM log; END; SELECT 1`},
+			{Query: `create temp trigger t after insert on todo when new.id > 0 begin delete from log; insert into log values (new.id); end`},
+			{Query: `CREATE TABLE x(a trigger begin); DELETE FROM todo`, ExpectedError: true},
+			{Query: `CREATE VIEW v AS SELECT 1 AS trigger, 2 AS begin; DELETE FROM todo`, ExpectedError: true},
+			{Query: `CREATE TRIGGER t AFTER INSERT ON todo BEGIN SELECT 1; END BEGIN; DELETE FROM todo`, ExpectedError: true},
+		}
+
+		opts := w2explorer.SQLExecOptions{Allow: []w2explorer.StatementKind{
+			w2explorer.StatementSelect,
+			w2explorer.StatementCreate,
+		}}
+		for _, test := range tests {
+			err := opts.Check(w2explorer.SQLExecRequest{Query: test.Query})
+			if test.ExpectedError && !errors.Is(err, w2explorer.ErrStatementNotAllowed) {
+				t.Errorf("❌ Expected ErrStatementNotAllowed for %q, got: %v", test.Query, err)
+			} else if !test.ExpectedError && err != nil {
+				t.Errorf("❌ Unexpected error for %q: %v", test.Query, err)
+			}
+		}
+	})
+
+	t.Run("ConfirmDestructive", func(t *testing.T) {
+		tests := []struct {
+			Query         string
+			Confirm       bool
+			ExpectedError bool
+		}{
+			{Query: `DELETE FROM todo WHERE id = 1`},
+			{Query: `UPDATE todo SET quantity = (SELECT max(quantity) FROM todo WHERE id = 2) WHERE id = 1`},
+			{Query: `DELETE FROM todo`, ExpectedError: true},
+			{Query: `delete from todo where id in (select id from todo where quantity = 0); update todo set quantity = 0`, ExpectedError: true},
+			{Query: `UPDATE todo SET quantity = (SELECT 1 WHERE 1)`, ExpectedError: true},
+			{Query: `DELETE FROM todo`, Confirm: true},
+		}
+
+		opts := w2explorer.SQLExecOptions{ConfirmDestructive: true}
+		for _, test := range tests {
+			err := opts.Check(w2explorer.SQLExecRequest{Query: test.Query, Confirm: test.Confirm})
+			if test.ExpectedError && !errors.Is(err, w2explorer.ErrConfirmRequired) {
+				t.Errorf("❌ Expected ErrConfirmRequired for %q, got: %v", test.Query, err)
+			} else if !test.ExpectedError && err != nil {
+				t.Errorf("❌ Unexpected error for %q: %v", test.Query, err)
+			}
+		}
+	})
+}
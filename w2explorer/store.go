@@ -0,0 +1,519 @@
+package w2explorer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/dv1x3r/w2go/w2"
+	"github.com/dv1x3r/w2go/w2db"
+	"github.com/huandu/go-sqlbuilder"
+)
+
+// QueryStoreOptions configures NewQueryStore.
+type QueryStoreOptions struct {
+	// HistoryTable is the trusted name of the query history table.
+	// It defaults to "w2explorer_history".
+	HistoryTable string
+
+	// SavedTable is the trusted name of the saved query table.
+	// It defaults to "w2explorer_saved_query".
+	SavedTable string
+
+	// MaxHistory keeps only the newest history entries when positive.
+	MaxHistory int
+
+	// Redact overrides DefaultRedactRules for the recorded history queries.
+	// Use an empty RedactRules to record them as they are.
+	Redact *RedactRules
+}
+
+// QueryStore keeps the SQL explorer query history and the saved query library
+// in SQLite tables, so they are shared by every user of the explorer.
+type QueryStore struct {
+	db   *sql.DB
+	opts QueryStoreOptions
+}
+
+// NewQueryStore returns a query store backed by db. Call Init to create its tables.
+//
+// The store may use the same database as the explorer, or a separate one when
+// the explorer database is opened read-only.
+func NewQueryStore(db *sql.DB, opts QueryStoreOptions) *QueryStore {
+	if opts.HistoryTable == "" {
+		opts.HistoryTable = "w2explorer_history"
+	}
+
+	if opts.SavedTable == "" {
+		opts.SavedTable = "w2explorer_saved_query"
+	}
+
+	if opts.Redact == nil {
+		opts.Redact = &DefaultRedactRules
+	}
+
+	return &QueryStore{db: db, opts: opts}
+}
+
+// HistoryEntry is one executed SQL explorer script.
+type HistoryEntry struct {
+	// ID is the history table row ID, used as the w2grid recid.
+	ID int64 `json:"recid"`
+
+	// User is the user set by WithUser when the script was executed.
+	User string `json:"user"`
+
+	// Query is the executed SQL text.
+	Query string `json:"query"`
+
+	// Elapsed is the execution time in seconds.
+	Elapsed float64 `json:"elapsed"`
+
+	// Rows is the number of rows returned plus the number of rows affected.
+	Rows int64 `json:"rows"`
+
+	// Error is the error message of a failed or rejected script.
+	Error string `json:"error,omitempty"`
+
+	// CreatedAt is the UTC execution time in ISO 8601 format.
+	CreatedAt string `json:"createdAt"`
+}
+
+// SavedQuery is a named query in the shared query library.
+type SavedQuery struct {
+	// ID is zero for a query that has not been saved yet.
+	ID int64 `json:"recid"`
+
+	// Name is the required display name of the query.
+	Name string `json:"name"`
+
+	// Query is the saved SQL text.
+	Query string `json:"query"`
+
+	// Tags groups the query in the library. SaveQuery trims and deduplicates them.
+	Tags []string `json:"tags"`
+
+	// Description is an optional note about what the query does.
+	Description string `json:"description"`
+
+	// Params holds the last parameter values of the query, so the widget can
+	// prompt with them when the query is opened.
+	Params []SQLParam `json:"params"`
+
+	// User is the user who saved the query last.
+	User string `json:"user"`
+
+	// CreatedAt and UpdatedAt are UTC times in ISO 8601 format.
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// ErrSavedQueryNotFound is returned when a saved query does not exist.
+var ErrSavedQueryNotFound = errors.New("saved query not found")
+
+const storeTimestamp = `strftime('%Y-%m-%dT%H:%M:%fZ', 'now')`
+
+// Init creates the history and saved query tables when they do not exist.
+func (s *QueryStore) Init(ctx context.Context) error {
+	query := fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %[1]s (
+  id INTEGER PRIMARY KEY,
+  user TEXT NOT NULL DEFAULT '',
+  query TEXT NOT NULL,
+  elapsed REAL NOT NULL DEFAULT 0,
+  rows INTEGER NOT NULL DEFAULT 0,
+  error TEXT NOT NULL DEFAULT '',
+  created_at TEXT NOT NULL DEFAULT (%[3]s)
+);
+CREATE INDEX IF NOT EXISTS %[1]s_user_idx ON %[1]s (user, id);
+CREATE TABLE IF NOT EXISTS %[2]s (
+  id INTEGER PRIMARY KEY,
+  name TEXT NOT NULL,
+  query TEXT NOT NULL,
+  tags TEXT NOT NULL DEFAULT '[]',
+  description TEXT NOT NULL DEFAULT '',
+  params TEXT NOT NULL DEFAULT '[]',
+  user TEXT NOT NULL DEFAULT '',
+  created_at TEXT NOT NULL DEFAULT (%[3]s),
+  updated_at TEXT NOT NULL DEFAULT (%[3]s)
+);
+`, s.opts.HistoryTable, s.opts.SavedTable, storeTimestamp)
+
+	_, err := s.db.ExecContext(ctx, query)
+	return err
+}
+
+// Record appends entry to the query history and drops the oldest entries
+// beyond MaxHistory. ID and CreatedAt of entry are ignored, and secrets in
+// the query are redacted.
+func (s *QueryStore) Record(ctx context.Context, entry HistoryEntry) error {
+	entry.Query = s.opts.Redact.Query(entry.Query)
+
+	builder := sqlbuilder.InsertInto(s.opts.HistoryTable)
+	builder.Cols("user", "query", "elapsed", "rows", "error")
+	builder.Values(entry.User, entry.Query, entry.Elapsed, entry.Rows, entry.Error)
+	query, args := builder.BuildWithFlavor(sqlbuilder.SQLite)
+
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+
+	if s.opts.MaxHistory <= 0 {
+		return nil
+	}
+
+	query = fmt.Sprintf(`DELETE FROM %[1]s WHERE id <= (SELECT id FROM %[1]s ORDER BY id DESC LIMIT 1 OFFSET ?)`, s.opts.HistoryTable)
+	_, err := s.db.ExecContext(ctx, query, s.opts.MaxHistory)
+	return err
+}
+
+// History returns the query history for a w2grid request, newest first
+// unless req is sorted.
+//
+// The search fields are user, query, and error, and the sort fields are
+// recid, user, elapsed, and rows. The "user" request parameter limits the
+// result to one user.
+func (s *QueryStore) History(ctx context.Context, req w2.GetGridRequest) (w2.GetGridResponse[HistoryEntry], error) {
+	if len(req.Sort) == 0 {
+		req.Sort = []w2.GridSort{{Field: "recid", Direction: "desc"}}
+	}
+
+	return w2db.GetGridContext(ctx, s.db, req, w2db.GetGridOptions[HistoryEntry]{
+		From:   s.opts.HistoryTable,
+		Select: []string{"id", "user", "query", "elapsed", "rows", "error", "created_at"},
+		Where: map[string]string{
+			"user":  "user",
+			"query": "query",
+			"error": "error",
+		},
+		OrderBy: map[string]string{
+			"recid":   "id",
+			"user":    "user",
+			"elapsed": "elapsed",
+			"rows":    "rows",
+		},
+		Params: map[string]string{
+			"user": "user",
+		},
+		Scan: func(rows *sql.Rows, record *HistoryEntry) error {
+			return rows.Scan(
+				&record.ID,
+				&record.User,
+				&record.Query,
+				&record.Elapsed,
+				&record.Rows,
+				&record.Error,
+				&record.CreatedAt,
+			)
+		},
+		Flavor: sqlbuilder.SQLite,
+	})
+}
+
+// SavedQueries returns the saved query library for a w2grid request, sorted
+// by name unless req is sorted.
+//
+// The search fields are name, query, description, tags, and user, and the sort
+// fields are recid, name, user, and updatedAt. The "tag" request parameter
+// limits the result to queries with that tag.
+func (s *QueryStore) SavedQueries(ctx context.Context, req w2.GetGridRequest) (w2.GetGridResponse[SavedQuery], error) {
+	if len(req.Sort) == 0 {
+		req.Sort = []w2.GridSort{{Field: "name", Direction: "asc"}}
+	}
+
+	return w2db.GetGridContext(ctx, s.db, req, w2db.GetGridOptions[SavedQuery]{
+		From:   s.opts.SavedTable,
+		Select: savedQueryColumns,
+		Where: map[string]string{
+			"name":        "name",
+			"query":       "query",
+			"description": "description",
+			"tags":        "tags",
+			"user":        "user",
+		},
+		OrderBy: map[string]string{
+			"recid":     "id",
+			"name":      "name COLLATE NOCASE",
+			"user":      "user",
+			"updatedAt": "updated_at",
+		},
+		Params: map[string]string{
+			"tag": "EXISTS (SELECT 1 FROM json_each(tags) WHERE value = ?)",
+		},
+		Scan: func(rows *sql.Rows, record *SavedQuery) error {
+			return scanSavedQuery(rows, record)
+		},
+		Flavor: sqlbuilder.SQLite,
+	})
+}
+
+var savedQueryColumns = []string{"id", "name", "query", "tags", "description", "user", "created_at", "updated_at", "params"}
+
+// scanSavedQuery scans the savedQueryColumns of the current row into record.
+func scanSavedQuery(row interface{ Scan(dest ...any) error }, record *SavedQuery) error {
+	var tags, params string
+
+	err := row.Scan(
+		&record.ID,
+		&record.Name,
+		&record.Query,
+		&tags,
+		&record.Description,
+		&record.User,
+		&record.CreatedAt,
+		&record.UpdatedAt,
+		&params,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal([]byte(tags), &record.Tags); err != nil {
+		return err
+	}
+
+	return json.Unmarshal([]byte(params), &record.Params)
+}
+
+// SavedQuery returns the saved query with id, or ErrSavedQueryNotFound.
+func (s *QueryStore) SavedQuery(ctx context.Context, id int64) (SavedQuery, error) {
+	builder := sqlbuilder.Select(savedQueryColumns...).From(s.opts.SavedTable)
+	builder.Where(builder.Equal("id", id))
+	query, args := builder.BuildWithFlavor(sqlbuilder.SQLite)
+
+	var record SavedQuery
+	err := scanSavedQuery(s.db.QueryRowContext(ctx, query, args...), &record)
+	if errors.Is(err, sql.ErrNoRows) {
+		return SavedQuery{}, ErrSavedQueryNotFound
+	}
+
+	return record, err
+}
+
+// SaveQuery inserts q when q.ID is zero and updates it otherwise, and returns
+// its ID. Tags are trimmed and deduplicated, and parameter values must match
+// their types. The user is taken from ctx.
+func (s *QueryStore) SaveQuery(ctx context.Context, q SavedQuery) (int64, error) {
+	if strings.TrimSpace(q.Name) == "" {
+		return 0, errors.New("name is required")
+	}
+
+	if strings.TrimSpace(q.Query) == "" {
+		return 0, errors.New("query is empty")
+	}
+
+	tags := []string{}
+	for _, tag := range q.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	tagsJSON, err := json.Marshal(tags)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := validateParams(q.Params); err != nil {
+		return 0, err
+	}
+
+	params := q.Params
+	if params == nil {
+		params = []SQLParam{}
+	}
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return 0, err
+	}
+
+	user := UserFromContext(ctx)
+
+	if q.ID == 0 {
+		builder := sqlbuilder.InsertInto(s.opts.SavedTable)
+		builder.Cols("name", "query", "tags", "description", "user", "params")
+		builder.Values(strings.TrimSpace(q.Name), q.Query, string(tagsJSON), q.Description, user, string(paramsJSON))
+		query, args := builder.BuildWithFlavor(sqlbuilder.SQLite)
+
+		result, err := s.db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return 0, err
+		}
+
+		return result.LastInsertId()
+	}
+
+	builder := sqlbuilder.Update(s.opts.SavedTable)
+	builder.Set(
+		builder.Assign("name", strings.TrimSpace(q.Name)),
+		builder.Assign("query", q.Query),
+		builder.Assign("tags", string(tagsJSON)),
+		builder.Assign("description", q.Description),
+		builder.Assign("user", user),
+		builder.Assign("params", string(paramsJSON)),
+		"updated_at = "+storeTimestamp,
+	)
+	builder.Where(builder.Equal("id", q.ID))
+	query, args := builder.BuildWithFlavor(sqlbuilder.SQLite)
+
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return 0, ErrSavedQueryNotFound
+	}
+
+	return q.ID, nil
+}
+
+// RemoveSavedQueries deletes the saved queries with ids and returns RowsAffected.
+func (s *QueryStore) RemoveSavedQueries(ctx context.Context, ids []int) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	builder := sqlbuilder.DeleteFrom(s.opts.SavedTable)
+	builder.Where(builder.In("id", sqlbuilder.Flatten(ids)...))
+	query, args := builder.BuildWithFlavor(sqlbuilder.SQLite)
+
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, _ := result.RowsAffected()
+	return int(affected), nil
+}
+
+// HistoryHTTPHandler returns an http.HandlerFunc that writes the query history
+// for the w2grid request in the "request" query parameter.
+func (s *QueryStore) HistoryHTTPHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, err := w2.ParseGetGridRequest(r.URL.Query().Get("request"))
+		if err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusBadRequest)
+			return
+		}
+
+		res, err := s.History(r.Context(), req)
+		if err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusInternalServerError)
+			return
+		}
+
+		res.Write(w)
+	}
+}
+
+// SavedQueriesHTTPHandler returns an http.HandlerFunc that writes the saved
+// query library for the w2grid request in the "request" query parameter.
+func (s *QueryStore) SavedQueriesHTTPHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, err := w2.ParseGetGridRequest(r.URL.Query().Get("request"))
+		if err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusBadRequest)
+			return
+		}
+
+		res, err := s.SavedQueries(r.Context(), req)
+		if err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusInternalServerError)
+			return
+		}
+
+		res.Write(w)
+	}
+}
+
+// SavedQueryHTTPHandler returns an http.HandlerFunc that loads one saved query
+// for the w2form request in the "request" query parameter.
+func (s *QueryStore) SavedQueryHTTPHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, err := w2.ParseGetFormRequest(r.URL.Query().Get("request"))
+		if err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusBadRequest)
+			return
+		}
+
+		record, err := s.SavedQuery(r.Context(), int64(req.RecID))
+		if errors.Is(err, ErrSavedQueryNotFound) {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusInternalServerError)
+			return
+		}
+
+		res := w2.NewGetFormResponse(record)
+		res.Write(w)
+	}
+}
+
+// SaveQueryHTTPHandler returns an http.HandlerFunc that inserts or updates a
+// saved query from a w2form save request. A zero recid inserts a new query.
+func (s *QueryStore) SaveQueryHTTPHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, err := w2.ParseSaveFormRequest[SavedQuery](r.Body)
+		if err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusBadRequest)
+			return
+		}
+
+		req.Record.ID = int64(req.RecID)
+		id, err := s.SaveQuery(r.Context(), req.Record)
+		if errors.Is(err, ErrSavedQueryNotFound) {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusNotFound)
+			return
+		} else if errors.Is(err, ErrInvalidParam) {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusBadRequest)
+			return
+		} else if err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusInternalServerError)
+			return
+		}
+
+		res := w2.NewSaveFormResponse(int(id))
+		res.Write(w)
+	}
+}
+
+// RemoveSavedQueriesHTTPHandler returns an http.HandlerFunc that deletes saved
+// queries from a w2grid remove request.
+func (s *QueryStore) RemoveSavedQueriesHTTPHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, err := w2.ParseRemoveGridRequest(r.Body)
+		if err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusBadRequest)
+			return
+		}
+
+		if _, err := s.RemoveSavedQueries(r.Context(), req.ID); err != nil {
+			res := w2.NewErrorResponse(err.Error())
+			res.Write(w, http.StatusInternalServerError)
+			return
+		}
+
+		res := w2.NewSuccessResponse()
+		res.Write(w, http.StatusOK)
+	}
+}
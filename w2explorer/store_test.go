@@ -0,0 +1,146 @@
+package w2explorer_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dv1x3r/w2go/w2"
+	"github.com/dv1x3r/w2go/w2explorer"
+)
+
+func TestQueryStore(t *testing.T) {
+	ctx := context.Background()
+
+	open := func(t *testing.T, opts w2explorer.QueryStoreOptions) *w2explorer.QueryStore {
+		t.Helper()
+
+		store := w2explorer.NewQueryStore(openTestDB(t, `CREATE TABLE todo (id INTEGER PRIMARY KEY, name TEXT)`), opts)
+		if err := store.Init(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Init(ctx); err != nil {
+			t.Fatalf("❌ Expected Init to be repeatable, got: %v", err)
+		}
+		return store
+	}
+
+	// queries returns the queries of the history, newest first
+	queries := func(t *testing.T, store *w2explorer.QueryStore, req w2.GetGridRequest) []string {
+		t.Helper()
+
+		res, err := store.History(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		queries := []string{}
+		for _, entry := range res.Records {
+			queries = append(queries, entry.Query)
+		}
+		return queries
+	}
+
+	t.Run("History", func(t *testing.T) {
+		store := open(t, w2explorer.QueryStoreOptions{MaxHistory: 2})
+
+		for i, query := range []string{"SELECT 1", "SELECT 2", "SELECT 3"} {
+			user := "alice"
+			if i == 2 {
+				user = "bob"
+			}
+			if err := store.Record(ctx, w2explorer.HistoryEntry{User: user, Query: query, Rows: 1}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if actual := queries(t, store, w2.GetGridRequest{}); strings.Join(actual, ",") != "SELECT 3,SELECT 2" {
+			t.Errorf("❌ Expected the newest 2 entries, got: %v", actual)
+		}
+
+		if actual := queries(t, store, w2.GetGridRequest{Extra: w2.Extra{"user": "alice"}}); strings.Join(actual, ",") != "SELECT 2" {
+			t.Errorf("❌ Expected the entries of alice, got: %v", actual)
+		}
+	})
+
+	t.Run("SQLExec", func(t *testing.T) {
+		store := open(t, w2explorer.QueryStoreOptions{})
+		db := openTestDB(t)
+		opts := w2explorer.SQLExecOptions{History: store, Allow: []w2explorer.StatementKind{w2explorer.StatementSelect}}
+
+		w2explorer.SQLExec(w2explorer.WithUser(ctx, "alice"), db, w2explorer.SQLExecRequest{Query: "SELECT 1 AS n"}, opts)
+		w2explorer.SQLExec(ctx, db, w2explorer.SQLExecRequest{Query: "SELECT 1 AS n", Grid: w2.GetGridRequest{Offset: 100}}, opts)
+		w2explorer.SQLExec(ctx, db, w2explorer.SQLExecRequest{Query: "DELETE FROM todo"}, opts)
+
+		res, err := store.History(ctx, w2.GetGridRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(res.Records) != 2 {
+			t.Fatalf("❌ Expected the script and the rejected script without the paging request, got: %+v", res.Records)
+		}
+
+		rejected, executed := res.Records[0], res.Records[1]
+		if executed.User != "alice" || executed.Rows != 1 || executed.Error != "" || executed.CreatedAt == "" {
+			t.Errorf("❌ Unexpected history entry: %+v", executed)
+		}
+		if rejected.Query != "DELETE FROM todo" || !strings.Contains(rejected.Error, w2explorer.ErrStatementNotAllowed.Error()) {
+			t.Errorf("❌ Expected the rejected script, got: %+v", rejected)
+		}
+	})
+
+	t.Run("SavedQueries", func(t *testing.T) {
+		store := open(t, w2explorer.QueryStoreOptions{})
+		alice := w2explorer.WithUser(ctx, "alice")
+
+		if _, err := store.SaveQuery(alice, w2explorer.SavedQuery{Name: " ", Query: "SELECT 1"}); err == nil {
+			t.Error("❌ Expected an error for an empty name")
+		}
+
+		id, err := store.SaveQuery(alice, w2explorer.SavedQuery{Name: " Open todos ", Query: "SELECT * FROM todo", Tags: []string{"todo", " todo ", "", "report"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := store.SaveQuery(ctx, w2explorer.SavedQuery{Name: "all statuses", Query: "SELECT * FROM status"}); err != nil {
+			t.Fatal(err)
+		}
+
+		saved, err := store.SavedQuery(ctx, id)
+		if err != nil || saved.Name != "Open todos" || strings.Join(saved.Tags, ",") != "todo,report" || saved.User != "alice" || saved.CreatedAt == "" {
+			t.Errorf("❌ Unexpected saved query: %+v %v", saved, err)
+		}
+
+		saved.Description = "changed"
+		if _, err := store.SaveQuery(ctx, saved); err != nil {
+			t.Fatal(err)
+		}
+		if saved, err = store.SavedQuery(ctx, id); err != nil || saved.Description != "changed" || saved.User != "" {
+			t.Errorf("❌ Expected the updated query, got: %+v %v", saved, err)
+		}
+
+		if _, err := store.SaveQuery(ctx, w2explorer.SavedQuery{ID: 99, Name: "x", Query: "SELECT 1"}); !errors.Is(err, w2explorer.ErrSavedQueryNotFound) {
+			t.Errorf("❌ Expected ErrSavedQueryNotFound, got: %v", err)
+		}
+
+		res, err := store.SavedQueries(ctx, w2.GetGridRequest{})
+		if err != nil || len(res.Records) != 2 || res.Records[0].Name != "all statuses" {
+			t.Errorf("❌ Expected the queries sorted by name, got: %+v %v", res.Records, err)
+		}
+
+		res, err = store.SavedQueries(ctx, w2.GetGridRequest{Extra: w2.Extra{"tag": "report"}})
+		if err != nil || len(res.Records) != 1 || res.Records[0].ID != id {
+			t.Errorf("❌ Expected the query tagged report, got: %+v %v", res.Records, err)
+		}
+
+		if removed, err := store.RemoveSavedQueries(ctx, []int{int(id), 99}); err != nil || removed != 1 {
+			t.Errorf("❌ Expected 1 removed query, got: %d %v", removed, err)
+		}
+
+		if _, err := store.SavedQuery(ctx, id); !errors.Is(err, w2explorer.ErrSavedQueryNotFound) {
+			t.Errorf("❌ Expected ErrSavedQueryNotFound, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,125 @@
+package w2explorer
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SQLExecColumn describes one SQL explorer result column.
+type SQLExecColumn struct {
+	// Name is the result column name.
+	Name string `json:"name"`
+
+	// DatabaseType is the declared column type, such as "INTEGER" or
+	// "VARCHAR(10)". It is empty for expressions.
+	DatabaseType string `json:"databaseType"`
+
+	// Affinity is the SQLite type affinity of DatabaseType: INTEGER, REAL,
+	// NUMERIC, TEXT, or BLOB. It is empty for expressions.
+	Affinity string `json:"affinity"`
+
+	// Nullable is false only when the driver reports the column as NOT NULL.
+	Nullable bool `json:"nullable"`
+
+	// ScanType is the Go type the driver scans values into, when known.
+	ScanType string `json:"scanType,omitempty"`
+}
+
+// newSQLExecColumns converts driver column types to SQL explorer columns.
+func newSQLExecColumns(types []*sql.ColumnType) []SQLExecColumn {
+	columns := make([]SQLExecColumn, len(types))
+	for i, ct := range types {
+		nullable, ok := ct.Nullable()
+
+		columns[i] = SQLExecColumn{
+			Name:         ct.Name(),
+			DatabaseType: ct.DatabaseTypeName(),
+			Affinity:     typeAffinity(ct.DatabaseTypeName()),
+			Nullable:     nullable || !ok,
+		}
+
+		if scanType := ct.ScanType(); scanType != nil {
+			columns[i].ScanType = scanType.String()
+		}
+	}
+	return columns
+}
+
+// typeAffinity returns the SQLite affinity of a declared column type
+// following https://www.sqlite.org/datatype3.html#determination_of_column_affinity.
+func typeAffinity(declared string) string {
+	t := strings.ToUpper(declared)
+	switch {
+	case t == "":
+		return ""
+	case strings.Contains(t, "INT"):
+		return "INTEGER"
+	case strings.Contains(t, "CHAR"), strings.Contains(t, "CLOB"), strings.Contains(t, "TEXT"):
+		return "TEXT"
+	case strings.Contains(t, "BLOB"):
+		return "BLOB"
+	case strings.Contains(t, "REAL"), strings.Contains(t, "FLOA"), strings.Contains(t, "DOUB"):
+		return "REAL"
+	default:
+		return "NUMERIC"
+	}
+}
+
+// SQLExecBlobPreviewSize is the number of leading bytes included in the hex
+// preview of a BLOB value.
+const SQLExecBlobPreviewSize = 32
+
+// SQLExecBlob is the JSON representation of a BLOB cell. The full value is
+// available from SQLSelectBlob.
+type SQLExecBlob struct {
+	// Size is the BLOB length in bytes.
+	Size int
+
+	// Preview is the hex encoding of the first SQLExecBlobPreviewSize bytes.
+	Preview string
+}
+
+// MarshalJSON encodes the BLOB as {"type":"blob","size":...,"hex":"..."}, so
+// the widget can tell it apart from text values.
+func (b SQLExecBlob) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type    string `json:"type"`
+		Size    int    `json:"size"`
+		Preview string `json:"hex"`
+	}{"blob", b.Size, b.Preview})
+}
+
+// maxSafeInteger is the largest integer a JavaScript number holds exactly.
+const maxSafeInteger = 1<<53 - 1
+
+// resultValue converts a scanned driver value to its JSON-native form.
+//
+// Integers in BOOLEAN columns become booleans, integers beyond the JavaScript
+// safe range and non-finite floats become strings, and BLOBs become an
+// SQLExecBlob preview.
+func resultValue(value any, column SQLExecColumn) any {
+	switch v := value.(type) {
+	case int64:
+		if strings.Contains(strings.ToUpper(column.DatabaseType), "BOOL") && (v == 0 || v == 1) {
+			return v == 1
+		}
+		if v > maxSafeInteger || v < -maxSafeInteger {
+			return strconv.FormatInt(v, 10)
+		}
+		return v
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return strconv.FormatFloat(v, 'g', -1, 64)
+		}
+		return v
+	case []byte:
+		preview := v[:min(len(v), SQLExecBlobPreviewSize)]
+		return SQLExecBlob{Size: len(v), Preview: hex.EncodeToString(preview)}
+	default:
+		return v
+	}
+}
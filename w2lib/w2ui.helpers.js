@@ -123,7 +123,7 @@ export async function w2fetch(opts = {}) {
       const err = await res.json().catch(() => {
         return { message: res.statusText }
       })
-      throw new Error(err.message)
+      throw Object.assign(new Error(err.message), { status: res.status })
     }
     const result = await res.json()
     if (owner) {
@@ -172,7 +172,8 @@ export async function w2download(opts = {}) {
     const objectUrl = URL.createObjectURL(blob)
     const a = document.createElement('a')
     a.href = objectUrl
-    a.download = name
+    // without a name the file is saved under the Content-Disposition filename
+    a.download = name ?? dispositionFilename(res.headers.get('Content-Disposition')) ?? ''
     a.click()
     URL.revokeObjectURL(objectUrl)
   }
@@ -190,8 +191,20 @@ export async function w2download(opts = {}) {
   }
 }
 
+function dispositionFilename(disposition) {
+  const match = /filename\*?=(?:UTF-8'')?"?([^";]+)"?/i.exec(disposition ?? '')
+  if (!match) {
+    return null
+  }
+  try {
+    return decodeURIComponent(match[1])
+  } catch (_err) {
+    return match[1]
+  }
+}
+
 export function w2upload(opts = {}) {
-  const { accept, multiple } = opts
+  const { accept, multiple, onUploaded } = opts
   const input = document.createElement('input')
   input.type = 'file'
   if (accept) {
@@ -205,7 +218,10 @@ export function w2upload(opts = {}) {
     for (const file of event.target.files) {
       body.append('files[]', file)
     }
-    await w2fetch({ ...opts, body })
+    const result = await w2fetch({ ...opts, body })
+    if (result && onUploaded) {
+      await onUploaded(result)
+    }
   }
   input.click()
 }
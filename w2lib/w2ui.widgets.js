@@ -1,4 +1,4 @@
-import { w2form, w2grid, w2layout, w2popup, w2sidebar, w2utils } from './w2ui.es6.min.js'
+import { w2confirm, w2form, w2grid, w2layout, w2popup, w2sidebar, w2utils } from './w2ui.es6.min.js'
 import * as helpers from './w2ui.helpers.js'
 
 const sqlQueryStorageKey = 'w2ui-sql-explorer-query'
@@ -92,12 +92,29 @@ export function openLocalePopup() {
 }
 
 export function createSqlExplorerLayout(opts = {}) {
-  const { url, darkTheme = 'dracula', initialQuery = '' } = opts
+  const { url, blobUrl = `${url}/blob`, exportUrl = `${url}/export`, explainUrl = `${url}/explain`, saveUrl = `${url}/save`, paramsUrl = `${url}/params`, formatUrl = `${url}/format`, lintUrl = `${url}/lint`, erdUrl = `${url}/erd`, tableUrl = `${url}/table`, backupUrl = `${url}/backup`, restoreUrl, sourcesUrl, runningUrl, historyUrl, savedUrl, darkTheme = 'dracula', initialQuery = '', pageSize = 1000 } = opts
 
   let abortController = null
+  let queryId = null
   let isRunning = false
+  let isPaged = false
+  let pagedQuery = null
+  let pagedParams = []
+  let paramValues = {}
+  let pagedSearch = { searchData: [], searchLogic: 'AND' }
+  let results = []
+  let activeResult = null
+  let schemaNodes = []
+  let historyNodes = []
+  let savedNodes = []
+  let savedQuery = null
+  let sources = []
+  let source = null
   let editor = null
   let stopWatchingTheme = null
+  let lintTimer = null
+  let lintSeq = 0
+  let lintMarks = []
 
   const grid = new w2grid({
     name: 'sqlExplorerGrid-' + Date.now(),
@@ -105,27 +122,234 @@ export function createSqlExplorerLayout(opts = {}) {
     recordHeight: 28,
     show: {
       footer: true,
-      toolbar: false,
+      toolbar: true,
+      toolbarReload: false,
+      toolbarColumns: false,
       lineNumbers: true,
     },
+    toolbar: {
+      items: [
+        { type: 'break' },
+        {
+          type: 'button',
+          id: 'more',
+          text: 'Load more',
+          tooltip: `Loads the next ${pageSize} rows`,
+          icon: 'fa fa-angles-down',
+          disabled: true,
+          onClick: async function() {
+            await loadMore()
+          },
+        },
+        {
+          type: 'menu',
+          id: 'export',
+          text: 'Export',
+          tooltip: 'Runs the statement again and downloads all of its rows',
+          icon: 'fa fa-file-export',
+          disabled: true,
+          items: [
+            { id: 'csv', text: 'CSV', icon: 'fa fa-file-csv' },
+            { id: 'json', text: 'JSON', icon: 'fa fa-file-code' },
+            { id: 'ndjson', text: 'NDJSON', icon: 'fa fa-file-lines' },
+            { id: 'sql', text: 'SQL INSERT', icon: 'fa fa-file-code' },
+          ],
+          onClick: async function(event) {
+            const subItem = event.detail.subItem
+            if (subItem) {
+              await exportResult(subItem.id)
+            }
+          },
+        },
+        {
+          type: 'button',
+          id: 'save-changes',
+          text: 'Save changes',
+          tooltip: 'Shows the UPDATE statements for the edited cells and saves them in one transaction',
+          icon: 'fa fa-floppy-disk',
+          disabled: true,
+          onClick: async function() {
+            await saveChanges()
+          },
+        },
+        {
+          type: 'button',
+          id: 'discard-changes',
+          text: 'Discard',
+          icon: 'fa fa-rotate-left',
+          disabled: true,
+          onClick: function() {
+            discardChanges()
+          },
+        },
+      ],
+    },
     onDelete: function(event) {
       event.preventDefault()
     },
+    onEditField: function(event) {
+      // BLOB cells are downloaded, not edited as text
+      const field = this.columns[event.detail.column]?.field
+      if (this.get(event.detail.recid)?.[field]?.type == 'blob') {
+        event.preventDefault()
+      }
+    },
+    onChange: async function(event) {
+      await event.complete
+      setChangesEnabled(this.getChanges().length > 0)
+    },
+    onDblClick: async function(event) {
+      const field = this.columns[event.detail.column]?.field
+      const record = this.get(event.detail.recid)
+      if (field != null && record?.[field]?.type == 'blob') {
+        event.preventDefault()
+        await downloadBlob(record.recid, field)
+      }
+    },
+    onSort: async function(event) {
+      // a single SELECT is sorted by the server, other results are sorted locally
+      if (!isPaged) {
+        return
+      }
+      event.preventDefault()
+      const current = this.sortData.find(s => s.field == event.detail.field)
+      const direction = event.detail.direction || (current?.direction == 'asc' ? 'desc' : 'asc')
+      this.sortData = [{ field: event.detail.field, direction }]
+      await reloadPage()
+    },
+    onSearch: async function(event) {
+      if (!isPaged) {
+        return
+      }
+      // keep the server search apart from searchData, which would filter the loaded rows again
+      event.preventDefault()
+      pagedSearch = {
+        searchData: event.detail.searchData ?? [],
+        searchLogic: event.detail.searchLogic ?? 'AND',
+      }
+      await reloadPage()
+    },
   })
 
   const sidebar = new w2sidebar({
     name: 'sqlExplorerSidebar-' + Date.now(),
     levelPadding: 8,
     topHTML: '<div style="margin-top:2px;padding:3px 5px;height:36px;"><input id="sql-explorer-search" class="w2ui-input" style="width:100%;" placeholder="Search..."></div>',
+    onClick: function(event) {
+      // history and saved query nodes load their query into the editor
+      const node = event.object
+      if (node?.sql != null) {
+        savedQuery = node.saved ?? null
+        savedQuery?.params?.forEach(param => paramValues[param.name] = param)
+        editor.setValue(node.sql)
+        editor.focus()
+      }
+    },
     onContextMenu: function(event) {
       const isTableNode = event.object?.query != null
+      const isSavedNode = event.object?.saved != null
+      const isDatabaseNode = event.object?.database != null
+      const current = sources.find(s => s.name == source)
+      const attachItems = isDatabaseNode && current?.allowAttach ? [
+        ...sources
+          .filter(s => s.name != source && !current.attached.includes(s.name))
+          .map(s => ({ id: `attach:${s.name}`, text: w2utils.encodeTags(`Attach ${s.name}`), icon: 'fa fa-link' })),
+        ...current.attached.includes(event.object.database)
+          ? [{ id: `detach:${event.object.database}`, text: 'Detach', icon: 'fa fa-link-slash' }]
+          : [],
+      ] : []
+      const scriptItem = event.object?.ddl ? [{
+        id: 'script-create',
+        text: 'Script CREATE',
+        icon: 'fa fa-code',
+      }] : []
       this.menu = isTableNode ? [{
         id: 'select-1000-rows',
         text: 'Select Top 1000 Rows',
         icon: 'fa fa-arrow-pointer',
-      }] : []
+      }, {
+        id: 'browse-table',
+        text: 'Browse Table',
+        icon: 'fa fa-table-cells',
+      }, ...scriptItem] : isSavedNode ? [{
+        id: 'remove-saved-query',
+        text: 'Delete',
+        icon: 'fa fa-trash',
+      }] : isDatabaseNode ? [{
+        id: 'show-row-counts',
+        text: 'Show Row Counts',
+        icon: 'fa fa-list-ol',
+      }, {
+        id: 'erd-mermaid',
+        text: 'ER Diagram (Mermaid)',
+        icon: 'fa fa-diagram-project',
+      }, {
+        id: 'erd-dot',
+        text: 'ER Diagram (DOT)',
+        icon: 'fa fa-diagram-project',
+      }, {
+        id: 'backup',
+        text: 'Backup',
+        icon: 'fa fa-download',
+      }, {
+        id: 'backup-gzip',
+        text: 'Backup (gzip)',
+        icon: 'fa fa-file-zipper',
+      }, ...restoreUrl && event.object.database == 'main' ? [{
+        id: 'restore',
+        text: 'Restore...',
+        icon: 'fa fa-upload',
+      }] : [], ...attachItems] : scriptItem
     },
     onMenuClick: async function(event) {
+      if (event.detail.item?.id == 'script-create') {
+        const node = this.get(event.target)
+        editor.setValue(`${node.ddl};`)
+        editor.focus()
+      }
+      if (event.detail.item?.id == 'erd-mermaid' || event.detail.item?.id == 'erd-dot') {
+        const node = this.get(event.target)
+        const format = event.detail.item.id == 'erd-dot' ? 'dot' : 'mermaid'
+        await helpers.w2download({
+          owner: grid,
+          lock: 'Exporting...',
+          url: sourceUrl(`${erdUrl}?format=${format}&database=${encodeURIComponent(node.database)}`),
+          name: `${node.database}.${format == 'dot' ? 'dot' : 'mmd'}`,
+          method: 'GET',
+        })
+      }
+      if (event.detail.item?.id.startsWith('attach:') || event.detail.item?.id.startsWith('detach:')) {
+        const [action, name] = event.detail.item.id.split(/:(.*)/)
+        await changeAttached(action, name)
+      }
+      if (event.detail.item?.id == 'backup' || event.detail.item?.id == 'backup-gzip') {
+        const node = this.get(event.target)
+        const gzip = event.detail.item.id == 'backup-gzip'
+        await helpers.w2download({
+          owner: grid,
+          lock: 'Backing up...',
+          url: sourceUrl(`${backupUrl}?database=${encodeURIComponent(node.database)}&gzip=${gzip}`),
+          name: `${node.database}.db${gzip ? '.gz' : ''}`,
+          method: 'GET',
+        })
+      }
+      if (event.detail.item?.id == 'restore' && await confirmStatement('Restoring replaces every table, view, index, and trigger of the main database. Continue?')) {
+        helpers.w2upload({
+          owner: grid,
+          lock: 'Restoring...',
+          url: sourceUrl(restoreUrl),
+          method: 'POST',
+          accept: '.db,.sqlite,.sqlite3,.gz',
+          onUploaded: async () => {
+            await loadSchema()
+            grid.message('The database has been restored.')
+          },
+        })
+      }
+      if (event.detail.item?.id == 'show-row-counts') {
+        await loadSchema(true)
+          .catch(err => grid.message(w2utils.encodeTags(err.toString())))
+      }
       if (event.detail.item?.id == 'select-1000-rows') {
         const node = this.get(event.target)
         const query = node.query
@@ -133,6 +357,15 @@ export function createSqlExplorerLayout(opts = {}) {
         editor.focus()
         await executeQuery(query)
       }
+      if (event.detail.item?.id == 'browse-table') {
+        const node = this.get(event.target)
+        await openTableBrowser(node.table.schema, node.table.name)
+          .catch(err => grid.message(w2utils.encodeTags(err.toString())))
+      }
+      if (event.detail.item?.id == 'remove-saved-query') {
+        const node = this.get(event.target)
+        await removeSavedQuery(node.saved)
+      }
     },
     onRender: async function(event) {
       await event.complete
@@ -168,6 +401,11 @@ export function createSqlExplorerLayout(opts = {}) {
     return `SELECT\n${columns}\nFROM ${database}.${table}\nLIMIT 1000;`
   }
 
+  function refreshSidebar() {
+    sidebar.nodes = [...savedNodes, ...historyNodes, ...schemaNodes]
+    sidebar.refresh()
+  }
+
   function setSchemaSidebar(schema) {
     const icons = {
       tables: 'fa fa-table-list',
@@ -175,33 +413,279 @@ export function createSqlExplorerLayout(opts = {}) {
       virtual: 'fa fa-cubes',
       shadow: 'fa fa-layer-group',
     }
-    sidebar.nodes = schema.databases.map((db, dbIndex) => ({
+    const objectNodes = (id, object) => [
+      ...object.columns.map((col, colIndex) => ({
+        id: `${id}-col-${colIndex}`,
+        text: w2utils.encodeTags(`${col.name}${col.type || col.notnull ? ` (${[col.type, col.notnull ? 'NOT NULL' : undefined].filter(Boolean).join(', ')})` : ''}`),
+        icon: col.pk ? 'fa fa-key' : 'fa',
+      })),
+      {
+        id: `${id}-indexes`,
+        text: 'indexes',
+        icon: 'fa fa-folder',
+        nodes: (object.indexes ?? []).map((index, indexIndex) => ({
+          id: `${id}-index-${indexIndex}`,
+          text: w2utils.encodeTags(`${index.name} (${index.columns.join(', ')})`),
+          icon: index.unique ? 'fa fa-fingerprint' : 'fa fa-bolt',
+          tooltip: w2utils.encodeTags([index.unique ? 'UNIQUE' : undefined, index.where ? `WHERE ${index.where}` : undefined].filter(Boolean).join(' ')),
+          ddl: index.sql,
+        })),
+      },
+      {
+        id: `${id}-fks`,
+        text: 'foreign keys',
+        icon: 'fa fa-folder',
+        nodes: (object.foreignKeys ?? []).map((fk, fkIndex) => ({
+          id: `${id}-fk-${fkIndex}`,
+          text: w2utils.encodeTags(`(${fk.from.join(', ')}) → ${fk.table}${fk.to.length > 0 ? ` (${fk.to.join(', ')})` : ''}`),
+          icon: 'fa fa-link',
+          tooltip: w2utils.encodeTags(`ON UPDATE ${fk.onUpdate} ON DELETE ${fk.onDelete}`),
+        })),
+      },
+      {
+        id: `${id}-triggers`,
+        text: 'triggers',
+        icon: 'fa fa-folder',
+        nodes: (object.triggers ?? []).map((trigger, triggerIndex) => ({
+          id: `${id}-trigger-${triggerIndex}`,
+          text: w2utils.encodeTags(trigger.name),
+          icon: 'fa fa-bolt-lightning',
+          ddl: trigger.sql,
+        })),
+      },
+    ].filter(node => node.nodes == null || node.nodes.length > 0)
+    schemaNodes = schema.databases.map((db, dbIndex) => ({
       id: `db-${dbIndex}`,
-      text: db.name,
+      text: w2utils.encodeTags(db.name),
       icon: 'fa fa-database',
       expanded: true,
-      nodes: Object.entries(db)
-        .filter(([key, value]) => key !== 'name' && Array.isArray(value) && value.length > 0)
-        .map(([type, objects]) => ({
+      database: db.name,
+      nodes: Object.keys(icons)
+        .filter(type => db[type]?.length > 0)
+        .map(type => ({
           id: `db-${dbIndex}-${type}`,
           text: type,
-          icon: icons[type] ?? 'fa fa-folder',
+          icon: icons[type],
           expanded: true,
-          nodes: objects.map((object, objectIndex) => ({
+          nodes: db[type].map((object, objectIndex) => ({
             id: `db-${dbIndex}-${type}-${objectIndex}`,
-            text: object.name,
+            text: w2utils.encodeTags(object.name),
             icon: 'fa fa-table',
+            count: object.rowCount,
             expanded: false,
             query: buildSelectRowsQuery(db.name, object.name, object.columns),
-            nodes: object.columns.map((col, colIndex) => ({
-              id: `db-${dbIndex}-${type}-${objectIndex}-col-${colIndex}`,
-              text: `${col.name}${col.type || col.notnull ? ` (${[col.type, col.notnull ? 'NOT NULL' : undefined].filter(Boolean).join(', ')})` : ''}`,
-              icon: col.pk ? 'fa fa-key' : 'fa',
-            })),
+            table: { schema: db.name, name: object.name },
+            ddl: object.sql,
+            nodes: objectNodes(`db-${dbIndex}-${type}-${objectIndex}`, object),
           })),
         })),
     }))
-    sidebar.refresh()
+    refreshSidebar()
+  }
+
+  function sourceUrl(baseUrl) {
+    if (!source) {
+      return baseUrl
+    }
+    return `${baseUrl}${baseUrl.includes('?') ? '&' : '?'}source=${encodeURIComponent(source)}`
+  }
+
+  async function loadSources() {
+    if (!sourcesUrl) {
+      return
+    }
+    sources = await helpers.w2fetch({ url: sourcesUrl, method: 'GET' })
+    source = sources.some(s => s.name == source) ? source : sources[0]?.name ?? null
+    const toolbar = editorLayout.get('main').toolbar
+    toolbar.set('source', {
+      hidden: sources.length == 0,
+      selected: source,
+      items: sources.map(s => ({ id: s.name, text: w2utils.encodeTags(s.readOnly ? `${s.name} (read-only)` : s.name), icon: 'fa fa-database' })),
+    })
+  }
+
+  async function selectSource(name) {
+    source = name
+    // results and pages of the previous source cannot be reloaded from the new one
+    results = []
+    activeResult = null
+    isPaged = false
+    pagedQuery = null
+    layout.get('main').tabs.tabs = []
+    layout.hideTabs('main')
+    grid.clear()
+    grid.columns = []
+    grid.refresh()
+    grid.toolbar.disable('export')
+    setChangesEnabled(false)
+    await loadSchema()
+  }
+
+  async function changeAttached(action, name) {
+    // attach uses the source name as the alias, so detaching sends it as the alias
+    await helpers.w2fetch({
+      owner: grid,
+      url: sourceUrl(`${sourcesUrl}/${action}`),
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(action == 'attach' ? { source: name } : { alias: name }),
+    })
+      .then(() => Promise.all([loadSources(), loadSchema()]))
+      .catch(err => grid.message(w2utils.encodeTags(err.toString())))
+  }
+
+  async function loadSchema(rowCounts = false) {
+    const schema = await helpers.w2fetch({ url: sourceUrl(rowCounts ? `${url}?rowCounts=true` : url), method: 'GET' })
+    setSchemaSidebar(schema)
+    setSchemaAutocomplete(schema)
+    scheduleLint()
+  }
+
+  function queryTitle(query) {
+    const line = query.trim().split('\n')[0]
+    return line.length > 60 ? `${line.slice(0, 60)}...` : line
+  }
+
+  function gridRequestUrl(baseUrl, request) {
+    return `${baseUrl}?request=${encodeURIComponent(JSON.stringify(request))}`
+  }
+
+  async function loadHistory() {
+    if (!historyUrl) {
+      return
+    }
+    const res = await helpers.w2fetch({ url: gridRequestUrl(historyUrl, { limit: 50, offset: 0 }), method: 'GET' })
+      .catch(err => {
+        grid.message(w2utils.encodeTags(err.toString()))
+        return {}
+      })
+    historyNodes = [{
+      id: 'history',
+      text: 'History',
+      icon: 'fa fa-clock-rotate-left',
+      expanded: sidebar.get('history')?.expanded ?? false,
+      nodes: (res.records ?? []).map(entry => ({
+        id: `history-${entry.recid}`,
+        text: w2utils.encodeTags(queryTitle(entry.query)),
+        icon: entry.error ? 'fa fa-triangle-exclamation' : 'fa fa-check',
+        tooltip: w2utils.encodeTags([
+          entry.user, entry.createdAt, `${entry.elapsed.toFixed(3)} seconds`, `${entry.rows} rows`, entry.error,
+        ].filter(Boolean).join(' | ')),
+        sql: entry.query,
+      })),
+    }]
+    refreshSidebar()
+  }
+
+  async function loadSavedQueries() {
+    if (!savedUrl) {
+      return
+    }
+    const res = await helpers.w2fetch({ url: gridRequestUrl(savedUrl, { limit: 500, offset: 0 }), method: 'GET' })
+      .catch(err => {
+        grid.message(w2utils.encodeTags(err.toString()))
+        return {}
+      })
+    savedNodes = [{
+      id: 'saved',
+      text: 'Saved Queries',
+      icon: 'fa fa-bookmark',
+      expanded: sidebar.get('saved')?.expanded ?? true,
+      nodes: (res.records ?? []).map(saved => ({
+        id: `saved-${saved.recid}`,
+        text: w2utils.encodeTags(saved.name + (saved.tags.length > 0 ? ` [${saved.tags.join(', ')}]` : '')),
+        icon: 'fa fa-file-code',
+        tooltip: w2utils.encodeTags([saved.description, saved.user, saved.updatedAt].filter(Boolean).join(' | ')),
+        sql: saved.query,
+        saved: saved,
+      })),
+    }]
+    refreshSidebar()
+  }
+
+  async function removeSavedQuery(saved) {
+    if (!await confirmStatement(`Delete saved query "${saved.name}"?`)) {
+      return
+    }
+    await helpers.w2fetch({
+      owner: sidebar,
+      url: `${savedUrl}/remove`,
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ id: [saved.recid] }),
+    })
+    if (savedQuery?.recid == saved.recid) {
+      savedQuery = null
+    }
+    await loadSavedQueries()
+  }
+
+  function openSaveQueryPopup() {
+    const query = editor.getValue()
+    const save = async (recid, record) => {
+      // the last values of the query parameters are saved with the query
+      const params = (await queryParams(query)).filter(name => paramValues[name]).map(name => paramValues[name])
+      const res = await helpers.w2fetch({
+        owner: form,
+        url: savedUrl,
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({
+          recid: recid,
+          record: {
+            name: record.name,
+            query: query,
+            tags: String(record.tags ?? '').split(',').map(tag => tag.trim()).filter(Boolean),
+            description: record.description ?? '',
+            params: params,
+          },
+        }),
+      })
+      if (res?.status == 'success') {
+        savedQuery = { recid: res.recid, name: record.name, tags: record.tags, description: record.description, params }
+        w2popup.close()
+        await loadSavedQueries()
+      }
+    }
+
+    const actions = {
+      Save() {
+        if (this.validate().length == 0) {
+          save(savedQuery?.recid ?? 0, this.record)
+        }
+      },
+      Cancel() { w2popup.close() },
+    }
+    if (savedQuery) {
+      actions['Save as New'] = function() {
+        if (this.validate().length == 0) {
+          save(0, this.record)
+        }
+      }
+    }
+
+    const form = new w2form({
+      name: 'sqlExplorerSaveForm-' + Date.now(),
+      fields: [
+        { field: 'name', type: 'text', required: true, html: { label: 'Name', attr: 'style="width:100%;"', span: 4 } },
+        { field: 'tags', type: 'text', html: { label: 'Tags', attr: 'style="width:100%;" placeholder="reports, daily"', span: 4 } },
+        { field: 'description', type: 'textarea', html: { label: 'Description', attr: 'style="width:100%; height:80px;"', span: 4 } },
+      ],
+      record: {
+        name: savedQuery?.name ?? '',
+        tags: Array.isArray(savedQuery?.tags) ? savedQuery.tags.join(', ') : savedQuery?.tags ?? '',
+        description: savedQuery?.description ?? '',
+      },
+      actions: actions,
+    })
+
+    w2popup.open({
+      title: 'Save Query',
+      body: '<div id="sql-explorer-save-form" style="width: 100%; height: 100%;"></div>',
+      width: 500, height: 300, showMax: false, resizable: false,
+    })
+      .then(() => form.render('#sql-explorer-save-form'))
+      .close(() => form.destroy())
   }
 
   function setSchemaAutocomplete(schema) {
@@ -221,60 +705,699 @@ export function createSqlExplorerLayout(opts = {}) {
     editor.setOption('hintOptions', { tables })
   }
 
-  async function executeQuery(queryOverride = null) {
+  function confirmStatement(message) {
+    return new Promise(resolve => {
+      w2confirm(w2utils.encodeTags(message), 'Confirm', action => resolve(action == 'yes'))
+    })
+  }
+
+  async function postQuery(body) {
+    grid.lock({ spinner: true, msg: 'Executing...' })
+    try {
+      return await helpers.w2fetch({
+        url: sourceUrl(url),
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(body),
+        signal: abortController.signal,
+      })
+    }
+    catch (err) {
+      // 409 Conflict means the server wants a confirmation for a destructive statement
+      if (err.status == 409 && !body.confirm && await confirmStatement(`${err.message}. Execute anyway?`)) {
+        return await postQuery({ ...body, confirm: true })
+      }
+      grid.message(err.toString())
+    }
+    finally {
+      grid.unlock()
+    }
+  }
+
+  async function runRequest(body, apply) {
     if (isRunning) {
       return
     }
 
     isRunning = true
     abortController = new AbortController()
+    queryId = newQueryId()
 
     const toolbar = editorLayout.get('main').toolbar
     toolbar.disable('run')
     toolbar.enable('cancel')
 
+    try {
+      const response = await postQuery({ ...body, queryId })
+      if (response) {
+        grid.lock({ spinner: true, msg: 'Processing...' })
+        apply(response)
+        grid.unlock()
+      }
+    }
+    finally {
+      isRunning = false
+      abortController = null
+      queryId = null
+      toolbar.enable('run')
+      toolbar.disable('cancel')
+    }
+  }
+
+  function newQueryId() {
+    return crypto.randomUUID?.() ?? `${Date.now().toString(36)}-${Math.random().toString(36).slice(2)}`
+  }
+
+  // the server interrupts a killed query and still returns the results of
+  // earlier statements, so the request is only aborted when the kill fails
+  async function cancelQuery() {
+    if (!abortController) {
+      return
+    }
+    const controller = abortController
+    if (runningUrl && queryId) {
+      try {
+        await helpers.w2fetch({
+          url: `${runningUrl}/kill`,
+          method: 'POST',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify({ action: 'delete', recid: [queryId] }),
+        })
+        return
+      }
+      catch { }
+    }
+    controller.abort('The query has been cancelled')
+  }
+
+  function openRunningQueries() {
+    const runningGrid = new w2grid({
+      name: 'sqlRunningQueries-' + Date.now(),
+      url: {
+        get: runningUrl,
+        remove: `${runningUrl}/kill`,
+      },
+      show: {
+        toolbar: true,
+        footer: true,
+        toolbarReload: true,
+        toolbarDelete: true,
+        toolbarSearch: false,
+        toolbarInput: false,
+      },
+      multiSelect: true,
+      columns: [
+        { field: 'user', text: 'User', size: '120px' },
+        { field: 'query', text: 'Query', size: '100%', render: record => w2utils.encodeTags(record.query) },
+        { field: 'startedAt', text: 'Started', size: '180px' },
+        { field: 'elapsed', text: 'Elapsed', size: '90px', style: 'text-align: right', render: record => `${record.elapsed.toFixed(1)}s` },
+      ],
+    })
+
+    w2popup.open({
+      title: 'Running Queries',
+      body: '<div id="sql-explorer-running" style="width: 100%; height: 100%;"></div>',
+      width: 900, height: 500, showMax: true, resizable: true,
+    })
+      .then(() => runningGrid.render('#sql-explorer-running'))
+      .close(() => runningGrid.destroy())
+  }
+
+  function toRecords(result, offset = 0) {
+    return result.records.map((row, i) => {
+      const { recid, ...rest } = row;
+      // the row key of an editable result identifies the table row when saving
+      const w2ui = result.keys ? { w2ui: { rowKey: result.keys[i] } } : {}
+      return { recid: offset + i + 1, ...rest, ...w2ui };
+    })
+  }
+
+  function setChangesEnabled(enabled) {
+    grid.toolbar[enabled ? 'enable' : 'disable']('save-changes', 'discard-changes')
+  }
+
+  function discardChanges() {
+    grid.records.forEach(record => delete record.w2ui?.changes)
+    grid.refresh()
+    setChangesEnabled(false)
+  }
+
+  async function saveChanges() {
+    const editable = activeResult?.editable
+    const changes = grid.getChanges().map(({ recid, ...values }) => ({ recid, key: grid.get(recid)?.w2ui?.rowKey, values }))
+    if (!editable || changes.length == 0) {
+      return
+    }
+
+    const save = preview => helpers.w2fetch({
+      url: sourceUrl(saveUrl),
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ query: activeResult.statement, changes: changes.map(({ key, values }) => ({ key, values })), preview }),
+    })
+
+    try {
+      grid.lock({ spinner: true, msg: 'Preparing...' })
+      const preview = await save(true)
+      grid.unlock()
+
+      const sql = w2utils.encodeTags(preview.statements.join('\n'))
+      const confirmed = await new Promise(resolve => {
+        w2confirm({
+          body: `<pre style="text-align: left; white-space: pre-wrap; max-height: 300px; overflow: auto;">${sql}</pre>`,
+          width: 700,
+          height: 400,
+        }, 'Save changes', action => resolve(action == 'yes'))
+      })
+      if (!confirmed) {
+        return
+      }
+
+      grid.lock({ spinner: true, msg: 'Saving...' })
+      const res = await save(false)
+
+      // an edited key column changes the key of the saved row
+      changes.forEach(({ recid, key, values }) => {
+        editable.key.forEach((column, i) => {
+          const field = Object.keys(editable.columns).find(name => editable.columns[name] == column)
+          if (field != null && field in values) {
+            key[i] = values[field]
+          }
+        })
+        grid.get(recid).w2ui.rowKey = key
+      })
+      grid.mergeChanges()
+      setChangesEnabled(false)
+      grid.status(`${res.rowsAffected} rows updated`)
+    }
+    catch (err) {
+      grid.message(w2utils.encodeTags(err.toString()))
+    }
+    finally {
+      grid.unlock()
+    }
+  }
+
+  function editableField(result, col, type) {
+    if (result.editable?.columns?.[col] == null) {
+      return null
+    }
+    return { type: String(type?.databaseType).includes('BOOL') ? 'checkbox' : 'text' }
+  }
+
+  function pageRequest(offset = 0) {
+    return {
+      query: pagedQuery,
+      params: pagedParams,
+      limit: pageSize,
+      offset: offset,
+      sort: grid.sortData,
+      search: pagedSearch.searchData,
+      searchLogic: pagedSearch.searchLogic,
+    }
+  }
+
+  function resultStatus(result) {
+    const elapsed = (result.elapsed ?? 0).toFixed(3)
+    const truncated = result.truncated ? ', result truncated' : ''
+    return `${result.kind} executed ${elapsed} seconds${truncated}`
+  }
+
+  function resultMessage(result) {
+    if (result.status == 'error') {
+      return result.message
+    }
+    const parts = [`${result.rowsAffected ?? 0} rows affected`]
+    if (result.lastInsertId) {
+      parts.push(`last insert id ${result.lastInsertId}`)
+    }
+    return parts.join(', ')
+  }
+
+  function isNumericColumn(column) {
+    return ['INTEGER', 'REAL'].includes(column?.affinity) || ['int64', 'float64'].includes(column?.scanType)
+  }
+
+  function renderCell(record, extra) {
+    const value = record[extra.field]
+    if (value == null) {
+      return `<span style="font-style: italic; color: darkgrey;">NULL</span>`
+    }
+    if (value.type == 'blob') {
+      const preview = value.hex.length < value.size * 2 ? `${value.hex}...` : value.hex
+      return `<span style="color: darkgrey;" title="Double-click to download">BLOB ${value.size} bytes</span> 0x${preview}`
+    }
+    return w2utils.encodeTags(String(value))
+  }
+
+  async function downloadBlob(recid, field) {
+    const body = { query: activeResult.statement, params: pagedParams, row: recid - 1, column: field }
+    if (isPaged) {
+      Object.assign(body, { sort: grid.sortData, search: pagedSearch.searchData, searchLogic: pagedSearch.searchLogic })
+    }
+    await helpers.w2download({
+      owner: grid,
+      lock: 'Downloading...',
+      url: sourceUrl(blobUrl),
+      name: `${field}-${recid}.bin`,
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(body),
+    })
+  }
+
+  async function exportResult(format) {
+    const body = { query: activeResult.statement, params: pagedParams, format }
+    if (isPaged) {
+      Object.assign(body, { sort: grid.sortData, search: pagedSearch.searchData, searchLogic: pagedSearch.searchLogic })
+    }
+    await helpers.w2download({
+      owner: grid,
+      lock: 'Exporting...',
+      url: sourceUrl(exportUrl),
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(body),
+    })
+  }
+
+  function planRecords(nodes) {
+    const styles = {
+      'full-scan': 'color: #c0392b; font-weight: bold;',
+      'temp-btree': 'color: #d35400; font-weight: bold;',
+    }
+    return nodes.map(node => ({
+      recid: node.id,
+      detail: node.detail,
+      highlight: node.highlight ?? '',
+      w2ui: {
+        style: styles[node.highlight] ?? '',
+        children: planRecords(node.nodes ?? []),
+        expanded: true,
+      },
+    }))
+  }
+
+  function suggestionsHtml(suggestions) {
+    if (suggestions.length == 0) {
+      return '<div style="padding: 10px;">No index suggestions.</div>'
+    }
+    return '<div style="padding: 10px; overflow: auto; height: 100%; box-sizing: border-box;">' + suggestions.map(s => `
+      <div style="margin-bottom: 10px;">
+        <div>${w2utils.encodeTags(s.reason)}</div>
+        <pre style="margin: 4px 0; user-select: all;">${w2utils.encodeTags(s.sql)}</pre>
+      </div>`).join('') + '</div>'
+  }
+
+  async function explainQuery(opcodes = false) {
+    const query = editor.getSelection() || editor.getValue()
+    const res = await helpers.w2fetch({
+      owner: grid,
+      lock: 'Explaining...',
+      url: sourceUrl(explainUrl),
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ query, opcodes }),
+    })
+    if (res?.status != 'success') {
+      return
+    }
+
+    const planGrid = new w2grid({
+      name: 'sqlExplainGrid-' + Date.now(),
+      show: { columnHeaders: true },
+      columns: [
+        { field: 'detail', text: 'Query Plan', size: '100%' },
+        { field: 'highlight', text: 'Warning', size: '100px' },
+      ],
+      records: planRecords(res.plan),
+    })
+    const opcodeGrid = new w2grid({
+      name: 'sqlExplainOpcodes-' + Date.now(),
+      columns: ['addr', 'opcode', 'p1', 'p2', 'p3', 'p4', 'p5', 'comment']
+        .map(field => ({ field, text: field, size: ['opcode', 'p4', 'comment'].includes(field) ? '120px' : '50px' })),
+      records: (res.opcodes ?? []).map(op => ({ recid: op.addr + 1, ...op })),
+    })
+    const explainLayout = new w2layout({
+      name: 'sqlExplainLayout-' + Date.now(),
+      panels: [
+        { type: 'main', html: planGrid },
+        { type: 'right', size: '45%', resizable: true, html: opcodeGrid, hidden: !opcodes },
+        { type: 'bottom', size: 160, resizable: true, html: suggestionsHtml(res.suggestions) },
+      ],
+    })
+
+    w2popup.open({
+      title: 'Explain Query Plan',
+      body: '<div id="sql-explorer-explain" style="width: 100%; height: 100%;"></div>',
+      width: 1000, height: 500, showMax: true, resizable: true,
+    })
+      .then(() => explainLayout.render('#sql-explorer-explain'))
+      .close(() => {
+        explainLayout.destroy()
+        planGrid.destroy()
+        opcodeGrid.destroy()
+      })
+  }
+
+  async function openTableBrowser(schema, name) {
+    const params = new URLSearchParams({ schema, table: name, ...(source ? { source } : {}) })
+    const table = await helpers.w2fetch({ url: `${tableUrl}?${params}`, method: 'GET' })
+
+    const browseGrid = new w2grid({
+      name: 'sqlTableBrowser-' + Date.now(),
+      url: {
+        get: `${tableUrl}/records?${params}`,
+        remove: `${tableUrl}/remove?${params}`,
+      },
+      limit: pageSize,
+      multiSearch: true,
+      show: {
+        toolbar: true,
+        footer: true,
+        lineNumbers: true,
+        toolbarAdd: table.canInsert,
+        toolbarDelete: table.canDelete,
+      },
+      columns: table.columns.map(col => ({
+        field: col.name,
+        text: w2utils.encodeTags(col.name),
+        size: '150px',
+        sortable: true,
+        render: renderCell,
+      })),
+      searches: table.columns.map(col => ({
+        field: col.name,
+        label: w2utils.encodeTags(col.name),
+        type: col.searchType,
+        operators: col.operators,
+      })),
+      onAdd: function() {
+        insertForm.clear()
+        browseLayout.show('right')
+      },
+    })
+
+    // date and time columns are entered as SQLite text, not in the locale format
+    const fieldTypes = { int: 'int', float: 'float', toggle: 'checkbox' }
+    const insertForm = new w2form({
+      name: 'sqlTableInsertForm-' + Date.now(),
+      fields: table.columns.map(col => ({
+        field: col.name,
+        type: fieldTypes[col.searchType] ?? 'text',
+        html: {
+          label: w2utils.encodeTags(col.name),
+          attr: `style="width:100%;" placeholder="${w2utils.encodeTags(col.default ?? col.type)}"`,
+          span: 4,
+        },
+      })),
+      actions: {
+        async Insert() {
+          // empty fields are left out so the column gets its default
+          const record = Object.fromEntries(Object.entries(this.record).filter(([_, value]) => value !== '' && value != null))
+          const res = await helpers.w2fetch({
+            owner: insertForm,
+            url: `${tableUrl}/insert?${params}`,
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ action: 'save', recid: 0, record }),
+          })
+          if (res?.status == 'success') {
+            browseLayout.hide('right')
+            browseGrid.reload()
+          }
+        },
+        Cancel() { browseLayout.hide('right') },
+      },
+    })
+
+    const browseLayout = new w2layout({
+      name: 'sqlTableBrowserLayout-' + Date.now(),
+      panels: [
+        { type: 'main', html: browseGrid },
+        { type: 'right', size: 380, resizable: true, hidden: true, html: insertForm },
+      ],
+    })
+
+    w2popup.open({
+      title: w2utils.encodeTags(`${table.schema}.${table.name}`),
+      body: '<div id="sql-explorer-browse" style="width: 100%; height: 100%;"></div>',
+      width: 1000, height: 600, showMax: true, resizable: true,
+    })
+      .then(() => browseLayout.render('#sql-explorer-browse'))
+      .close(() => {
+        browseLayout.destroy()
+        browseGrid.destroy()
+        insertForm.destroy()
+      })
+  }
+
+  function resultTabs(response) {
+    const icons = { rows: 'fa fa-table', exec: 'fa fa-check', error: 'fa fa-triangle-exclamation' }
+    return response.results.map((result, i) => {
+      const type = result.status == 'error' ? 'error' : result.columns.length > 0 ? 'rows' : 'exec'
+      return { id: `result-${i}`, text: `${i + 1}: ${result.kind}`, icon: icons[type], tooltip: w2utils.encodeTags(result.statement) }
+    })
+  }
+
+  function showResult(index) {
+    const result = results[index]
+    if (!result) {
+      return
+    }
+
+    activeResult = result
+    isPaged = Boolean(result.paged)
+    const types = Object.fromEntries((result.columnTypes ?? []).map(col => [col.name, col]))
+    const columns = result.columns.filter(col => col.toLowerCase() !== 'recid')
+    grid.columns = columns
+      .map(col => ({
+        field: col,
+        text: w2utils.encodeTags(col),
+        tooltip: w2utils.encodeTags(types[col]?.databaseType ?? ''),
+        style: isNumericColumn(types[col]) ? 'text-align: right' : '',
+        render: renderCell,
+        min: 80,
+        sortable: true,
+        editable: editableField(result, col, types[col]),
+      }))
+    grid.searches = columns
+      .map(col => ({ field: col, label: w2utils.encodeTags(col), type: 'text' }))
+    grid.records = toRecords(result)
+    grid.sortData = []
+    grid.searchData = []
+    pagedSearch = { searchData: [], searchLogic: 'AND' }
+    grid.reset()
+    grid.total = result.total
+    grid.selectNone()
+    grid.columnAutoSize()
+    grid.status(resultStatus(result))
+    grid.toolbar[isPaged && grid.records.length < grid.total ? 'enable' : 'disable']('more')
+    grid.toolbar[result.status != 'error' && columns.length > 0 ? 'enable' : 'disable']('export')
+    setChangesEnabled(false)
+
+    if (result.status == 'error' || columns.length == 0) {
+      grid.message(w2utils.encodeTags(resultMessage(result)))
+    }
+  }
+
+  function showResponse(response) {
+    results = response.results ?? []
+
+    const tabs = layout.get('main').tabs
+    tabs.tabs = resultTabs(response)
+    tabs.active = null
+    layout[results.length > 1 ? 'showTabs' : 'hideTabs']('main')
+
+    // open the failed statement, or the last result set of the script
+    let index = results.findIndex(result => result.status == 'error')
+    if (index < 0) {
+      index = results.findLastIndex(result => result.columns.length > 0)
+    }
+    if (index < 0) {
+      index = results.length - 1
+    }
+
+    if (index >= 0) {
+      tabs.click(`result-${index}`)
+    }
+
+    if (response.rolledBack) {
+      grid.message(w2utils.encodeTags(`${response.message}. The transaction has been rolled back.`))
+    }
+  }
+
+  async function executeQuery(queryOverride = null) {
     const query = queryOverride ?? (editor.getSelection() || editor.getValue())
     try {
       localStorage.setItem(sqlQueryStorageKey, query)
     } catch (_err) { }
 
+    const params = await promptParams(query)
+    if (params == null) {
+      return
+    }
+
+    const transaction = Boolean(editorLayout.get('main').toolbar.get('transaction')?.checked)
+    await runRequest({ query, params, transaction, limit: pageSize, offset: 0 }, response => {
+      pagedQuery = query
+      pagedParams = params
+      showResponse(response)
+    })
+    await loadHistory()
+  }
+
+  async function formatQuery() {
+    const selected = editor.somethingSelected()
+    const res = await helpers.w2fetch({
+      owner: grid,
+      url: formatUrl,
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ query: selected ? editor.getSelection() : editor.getValue() }),
+    })
+    if (res?.status != 'success') {
+      return
+    }
+    if (!selected) {
+      editor.setSelection(CodeMirror.Pos(editor.firstLine(), 0), CodeMirror.Pos(editor.lastLine()))
+    }
+    // replacing the selection keeps the change undoable
+    editor.replaceSelection(res.query, selected ? 'around' : 'start')
+  }
+
+  function scheduleLint() {
+    clearTimeout(lintTimer)
+    lintTimer = setTimeout(lintQuery, 500)
+  }
+
+  // lintQuery underlines the problems reported by the server; the markers use
+  // the annotation format of the CodeMirror lint addon
+  async function lintQuery() {
+    if (!editor || !lintUrl) {
+      return
+    }
+    const seq = ++lintSeq
+    let markers = []
+    try {
+      const res = await helpers.w2fetch({
+        url: sourceUrl(lintUrl),
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ query: editor.getValue() }),
+      })
+      markers = res.markers ?? []
+    }
+    catch (_err) {
+      // linting is optional, servers without it keep the editor unmarked
+    }
+    if (seq != lintSeq) {
+      return
+    }
+    lintMarks.forEach(mark => mark.clear())
+    lintMarks = markers.map(m => editor.markText(m.from, m.to, {
+      className: `sql-explorer-lint-${m.severity}`,
+      attributes: { title: m.message },
+    }))
+  }
+
+  async function queryParams(query) {
     try {
-      const startTime = performance.now()
-      const result = await helpers.w2fetch({
-        owner: grid,
-        lock: 'Executing...',
-        url: url,
+      const res = await helpers.w2fetch({
+        url: paramsUrl,
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify({ query }),
-        signal: abortController.signal,
       })
-      if (result) {
-        grid.lock({ spinner: true, msg: 'Processing...' })
-        grid.columns = result.columns
-          .filter(col => col.toLowerCase() !== 'recid')
-          .map(col => ({ field: col, text: w2utils.encodeTags(col), render: 'nullable', min: 80, sortable: true, editable: {} }))
-        grid.records = result.records.map((row, i) => {
-          const { recid, ...rest } = row;
-          return { recid: i + 1, ...rest };
-        })
-        grid.total = result.total
-        grid.sortData = []
-        // grid.refresh()
-        grid.reset()
-        grid.selectNone()
-        grid.columnAutoSize()
-        grid.unlock()
-      }
-      const elapsed = ((performance.now() - startTime) / 1000).toFixed(3)
-      grid.status(`Query Executed ${elapsed} seconds`)
+      return res.params ?? []
     }
-    finally {
-      isRunning = false
-      abortController = null
-      toolbar.enable('run')
-      toolbar.disable('cancel')
+    catch (_err) {
+      // servers without parameter detection run the query as it is
+      return []
+    }
+  }
+
+  // promptParams asks for the values of the query parameters reported by the
+  // server, prefilled with the last values, and resolves to null when cancelled
+  async function promptParams(query) {
+    const names = await queryParams(query)
+    if (names.length == 0) {
+      return []
     }
+
+    const types = ['text', 'integer', 'real', 'boolean', 'date', 'datetime', 'null']
+    const record = {}
+    names.forEach((name, i) => {
+      const last = paramValues[name]
+      record[`type${i}`] = last?.type || 'text'
+      record[`value${i}`] = last?.value == null ? '' : String(last.value)
+    })
+
+    return new Promise(resolve => {
+      let params = null
+      const form = new w2form({
+        name: 'sqlExplorerParamsForm-' + Date.now(),
+        fields: names.flatMap((name, i) => [
+          { field: `type${i}`, type: 'list', options: { items: types }, html: { label: w2utils.encodeTags(name), attr: 'style="width:110px;"', span: 4 } },
+          { field: `value${i}`, type: 'text', html: { label: '', attr: 'style="width:100%;"', span: 0, column: 'after' } },
+        ]),
+        record: record,
+        actions: {
+          Run() {
+            params = names.map((name, i) => {
+              const type = this.record[`type${i}`]?.id ?? this.record[`type${i}`]
+              return { name, type, value: type == 'null' ? null : this.record[`value${i}`] ?? '' }
+            })
+            params.forEach(param => paramValues[param.name] = param)
+            w2popup.close()
+          },
+          Cancel() { w2popup.close() },
+        },
+      })
+
+      w2popup.open({
+        title: 'Query Parameters',
+        body: '<div id="sql-explorer-params-form" style="width: 100%; height: 100%;"></div>',
+        width: 500, height: Math.min(140 + names.length * 40, 500), showMax: false, resizable: true,
+      })
+        .then(() => form.render('#sql-explorer-params-form'))
+        .close(() => {
+          form.destroy()
+          resolve(params)
+        })
+    })
+  }
+
+  async function reloadPage() {
+    await runRequest(pageRequest(), response => {
+      const result = response.results[0]
+      if (result.status == 'error') {
+        grid.message(w2utils.encodeTags(result.message))
+        return
+      }
+      grid.records = toRecords(result)
+      grid.reset()
+      grid.total = result.total
+      grid.selectNone()
+      grid.status(resultStatus(result))
+      grid.toolbar[grid.records.length < grid.total ? 'enable' : 'disable']('more')
+    })
+  }
+
+  async function loadMore() {
+    const offset = grid.records.length
+    await runRequest(pageRequest(offset), response => {
+      const result = response.results[0]
+      if (result.status == 'error') {
+        grid.message(w2utils.encodeTags(result.message))
+        return
+      }
+      grid.records.push(...toRecords(result, offset))
+      grid.total = result.total
+      grid.refresh()
+      grid.status(resultStatus(result))
+      grid.toolbar[grid.records.length < grid.total ? 'enable' : 'disable']('more')
+    })
   }
 
   const editorLayout = new w2layout({
@@ -289,6 +1412,24 @@ export function createSqlExplorerLayout(opts = {}) {
         type: 'main',
         toolbar: {
           items: [
+            {
+              type: 'menu-radio',
+              id: 'source',
+              icon: 'fa fa-database',
+              tooltip: 'Data source of the editor, sidebar, and results',
+              hidden: true,
+              items: [],
+              text: function(item) {
+                return `Source: ${this.get(`source:${item.selected}`)?.text ?? ''}`
+              },
+              onClick: async function(event) {
+                const subItem = event.detail.subItem
+                if (subItem && subItem.id != source) {
+                  await selectSource(subItem.id)
+                }
+              },
+            },
+            { type: 'break' },
             {
               type: 'button',
               id: 'run',
@@ -306,11 +1447,67 @@ export function createSqlExplorerLayout(opts = {}) {
               tooltip: 'Shift-Esc cancels a running query',
               icon: 'fa fa-stop',
               disabled: true,
-              onClick: function() {
-                abortController?.abort('The query has been cancelled')
+              onClick: async function() {
+                await cancelQuery()
+              },
+            },
+            {
+              type: 'menu',
+              id: 'explain',
+              text: 'Explain',
+              tooltip: 'Shows the query plan of the selection or full query with index suggestions',
+              icon: 'fa fa-diagram-project',
+              items: [
+                { id: 'plan', text: 'Query Plan', icon: 'fa fa-sitemap' },
+                { id: 'opcodes', text: 'Query Plan and Opcodes', icon: 'fa fa-microchip' },
+              ],
+              onClick: async function(event) {
+                const subItem = event.detail.subItem
+                if (subItem) {
+                  await explainQuery(subItem.id == 'opcodes')
+                }
+              },
+            },
+            {
+              type: 'button',
+              id: 'format',
+              text: 'Format',
+              tooltip: 'Shift-Alt-F formats the selection or full query',
+              icon: 'fa fa-align-left',
+              onClick: async function() {
+                await formatQuery()
               },
             },
+            {
+              type: 'check',
+              id: 'transaction',
+              text: 'Transaction',
+              tooltip: 'Runs the script in a transaction that is rolled back when a statement fails',
+              icon: 'fa fa-right-left',
+            },
             { type: 'spacer' },
+            {
+              type: 'button',
+              id: 'running',
+              text: 'Running',
+              tooltip: 'Lists the running queries of all users and kills them',
+              icon: 'fa fa-list-check',
+              hidden: !runningUrl,
+              onClick: function() {
+                openRunningQueries()
+              },
+            },
+            {
+              type: 'button',
+              id: 'save',
+              text: 'Save',
+              tooltip: 'Saves the query to the shared query library',
+              icon: 'fa fa-floppy-disk',
+              hidden: !savedUrl,
+              onClick: function() {
+                openSaveQueryPopup()
+              },
+            },
             {
               type: 'button',
               id: 'reopen',
@@ -333,7 +1530,11 @@ export function createSqlExplorerLayout(opts = {}) {
             },
           ],
         },
-        html: `<style>.CodeMirror-hints{ z-index: 9999 !important; }</style><div id="sql-explorer-editor" style="height:100%;"></div>`,
+        html: `<style>
+          .CodeMirror-hints{ z-index: 9999 !important; }
+          .sql-explorer-lint-error{ text-decoration: underline wavy #e53935; }
+          .sql-explorer-lint-warning{ text-decoration: underline wavy #fb8c00; }
+        </style><div id="sql-explorer-editor" style="height:100%;"></div>`,
       },
     ],
     onRender: async function(event) {
@@ -357,12 +1558,13 @@ export function createSqlExplorerLayout(opts = {}) {
           'Shift-Alt-Enter': async () => {
             await executeQuery()
             document.getElementById('sql-explorer-search').value = ''
-            const schema = await helpers.w2fetch({ url: url, method: 'GET' })
-            setSchemaSidebar(schema)
-            setSchemaAutocomplete(schema)
+            await loadSchema()
+          },
+          'Shift-Esc': async () => {
+            await cancelQuery()
           },
-          'Shift-Esc': () => {
-            abortController?.abort('The query has been cancelled')
+          'Shift-Alt-F': async () => {
+            await formatQuery()
           },
           'Ctrl-`': () => {
             const toolbar = editorLayout.get('main').toolbar
@@ -376,13 +1578,15 @@ export function createSqlExplorerLayout(opts = {}) {
         editor.setOption('theme', isDark ? darkTheme : 'default')
       })
       editor.setSize('100%', '100%')
-      const schema = await helpers.w2fetch({ url: url, method: 'GET' })
-      setSchemaSidebar(schema)
-      setSchemaAutocomplete(schema)
+      editor.on('changes', scheduleLint)
+      await loadSources()
+        .catch(err => grid.message(w2utils.encodeTags(err.toString())))
+      await loadSchema()
+      await Promise.all([loadHistory(), loadSavedQueries()])
     }
   })
 
-  return new w2layout({
+  const layout = new w2layout({
     name: 'sqlExplorerLayout-' + Date.now(),
     panels: [
       {
@@ -394,15 +1598,24 @@ export function createSqlExplorerLayout(opts = {}) {
       {
         type: 'main',
         html: grid,
+        tabs: {
+          tabs: [],
+          onClick: function(event) {
+            showResult(Number(event.target.replace('result-', '')))
+          },
+        },
       },
     ],
     onDestroy: function() {
       stopWatchingTheme?.()
+      clearTimeout(lintTimer)
       abortController?.abort()
       editorLayout.destroy()
       sidebar.destroy()
       grid.destroy()
     }
   })
+
+  return layout
 }
 
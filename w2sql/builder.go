@@ -2,6 +2,9 @@ package w2sql
 
 import (
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 
 	"github.com/dv1x3r/w2go/w2"
 	"github.com/huandu/go-sqlbuilder"
@@ -139,3 +142,46 @@ func OrderBy(sb *sqlbuilder.SelectBuilder, r w2.GetGridRequest, mapping map[stri
 		}
 	}
 }
+
+// Params applies whitelisted extra request parameters to sb as predicates.
+//
+// Keys in mapping are names from w2.Extra; values are trusted SQL expressions
+// or predicates. A value containing "?" is a predicate whose placeholders are
+// all bound to the parameter, for example "t.created_at >= ?". Any other value
+// is compared by equality, or with IN when the parameter is an array.
+// Parameters that were not sent are ignored.
+func Params(sb *sqlbuilder.SelectBuilder, extra w2.Extra, mapping map[string]string) {
+	for _, key := range slices.Sorted(maps.Keys(mapping)) {
+		expr := mapping[key]
+		value, ok := extra[key]
+		if !ok {
+			continue
+		}
+
+		if strings.Contains(expr, "?") {
+			parts := strings.Split(expr, "?")
+			var predicate strings.Builder
+			for i, part := range parts {
+				if i > 0 {
+					predicate.WriteString(sb.Var(value))
+				}
+				predicate.WriteString(part)
+			}
+			sb.Where(predicate.String())
+			continue
+		}
+
+		switch v := value.(type) {
+		case nil:
+			sb.Where(sb.IsNull(expr))
+		case []any:
+			if ids, ok := extra.Ints(key); ok && len(ids) > 0 {
+				sb.Where(sb.In(expr, sqlbuilder.List(ids)))
+			} else {
+				sb.Where(sb.In(expr, v...))
+			}
+		default:
+			sb.Where(sb.EQ(expr, v))
+		}
+	}
+}